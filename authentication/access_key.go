@@ -0,0 +1,96 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// AccessKey is S3-style credential of service user.
+// Secret is stored as is since request signatures
+// cannot be verified without it.
+type AccessKey struct {
+	ID      string
+	Secret  string
+	Created time.Time
+}
+
+func (k accessKey) Export() AccessKey {
+	return AccessKey{
+		ID:      k.ID,
+		Secret:  k.Secret,
+		Created: time.Unix(k.Created, 0),
+	}
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// CreateAccessKey issues new key, only service users may have them.
+func (a *Authenticator) CreateAccessKey(username string) (AccessKey, error) {
+	u, err := selectUser(a.db, username)
+	if err != nil {
+		return AccessKey{}, err
+	}
+	if u.Export().Role != Service {
+		return AccessKey{}, errors.New("access keys are issued to service users only")
+	}
+
+	id, err := randomBytes(10)
+	if err != nil {
+		return AccessKey{}, err
+	}
+	secret, err := randomBytes(30)
+	if err != nil {
+		return AccessKey{}, err
+	}
+	k := accessKey{
+		ID:       "CA" + base32.StdEncoding.EncodeToString(id),
+		Username: username,
+		Secret:   base64.StdEncoding.EncodeToString(secret),
+		Created:  time.Now().Unix(),
+	}
+	err = insertAccessKey(a.db, k)
+	if err != nil {
+		return AccessKey{}, err
+	}
+	return k.Export(), nil
+}
+
+func (a *Authenticator) AccessKeys(username string) ([]AccessKey, error) {
+	keys, err := selectAccessKeys(a.db, username)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]AccessKey, 0, len(keys))
+	for _, k := range keys {
+		r = append(r, k.Export())
+	}
+	return r, nil
+}
+
+func (a *Authenticator) RemoveAccessKey(username string, id string) error {
+	return deleteAccessKey(a.db, username, id)
+}
+
+// LookupAccessKey returns enabled service user owning key and its secret.
+func (a *Authenticator) LookupAccessKey(id string) (User, string, error) {
+	k, err := selectAccessKey(a.db, id)
+	if err != nil {
+		return User{}, "", errors.New("wrong credentials")
+	}
+	u, err := selectUser(a.db, k.Username)
+	if err != nil || !u.Enabled {
+		return User{}, "", errors.New("wrong credentials")
+	}
+	exported := u.Export()
+	if exported.Role != Service {
+		return User{}, "", errors.New("wrong credentials")
+	}
+	return exported, k.Secret, nil
+}
@@ -0,0 +1,91 @@
+package authentication
+
+import (
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	tableAccessKeys = "access_keys"
+	fieldAkID       = "access_key"
+	fieldAkUser     = "username"
+	fieldAkSecret   = "secret"
+	fieldAkCreated  = "created"
+	indexAkUser     = "access_keys_user_idx"
+)
+
+type accessKey struct {
+	ID       string `db:"access_key"`
+	Username string `db:"username"`
+	Secret   string `db:"secret"`
+	Created  int64  `db:"created"`
+}
+
+func initAccessKeysTable(db *dbx.DB) error {
+	keys := make(map[string]string)
+	keys[fieldAkID] = "TEXT PRIMARY KEY NOT NULL"
+	keys[fieldAkUser] = "TEXT NOT NULL REFERENCES " + tableUsers +
+		"(" + fieldUserUsername + ") ON DELETE CASCADE"
+	keys[fieldAkSecret] = "TEXT NOT NULL"
+	keys[fieldAkCreated] = "INTEGER DEFAULT 0 NOT NULL"
+
+	query := db.CreateTable(tableAccessKeys, keys)
+	_, err := query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateIndex(tableAccessKeys, indexAkUser, fieldAkUser)
+	_, err = query.Execute()
+	return err
+}
+
+func selectAccessKey(db *dbx.DB, id string) (accessKey, error) {
+	var k accessKey
+	e := db.Select(
+		fieldAkID,
+		fieldAkUser,
+		fieldAkSecret,
+		fieldAkCreated).
+		From(tableAccessKeys).
+		Where(dbx.HashExp{
+			fieldAkID: id,
+		}).
+		One(&k)
+	return k, e
+}
+
+func selectAccessKeys(db *dbx.DB, username string) ([]accessKey, error) {
+	var keys []accessKey
+	e := db.Select(
+		fieldAkID,
+		fieldAkUser,
+		fieldAkSecret,
+		fieldAkCreated).
+		From(tableAccessKeys).
+		Where(dbx.HashExp{
+			fieldAkUser: username,
+		}).
+		OrderBy(fieldAkCreated).
+		All(&keys)
+	return keys, e
+}
+
+func insertAccessKey(db *dbx.DB, k accessKey) error {
+	_, e := db.Insert(tableAccessKeys,
+		dbx.Params{
+			fieldAkID:      k.ID,
+			fieldAkUser:    k.Username,
+			fieldAkSecret:  k.Secret,
+			fieldAkCreated: k.Created,
+		}).Execute()
+	return e
+}
+
+func deleteAccessKey(db *dbx.DB, username string, id string) error {
+	_, e := db.Delete(tableAccessKeys,
+		dbx.HashExp{
+			fieldAkUser: username,
+			fieldAkID:   id,
+		}).Execute()
+	return e
+}
@@ -1,7 +1,11 @@
 package authentication
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
@@ -9,10 +13,13 @@ import (
 	"github.com/shabunin/cardia/database"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/sha3"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/metadata"
 	_ "modernc.org/sqlite"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -20,10 +27,17 @@ type identityClaims struct {
 	jwt.RegisteredClaims
 	User string `json:"user"`
 	Role string `json:"role"`
+	Home string `json:"home"`
+	// only if group claims are enabled
+	Groups []string `json:"groups,omitempty"`
 }
 
 const phashCost = 14
 
+// IdentityAudience is audience of identity tokens, other tokens
+// signed with the same key, e.g. share links, are not accepted as identity.
+const IdentityAudience = "cardia-identity"
+
 func generateFromPassword(password []byte, cost int) (result []byte, err error) {
 	sum := sha3.Sum512(password)
 	return bcrypt.GenerateFromPassword(sum[:], cost)
@@ -34,11 +48,12 @@ func compareHashAndPassword(hashedPassword, password []byte) error {
 }
 
 type Authenticator struct {
-	db        *dbx.DB
-	jwtSigner *rsa.PrivateKey
+	db          *dbx.DB
+	jwtSigner   *rsa.PrivateKey
+	groupClaims bool
 }
 
-func NewAuthenticator(dbpath string) (*Authenticator, error) {
+func NewAuthenticator(dbpath string, signer *rsa.PrivateKey) (*Authenticator, error) {
 	if !filepath.IsAbs(dbpath) {
 		base, _ := os.Getwd()
 		dbpath = path.Join(base, dbpath)
@@ -49,12 +64,30 @@ func NewAuthenticator(dbpath string) (*Authenticator, error) {
 	}
 
 	_ = initUsersTable(db)
+	_ = initPublicKeysTable(db)
+	_ = initAccessKeysTable(db)
+	_ = initGrantsTable(db)
+	_ = initGroupsTables(db)
+
+	return &Authenticator{db: db, jwtSigner: signer}, nil
+}
 
-	return &Authenticator{db: db}, nil
+// SetGroupClaims makes tokens carry group membership,
+// so it could be authorized without database lookup.
+// Membership changes are seen by services when token is renewed.
+func (a *Authenticator) SetGroupClaims(enabled bool) {
+	a.groupClaims = enabled
 }
 
 func (a *Authenticator) newTokenForUser(u User) (string, error) {
-	id := identityClaims{User: u.Name}
+	id := identityClaims{User: u.Name, Home: u.Home}
+	if a.groupClaims {
+		groups, err := a.MemberGroups(u.Name)
+		if err != nil {
+			return "", err
+		}
+		id.Groups = groups
+	}
 	switch u.Role {
 	case Regular:
 		id.Role = roleRegular
@@ -65,6 +98,7 @@ func (a *Authenticator) newTokenForUser(u User) (string, error) {
 	}
 	// TODO : customize claims
 	id.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	id.Audience = jwt.ClaimStrings{IdentityAudience}
 	token := jwt.NewWithClaims(jwt.SigningMethodRS512, id)
 	ss, err := token.SignedString(a.jwtSigner)
 	return ss, err
@@ -76,26 +110,89 @@ func (a *Authenticator) AuthenticateWithPassword(username string, password strin
 		return "", err
 	}
 
-	err = compareHashAndPassword([]byte(u.password), []byte(password))
-	if err != nil {
+	err = compareHashAndPassword([]byte(u.Password), []byte(password))
+	if err != nil || !u.Enabled {
 		return "", errors.New("wrong credentials")
 	}
 
 	return a.newTokenForUser(u.Export())
 }
 
+// PasswordStamp returns value changed whenever password of user is,
+// so verified credentials could be cached until then. Unlike
+// password itself, it is cheap to check.
+func (a *Authenticator) PasswordStamp(username string) (string, error) {
+	u, err := selectUser(a.db, username)
+	if err != nil {
+		return "", err
+	}
+	// hash is salted, so it changes even if password is set again
+	sum := sha256.Sum256([]byte(u.Password))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (a *Authenticator) GetUser(username string) (User, error) {
+	u, err := selectUser(a.db, username)
+	if err != nil {
+		return User{}, err
+	}
+	return u.Export(), nil
+}
+
+func (a *Authenticator) SetQuota(username string, maxBytes int64, maxFiles int64) error {
+	return updateQuota(a.db, username, maxBytes, maxFiles)
+}
+
+// ReportUsage stores usage of user home as counted by storage node.
+func (a *Authenticator) ReportUsage(username string, usedBytes int64, usedFiles int64) error {
+	return updateUsage(a.db, username, usedBytes, usedFiles)
+}
+
 func (a *Authenticator) AuthenticateWithPubkey(
 	username string,
 	pubkeyPayload []byte,
 	signCallback func(request []byte) []byte) (string, error) {
 
-	return "", errors.New("not implemented")
+	err := a.AuthorizePublicKey(username, pubkeyPayload)
+	if err != nil {
+		return "", err
+	}
+	key, err := ssh.ParsePublicKey(pubkeyPayload)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := make([]byte, 32)
+	_, err = rand.Read(challenge)
+	if err != nil {
+		return "", err
+	}
+	// signature is expected in SSH wire format
+	var sig ssh.Signature
+	err = ssh.Unmarshal(signCallback(challenge), &sig)
+	if err != nil {
+		return "", errors.New("wrong credentials")
+	}
+	err = key.Verify(challenge, &sig)
+	if err != nil {
+		return "", errors.New("wrong credentials")
+	}
+
+	u, err := selectUser(a.db, username)
+	if err != nil {
+		return "", err
+	}
+	return a.newTokenForUser(u.Export())
 }
 
 type Verifier struct {
 	jwtVerifier *rsa.PublicKey
 }
 
+func NewVerifier(key *rsa.PublicKey) *Verifier {
+	return &Verifier{jwtVerifier: key}
+}
+
 func (v *Verifier) VerifyToken(token string) (User, error) {
 	parsed, err := jwt.ParseWithClaims(token, &identityClaims{},
 		func(token *jwt.Token) (interface{}, error) {
@@ -103,12 +200,34 @@ func (v *Verifier) VerifyToken(token string) (User, error) {
 				return nil, errors.New("signing method not supported")
 			}
 			return v.jwtVerifier, nil
-		})
-	if claims, ok := parsed.Claims.(*identityClaims); ok && parsed.Valid {
-		fmt.Printf("%v %v", claims.User, claims.RegisteredClaims.Issuer)
-		// TODO create User struct
-		return User{}, nil
-	} else {
+		}, jwt.WithAudience(IdentityAudience))
+	if err != nil {
 		return User{}, fmt.Errorf("cannot parse token: %w", err)
 	}
+	if claims, ok := parsed.Claims.(*identityClaims); ok && parsed.Valid &&
+		claims.User != "" && claims.ExpiresAt != nil {
+		u := user{
+			Enabled:  true,
+			Username: claims.User,
+			Role:     claims.Role,
+			Home:     claims.Home,
+		}.Export()
+		u.Groups = claims.Groups
+		return u, nil
+	} else {
+		return User{}, errors.New("cannot parse token: invalid claims")
+	}
+}
+
+// VerifyContext verifies bearer token passed in "authorization" grpc metadata.
+func (v *Verifier) VerifyContext(ctx context.Context) (User, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return User{}, errors.New("no credentials")
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return User{}, errors.New("no credentials")
+	}
+	return v.VerifyToken(strings.TrimPrefix(values[0], "Bearer "))
 }
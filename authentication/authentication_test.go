@@ -0,0 +1,45 @@
+package authentication
+
+import "testing"
+
+func TestPasswordStamp(t *testing.T) {
+	a := testAuthenticator(t)
+	phash, err := generateFromPassword([]byte("secret"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = updatePassword(a.db, "alice", string(phash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := a.PasswordStamp("alice")
+	if err != nil || stamp == "" {
+		t.Error("stamp should be returned: ", err)
+	}
+	if _, err = a.AuthenticateWithPassword("alice", "secret"); err != nil {
+		t.Error(err)
+	}
+
+	// the same password set again changes stamp as well
+	phash, _ = generateFromPassword([]byte("secret"), 4)
+	_ = updatePassword(a.db, "alice", string(phash))
+	if changed, _ := a.PasswordStamp("alice"); changed == stamp {
+		t.Error("stamp should change along with password")
+	}
+	if _, err = a.PasswordStamp("nobody"); err == nil {
+		t.Error("unknown user should have no stamp")
+	}
+}
+
+func TestGrantDirs(t *testing.T) {
+	a := testAuthenticator(t)
+	for _, dir := range []string{".", "/", "", "../bob", "docs/../.."} {
+		if _, err := a.Grant("alice", dir, "bob", Read); err == nil {
+			t.Error("grant of ", dir, " should be refused")
+		}
+	}
+	g, err := a.Grant("alice", "docs/", "bob", ReadWrite)
+	if err != nil || g.Path != "docs" {
+		t.Error("directory inside home should be granted: ", g, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package authtest keeps users and grants in memory
+// for tests of services built on authentication.
+package authtest
+
+import (
+	"errors"
+	"path"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+)
+
+// Users are kept by name, usage reports are discarded.
+type Users map[string]authentication.User
+
+func (u Users) GetUser(username string) (authentication.User, error) {
+	user, ok := u[username]
+	if !ok {
+		return authentication.User{}, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (u Users) ReportUsage(username string, usedBytes int64, usedFiles int64) error {
+	return nil
+}
+
+// Grants keeps grants in memory, new ones are given
+// to Users only if they are set.
+type Grants struct {
+	Users  Users
+	Grants []authentication.Grant
+}
+
+func (g *Grants) Grant(owner string, dir string, grantee string,
+	access authentication.Access) (authentication.Grant, error) {
+
+	if _, ok := g.Users[grantee]; (g.Users != nil && !ok) || owner == grantee {
+		return authentication.Grant{}, errors.New("wrong grantee")
+	}
+	r := authentication.Grant{
+		ID:      owner + ":" + dir + ":" + grantee,
+		Owner:   owner,
+		Path:    path.Clean(dir),
+		Grantee: grantee,
+		Access:  access,
+		Created: time.Now(),
+	}
+	g.Grants = append(g.Grants, r)
+	return r, nil
+}
+
+func (g *Grants) RevokeGrant(owner string, id string) error {
+	for i, r := range g.Grants {
+		if r.ID == id && r.Owner == owner {
+			g.Grants = append(g.Grants[:i], g.Grants[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("grant not found")
+}
+
+func (g *Grants) GrantsBy(owner string) ([]authentication.Grant, error) {
+	var r []authentication.Grant
+	for _, gr := range g.Grants {
+		if gr.Owner == owner {
+			r = append(r, gr)
+		}
+	}
+	return r, nil
+}
+
+func (g *Grants) GrantsFor(grantee string) ([]authentication.Grant, error) {
+	var r []authentication.Grant
+	for _, gr := range g.Grants {
+		if gr.Grantee == grantee {
+			r = append(r, gr)
+		}
+	}
+	return r, nil
+}
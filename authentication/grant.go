@@ -0,0 +1,111 @@
+package authentication
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+)
+
+type Access int
+
+const (
+	Read Access = iota
+	ReadWrite
+)
+
+// Grant gives grantee access to directory in owner's home.
+type Grant struct {
+	ID      string
+	Owner   string
+	Path    string
+	Grantee string
+	Access  Access
+	Created time.Time
+}
+
+func (g grant) Export() Grant {
+	r := Grant{
+		ID:      g.ID,
+		Owner:   g.Owner,
+		Path:    g.Path,
+		Grantee: g.Grantee,
+		Access:  Read,
+		Created: time.Unix(g.Created, 0),
+	}
+	if g.Access == accessReadWrite {
+		r.Access = ReadWrite
+	}
+	return r
+}
+
+func exportGrants(grants []grant) []Grant {
+	r := make([]Grant, 0, len(grants))
+	for _, g := range grants {
+		r = append(r, g.Export())
+	}
+	return r
+}
+
+// Grant stores new grant, dir should be already verified
+// to be a directory in owner's home. Home itself is never granted.
+func (a *Authenticator) Grant(owner string, dir string, grantee string, access Access) (Grant, error) {
+	dir = path.Clean(dir)
+	if dir == "." || !fs.ValidPath(dir) {
+		return Grant{}, errors.New("only directories inside home could be granted")
+	}
+	if owner == grantee {
+		return Grant{}, errors.New("cannot grant access to yourself")
+	}
+	if _, err := selectUser(a.db, grantee); err != nil {
+		return Grant{}, errors.New("grantee does not exist")
+	}
+	g := grant{
+		ID:      uuid.NewString(),
+		Owner:   owner,
+		Path:    dir,
+		Grantee: grantee,
+		Access:  accessRead,
+		Created: time.Now().Unix(),
+	}
+	if access == ReadWrite {
+		g.Access = accessReadWrite
+	}
+	err := insertGrant(a.db, g)
+	if err != nil {
+		return Grant{}, err
+	}
+	return g.Export(), nil
+}
+
+func (a *Authenticator) RevokeGrant(owner string, id string) error {
+	n, err := deleteGrant(a.db, owner, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("grant not found")
+	}
+	return nil
+}
+
+// GrantsBy returns grants given by owner.
+func (a *Authenticator) GrantsBy(owner string) ([]Grant, error) {
+	grants, err := selectGrants(a.db, dbx.HashExp{fieldGrantOwner: owner})
+	if err != nil {
+		return nil, err
+	}
+	return exportGrants(grants), nil
+}
+
+// GrantsFor returns grants given to grantee.
+func (a *Authenticator) GrantsFor(grantee string) ([]Grant, error) {
+	grants, err := selectGrants(a.db, dbx.HashExp{fieldGrantGrantee: grantee})
+	if err != nil {
+		return nil, err
+	}
+	return exportGrants(grants), nil
+}
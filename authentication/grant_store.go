@@ -0,0 +1,103 @@
+package authentication
+
+import (
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	tableGrants       = "grants"
+	fieldGrantID      = "id"
+	fieldGrantOwner   = "owner"
+	fieldGrantPath    = "path"
+	fieldGrantGrantee = "grantee"
+	fieldGrantAccess  = "access"
+	fieldGrantCreated = "created"
+	indexGrantOwner   = "grants_owner_idx"
+	indexGrantGrantee = "grants_grantee_idx"
+)
+
+type grant struct {
+	ID      string `db:"id"`
+	Owner   string `db:"owner"`
+	Path    string `db:"path"`
+	Grantee string `db:"grantee"`
+	Access  string `db:"access"`
+	Created int64  `db:"created"`
+}
+
+const (
+	accessRead      string = "r"
+	accessReadWrite string = "rw"
+)
+
+func initGrantsTable(db *dbx.DB) error {
+	grants := make(map[string]string)
+	grants[fieldGrantID] = "TEXT PRIMARY KEY NOT NULL"
+	grants[fieldGrantOwner] = "TEXT NOT NULL REFERENCES " + tableUsers +
+		"(" + fieldUserUsername + ") ON DELETE CASCADE"
+	grants[fieldGrantPath] = "TEXT NOT NULL"
+	grants[fieldGrantGrantee] = "TEXT NOT NULL REFERENCES " + tableUsers +
+		"(" + fieldUserUsername + ") ON DELETE CASCADE"
+	grants[fieldGrantAccess] = "TEXT DEFAULT 'r' NOT NULL"
+	grants[fieldGrantCreated] = "INTEGER DEFAULT 0 NOT NULL"
+
+	query := db.CreateTable(tableGrants, grants)
+	_, err := query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateIndex(tableGrants, indexGrantOwner, fieldGrantOwner)
+	_, err = query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateIndex(tableGrants, indexGrantGrantee, fieldGrantGrantee)
+	_, err = query.Execute()
+	return err
+}
+
+var grantFields = []string{
+	fieldGrantID,
+	fieldGrantOwner,
+	fieldGrantPath,
+	fieldGrantGrantee,
+	fieldGrantAccess,
+	fieldGrantCreated,
+}
+
+func selectGrants(db *dbx.DB, where dbx.HashExp) ([]grant, error) {
+	var g []grant
+	e := db.Select(grantFields...).
+		From(tableGrants).
+		Where(where).
+		OrderBy(fieldGrantCreated).
+		All(&g)
+	return g, e
+}
+
+func insertGrant(db *dbx.DB, g grant) error {
+	_, e := db.Insert(tableGrants,
+		dbx.Params{
+			fieldGrantID:      g.ID,
+			fieldGrantOwner:   g.Owner,
+			fieldGrantPath:    g.Path,
+			fieldGrantGrantee: g.Grantee,
+			fieldGrantAccess:  g.Access,
+			fieldGrantCreated: g.Created,
+		}).Execute()
+	return e
+}
+
+func deleteGrant(db *dbx.DB, owner string, id string) (int64, error) {
+	res, e := db.Delete(tableGrants,
+		dbx.HashExp{
+			fieldGrantOwner: owner,
+			fieldGrantID:    id,
+		}).Execute()
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
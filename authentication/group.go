@@ -0,0 +1,168 @@
+package authentication
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/pocketbase/dbx"
+)
+
+// Group owns storage space shared by its members.
+type Group struct {
+	Name  string
+	Home  string
+	Quota Quota
+}
+
+type Member struct {
+	Group    string
+	Username string
+	Admin    bool // manages membership
+}
+
+func (g group) Export() Group {
+	return Group{
+		Name: g.Name,
+		Home: g.Home,
+		Quota: Quota{
+			MaxBytes:  g.QuotaBytes,
+			MaxFiles:  g.QuotaFiles,
+			UsedBytes: g.UsedBytes,
+			UsedFiles: g.UsedFiles,
+		},
+	}
+}
+
+func (m member) Export() Member {
+	return Member{Group: m.Group, Username: m.Username, Admin: m.Admin}
+}
+
+func (a *Authenticator) CreateGroup(name string, home string, maxBytes int64, maxFiles int64) (Group, error) {
+	if name == "" || home == "" {
+		return Group{}, errors.New("group name and home are required")
+	}
+	if !fs.ValidPath(home) || home == "." {
+		return Group{}, errors.New("group home should be relative path")
+	}
+	// files of group would be reachable through another home
+	overlaps, err := homeOverlaps(a.db, home)
+	if err != nil {
+		return Group{}, err
+	}
+	if overlaps {
+		return Group{}, errors.New("group home overlaps home of user or group")
+	}
+	g := group{Name: name, Home: home, QuotaBytes: maxBytes, QuotaFiles: maxFiles}
+	err = createGroup(a.db, g)
+	if err != nil {
+		return Group{}, err
+	}
+	return g.Export(), nil
+}
+
+func (a *Authenticator) GetGroup(name string) (Group, error) {
+	g, err := selectGroup(a.db, name)
+	if err != nil {
+		return Group{}, err
+	}
+	return g.Export(), nil
+}
+
+func (a *Authenticator) ListGroups() ([]Group, error) {
+	groups, err := selectAllGroups(a.db)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		r = append(r, g.Export())
+	}
+	return r, nil
+}
+
+// DeleteGroup removes group and memberships, its storage is kept.
+func (a *Authenticator) DeleteGroup(name string) error {
+	return deleteGroup(a.db, name)
+}
+
+func (a *Authenticator) SetGroupQuota(name string, maxBytes int64, maxFiles int64) error {
+	return updateGroup(a.db, name, dbx.Params{
+		fieldGroupQuotaBytes: maxBytes,
+		fieldGroupQuotaFiles: maxFiles,
+	})
+}
+
+// ReportGroupUsage stores usage of group space as counted by storage node.
+func (a *Authenticator) ReportGroupUsage(name string, usedBytes int64, usedFiles int64) error {
+	return updateGroup(a.db, name, dbx.Params{
+		fieldGroupUsedBytes: usedBytes,
+		fieldGroupUsedFiles: usedFiles,
+	})
+}
+
+func exportMembers(members []member) []Member {
+	r := make([]Member, 0, len(members))
+	for _, m := range members {
+		r = append(r, m.Export())
+	}
+	return r
+}
+
+func (a *Authenticator) Members(groupName string) ([]Member, error) {
+	members, err := selectMembers(a.db, dbx.HashExp{fieldMemberGroup: groupName})
+	if err != nil {
+		return nil, err
+	}
+	return exportMembers(members), nil
+}
+
+// Memberships returns groups user is member of.
+func (a *Authenticator) Memberships(username string) ([]Member, error) {
+	members, err := selectMembers(a.db, dbx.HashExp{fieldMemberUser: username})
+	if err != nil {
+		return nil, err
+	}
+	return exportMembers(members), nil
+}
+
+// MemberGroups returns names of groups user is member of.
+func (a *Authenticator) MemberGroups(username string) ([]string, error) {
+	members, err := a.Memberships(username)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		names = append(names, m.Group)
+	}
+	return names, nil
+}
+
+func (a *Authenticator) AddMember(groupName string, username string, admin bool) error {
+	// existing member just gets admin flag changed
+	n, err := updateMemberAdmin(a.db, groupName, username, admin)
+	if err != nil || n > 0 {
+		return err
+	}
+	return insertMember(a.db, member{Group: groupName, Username: username, Admin: admin})
+}
+
+func (a *Authenticator) RemoveMember(groupName string, username string) error {
+	n, err := deleteMember(a.db, groupName, username)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("membership not found")
+	}
+	return nil
+}
+
+// IsGroupAdmin reports whether user manages membership of group.
+func (a *Authenticator) IsGroupAdmin(groupName string, username string) bool {
+	members, err := selectMembers(a.db, dbx.HashExp{
+		fieldMemberGroup: groupName,
+		fieldMemberUser:  username,
+	})
+	return err == nil && len(members) == 1 && members[0].Admin
+}
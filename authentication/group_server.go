@@ -0,0 +1,204 @@
+package authentication
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GroupServer manages groups. Groups are created and deleted by
+// superusers, membership is also managed by group admins.
+type GroupServer struct {
+	svc      *Authenticator
+	verifier *Verifier
+	proto.UnimplementedGroupsServer
+}
+
+func NewGroupServer(svc *Authenticator, verifier *Verifier) *GroupServer {
+	return &GroupServer{svc: svc, verifier: verifier}
+}
+
+func exportGroup(g Group) *proto.Group {
+	return &proto.Group{
+		Name: g.Name,
+		Home: g.Home,
+		Quota: &proto.UserQuota{
+			MaxBytes:  g.Quota.MaxBytes,
+			MaxFiles:  g.Quota.MaxFiles,
+			UsedBytes: g.Quota.UsedBytes,
+			UsedFiles: g.Quota.UsedFiles,
+		},
+	}
+}
+
+func (s *GroupServer) caller(ctx context.Context) (User, error) {
+	caller, err := s.verifier.VerifyContext(ctx)
+	if err != nil {
+		return caller, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return caller, nil
+}
+
+func (s *GroupServer) superuser(ctx context.Context) error {
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	if caller.Role != Superuser {
+		return status.Error(codes.PermissionDenied, "not allowed")
+	}
+	return nil
+}
+
+// member checks that caller is member of group or superuser.
+func (s *GroupServer) member(ctx context.Context, name string) error {
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	if caller.Role == Superuser {
+		return nil
+	}
+	groups, err := s.svc.MemberGroups(caller.Name)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !slices.Contains(groups, name) {
+		return status.Error(codes.PermissionDenied, "not allowed")
+	}
+	return nil
+}
+
+// admin checks that caller is admin of group or superuser.
+func (s *GroupServer) admin(ctx context.Context, name string) error {
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return err
+	}
+	if caller.Role != Superuser && !s.svc.IsGroupAdmin(name, caller.Name) {
+		return status.Error(codes.PermissionDenied, "not allowed")
+	}
+	return nil
+}
+
+func (s *GroupServer) Create(ctx context.Context, req *proto.CreateGroupReq) (*proto.CreateGroupRes, error) {
+	err := s.superuser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.svc.CreateGroup(req.GetName(), req.GetHome(), req.GetMaxBytes(), req.GetMaxFiles())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.CreateGroupRes{Group: exportGroup(g)}, nil
+}
+
+func (s *GroupServer) Get(ctx context.Context, req *proto.GetGroupReq) (*proto.GetGroupRes, error) {
+	err := s.member(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.svc.GetGroup(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.GetGroupRes{Group: exportGroup(g)}, nil
+}
+
+func (s *GroupServer) List(ctx context.Context, req *proto.ListGroupsReq) (*proto.ListGroupsRes, error) {
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.svc.ListGroups()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var own []string
+	if caller.Role != Superuser {
+		own, err = s.svc.MemberGroups(caller.Name)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	res := &proto.ListGroupsRes{}
+	for _, g := range groups {
+		if caller.Role == Superuser || slices.Contains(own, g.Name) {
+			res.Groups = append(res.Groups, exportGroup(g))
+		}
+	}
+	return res, nil
+}
+
+func (s *GroupServer) Delete(ctx context.Context, req *proto.DeleteGroupReq) (*proto.DeleteGroupRes, error) {
+	err := s.superuser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.DeleteGroup(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.DeleteGroupRes{}, nil
+}
+
+func (s *GroupServer) SetQuota(ctx context.Context, req *proto.SetGroupQuotaReq) (*proto.SetGroupQuotaRes, error) {
+	err := s.superuser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.SetGroupQuota(req.GetName(), req.GetMaxBytes(), req.GetMaxFiles())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.SetGroupQuotaRes{}, nil
+}
+
+func (s *GroupServer) ListMembers(ctx context.Context, req *proto.ListMembersReq) (*proto.ListMembersRes, error) {
+	err := s.member(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.svc.Members(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListMembersRes{}
+	for _, m := range members {
+		res.Members = append(res.Members, &proto.GroupMember{
+			Username: m.Username,
+			Admin:    m.Admin,
+		})
+	}
+	return res, nil
+}
+
+func (s *GroupServer) AddMember(ctx context.Context, req *proto.AddMemberReq) (*proto.AddMemberRes, error) {
+	err := s.admin(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMember() == nil {
+		return nil, status.Error(codes.InvalidArgument, "member is required")
+	}
+	err = s.svc.AddMember(req.GetName(), req.GetMember().GetUsername(), req.GetMember().GetAdmin())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.AddMemberRes{}, nil
+}
+
+func (s *GroupServer) RemoveMember(ctx context.Context, req *proto.RemoveMemberReq) (*proto.RemoveMemberRes, error) {
+	err := s.admin(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.RemoveMember(req.GetName(), req.GetUsername())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.RemoveMemberRes{}, nil
+}
@@ -0,0 +1,190 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testAuthenticator opens database in temporary directory
+// with superuser root, group admin alice and users bob and carol.
+func testAuthenticator(t *testing.T) *Authenticator {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(path.Join(t.TempDir(), "auth.db"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.db.Close() })
+	for _, u := range []user{
+		{Username: "root", Role: roleSuperuser, Home: "root", Email: "root@example.com", Enabled: true},
+		{Username: "alice", Role: roleRegular, Home: "alice", Email: "alice@example.com", Enabled: true},
+		{Username: "bob", Role: roleRegular, Home: "bob", Email: "bob@example.com", Enabled: true},
+		{Username: "carol", Role: roleRegular, Home: "carol", Email: "carol@example.com", Enabled: true},
+	} {
+		err = createUser(a.db, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+// testContext carries token of user as issued on login.
+func testContext(t *testing.T, a *Authenticator, username string) context.Context {
+	u, err := a.GetUser(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := a.newTokenForUser(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestGroupServer(t *testing.T) {
+	a := testAuthenticator(t)
+	s := NewGroupServer(a, NewVerifier(&a.jwtSigner.PublicKey))
+	root := testContext(t, a, "root")
+	alice := testContext(t, a, "alice")
+	bob := testContext(t, a, "bob")
+	carol := testContext(t, a, "carol")
+
+	if _, err := s.Create(context.Background(), &proto.CreateGroupReq{Name: "team", Home: "groups/team"}); status.Code(err) != codes.Unauthenticated {
+		t.Error("group should not be created without token: ", err)
+	}
+	if _, err := s.Create(alice, &proto.CreateGroupReq{Name: "team", Home: "groups/team"}); status.Code(err) != codes.PermissionDenied {
+		t.Error("group should be created by superuser only: ", err)
+	}
+	_, err := s.Create(root, &proto.CreateGroupReq{Name: "team", Home: "groups/team", MaxBytes: 1024})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = s.AddMember(root, &proto.AddMemberReq{Name: "team", Member: &proto.GroupMember{Username: "alice", Admin: true}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// admin manages membership
+	_, err = s.AddMember(alice, &proto.AddMemberReq{Name: "team", Member: &proto.GroupMember{Username: "bob"}})
+	if err != nil {
+		t.Error("admin should add members: ", err)
+	}
+	res, err := s.ListMembers(bob, &proto.ListMembersReq{Name: "team"})
+	if err != nil || len(res.GetMembers()) != 2 {
+		t.Error("member should list members: ", res, err)
+	}
+
+	// regular members and others do not
+	_, err = s.AddMember(bob, &proto.AddMemberReq{Name: "team", Member: &proto.GroupMember{Username: "carol"}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("member should not add members: ", err)
+	}
+	_, err = s.AddMember(bob, &proto.AddMemberReq{Name: "team", Member: &proto.GroupMember{Username: "bob", Admin: true}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("member should not make itself admin: ", err)
+	}
+	_, err = s.RemoveMember(bob, &proto.RemoveMemberReq{Name: "team", Username: "alice"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("member should not remove members: ", err)
+	}
+	_, err = s.RemoveMember(carol, &proto.RemoveMemberReq{Name: "team", Username: "bob"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("other user should not remove members: ", err)
+	}
+	if _, err = s.ListMembers(carol, &proto.ListMembersReq{Name: "team"}); status.Code(err) != codes.PermissionDenied {
+		t.Error("other user should not list members: ", err)
+	}
+	if _, err = s.Get(carol, &proto.GetGroupReq{Name: "team"}); status.Code(err) != codes.PermissionDenied {
+		t.Error("other user should not get group: ", err)
+	}
+	list, err := s.List(carol, &proto.ListGroupsReq{})
+	if err != nil || len(list.GetGroups()) != 0 {
+		t.Error("other user should not see group: ", list, err)
+	}
+	if _, err = s.SetQuota(alice, &proto.SetGroupQuotaReq{Name: "team", MaxBytes: 0}); status.Code(err) != codes.PermissionDenied {
+		t.Error("admin should not change quota: ", err)
+	}
+	if _, err = s.Delete(alice, &proto.DeleteGroupReq{Name: "team"}); status.Code(err) != codes.PermissionDenied {
+		t.Error("admin should not delete group: ", err)
+	}
+
+	_, err = s.RemoveMember(alice, &proto.RemoveMemberReq{Name: "team", Username: "bob"})
+	if err != nil {
+		t.Error("admin should remove members: ", err)
+	}
+	if _, err = s.Get(bob, &proto.GetGroupReq{Name: "team"}); status.Code(err) != codes.PermissionDenied {
+		t.Error("removed member should lose access: ", err)
+	}
+	_, err = s.Delete(root, &proto.DeleteGroupReq{Name: "team"})
+	if err != nil {
+		t.Error(err)
+	}
+	if groups, _ := a.MemberGroups("alice"); len(groups) != 0 {
+		t.Error("memberships should be deleted with group: ", groups)
+	}
+}
+
+func TestGroupClaims(t *testing.T) {
+	a := testAuthenticator(t)
+	v := NewVerifier(&a.jwtSigner.PublicKey)
+	_, err := a.CreateGroup("team", "groups/team", 0, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = a.AddMember("team", "alice", false)
+	if err != nil {
+		t.Error(err)
+	}
+	u, _ := a.GetUser("alice")
+
+	// without claims services look membership up
+	token, _ := a.newTokenForUser(u)
+	verified, err := v.VerifyToken(token)
+	if err != nil || verified.Groups != nil {
+		t.Error("token should not carry groups: ", verified, err)
+	}
+
+	a.SetGroupClaims(true)
+	token, _ = a.newTokenForUser(u)
+	verified, err = v.VerifyToken(token)
+	if err != nil || !slices.Equal(verified.Groups, []string{"team"}) {
+		t.Error("token should carry groups: ", verified, err)
+	}
+	u, _ = a.GetUser("bob")
+	token, _ = a.newTokenForUser(u)
+	if verified, _ = v.VerifyToken(token); verified.Groups != nil {
+		t.Error("token of user without groups should fall back to lookup: ", verified.Groups)
+	}
+}
+
+func TestGroupHome(t *testing.T) {
+	a := testAuthenticator(t)
+	_, err := a.CreateGroup("team", "groups/team", 0, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, home := range []string{"alice", "alice/team", "groups", "groups/team", "groups/team/sub"} {
+		if _, err = a.CreateGroup("other", home, 0, 0); err == nil {
+			t.Error("overlapping home should be rejected: ", home)
+		}
+	}
+	if _, err = a.CreateGroup("other", "groups/teams", 0, 0); err != nil {
+		t.Error("home with common prefix should be accepted: ", err)
+	}
+}
@@ -0,0 +1,205 @@
+package authentication
+
+import (
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	tableGroups          = "groups"
+	fieldGroupName       = "name"
+	fieldGroupHome       = "home"
+	fieldGroupQuotaBytes = "quota_bytes"
+	fieldGroupQuotaFiles = "quota_files"
+	fieldGroupUsedBytes  = "used_bytes"
+	fieldGroupUsedFiles  = "used_files"
+	indexGroupHome       = "groups_home_idx"
+
+	tableMembers      = "group_members"
+	fieldMemberGroup  = "group_name"
+	fieldMemberUser   = "username"
+	fieldMemberAdmin  = "admin"
+	indexMemberUnique = "group_members_idx"
+	indexMemberUser   = "group_members_user_idx"
+)
+
+type group struct {
+	Name       string `db:"name"`
+	Home       string `db:"home"`
+	QuotaBytes int64  `db:"quota_bytes"`
+	QuotaFiles int64  `db:"quota_files"`
+	UsedBytes  int64  `db:"used_bytes"`
+	UsedFiles  int64  `db:"used_files"`
+}
+
+type member struct {
+	Group    string `db:"group_name"`
+	Username string `db:"username"`
+	Admin    bool   `db:"admin"`
+}
+
+func initGroupsTables(db *dbx.DB) error {
+	groups := make(map[string]string)
+	groups[fieldGroupName] = "TEXT PRIMARY KEY NOT NULL"
+	groups[fieldGroupHome] = "TEXT NOT NULL"
+	groups[fieldGroupQuotaBytes] = "INTEGER DEFAULT 0 NOT NULL"
+	groups[fieldGroupQuotaFiles] = "INTEGER DEFAULT 0 NOT NULL"
+	groups[fieldGroupUsedBytes] = "INTEGER DEFAULT 0 NOT NULL"
+	groups[fieldGroupUsedFiles] = "INTEGER DEFAULT 0 NOT NULL"
+
+	query := db.CreateTable(tableGroups, groups)
+	_, err := query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateUniqueIndex(tableGroups, indexGroupHome, fieldGroupHome)
+	_, err = query.Execute()
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]string)
+	members[fieldMemberGroup] = "TEXT NOT NULL REFERENCES " + tableGroups +
+		"(" + fieldGroupName + ") ON DELETE CASCADE"
+	members[fieldMemberUser] = "TEXT NOT NULL REFERENCES " + tableUsers +
+		"(" + fieldUserUsername + ") ON DELETE CASCADE"
+	members[fieldMemberAdmin] = "BOOLEAN DEFAULT FALSE NOT NULL"
+
+	query = db.CreateTable(tableMembers, members)
+	_, err = query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateUniqueIndex(tableMembers, indexMemberUnique,
+		fieldMemberGroup, fieldMemberUser)
+	_, err = query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateIndex(tableMembers, indexMemberUser, fieldMemberUser)
+	_, err = query.Execute()
+	return err
+}
+
+var groupFields = []string{
+	fieldGroupName,
+	fieldGroupHome,
+	fieldGroupQuotaBytes,
+	fieldGroupQuotaFiles,
+	fieldGroupUsedBytes,
+	fieldGroupUsedFiles,
+}
+
+// homeOverlaps reports whether home is, contains or is inside
+// home of a user or another group.
+func homeOverlaps(db *dbx.DB, home string) (bool, error) {
+	overlaps := dbx.NewExp("home = {:home}"+
+		" OR substr(home, 1, length({:home}) + 1) = {:home} || '/'"+
+		" OR substr({:home}, 1, length(home) + 1) = home || '/'",
+		dbx.Params{"home": home})
+	for _, table := range []string{tableUsers, tableGroups} {
+		var n int
+		err := db.Select("COUNT(*)").From(table).Where(overlaps).Row(&n)
+		if err != nil || n > 0 {
+			return n > 0, err
+		}
+	}
+	return false, nil
+}
+
+func selectGroup(db *dbx.DB, name string) (group, error) {
+	var g group
+	e := db.Select(groupFields...).
+		From(tableGroups).
+		Where(dbx.HashExp{
+			fieldGroupName: name,
+		}).
+		One(&g)
+	return g, e
+}
+
+func selectAllGroups(db *dbx.DB) ([]group, error) {
+	var g []group
+	e := db.Select(groupFields...).
+		From(tableGroups).
+		OrderBy(fieldGroupName).
+		All(&g)
+	return g, e
+}
+
+func createGroup(db *dbx.DB, g group) error {
+	_, e := db.Insert(tableGroups,
+		dbx.Params{
+			fieldGroupName:       g.Name,
+			fieldGroupHome:       g.Home,
+			fieldGroupQuotaBytes: g.QuotaBytes,
+			fieldGroupQuotaFiles: g.QuotaFiles,
+		}).Execute()
+	return e
+}
+
+func updateGroup(db *dbx.DB, name string, values dbx.Params) error {
+	_, e := db.Update(tableGroups, values,
+		dbx.HashExp{
+			fieldGroupName: name,
+		}).Execute()
+	return e
+}
+
+func deleteGroup(db *dbx.DB, name string) error {
+	_, e := db.Delete(tableGroups,
+		dbx.HashExp{
+			fieldGroupName: name,
+		}).Execute()
+	return e
+}
+
+func selectMembers(db *dbx.DB, where dbx.HashExp) ([]member, error) {
+	var m []member
+	e := db.Select(
+		fieldMemberGroup,
+		fieldMemberUser,
+		fieldMemberAdmin).
+		From(tableMembers).
+		Where(where).
+		OrderBy(fieldMemberGroup, fieldMemberUser).
+		All(&m)
+	return m, e
+}
+
+func insertMember(db *dbx.DB, m member) error {
+	_, e := db.Insert(tableMembers,
+		dbx.Params{
+			fieldMemberGroup: m.Group,
+			fieldMemberUser:  m.Username,
+			fieldMemberAdmin: m.Admin,
+		}).Execute()
+	return e
+}
+
+func deleteMember(db *dbx.DB, groupName string, username string) (int64, error) {
+	res, e := db.Delete(tableMembers,
+		dbx.HashExp{
+			fieldMemberGroup: groupName,
+			fieldMemberUser:  username,
+		}).Execute()
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
+
+func updateMemberAdmin(db *dbx.DB, groupName string, username string, admin bool) (int64, error) {
+	res, e := db.Update(tableMembers,
+		dbx.Params{fieldMemberAdmin: admin},
+		dbx.HashExp{
+			fieldMemberGroup: groupName,
+			fieldMemberUser:  username,
+		}).Execute()
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
@@ -1 +1,75 @@
 package authentication
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PublicKey is a named SSH public key of user,
+// Blob is in SSH wire format.
+type PublicKey struct {
+	Name      string
+	Algorithm string
+	Blob      []byte
+}
+
+func (k publicKey) Export() PublicKey {
+	r := PublicKey{Name: k.Name, Blob: k.Payload}
+	if parsed, err := ssh.ParsePublicKey(k.Payload); err == nil {
+		r.Algorithm = parsed.Type()
+	}
+	return r
+}
+
+func (a *Authenticator) AddPublicKey(username string, name string, blob []byte) error {
+	if name == "" {
+		return errors.New("key name is required")
+	}
+	_, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	return insertPublicKey(a.db, publicKey{
+		Username: username,
+		Name:     name,
+		Payload:  blob,
+	})
+}
+
+func (a *Authenticator) RemovePublicKey(username string, name string) error {
+	return deletePublicKey(a.db, username, name)
+}
+
+func (a *Authenticator) PublicKeys(username string) ([]PublicKey, error) {
+	keys, err := selectPublicKeys(a.db, username)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]PublicKey, 0, len(keys))
+	for _, k := range keys {
+		r = append(r, k.Export())
+	}
+	return r, nil
+}
+
+// AuthorizePublicKey checks that key belongs to enabled user.
+// Possession of private key must be verified by caller.
+func (a *Authenticator) AuthorizePublicKey(username string, blob []byte) error {
+	u, err := selectUser(a.db, username)
+	if err != nil || !u.Enabled {
+		return errors.New("wrong credentials")
+	}
+	keys, err := selectPublicKeys(a.db, username)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if bytes.Equal(k.Payload, blob) {
+			return nil
+		}
+	}
+	return errors.New("wrong credentials")
+}
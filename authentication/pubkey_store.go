@@ -1,7 +1,72 @@
 package authentication
 
+import (
+	"github.com/pocketbase/dbx"
+)
+
 const (
 	tablePublicKeys = "public_keys"
+	fieldPkUser     = "username"
 	fieldPkName     = "name"
 	fieldPkBlob     = "payload"
+	indexPkUserName = "public_keys_user_name_idx"
 )
+
+type publicKey struct {
+	Username string `db:"username"`
+	Name     string `db:"name"`
+	Payload  []byte `db:"payload"`
+}
+
+func initPublicKeysTable(db *dbx.DB) error {
+	keys := make(map[string]string)
+	keys[fieldPkUser] = "TEXT NOT NULL REFERENCES " + tableUsers +
+		"(" + fieldUserUsername + ") ON DELETE CASCADE"
+	keys[fieldPkName] = "TEXT NOT NULL"
+	keys[fieldPkBlob] = "BLOB NOT NULL"
+
+	query := db.CreateTable(tablePublicKeys, keys)
+	_, err := query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateUniqueIndex(tablePublicKeys, indexPkUserName,
+		fieldPkUser, fieldPkName)
+	_, err = query.Execute()
+	return err
+}
+
+func selectPublicKeys(db *dbx.DB, username string) ([]publicKey, error) {
+	var keys []publicKey
+	e := db.Select(
+		fieldPkUser,
+		fieldPkName,
+		fieldPkBlob).
+		From(tablePublicKeys).
+		Where(dbx.HashExp{
+			fieldPkUser: username,
+		}).
+		OrderBy(fieldPkName).
+		All(&keys)
+	return keys, e
+}
+
+func insertPublicKey(db *dbx.DB, k publicKey) error {
+	_, e := db.Insert(tablePublicKeys,
+		dbx.Params{
+			fieldPkUser: k.Username,
+			fieldPkName: k.Name,
+			fieldPkBlob: k.Payload,
+		}).Execute()
+	return e
+}
+
+func deletePublicKey(db *dbx.DB, username string, name string) error {
+	_, e := db.Delete(tablePublicKeys,
+		dbx.HashExp{
+			fieldPkUser: username,
+			fieldPkName: name,
+		}).Execute()
+	return e
+}
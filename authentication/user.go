@@ -13,19 +13,35 @@ const (
 )
 
 type User struct {
-	Name  string
-	Role  Role
-	Home  string
-	Email string
+	Name    string
+	Enabled bool
+	Role    Role
+	Home    string
+	Email   string
+	Quota   Quota
+	Groups  []string // set only from token with group claims
+}
+
+// Quota holds storage limits of user home, zero means unlimited.
+// Used values are reported by storage nodes.
+type Quota struct {
+	MaxBytes  int64
+	MaxFiles  int64
+	UsedBytes int64
+	UsedFiles int64
 }
 
 type user struct {
-	enabled  bool
-	username string
-	password string
-	role     string
-	email    string
-	home     string
+	Enabled    bool   `db:"enabled"`
+	Username   string `db:"username"`
+	Password   string `db:"password"`
+	Role       string `db:"role"`
+	Email      string `db:"email"`
+	Home       string `db:"home"`
+	QuotaBytes int64  `db:"quota_bytes"`
+	QuotaFiles int64  `db:"quota_files"`
+	UsedBytes  int64  `db:"used_bytes"`
+	UsedFiles  int64  `db:"used_files"`
 }
 
 const (
@@ -36,7 +52,7 @@ const (
 
 func (u user) Export() User {
 	var r Role
-	switch u.role {
+	switch u.Role {
 	case roleSuperuser:
 		r = Superuser
 	case roleService:
@@ -47,24 +63,35 @@ func (u user) Export() User {
 		r = Regular
 	}
 	return User{
-		Name:  u.username,
-		Role:  r,
-		Home:  u.home,
-		Email: u.email,
+		Name:    u.Username,
+		Enabled: u.Enabled,
+		Role:    r,
+		Home:    u.Home,
+		Email:   u.Email,
+		Quota: Quota{
+			MaxBytes:  u.QuotaBytes,
+			MaxFiles:  u.QuotaFiles,
+			UsedBytes: u.UsedBytes,
+			UsedFiles: u.UsedFiles,
+		},
 	}
 }
 
 const (
-	tableUsers        = "users"
-	fieldUserEnabled  = "enabled"
-	fieldUserUsername = "username"
-	fieldUserPassword = "password"
-	fieldUserRole     = "role"
-	fieldUserEmail    = "email"
-	fieldUserHome     = "home"
-	indexUserUsername = "username_idx"
-	indexUserEmail    = "email_idx"
-	indexUserHome     = "home_idx"
+	tableUsers          = "users"
+	fieldUserEnabled    = "enabled"
+	fieldUserUsername   = "username"
+	fieldUserPassword   = "password"
+	fieldUserRole       = "role"
+	fieldUserEmail      = "email"
+	fieldUserHome       = "home"
+	fieldUserQuotaBytes = "quota_bytes"
+	fieldUserQuotaFiles = "quota_files"
+	fieldUserUsedBytes  = "used_bytes"
+	fieldUserUsedFiles  = "used_files"
+	indexUserUsername   = "username_idx"
+	indexUserEmail      = "email_idx"
+	indexUserHome       = "home_idx"
 )
 
 func initUsersTable(db *dbx.DB) error {
@@ -74,7 +101,11 @@ func initUsersTable(db *dbx.DB) error {
 	users[fieldUserPassword] = "TEXT NOT NULL"
 	users[fieldUserRole] = "TEXT DEFAULT 'u' NOT NULL"
 	users[fieldUserEmail] = "TEXT DEFAULT '' NOT NULL"
-	users[fieldUserHome] = "TEXT PRIMARY KEY NOT NULL"
+	users[fieldUserHome] = "TEXT NOT NULL"
+	users[fieldUserQuotaBytes] = "INTEGER DEFAULT 0 NOT NULL"
+	users[fieldUserQuotaFiles] = "INTEGER DEFAULT 0 NOT NULL"
+	users[fieldUserUsedBytes] = "INTEGER DEFAULT 0 NOT NULL"
+	users[fieldUserUsedFiles] = "INTEGER DEFAULT 0 NOT NULL"
 
 	query := db.CreateTable(tableUsers, users)
 	_, err := query.Execute()
@@ -109,8 +140,13 @@ func selectUser(db *dbx.DB, username string) (user, error) {
 		fieldUserEnabled,
 		fieldUserUsername,
 		fieldUserPassword,
+		fieldUserRole,
 		fieldUserEmail,
-		fieldUserHome).
+		fieldUserHome,
+		fieldUserQuotaBytes,
+		fieldUserQuotaFiles,
+		fieldUserUsedBytes,
+		fieldUserUsedFiles).
 		From(tableUsers).
 		Where(dbx.HashExp{
 			fieldUserUsername: username,
@@ -122,11 +158,14 @@ func selectUser(db *dbx.DB, username string) (user, error) {
 func createUser(db *dbx.DB, u user) error {
 	_, e := db.Insert(tableUsers,
 		dbx.Params{
-			fieldUserEnabled:  u.enabled,
-			fieldUserUsername: u.username,
-			fieldUserPassword: u.password,
-			fieldUserEmail:    u.email,
-			fieldUserHome:     u.home,
+			fieldUserEnabled:    u.Enabled,
+			fieldUserUsername:   u.Username,
+			fieldUserPassword:   u.Password,
+			fieldUserRole:       u.Role,
+			fieldUserEmail:      u.Email,
+			fieldUserHome:       u.Home,
+			fieldUserQuotaBytes: u.QuotaBytes,
+			fieldUserQuotaFiles: u.QuotaFiles,
 		}).Execute()
 	return e
 }
@@ -150,6 +189,20 @@ func disableUser(db *dbx.DB, username string) error {
 	return updateUser(db, username, dbx.Params{fieldUserEnabled: false})
 }
 
+func updateQuota(db *dbx.DB, username string, maxBytes int64, maxFiles int64) error {
+	return updateUser(db, username, dbx.Params{
+		fieldUserQuotaBytes: maxBytes,
+		fieldUserQuotaFiles: maxFiles,
+	})
+}
+
+func updateUsage(db *dbx.DB, username string, usedBytes int64, usedFiles int64) error {
+	return updateUser(db, username, dbx.Params{
+		fieldUserUsedBytes: usedBytes,
+		fieldUserUsedFiles: usedFiles,
+	})
+}
+
 func deleteUser(db *dbx.DB, username string) error {
 	_, e := db.Delete(tableUsers,
 		dbx.HashExp{
@@ -170,8 +223,13 @@ func listUsers(db *dbx.DB, q *dbx.AndOrExp, offset int64, limit int64, sortBy st
 		fieldUserEnabled,
 		fieldUserUsername,
 		fieldUserPassword,
+		fieldUserRole,
 		fieldUserEmail,
-		fieldUserHome).
+		fieldUserHome,
+		fieldUserQuotaBytes,
+		fieldUserQuotaFiles,
+		fieldUserUsedBytes,
+		fieldUserUsedFiles).
 		From(tableUsers).
 		Where(q).
 		Limit(limit).
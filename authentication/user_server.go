@@ -0,0 +1,179 @@
+package authentication
+
+import (
+	"context"
+
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type UserServer struct {
+	svc      *Authenticator
+	verifier *Verifier
+	proto.UnimplementedUserManagerServer
+}
+
+func NewUserServer(svc *Authenticator, verifier *Verifier) *UserServer {
+	return &UserServer{svc: svc, verifier: verifier}
+}
+
+func exportUser(u User) *proto.User {
+	r := &proto.User{
+		Name:    u.Name,
+		Enabled: u.Enabled,
+		Email:   u.Email,
+		Quota: &proto.UserQuota{
+			MaxBytes:  u.Quota.MaxBytes,
+			MaxFiles:  u.Quota.MaxFiles,
+			UsedBytes: u.Quota.UsedBytes,
+			UsedFiles: u.Quota.UsedFiles,
+		},
+	}
+	switch u.Role {
+	case Regular:
+		r.Role = proto.UserRoleE_REGULAR
+	case Service:
+		r.Role = proto.UserRoleE_SERVICE
+	case Superuser:
+		r.Role = proto.UserRoleE_SUPERUSER
+	}
+	return r
+}
+
+func (s *UserServer) Get(ctx context.Context, req *proto.GetUserReq) (*proto.GetUserRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.svc.GetUser(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.GetUserRes{User: exportUser(u)}, nil
+}
+
+// allowed checks that caller is either user itself or superuser.
+func (s *UserServer) allowed(ctx context.Context, name string) error {
+	caller, err := s.verifier.VerifyContext(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if caller.Name != name && caller.Role != Superuser {
+		return status.Error(codes.PermissionDenied, "not allowed")
+	}
+	return nil
+}
+
+// SetQuota changes limits of user home, only superuser can do it.
+func (s *UserServer) SetQuota(ctx context.Context, req *proto.SetUserQuotaReq) (*proto.SetUserQuotaRes, error) {
+	caller, err := s.verifier.VerifyContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if caller.Role != Superuser {
+		return nil, status.Error(codes.PermissionDenied, "not allowed")
+	}
+	_, err = s.svc.GetUser(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	err = s.svc.SetQuota(req.GetName(), req.GetMaxBytes(), req.GetMaxFiles())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.SetUserQuotaRes{}, nil
+}
+
+func (s *UserServer) ListPublicKeys(ctx context.Context, req *proto.ListPublicKeysReq) (*proto.ListPublicKeysRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.svc.PublicKeys(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListPublicKeysRes{}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, &proto.PublicKey{
+			Name:      k.Name,
+			Algorithm: k.Algorithm,
+			Blob:      k.Blob,
+		})
+	}
+	return res, nil
+}
+
+func (s *UserServer) AddPublicKey(ctx context.Context, req *proto.AddPublicKeyReq) (*proto.AddPublicKeyRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.AddPublicKey(req.GetName(),
+		req.GetKey().GetName(), req.GetKey().GetBlob())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.AddPublicKeyRes{}, nil
+}
+
+func (s *UserServer) RemovePublicKey(ctx context.Context, req *proto.RemovePublicKeyReq) (*proto.RemovePublicKeyRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.RemovePublicKey(req.GetName(), req.GetKeyName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.RemovePublicKeyRes{}, nil
+}
+
+func (s *UserServer) ListAccessKeys(ctx context.Context, req *proto.ListAccessKeysReq) (*proto.ListAccessKeysRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.svc.AccessKeys(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListAccessKeysRes{}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, &proto.AccessKey{
+			Id:      k.ID,
+			Created: k.Created.Unix(),
+		})
+	}
+	return res, nil
+}
+
+func (s *UserServer) CreateAccessKey(ctx context.Context, req *proto.CreateAccessKeyReq) (*proto.CreateAccessKeyRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	k, err := s.svc.CreateAccessKey(req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &proto.CreateAccessKeyRes{Key: &proto.AccessKey{
+		Id:      k.ID,
+		Secret:  k.Secret,
+		Created: k.Created.Unix(),
+	}}, nil
+}
+
+func (s *UserServer) RemoveAccessKey(ctx context.Context, req *proto.RemoveAccessKeyReq) (*proto.RemoveAccessKeyRes, error) {
+	err := s.allowed(ctx, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.RemoveAccessKey(req.GetName(), req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.RemoveAccessKeyRes{}, nil
+}
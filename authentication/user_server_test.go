@@ -0,0 +1,38 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserSetQuota(t *testing.T) {
+	a := testAuthenticator(t)
+	s := NewUserServer(a, NewVerifier(&a.jwtSigner.PublicKey))
+	root := testContext(t, a, "root")
+	bob := testContext(t, a, "bob")
+
+	_, err := s.SetQuota(bob, &proto.SetUserQuotaReq{Name: "bob", MaxBytes: 1 << 30})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Error("user should not change own quota: ", err)
+	}
+	_, err = s.SetQuota(root, &proto.SetUserQuotaReq{Name: "nobody", MaxBytes: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Error("expected not found for unknown user: ", err)
+	}
+	_, err = s.SetQuota(root, &proto.SetUserQuotaReq{Name: "bob", MaxBytes: 1024, MaxFiles: 10})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := s.Get(bob, &proto.GetUserReq{Name: "bob"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if q := res.GetUser().GetQuota(); q.GetMaxBytes() != 1024 || q.GetMaxFiles() != 10 {
+		t.Error("unexpected quota: ", q)
+	}
+}
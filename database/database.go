@@ -29,3 +29,12 @@ func InMemDB() (*dbx.DB, error) {
 	// TODO check
 	return ConnectDB("file::memory:")
 }
+
+func TableExists(db *dbx.DB, table string) (bool, error) {
+	var cnt int
+	err := db.Select("COUNT(*)").
+		From("sqlite_master").
+		Where(dbx.HashExp{"type": "table", "name": table}).
+		Row(&cnt)
+	return cnt > 0, err
+}
@@ -0,0 +1,195 @@
+package httpfs
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// TokenCookie is cookie token is accepted from,
+// so browsers could open links to files.
+const TokenCookie = "cardia_token"
+
+// Handler serves files of user homes over plain HTTP with range
+// and conditional requests, so media could be played and cached.
+// URL is prefix + "/" + name inside home of caller.
+// Clients authenticate with bearer token or TokenCookie.
+type Handler struct {
+	prefix   string
+	verifier *authentication.Verifier
+	homes    *storage.Homes
+}
+
+func NewHandler(prefix string, verifier *authentication.Verifier, homes *storage.Homes) *Handler {
+	return &Handler{
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		verifier: verifier,
+		homes:    homes,
+	}
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head><body>
+<h1>{{.Title}}</h1>
+<ul>
+{{range .Entries}}<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{end}}</ul>
+</body></html>
+`))
+
+type listingEntry struct {
+	Name string
+	Href string
+}
+
+type listing struct {
+	Title   string
+	Entries []listingEntry
+}
+
+func (h *Handler) authenticate(r *http.Request) (authentication.User, error) {
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return h.verifier.VerifyToken(token)
+	}
+	if c, err := r.Cookie(TokenCookie); err == nil && c.Value != "" {
+		return h.verifier.VerifyToken(c.Value)
+	}
+	return authentication.User{}, errors.New("no credentials")
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := h.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cardia"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	home, err := h.homes.Open(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, h.prefix)), "/")
+	if name == "" {
+		name = "."
+	}
+	f, err := home.Open(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if info.IsDir() {
+		h.list(w, r, home, name)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok || !info.Mode().IsRegular() {
+		http.Error(w, "file is not seekable", http.StatusInternalServerError)
+		return
+	}
+	tag, err := etag(home, name, info)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	mimeType, err := contentType(rs, name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Content-Type", mimeType)
+	// revalidated with ETag, content may change any time
+	w.Header().Set("Cache-Control", "private, no-cache")
+	// handles Range, If-Range and conditional headers
+	http.ServeContent(w, r, info.Name(), info.ModTime(), rs)
+}
+
+// etag is derived from content hash if home keeps them,
+// otherwise from modification time and size.
+func etag(home localstorage.WriteFS, name string, info fs.FileInfo) (string, error) {
+	if hfs, ok := home.(localstorage.HashFS); ok {
+		h, err := hfs.Hash(name)
+		if err == nil {
+			return `"` + h.SHA256 + `"`, nil
+		}
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return "", err
+		}
+	}
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// contentType detects media type of file by name and beginning
+// of content, rs is rewound afterwards.
+func contentType(rs io.ReadSeeker, name string) (string, error) {
+	// as much as http.DetectContentType considers
+	head := make([]byte, 512)
+	n, err := io.ReadFull(rs, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	_, err = rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	t := localstorage.DetectType(name, head[:n])
+	if strings.HasPrefix(t, "text/") {
+		t += "; charset=utf-8"
+	}
+	return t, nil
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, home localstorage.WriteFS, name string) {
+	entries, err := fs.ReadDir(home, name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	l := listing{Title: path.Join("/", name)}
+	base := strings.TrimSuffix(r.URL.Path, "/") + "/"
+	for _, e := range entries {
+		n, href := e.Name(), url.PathEscape(e.Name())
+		if e.IsDir() {
+			n, href = n+"/", href+"/"
+		}
+		l.Entries = append(l.Entries, listingEntry{Name: n, Href: base + href})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = listingTemplate.Execute(w, l)
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
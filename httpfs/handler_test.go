@@ -0,0 +1,169 @@
+package httpfs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// plainFS hides optional interfaces of home.
+type plainFS struct {
+	localstorage.WriteFS
+}
+
+func TestHandler(t *testing.T) {
+	root := t.TempDir()
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	for _, dir := range []string{"alice/music", "bob"} {
+		err := os.MkdirAll(path.Join(root, dir), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := []byte("a quiet song")
+	err := os.WriteFile(path.Join(root, "alice/music/song.txt"), content, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path.Join(root, "bob/secret.txt"), []byte("secret"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &localstorage.Config{}
+	homes := storage.NewHomes(localstorage.NewLocalFs(root, config), config, users, nil, nil)
+	srv := httptest.NewServer(NewHandler("/files", authentication.NewVerifier(&key.PublicKey), homes))
+	defer srv.Close()
+
+	claims := jwt.MapClaims{
+		"user": "alice",
+		"role": "u",
+		"home": "alice",
+		"aud":  authentication.IdentityAudience,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodRS512, claims).SignedString(key)
+	claims["aud"] = "cardia-share"
+	linkToken, _ := jwt.NewWithClaims(jwt.SigningMethodRS512, claims).SignedString(key)
+
+	get := func(method string, name string, header map[string]string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, srv.URL+"/files/"+name, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res, string(body)
+	}
+	bearer := "Bearer " + token
+
+	// authentication
+	res, _ := get(http.MethodGet, "music/song.txt", nil)
+	if res.StatusCode != http.StatusUnauthorized || res.Header.Get("WWW-Authenticate") == "" {
+		t.Error("anonymous request should be challenged: ", res.Status)
+	}
+	res, _ = get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": "Bearer " + linkToken})
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Error("link token should not authenticate: ", res.Status)
+	}
+	res, body := get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": bearer})
+	if res.StatusCode != http.StatusOK || body != string(content) {
+		t.Error("bearer token should be accepted: ", res.Status, body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		t.Error("wrong content type: ", res.Header.Get("Content-Type"))
+	}
+	res, body = get(http.MethodGet, "music/song.txt", map[string]string{"Cookie": TokenCookie + "=" + token})
+	if res.StatusCode != http.StatusOK || body != string(content) {
+		t.Error("cookie should be accepted: ", res.Status, body)
+	}
+	res, _ = get(http.MethodPut, "music/song.txt", map[string]string{"Authorization": bearer})
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Error("writes should not be allowed: ", res.Status)
+	}
+
+	// etag is content hash kept by home
+	sum := sha256.Sum256(content)
+	tag := `"` + hex.EncodeToString(sum[:]) + `"`
+	res, _ = get(http.MethodHead, "music/song.txt", map[string]string{"Authorization": bearer})
+	if res.Header.Get("ETag") != tag {
+		t.Error("etag should be content hash: ", res.Header.Get("ETag"))
+	}
+
+	// ranges and conditional requests
+	res, body = get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": bearer, "Range": "bytes=2-6"})
+	if res.StatusCode != http.StatusPartialContent || body != "quiet" ||
+		res.Header.Get("Content-Range") != "bytes 2-6/12" {
+		t.Error("wrong range: ", res.Status, body)
+	}
+	res, _ = get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": bearer, "If-None-Match": tag})
+	if res.StatusCode != http.StatusNotModified {
+		t.Error("matching etag should not be sent again: ", res.Status)
+	}
+	res, _ = get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": bearer, "If-Match": `"stale"`})
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Error("stale etag should fail precondition: ", res.Status)
+	}
+	res, body = get(http.MethodGet, "music/song.txt", map[string]string{"Authorization": bearer, "If-Match": tag})
+	if res.StatusCode != http.StatusOK || body != string(content) {
+		t.Error("matching etag should pass precondition: ", res.Status)
+	}
+
+	// listing and confinement to home
+	res, body = get(http.MethodGet, "music/", map[string]string{"Authorization": bearer})
+	if res.StatusCode != http.StatusOK || !strings.Contains(body, `href="/files/music/song.txt"`) {
+		t.Error("wrong listing: ", res.Status, body)
+	}
+	res, _ = get(http.MethodGet, "../bob/secret.txt", map[string]string{"Authorization": bearer})
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("other home should not be reached: ", res.Status)
+	}
+	res, _ = get(http.MethodGet, "music/missing.txt", map[string]string{"Authorization": bearer})
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("missing file should not be found: ", res.Status)
+	}
+
+	// regular files are served from file opened by verified path
+	home, _ := homes.Open(users["alice"])
+	f, err := home.Open("music/song.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := f.(*os.File); !ok {
+		t.Errorf("file should be opened from disk: %T", f)
+	}
+	_ = f.Close()
+
+	// homes without hashes tag content by time and size
+	info, _ := os.Stat(path.Join(root, "alice/music/song.txt"))
+	tag, err = etag(plainFS{home}, "music/song.txt", info)
+	if err != nil || !strings.HasSuffix(tag, `-c"`) {
+		t.Error("wrong etag of file without hash: ", tag, err)
+	}
+}
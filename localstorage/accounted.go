@@ -0,0 +1,202 @@
+package localstorage
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// accountedFS applies quota and reports changes of file system
+// not on local disk, e.g. memory or s3 backend, the way localfs does
+// for its root. Stores are kept in local state dir, names are
+// reported to them as if files were under it.
+type accountedFS struct {
+	wfs    WriteFS
+	state  string
+	config *Config
+}
+
+// NewAccountedFS returns wfs accounting usage in config.Quota and
+// reporting changes to config.Changes opened in state dir.
+// Trash, versions, uploads and snapshots are not supported.
+func NewAccountedFS(wfs WriteFS, state string, config *Config) WriteFS {
+	return &accountedFS{wfs: wfs, state: filepath.Clean(state), config: config}
+}
+
+func (a *accountedFS) fullPath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(a.state, filepath.FromSlash(name)), nil
+}
+
+func (a *accountedFS) Open(name string) (fs.File, error) {
+	return a.wfs.Open(name)
+}
+
+func (a *accountedFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(a.wfs, name)
+}
+
+func (a *accountedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(a.wfs, name)
+}
+
+// Create accounts content as it is written, replaced
+// file keeps its space until new content is in place.
+func (a *accountedFS) Create(name string) (FileWriter, error) {
+	fullPath, err := a.fullPath("create", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(a.wfs, name)
+	existing := err == nil && info.Mode().IsRegular()
+	w := &accountedWriter{quota: a.config.Quota}
+	if existing {
+		w.replaced = info.Size()
+	} else {
+		w.files = 1
+	}
+	err = a.config.Quota.reserve(0, w.files)
+	if err != nil {
+		return nil, err
+	}
+
+	op := ChangeCreate
+	if existing {
+		op = ChangeModify
+	}
+	a.config.Changes.writeStarted(fullPath)
+	w.FileWriter, err = a.wfs.Create(name)
+	if err != nil {
+		a.config.Quota.release(0, w.files)
+		a.config.Changes.writeFailed(fullPath)
+		return nil, err
+	}
+	w.done = func(err error) {
+		if err != nil {
+			a.config.Changes.writeFailed(fullPath)
+			return
+		}
+		a.config.Changes.writeDone(fullPath, op)
+	}
+	return w, nil
+}
+
+func (a *accountedFS) Mkdir(name string, perm fs.FileMode) error {
+	fullPath, err := a.fullPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	err = a.wfs.Mkdir(name, perm)
+	if err != nil {
+		return err
+	}
+	a.config.Changes.publish(ChangeCreate, fullPath, "", true)
+	return nil
+}
+
+func (a *accountedFS) Remove(name string) error {
+	fullPath, err := a.fullPath("remove", name)
+	if err != nil {
+		return err
+	}
+	if path.Clean(name) == "." {
+		return errors.New("cannot remove root")
+	}
+	info, err := fs.Stat(a.wfs, name)
+	if err != nil {
+		return err
+	}
+	err = a.wfs.Remove(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		a.config.Quota.release(info.Size(), 1)
+	}
+	a.config.Changes.publish(ChangeDelete, fullPath, "", info.IsDir())
+	return nil
+}
+
+func (a *accountedFS) Rename(oldname string, newname string) error {
+	oldPath, err := a.fullPath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newPath, err := a.fullPath("rename", newname)
+	if err != nil {
+		return err
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	info, err := fs.Stat(a.wfs, oldname)
+	if err != nil {
+		return err
+	}
+	replaced, _ := fs.Stat(a.wfs, newname)
+	err = a.wfs.Rename(oldname, newname)
+	if err != nil {
+		return err
+	}
+	if replaced != nil && replaced.Mode().IsRegular() {
+		a.config.Quota.release(replaced.Size(), 1)
+	}
+	a.config.Changes.publish(ChangeRename, newPath, oldPath, info.IsDir())
+	return nil
+}
+
+// Root is empty, files are not on local disk.
+func (a *accountedFS) Root() string {
+	return ""
+}
+
+// accountedWriter reserves quota for content before it is written.
+type accountedWriter struct {
+	FileWriter
+	mu       sync.Mutex
+	quota    *Quota
+	written  int64
+	replaced int64 // size of replaced file
+	files    int64 // slot taken by new file
+	done     func(err error)
+}
+
+func (w *accountedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// replaced content is counted until new one is in place,
+	// only growth over it is reserved
+	grow := min(int64(len(b)), w.written+int64(len(b))-w.replaced)
+	if grow > 0 {
+		err := w.quota.reserve(grow, 0)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.FileWriter.Write(b)
+	w.written += int64(n)
+	if unused := int64(len(b) - n); grow > 0 && unused > 0 {
+		w.quota.release(min(grow, unused), 0)
+	}
+	return n, err
+}
+
+// Close gives back space of replaced content once new one is in
+// place, otherwise space reserved for new content.
+func (w *accountedWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.FileWriter.Close()
+	counted := max(w.written, w.replaced)
+	if err != nil {
+		w.quota.release(counted-w.replaced, w.files)
+	} else {
+		w.quota.release(counted-w.written, 0)
+	}
+	w.done(err)
+	return err
+}
@@ -0,0 +1,69 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAccountedFS(t *testing.T) {
+	state := t.TempDir()
+	quota := NewQuota(0, 0)
+	changes := NewChangeFeed(state, 16)
+	wfs := NewAccountedFS(NewMemFS(), state, &Config{Quota: quota, Changes: changes})
+	testBackend(t, wfs)
+	if used, files := quota.Usage(); used != 0 || files != 0 {
+		t.Error("removed files should be released: ", used, files)
+	}
+	quota.SetLimits(10, 2)
+
+	sub, _ := changes.Subscribe(".", 0)
+	defer sub.Close()
+	f, err := wfs.Create("notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "draft")
+	if _, err = io.WriteString(f, " over limit"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("write over limit should be rejected: ", err)
+	}
+	_ = f.Close()
+	if used, files := quota.Usage(); used != 5 || files != 1 {
+		t.Error("written file should be counted: ", used, files)
+	}
+	select {
+	case c := <-sub.C:
+		if c.Op != ChangeCreate || c.Name != "notes.txt" {
+			t.Error("wrong change: ", c)
+		}
+	case <-time.After(time.Second):
+		t.Error("change should be reported")
+	}
+
+	// replaced content is counted until new one is in place
+	f, _ = wfs.Create("notes.txt")
+	_, _ = io.WriteString(f, "final")
+	if used, _ := quota.Usage(); used != 5 {
+		t.Error("rewrite should not be counted twice: ", used)
+	}
+	_ = f.Close()
+	f, _ = wfs.Create("copy.txt")
+	_ = f.Close()
+	if _, err = wfs.Create("third.txt"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("file over limit should be rejected: ", err)
+	}
+	err = wfs.Rename("copy.txt", "notes.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if used, files := quota.Usage(); used != 0 || files != 1 {
+		t.Error("replaced file should be released: ", used, files)
+	}
+
+	err = quota.ReconcileFS(wfs)
+	if used, files := quota.Usage(); err != nil || used != 0 || files != 1 {
+		t.Error("wrong recounted usage: ", used, files, err)
+	}
+}
@@ -0,0 +1,304 @@
+package localstorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type ArchiveFormat int
+
+const (
+	Zip ArchiveFormat = iota
+	TarGz
+)
+
+// ErrUnsafeArchive is returned when archive entry would be
+// extracted outside of target directory.
+var ErrUnsafeArchive = errors.New("archive entry has unsafe path")
+
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	switch s {
+	case "zip":
+		return Zip, nil
+	case "tar.gz", "tgz":
+		return TarGz, nil
+	}
+	return 0, fmt.Errorf("unknown archive format %q", s)
+}
+
+// Ext returns file name extension of format.
+func (f ArchiveFormat) Ext() string {
+	if f == TarGz {
+		return ".tar.gz"
+	}
+	return ".zip"
+}
+
+// MkdirAll creates directory along with missing parents.
+func MkdirAll(wfs WriteFS, dir string, perm fs.FileMode) error {
+	dir = path.Clean(dir)
+	if dir == "." {
+		return nil
+	}
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		err := wfs.Mkdir(strings.Join(parts[:i+1], "/"), perm)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceFile writes content of r to hidden sibling of name and renames
+// it over name, so existing file is kept intact until new content is
+// complete. Verify, if not nil, is called once content is written,
+// its error leaves name untouched as well.
+func ReplaceFile(wfs WriteFS, name string, r io.Reader, verify func() error) error {
+	tmp := path.Join(path.Dir(name), "."+path.Base(name)+"."+uuid.NewString()[:8]+".part")
+	f, err := wfs.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && verify != nil {
+		err = verify()
+	}
+	if err == nil {
+		err = wfs.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = wfs.Remove(tmp)
+	}
+	return err
+}
+
+// walkArchive calls entry for every file and directory inside dir
+// with name relative to it. Other files, e.g. symlinks, are skipped.
+func walkArchive(fsys fs.FS, dir string, entry func(name string, info fs.FileInfo) error) error {
+	return fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name := p
+		if dir != "." {
+			name = strings.TrimPrefix(p, dir+"/")
+		}
+		return entry(name, info)
+	})
+}
+
+// copyFile writes content of name, which is expected to be size bytes.
+func copyFile(w io.Writer, fsys fs.FS, name string, size int64) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.CopyN(w, f, size)
+	if err == io.EOF {
+		return fmt.Errorf("%s was truncated while archived: %d of %d bytes", name, n, size)
+	}
+	return err
+}
+
+// WriteArchive streams directory as archive, entries are named
+// relative to it. Nothing is buffered besides compressor state.
+func WriteArchive(w io.Writer, fsys fs.FS, dir string, format ArchiveFormat) error {
+	dir = path.Clean(dir)
+	info, err := fs.Stat(fsys, dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if format == TarGz {
+		return writeTarGz(w, fsys, dir)
+	}
+	return writeZip(w, fsys, dir)
+}
+
+func writeZip(w io.Writer, fsys fs.FS, dir string) error {
+	zw := zip.NewWriter(w)
+	err := walkArchive(fsys, dir, func(name string, info fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+			_, err = zw.CreateHeader(hdr)
+			return err
+		}
+		hdr.Method = zip.Deflate
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyFile(fw, fsys, path.Join(dir, name), info.Size())
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, fsys fs.FS, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := walkArchive(fsys, dir, func(name string, info fs.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		// owners on server mean nothing to clients
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyFile(tw, fsys, path.Join(dir, name), info.Size())
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	return err
+}
+
+// extractName returns name of archive entry inside dir, empty
+// for root. Names escaping dir are rejected, the rest of trusted
+// root rules are enforced by wfs itself.
+func extractName(dir string, name string) (string, error) {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "./"), "/")
+	if name == "" || name == "." {
+		return "", nil
+	}
+	if strings.Contains(name, "\\") || !fs.ValidPath(name) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeArchive, name)
+	}
+	return path.Join(dir, name), nil
+}
+
+// extractFile writes file of archive, its size counts toward quota.
+// Existing file is replaced only once entry is extracted completely.
+func extractFile(wfs WriteFS, name string, r io.Reader) error {
+	err := MkdirAll(wfs, path.Dir(name), 0750)
+	if err != nil {
+		return err
+	}
+	return ReplaceFile(wfs, name, r, nil)
+}
+
+// ExtractTarGz extracts archive into dir and returns number of
+// extracted files. Entries other than files and directories are skipped.
+// Archive is rejected on first unsafe entry, entries extracted before
+// it are kept.
+func ExtractTarGz(wfs WriteFS, dir string, r io.Reader) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		name, err := extractName(dir, hdr.Name)
+		if err != nil {
+			return files, err
+		}
+		if name == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = MkdirAll(wfs, name, 0750)
+		case tar.TypeReg:
+			err = extractFile(wfs, name, tr)
+			if err == nil {
+				files++
+			}
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+}
+
+// ExtractZip is the same as ExtractTarGz for zip archive of size bytes,
+// except that nothing is extracted from archive with unsafe entry,
+// since its names are known in advance.
+func ExtractZip(wfs WriteFS, dir string, r io.ReaderAt, size int64) (int, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(zr.File))
+	for i, f := range zr.File {
+		names[i], err = extractName(dir, f.Name)
+		if err != nil {
+			return 0, err
+		}
+	}
+	files := 0
+	for i, f := range zr.File {
+		name := names[i]
+		if name == "" {
+			continue
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = MkdirAll(wfs, name, 0750)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			rc, err = f.Open()
+			if err == nil {
+				err = extractFile(wfs, name, rc)
+				_ = rc.Close()
+			}
+			if err == nil {
+				files++
+			}
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,143 @@
+package localstorage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024}).(WriteFS)
+
+	for _, format := range []ArchiveFormat{Zip, TarGz} {
+		var buf bytes.Buffer
+		err = WriteArchive(&buf, lfs, "subfolder1", format)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		target := "copy" + format.Ext()
+		var files int
+		if format == Zip {
+			files, err = ExtractZip(lfs, target, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		} else {
+			files, err = ExtractTarGz(lfs, target, &buf)
+		}
+		if err != nil || files != 2 {
+			t.Error("files = ", files, "; err = ", err)
+		}
+		b, err := fs.ReadFile(lfs, target+"/dir12/friend.txt")
+		if err != nil || string(b) != "friend" {
+			t.Error("wrong content: ", string(b), err)
+		}
+		info, err := fs.Stat(lfs, target+"/dir11")
+		if err != nil || !info.IsDir() {
+			t.Error("empty directory should be extracted: ", err)
+		}
+	}
+
+	err = WriteArchive(&bytes.Buffer{}, lfs, "subfolder1/hello.txt", Zip)
+	if err == nil {
+		t.Error("file should not be archived as directory")
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	quota := NewQuota(0, 0)
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024, Quota: quota}).(WriteFS)
+
+	// zip slip
+	for _, name := range []string{"../evil.txt", "/evil.txt", "a/../../evil.txt", "a\\..\\evil.txt"} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte("evil"))
+		_ = zw.Close()
+		_, err = ExtractZip(lfs, "subfolder2", bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if !errors.Is(err, ErrUnsafeArchive) {
+			t.Error(name, " should be rejected: ", err)
+		}
+	}
+	if _, err := os.Stat(path.Join(path.Dir(p), "evil.txt")); err == nil {
+		t.Error("file is extracted outside of root")
+	}
+
+	// nothing is extracted from zip with unsafe entry
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	for _, name := range []string{"safe.txt", "../evil.txt"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte("content"))
+	}
+	_ = zw.Close()
+	_, err = ExtractZip(lfs, "subfolder2", bytes.NewReader(zbuf.Bytes()), int64(zbuf.Len()))
+	if !errors.Is(err, ErrUnsafeArchive) {
+		t.Error("unsafe entry should be rejected: ", err)
+	}
+	if _, err = fs.Stat(lfs, "subfolder2/safe.txt"); err == nil {
+		t.Error("entries before unsafe one should not be extracted")
+	}
+
+	// reserved directory, symlinks are skipped
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	_ = tw.WriteHeader(&tar.Header{Name: ".cardia/x", Typeflag: tar.TypeReg, Size: 1, Mode: 0640})
+	_, _ = tw.Write([]byte("x"))
+	_ = tw.Close()
+	_ = gz.Close()
+	_, err = ExtractTarGz(lfs, ".", &buf)
+	if err == nil {
+		t.Error("reserved directory should be rejected")
+	}
+	if _, err := os.Lstat(path.Join(p, "link")); err == nil {
+		t.Error("symlink should be skipped")
+	}
+
+	// quota is enforced, existing file is kept
+	quota.SetLimits(20, 0)
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	w, _ := zw.Create("goodbye.txt")
+	_, _ = w.Write(bytes.Repeat([]byte{0}, 1000))
+	_ = zw.Close()
+	_, err = ExtractZip(lfs, "subfolder2", bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("quota should be exceeded: ", err)
+	}
+	b, err := fs.ReadFile(lfs, "subfolder2/goodbye.txt")
+	if err != nil || string(b) != "friend" {
+		t.Error("existing file should be kept: ", string(b), err)
+	}
+	entries, _ := fs.ReadDir(lfs, "subfolder2")
+	if len(entries) != 2 {
+		t.Error("partially extracted file should be removed: ", entries)
+	}
+}
@@ -0,0 +1,199 @@
+package localstorage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"sync"
+)
+
+// Backend opens file system described by URL of its scheme.
+// Code written against WriteFS runs on any of them, e.g. homes of
+// storage service, archives and tests.
+type Backend func(u *url.URL) (WriteFS, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		"file": openFileBackend,
+		"mem":  openMemBackend,
+	}
+)
+
+// RegisterBackend makes backend available by scheme,
+// e.g. package s3 registers "s3" client backend.
+func RegisterBackend(scheme string, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[scheme] = b
+}
+
+// Backends returns registered schemes.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	r := make([]string, 0, len(backends))
+	for scheme := range backends {
+		r = append(r, scheme)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// OpenBackend opens file system by URL, e.g. "file:///srv/cardia"
+// or "mem:". URL without scheme is path of local directory.
+func OpenBackend(rawURL string) (WriteFS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		u = &url.URL{Scheme: "file", Path: rawURL}
+	}
+	backendsMu.RLock()
+	b, ok := backends[u.Scheme]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q", u.Scheme)
+	}
+	return b(u)
+}
+
+func openFileBackend(u *url.URL) (WriteFS, error) {
+	if u.Path == "" {
+		return nil, errors.New("path of local backend is required")
+	}
+	return NewLocalFs(u.Path, &Config{}).(WriteFS), nil
+}
+
+func openMemBackend(*url.URL) (WriteFS, error) {
+	return NewMemFS(), nil
+}
+
+// Sub returns dir of wfs as WriteFS, backends
+// not implementing it as fs.SubFS are wrapped.
+func Sub(wfs WriteFS, dir string) (WriteFS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return wfs, nil
+	}
+	if s, ok := wfs.(fs.SubFS); ok {
+		sub, err := s.Sub(dir)
+		if err != nil {
+			return nil, err
+		}
+		if sw, ok := sub.(WriteFS); ok {
+			return sw, nil
+		}
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("sub is not writable")}
+	}
+	info, err := fs.Stat(wfs, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+	return &subFS{wfs: wfs, dir: dir}, nil
+}
+
+// subFS is directory of backend without its own Sub.
+type subFS struct {
+	wfs WriteFS
+	dir string
+}
+
+func (s *subFS) full(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.dir, name), nil
+}
+
+// shorten makes name of path error relative to sub.
+func (s *subFS) shorten(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+func (s *subFS) Open(name string) (fs.File, error) {
+	full, err := s.full("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.wfs.Open(full)
+	return f, s.shorten(err, name)
+}
+
+func (s *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := s.full("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(s.wfs, full)
+	return info, s.shorten(err, name)
+}
+
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := s.full("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(s.wfs, full)
+	return entries, s.shorten(err, name)
+}
+
+func (s *subFS) Create(name string) (FileWriter, error) {
+	full, err := s.full("create", name)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.wfs.Create(full)
+	return w, s.shorten(err, name)
+}
+
+func (s *subFS) Mkdir(name string, perm fs.FileMode) error {
+	full, err := s.full("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.wfs.Mkdir(full, perm), name)
+}
+
+func (s *subFS) Remove(name string) error {
+	full, err := s.full("remove", name)
+	if err != nil {
+		return err
+	}
+	if full == s.dir {
+		return errors.New("cannot remove root")
+	}
+	return s.shorten(s.wfs.Remove(full), name)
+}
+
+func (s *subFS) Rename(oldname string, newname string) error {
+	oldFull, err := s.full("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newFull, err := s.full("rename", newname)
+	if err != nil {
+		return err
+	}
+	return s.shorten(s.wfs.Rename(oldFull, newFull), newname)
+}
+
+func (s *subFS) Root() string {
+	if root := s.wfs.Root(); root != "" {
+		return path.Join(root, s.dir)
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+// testBackend checks behaviour code running on WriteFS relies on.
+func testBackend(t *testing.T, wfs WriteFS) {
+	err := wfs.Mkdir("docs", 0750)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = wfs.Mkdir("docs", 0750); !errors.Is(err, fs.ErrExist) {
+		t.Error("existing directory should not be created: ", err)
+	}
+	if _, err = wfs.Create("missing/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("file should not be created in missing directory: ", err)
+	}
+
+	f, err := wfs.Create("docs/notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "first ")
+	_, _ = io.WriteString(f, "draft")
+	err = f.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	content, err := fs.ReadFile(wfs, "docs/notes.txt")
+	if err != nil || string(content) != "first draft" {
+		t.Error("wrong content: ", string(content), err)
+	}
+	info, err := fs.Stat(wfs, "docs/notes.txt")
+	if err != nil || info.Size() != 11 || info.IsDir() {
+		t.Error("wrong info: ", info, err)
+	}
+
+	err = wfs.Rename("docs", "archive")
+	if err != nil {
+		t.Error(err)
+	}
+	entries, err := fs.ReadDir(wfs, "archive")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		t.Error("directory should be moved with content: ", entries, err)
+	}
+	if _, err = fs.Stat(wfs, "docs"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("renamed directory should not exist: ", err)
+	}
+
+	if err = wfs.Remove("archive"); err == nil {
+		t.Error("directory with content should not be removed")
+	}
+	err = wfs.Remove("archive/notes.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	err = wfs.Remove("archive")
+	if err != nil {
+		t.Error(err)
+	}
+	entries, err = fs.ReadDir(wfs, ".")
+	if err != nil || len(entries) != 0 {
+		t.Error("backend should be empty: ", entries, err)
+	}
+}
+
+func TestMemBackend(t *testing.T) {
+	wfs, err := OpenBackend("mem:")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	testBackend(t, wfs)
+}
+
+func TestFileBackend(t *testing.T) {
+	p, err := os.MkdirTemp("", "cardia*")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(p)
+	wfs, err := OpenBackend("file://" + p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	testBackend(t, wfs)
+
+	if _, err = OpenBackend("ftp://example.com"); err == nil {
+		t.Error("unknown backend should not be opened")
+	}
+}
+
+func TestSubBackend(t *testing.T) {
+	wfs := NewMemFS()
+	err := wfs.Mkdir("alice", 0750)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sub, err := Sub(wfs, "alice")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	testBackend(t, sub)
+	if _, err = fs.Stat(sub, "../alice"); err == nil {
+		t.Error("names outside sub should be rejected")
+	}
+	if _, err = Sub(wfs, "bob"); err == nil {
+		t.Error("missing directory should not be sub")
+	}
+}
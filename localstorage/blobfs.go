@@ -0,0 +1,660 @@
+package localstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+)
+
+// blobfs stores file content as sha256 addressed blobs,
+// so identical files share the same blob on disk.
+// Directory tree lives in sqlite, blobs are reference counted
+// and unreferenced ones are removed by CollectGarbage.
+//
+// Layout of dir:
+//
+//	blobs/ab/abcdef... content
+//	tmp/               files being written
+//	tree.db            nodes and blob references
+type blobfs struct {
+	store  *blobStore
+	prefix string // sub tree, "." for the whole store
+	config *Config
+}
+
+type blobStore struct {
+	mu  sync.Mutex // serializes tree and refcount changes
+	dir string
+	db  *dbx.DB
+}
+
+const (
+	tableBlobNodes    = "blob_nodes"
+	fieldNodePath     = "path"
+	fieldNodeParent   = "parent"
+	fieldNodeIsDir    = "is_dir"
+	fieldNodeBlob     = "blob"
+	fieldNodeSize     = "size"
+	fieldNodeModified = "modified"
+	indexNodeParent   = "blob_nodes_parent_idx"
+	tableBlobs        = "blobs"
+	fieldBlobHash     = "hash"
+	fieldBlobSize     = "size"
+	fieldBlobRefs     = "refs"
+)
+
+const (
+	blobsDir         = "blobs"
+	blobsTmpDir      = "tmp"
+	blobsDatabase    = "tree.db"
+	blobRootPath     = "."
+	blobFileMode     = 0640
+	blobDirMode      = 0750 | fs.ModeDir
+	blobReadOnlyMode = 0440
+)
+
+type blobNode struct {
+	Path     string `db:"path"`
+	Parent   string `db:"parent"`
+	IsDir    bool   `db:"is_dir"`
+	Blob     string `db:"blob"`
+	Size     int64  `db:"size"`
+	Modified int64  `db:"modified"` // unix nano
+}
+
+func initBlobTables(db *dbx.DB) error {
+	exists, err := database.TableExists(db, tableBlobNodes)
+	if err != nil || exists {
+		return err
+	}
+
+	nodes := make(map[string]string)
+	nodes[fieldNodePath] = "TEXT PRIMARY KEY NOT NULL"
+	nodes[fieldNodeParent] = "TEXT NOT NULL"
+	nodes[fieldNodeIsDir] = "BOOLEAN NOT NULL"
+	nodes[fieldNodeBlob] = "TEXT DEFAULT '' NOT NULL"
+	nodes[fieldNodeSize] = "INTEGER DEFAULT 0 NOT NULL"
+	nodes[fieldNodeModified] = "INTEGER DEFAULT 0 NOT NULL"
+	_, err = db.CreateTable(tableBlobNodes, nodes).Execute()
+	if err != nil {
+		return err
+	}
+	_, err = db.CreateIndex(tableBlobNodes, indexNodeParent, fieldNodeParent).Execute()
+	if err != nil {
+		return err
+	}
+
+	blobs := make(map[string]string)
+	blobs[fieldBlobHash] = "TEXT PRIMARY KEY NOT NULL"
+	blobs[fieldBlobSize] = "INTEGER NOT NULL"
+	blobs[fieldBlobRefs] = "INTEGER DEFAULT 0 NOT NULL"
+	_, err = db.CreateTable(tableBlobs, blobs).Execute()
+	return err
+}
+
+// BlobFS is WriteFS deduplicating file content.
+type BlobFS interface {
+	WriteFS
+	CollectGarbage() (int, error)
+}
+
+// NewBlobFs opens deduplicating store in dir, creating it if needed.
+func NewBlobFs(dir string, config *Config) (BlobFS, error) {
+	if !filepath.IsAbs(dir) {
+		base, _ := os.Getwd()
+		dir = path.Join(base, dir)
+	}
+	err := os.MkdirAll(path.Join(dir, blobsDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	// leftovers of interrupted writes
+	_ = os.RemoveAll(path.Join(dir, blobsTmpDir))
+	err = os.MkdirAll(path.Join(dir, blobsTmpDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.ConnectDB(path.Join(dir, blobsDatabase))
+	if err != nil {
+		return nil, err
+	}
+	err = initBlobTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobfs{
+		store:  &blobStore{dir: dir, db: db},
+		prefix: blobRootPath,
+		config: config,
+	}, nil
+}
+
+// nodePath converts fs name to path of node in the whole store.
+func (b *blobfs) nodePath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(b.prefix, name), nil
+}
+
+func (s *blobStore) blobPath(hash string) string {
+	return path.Join(s.dir, blobsDir, hash[:2], hash)
+}
+
+func (s *blobStore) node(p string) (blobNode, error) {
+	if p == blobRootPath {
+		return blobNode{Path: blobRootPath, IsDir: true}, nil
+	}
+	var n blobNode
+	err := s.db.Select().
+		From(tableBlobNodes).
+		Where(dbx.HashExp{fieldNodePath: p}).
+		One(&n)
+	if err != nil {
+		return n, fs.ErrNotExist
+	}
+	return n, nil
+}
+
+func (s *blobStore) children(p string) ([]blobNode, error) {
+	var nodes []blobNode
+	err := s.db.Select().
+		From(tableBlobNodes).
+		Where(dbx.HashExp{fieldNodeParent: p}).
+		OrderBy(fieldNodePath).
+		All(&nodes)
+	return nodes, err
+}
+
+// parentDir checks that parent of p exists and is a directory.
+func (s *blobStore) parentDir(p string) error {
+	parent, err := s.node(path.Dir(p))
+	if err != nil {
+		return err
+	}
+	if !parent.IsDir {
+		return errors.New("not a directory")
+	}
+	return nil
+}
+
+type blobInfo struct {
+	n blobNode
+}
+
+func (i blobInfo) Name() string {
+	return path.Base(i.n.Path)
+}
+
+func (i blobInfo) Size() int64 {
+	return i.n.Size
+}
+
+func (i blobInfo) Mode() fs.FileMode {
+	if i.n.IsDir {
+		return blobDirMode
+	}
+	return blobFileMode
+}
+
+func (i blobInfo) ModTime() time.Time {
+	return time.Unix(0, i.n.Modified)
+}
+
+func (i blobInfo) IsDir() bool {
+	return i.n.IsDir
+}
+
+func (i blobInfo) Sys() any {
+	return nil
+}
+
+// blobFile reads blob content with node attributes.
+type blobFile struct {
+	*os.File
+	info blobInfo
+}
+
+func (f *blobFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// dirFile is directory listed in advance.
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+	return rest[:count], nil
+}
+
+func (b *blobfs) Open(name string) (fs.File, error) {
+	p, err := b.nodePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := b.store.node(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if n.IsDir {
+		entries, err := b.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: blobInfo{n}, entries: entries}, nil
+	}
+	f, err := os.Open(b.store.blobPath(n.Blob))
+	if err != nil {
+		return nil, err
+	}
+	return &blobFile{File: f, info: blobInfo{n}}, nil
+}
+
+func (b *blobfs) Stat(name string) (fs.FileInfo, error) {
+	p, err := b.nodePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := b.store.node(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return blobInfo{n}, nil
+}
+
+func (b *blobfs) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := b.nodePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := b.store.node(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !n.IsDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	nodes, err := b.store.children(p)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(nodes))
+	for _, c := range nodes {
+		entries = append(entries, fs.FileInfoToDirEntry(blobInfo{c}))
+	}
+	return entries, nil
+}
+
+func (b *blobfs) Sub(name string) (fs.FS, error) {
+	p, err := b.nodePath("sub", name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := b.store.node(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: name, Err: err}
+	}
+	if !n.IsDir {
+		return nil, &fs.PathError{Op: "sub", Path: name, Err: errors.New("not a directory")}
+	}
+	return &blobfs{store: b.store, prefix: p, config: b.config}, nil
+}
+
+// Create stages content in temporary file,
+// which is moved into blob storage on Close.
+func (b *blobfs) Create(name string) (FileWriter, error) {
+	p, err := b.nodePath("create", name)
+	if err != nil {
+		return nil, err
+	}
+	if p == blobRootPath {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	err = b.store.parentDir(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: err}
+	}
+
+	existing, err := b.store.node(p)
+	if err == nil {
+		if existing.IsDir {
+			return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("is a directory")}
+		}
+		b.config.Quota.release(existing.Size, 0)
+	} else {
+		err = b.config.Quota.reserve(0, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tmp, err := os.Create(path.Join(b.store.dir, blobsTmpDir, uuid.NewString()))
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFile(tmp, b.config.Quota)
+	if err != nil {
+		return nil, err
+	}
+	f.commit = func() error {
+		err := b.store.commit(p, tmp.Name())
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+		return err
+	}
+	return f, nil
+}
+
+// commit links staged file as blob and points node p to it.
+func (s *blobStore) commit(p string, staged string) error {
+	f, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.parentDir(p)
+	if err != nil {
+		return err
+	}
+
+	blob := s.blobPath(hash)
+	if _, err := os.Stat(blob); err == nil {
+		// already stored, deduplicated
+		_ = os.Remove(staged)
+	} else {
+		err = os.MkdirAll(path.Dir(blob), 0750)
+		if err != nil {
+			return err
+		}
+		err = os.Chmod(staged, blobReadOnlyMode)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(staged, blob)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.db.Transactional(func(tx *dbx.Tx) error {
+		var old blobNode
+		err := tx.Select().
+			From(tableBlobNodes).
+			Where(dbx.HashExp{fieldNodePath: p}).
+			One(&old)
+		exists := err == nil
+		if exists {
+			if old.IsDir {
+				return errors.New("is a directory")
+			}
+			err = addBlobRef(tx, old.Blob, -1)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.NewQuery("INSERT INTO " + tableBlobs +
+			" (" + fieldBlobHash + ", " + fieldBlobSize + ", " + fieldBlobRefs + ")" +
+			" VALUES ({:hash}, {:size}, 0) ON CONFLICT DO NOTHING").
+			Bind(dbx.Params{"hash": hash, "size": size}).
+			Execute()
+		if err != nil {
+			return err
+		}
+		err = addBlobRef(tx, hash, 1)
+		if err != nil {
+			return err
+		}
+
+		values := dbx.Params{
+			fieldNodeBlob:     hash,
+			fieldNodeSize:     size,
+			fieldNodeModified: time.Now().UnixNano(),
+		}
+		if exists {
+			_, err = tx.Update(tableBlobNodes, values,
+				dbx.HashExp{fieldNodePath: p}).Execute()
+			return err
+		}
+		values[fieldNodePath] = p
+		values[fieldNodeParent] = path.Dir(p)
+		values[fieldNodeIsDir] = false
+		_, err = tx.Insert(tableBlobNodes, values).Execute()
+		return err
+	})
+}
+
+func addBlobRef(tx *dbx.Tx, hash string, delta int) error {
+	_, err := tx.Update(tableBlobs,
+		dbx.Params{fieldBlobRefs: dbx.NewExp(fieldBlobRefs+" + {:delta}", dbx.Params{"delta": delta})},
+		dbx.HashExp{fieldBlobHash: hash}).Execute()
+	return err
+}
+
+func (b *blobfs) Mkdir(name string, perm fs.FileMode) error {
+	p, err := b.nodePath("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	if _, err := b.store.node(p); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	err = b.store.parentDir(p)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	_, err = b.store.db.Insert(tableBlobNodes, dbx.Params{
+		fieldNodePath:     p,
+		fieldNodeParent:   path.Dir(p),
+		fieldNodeIsDir:    true,
+		fieldNodeModified: time.Now().UnixNano(),
+	}).Execute()
+	return err
+}
+
+// Remove removes file or empty directory.
+// Blob itself stays until CollectGarbage.
+func (b *blobfs) Remove(name string) error {
+	p, err := b.nodePath("remove", name)
+	if err != nil {
+		return err
+	}
+	if p == b.prefix {
+		return errors.New("cannot remove root")
+	}
+
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	n, err := b.store.node(p)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if n.IsDir {
+		children, err := b.store.children(p)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+
+	err = b.store.db.Transactional(func(tx *dbx.Tx) error {
+		_, err := tx.Delete(tableBlobNodes, dbx.HashExp{fieldNodePath: p}).Execute()
+		if err != nil || n.IsDir {
+			return err
+		}
+		return addBlobRef(tx, n.Blob, -1)
+	})
+	if err != nil {
+		return err
+	}
+	if !n.IsDir {
+		b.config.Quota.release(n.Size, 1)
+	}
+	return nil
+}
+
+// Rename moves node with all its descendants.
+func (b *blobfs) Rename(oldname string, newname string) error {
+	oldPath, err := b.nodePath("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newPath, err := b.nodePath("rename", newname)
+	if err != nil {
+		return err
+	}
+	if oldPath == b.prefix || newPath == b.prefix {
+		return errors.New("cannot rename root")
+	}
+	if oldPath == newPath {
+		return nil
+	}
+
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+	n, err := b.store.node(oldPath)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	if n.IsDir && strings.HasPrefix(newPath, oldPath+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errors.New("cannot move directory into itself")}
+	}
+	err = b.store.parentDir(newPath)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	replaced, err := b.store.node(newPath)
+	exists := err == nil
+	if exists && (replaced.IsDir || n.IsDir) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+
+	err = b.store.db.Transactional(func(tx *dbx.Tx) error {
+		if exists {
+			_, err := tx.Delete(tableBlobNodes, dbx.HashExp{fieldNodePath: newPath}).Execute()
+			if err != nil {
+				return err
+			}
+			err = addBlobRef(tx, replaced.Blob, -1)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Update(tableBlobNodes, dbx.Params{
+			fieldNodePath:   newPath,
+			fieldNodeParent: path.Dir(newPath),
+		}, dbx.HashExp{fieldNodePath: oldPath}).Execute()
+		if err != nil || !n.IsDir {
+			return err
+		}
+
+		// descendants, prefix is compared by substr to avoid LIKE wildcards
+		_, err = tx.NewQuery("UPDATE " + tableBlobNodes + " SET " +
+			fieldNodePath + " = {:new} || substr(" + fieldNodePath + ", length({:old}) + 1), " +
+			fieldNodeParent + " = {:new} || substr(" + fieldNodeParent + ", length({:old}) + 1)" +
+			" WHERE substr(" + fieldNodePath + ", 1, length({:prefix})) = {:prefix}").
+			Bind(dbx.Params{"new": newPath, "old": oldPath, "prefix": oldPath + "/"}).
+			Execute()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if exists {
+		b.config.Quota.release(replaced.Size, 1)
+	}
+	return nil
+}
+
+// Root is empty, store dir holds blobs and tree
+// of all subs, not files under their names.
+func (b *blobfs) Root() string {
+	return ""
+}
+
+// CollectGarbage removes blobs not referenced by any node
+// and returns their count.
+func (b *blobfs) CollectGarbage() (int, error) {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	var hashes []string
+	err := b.store.db.Select(fieldBlobHash).
+		From(tableBlobs).
+		Where(dbx.NewExp(fieldBlobRefs + " <= 0")).
+		Column(&hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	for _, hash := range hashes {
+		err := os.Remove(b.store.blobPath(hash))
+		if err != nil && !os.IsNotExist(err) {
+			return cnt, err
+		}
+		_, err = b.store.db.Delete(tableBlobs, dbx.HashExp{fieldBlobHash: hash}).Execute()
+		if err != nil {
+			return cnt, err
+		}
+		cnt += 1
+	}
+	return cnt, nil
+}
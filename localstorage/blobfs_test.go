@@ -0,0 +1,151 @@
+package localstorage
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBlobStorage(t *testing.T) {
+	p, err := os.MkdirTemp("", "cardia*")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(p)
+
+	bfs, err := NewBlobFs(p, &Config{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, dir := range []string{"home1", "home1/artifacts", "home2"} {
+		err = bfs.Mkdir(dir, 0750)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	err = bfs.Mkdir("missing/dir", 0750)
+	if err == nil {
+		t.Error("should not create dir without parent")
+	}
+
+	content := []byte("same build artifact")
+	for _, name := range []string{"home1/artifacts/build.bin", "home2/build.bin"} {
+		f, err := bfs.Create(name)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = f.Write(content)
+		if err != nil {
+			t.Error(err)
+		}
+		err = f.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// identical content is stored once
+	blobs := 0
+	fs.WalkDir(os.DirFS(path.Join(p, blobsDir)), ".",
+		func(path string, d fs.DirEntry, err error) error {
+			if d.Type().IsRegular() {
+				blobs += 1
+			}
+			return nil
+		})
+	if blobs != 1 {
+		t.Error("blobs = ", blobs)
+	}
+
+	sfs, err := fs.Sub(bfs, "home1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	all, err := fs.ReadFile(sfs, "artifacts/build.bin")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(all, content) {
+		t.Error("wrong read")
+	}
+	info, err := fs.Stat(sfs, "artifacts/build.bin")
+	if err != nil {
+		t.Error(err)
+	} else if info.Name() != "build.bin" || info.Size() != int64(len(content)) {
+		t.Error("wrong stat: ", info.Name(), info.Size())
+	}
+	_, err = sfs.Open("../home2/build.bin")
+	if err == nil {
+		t.Error("should return 'invalid name' error")
+	}
+
+	cnt := 0
+	fs.WalkDir(bfs, ".",
+		func(path string, d fs.DirEntry, err error) error {
+			cnt += 1
+			return nil
+		})
+	if cnt != 6 {
+		t.Error("cnt = ", cnt)
+	}
+
+	// rename moves whole subtree
+	err = bfs.Rename("home1/artifacts", "home1/releases")
+	if err != nil {
+		t.Error(err)
+	}
+	f, err := bfs.Open("home1/releases/build.bin")
+	if err != nil {
+		t.Error(err)
+	} else {
+		all, _ = io.ReadAll(f)
+		f.Close()
+		if !bytes.Equal(all, content) {
+			t.Error("wrong read after rename")
+		}
+	}
+
+	err = bfs.Remove("home1/releases")
+	if err == nil {
+		t.Error("should not remove non-empty directory")
+	}
+	err = bfs.Remove("home1/releases/build.bin")
+	if err != nil {
+		t.Error(err)
+	}
+	collected, err := bfs.CollectGarbage()
+	if err != nil {
+		t.Error(err)
+	}
+	if collected != 0 {
+		t.Error("blob is still referenced, collected = ", collected)
+	}
+
+	// overwrite drops the last reference
+	f2, err := bfs.Create("home2/build.bin")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	f2.Write([]byte("new content"))
+	f2.Close()
+	collected, err = bfs.CollectGarbage()
+	if err != nil {
+		t.Error(err)
+	}
+	if collected != 1 {
+		t.Error("collected = ", collected)
+	}
+	all, err = fs.ReadFile(bfs, "home2/build.bin")
+	if err != nil || !bytes.Equal(all, []byte("new content")) {
+		t.Error("wrong read after overwrite: ", err)
+	}
+}
@@ -0,0 +1,422 @@
+package localstorage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type ChangeOp int
+
+const (
+	ChangeCreate ChangeOp = iota
+	ChangeModify
+	ChangeDelete
+	ChangeRename
+)
+
+// Change of file or directory inside feed root.
+// Root itself reported as modified means that changes could be
+// missed and clients should rescan.
+type Change struct {
+	Seq     uint64
+	Op      ChangeOp
+	Name    string // relative to feed root
+	OldName string // only for ChangeRename
+	IsDir   bool
+	Time    time.Time
+}
+
+var (
+	// ErrChangesExpired is returned when changes since requested
+	// sequence number are no longer retained, e.g. after restart.
+	ErrChangesExpired = errors.New("changes since requested sequence are not retained")
+	// ErrChangesLost is returned when subscriber does not keep up.
+	ErrChangesLost = errors.New("subscriber is too slow, changes are lost")
+)
+
+// echoWindow is how long inotify events are ignored for names changed
+// through fs, since those changes are already reported by fs itself.
+const echoWindow = 2 * time.Second
+
+const subscriptionBuffer = 256
+
+// ChangeFeed collects changes of trusted root, both made through fs
+// and, once Watch is called, external ones noticed by inotify.
+// Last changes are retained, so subscribers could resume
+// from a sequence number. Renames done outside of fs are reported
+// as deletion and creation, since inotify does not pair them.
+type ChangeFeed struct {
+	root  string
+	size  int
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	log     []Change // retained changes, oldest first
+	subs    map[*Subscription]struct{}
+	echoes  map[string]time.Time // names changed through fs
+	writing map[string]int       // names of files opened through fs
+	watcher *fsnotify.Watcher
+	// root is upper layer of overlay, see MapWhiteouts
+	whiteouts bool
+}
+
+// NewChangeFeed retains up to size last changes of dir.
+// Sequence numbers start over in a new epoch.
+func NewChangeFeed(dir string, size int) *ChangeFeed {
+	epoch := make([]byte, 8)
+	_, _ = rand.Read(epoch)
+	return &ChangeFeed{
+		root:    filepath.Clean(dir),
+		size:    size,
+		epoch:   hex.EncodeToString(epoch),
+		subs:    make(map[*Subscription]struct{}),
+		echoes:  make(map[string]time.Time),
+		writing: make(map[string]int),
+	}
+}
+
+func (f *ChangeFeed) rel(fullPath string) (string, bool) {
+	return relativeName(f.root, fullPath)
+}
+
+// relativeName returns name relative to root, reports whether
+// it is inside root and not reserved.
+func relativeName(root string, fullPath string) (string, bool) {
+	name, err := filepath.Rel(root, fullPath)
+	if err != nil || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	first, _, _ := strings.Cut(name, "/")
+	return name, first != reservedDir
+}
+
+// Inside reports whether name is prefix or inside it.
+func Inside(name string, prefix string) bool {
+	return prefix == "." || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// Subscription delivers changes matching prefix.
+// C is closed when subscription is closed or changes are lost.
+type Subscription struct {
+	C      <-chan Change
+	c      chan Change
+	prefix string
+	feed   *ChangeFeed
+	err    error
+}
+
+func (s *Subscription) send(c Change) {
+	if c.Name != "." && !Inside(c.Name, s.prefix) &&
+		(c.OldName == "" || !Inside(c.OldName, s.prefix)) {
+		return
+	}
+	select {
+	case s.c <- c:
+	default:
+		s.err = ErrChangesLost
+		s.close()
+	}
+}
+
+// close is called with feed locked.
+func (s *Subscription) close() {
+	if _, ok := s.feed.subs[s]; ok {
+		delete(s.feed.subs, s)
+		close(s.c)
+	}
+}
+
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.close()
+}
+
+// Err returns reason subscription was closed by feed.
+func (s *Subscription) Err() error {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	return s.err
+}
+
+// Subscribe returns changes inside prefix made after sequence number
+// since, 0 means only new changes.
+func (f *ChangeFeed) Subscribe(prefix string, since uint64) (*Subscription, error) {
+	return f.Resume(prefix, f.epoch, since)
+}
+
+// Resume returns changes inside prefix made after sequence number since
+// of epoch. Changes of another epoch, e.g. of feed before restart,
+// could not be resumed, so root rescan marker is sent first instead.
+func (f *ChangeFeed) Resume(prefix string, epoch string, since uint64) (*Subscription, error) {
+	prefix = filepath.Clean(prefix)
+	if prefix == "" || prefix == ".." || strings.HasPrefix(prefix, "../") || filepath.IsAbs(prefix) {
+		return nil, errors.New("invalid prefix")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var replay []Change
+	switch {
+	case since > 0 && epoch != f.epoch:
+		replay = []Change{{Seq: f.seq, Op: ChangeModify, Name: ".", IsDir: true, Time: time.Now()}}
+	case since > 0:
+		if since > f.seq || (since < f.seq && since+1 < f.log[0].Seq) {
+			return nil, ErrChangesExpired
+		}
+		replay = f.log[len(f.log)-int(f.seq-since):]
+	}
+	c := make(chan Change, len(replay)+subscriptionBuffer)
+	s := &Subscription{C: c, c: c, prefix: prefix, feed: f}
+	f.subs[s] = struct{}{}
+	for _, change := range replay {
+		s.send(change)
+	}
+	return s, nil
+}
+
+// Epoch identifies feed sequence numbers belong to.
+func (f *ChangeFeed) Epoch() string {
+	return f.epoch
+}
+
+// Seq returns sequence number of last change.
+func (f *ChangeFeed) Seq() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seq
+}
+
+// MapWhiteouts makes feed report whiteouts kept in root by overlay
+// as deletion of names they hide, other changes of them are dropped,
+// since they are not visible through overlay.
+func (f *ChangeFeed) MapWhiteouts() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.whiteouts = true
+}
+
+// mapWhiteout returns change as seen through overlay
+// and reports whether it is visible.
+func mapWhiteout(c Change) (Change, bool) {
+	if c.OldName != "" && reservedOverlayName(c.OldName) {
+		return c, false
+	}
+	if !reservedOverlayName(c.Name) {
+		return c, true
+	}
+	dir, base := path.Split(c.Name)
+	hidden, ok := strings.CutPrefix(base, whiteoutPrefix)
+	if !ok || reservedOverlayName(dir) || c.IsDir ||
+		(c.Op != ChangeCreate && c.Op != ChangeModify) {
+		// whiteout inside removed directory or one being dropped
+		// along with name it hides
+		return c, false
+	}
+	return Change{Op: ChangeDelete, Name: path.Join(dir, hidden)}, true
+}
+
+// append is called with feed locked.
+func (f *ChangeFeed) append(c Change) {
+	if f.whiteouts {
+		var ok bool
+		c, ok = mapWhiteout(c)
+		if !ok {
+			return
+		}
+	}
+	f.seq++
+	c.Seq = f.seq
+	c.Time = time.Now()
+	if len(f.log) >= f.size {
+		f.log = f.log[len(f.log)-f.size+1:]
+	}
+	f.log = append(f.log, c)
+	for s := range f.subs {
+		s.send(c)
+	}
+}
+
+// publish reports change made through fs.
+func (f *ChangeFeed) publish(op ChangeOp, fullPath string, oldPath string, isDir bool) {
+	if f == nil {
+		return
+	}
+	name, ok := f.rel(fullPath)
+	if !ok {
+		return
+	}
+	c := Change{Op: op, Name: name, IsDir: isDir}
+	if oldPath != "" {
+		c.OldName, _ = f.rel(oldPath)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for n, until := range f.echoes {
+		if now.After(until) {
+			delete(f.echoes, n)
+		}
+	}
+	f.echoes[name] = now.Add(echoWindow)
+	if c.OldName != "" {
+		f.echoes[c.OldName] = now.Add(echoWindow)
+	}
+	f.append(c)
+}
+
+// writeStarted mutes inotify events for file being written through fs
+// until writeDone.
+func (f *ChangeFeed) writeStarted(fullPath string) {
+	if f == nil {
+		return
+	}
+	name, ok := f.rel(fullPath)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writing[name]++
+}
+
+// writeDone publishes change of written file.
+func (f *ChangeFeed) writeDone(fullPath string, op ChangeOp) {
+	f.writeFailed(fullPath)
+	f.publish(op, fullPath, "", false)
+}
+
+func (f *ChangeFeed) writeFailed(fullPath string) {
+	if f == nil {
+		return
+	}
+	name, ok := f.rel(fullPath)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writing[name]--
+	if f.writing[name] <= 0 {
+		delete(f.writing, name)
+	}
+}
+
+// Watch starts reporting external changes noticed by inotify.
+func (f *ChangeFeed) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	err = f.addTree(w, f.root)
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	f.mu.Lock()
+	f.watcher = w
+	f.mu.Unlock()
+	go f.run(w)
+	return nil
+}
+
+// Close stops watching and closes all subscriptions.
+func (f *ChangeFeed) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s := range f.subs {
+		s.close()
+	}
+	if f.watcher == nil {
+		return nil
+	}
+	err := f.watcher.Close()
+	f.watcher = nil
+	return err
+}
+
+// addTree watches dir and its subdirectories, since inotify
+// is not recursive.
+func (f *ChangeFeed) addTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// removed meanwhile
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, ok := f.rel(p); !ok {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+func (f *ChangeFeed) run(w *fsnotify.Watcher) {
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			f.external(w, ev)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				f.mu.Lock()
+				f.append(Change{Op: ChangeModify, Name: ".", IsDir: true})
+				f.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (f *ChangeFeed) external(w *fsnotify.Watcher, ev fsnotify.Event) {
+	name, ok := f.rel(ev.Name)
+	if !ok || name == "." {
+		return
+	}
+	c := Change{Name: name}
+	switch {
+	case ev.Has(fsnotify.Create):
+		c.Op = ChangeCreate
+		info, err := os.Lstat(ev.Name)
+		if err == nil && info.IsDir() {
+			c.IsDir = true
+			_ = f.addTree(w, ev.Name)
+		}
+	case ev.Has(fsnotify.Write):
+		c.Op = ChangeModify
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		c.Op = ChangeDelete
+		// watch of moved directory would report old names
+		_ = w.Remove(ev.Name)
+	default:
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.writing[name] > 0 {
+		return
+	}
+	if until, ok := f.echoes[name]; ok && time.Now().Before(until) {
+		return
+	}
+	f.append(c)
+}
@@ -0,0 +1,139 @@
+package localstorage
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func nextChange(t *testing.T, s *Subscription) Change {
+	t.Helper()
+	select {
+	case c, ok := <-s.C:
+		if !ok {
+			t.Fatal("subscription is closed: ", s.Err())
+		}
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change")
+	}
+	return Change{}
+}
+
+func TestChanges(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	feed := NewChangeFeed(p, 100)
+	defer feed.Close()
+	err = feed.Watch()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Changes:   feed,
+	}).(WriteFS)
+
+	s, err := feed.Subscribe("subfolder1", 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Close()
+
+	f, err := lfs.Create("subfolder1/new.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("new"))
+	_ = f.Close()
+	c := nextChange(t, s)
+	if c.Op != ChangeCreate || c.Name != "subfolder1/new.txt" || c.Seq != 1 {
+		t.Error("wrong change: ", c)
+	}
+
+	// outside of prefix
+	err = lfs.Mkdir("subfolder2/skipped", 0750)
+	if err != nil {
+		t.Error(err)
+	}
+	err = lfs.Rename("subfolder1/new.txt", "subfolder1/dir11/moved.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	c = nextChange(t, s)
+	if c.Op != ChangeRename || c.Name != "subfolder1/dir11/moved.txt" ||
+		c.OldName != "subfolder1/new.txt" || c.Seq != 3 {
+		t.Error("wrong change: ", c)
+	}
+
+	// external change is noticed, also in watched subdirectory
+	err = os.WriteFile(path.Join(p, "subfolder1/dir12/external.txt"), nil, 0640)
+	if err != nil {
+		t.Error(err)
+	}
+	c = nextChange(t, s)
+	if c.Op != ChangeCreate || c.Name != "subfolder1/dir12/external.txt" {
+		t.Error("wrong change: ", c)
+	}
+	err = os.Mkdir(path.Join(p, "subfolder1/created"), 0750)
+	if err != nil {
+		t.Error(err)
+	}
+	c = nextChange(t, s)
+	if c.Op != ChangeCreate || !c.IsDir {
+		t.Error("wrong change: ", c)
+	}
+	err = os.WriteFile(path.Join(p, "subfolder1/created/inner.txt"), nil, 0640)
+	if err != nil {
+		t.Error(err)
+	}
+	c = nextChange(t, s)
+	if c.Name != "subfolder1/created/inner.txt" {
+		t.Error("new directory should be watched: ", c)
+	}
+
+	// resume from sequence number
+	resumed, err := feed.Subscribe(".", 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resumed.Close()
+	c = nextChange(t, resumed)
+	if c.Seq != 2 || c.Name != "subfolder2/skipped" {
+		t.Error("wrong change: ", c)
+	}
+	_, err = feed.Subscribe(".", feed.Seq()+1)
+	if !errors.Is(err, ErrChangesExpired) {
+		t.Error("sequence from the future should be expired: ", err)
+	}
+
+	// feed of restarted storage starts over in a new epoch
+	restarted := NewChangeFeed(p, 100)
+	defer restarted.Close()
+	if restarted.Epoch() == feed.Epoch() {
+		t.Error("epoch should be new")
+	}
+	resumed, err = restarted.Resume(".", feed.Epoch(), 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resumed.Close()
+	c = nextChange(t, resumed)
+	if c.Name != "." || c.Op != ChangeModify || c.Seq != restarted.Seq() {
+		t.Error("resume of another epoch should begin with rescan: ", c)
+	}
+}
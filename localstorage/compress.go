@@ -0,0 +1,483 @@
+package localstorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed file is zstd seekable stream: skippable frame marking
+// it as written by compression layer, frames of content compressed
+// independently and seek table at the end, so any frame could be
+// read without decompressing preceding ones.
+const (
+	compressFrameSize  = 256 * 1024
+	compressMaxPending = 64 // frames written out of order kept in memory
+	compressMarker     = "cardia.z"
+	markerFrameSize    = 8 + len(compressMarker)
+	markerFrameMagic   = 0x184D2A50
+	seekTableMagic     = 0x184D2A5E
+	seekableMagic      = 0x8F92EAB1
+	seekFooterSize     = 9
+	compressSizesMax   = 100000 // cached content sizes
+)
+
+// ErrNotSequential is returned when compressed file is written
+// before content which is already compressed.
+var ErrNotSequential = errors.New("compressed file could only be written sequentially")
+
+type CompressPolicy struct {
+	Level     int      // zstd level from 1 to 4, 0 for default
+	MinRatio  float64  // sample should shrink at least that many times, 0 for 1.2
+	SkipTypes []string // MIME types or prefixes ending with "/" stored as is, nil for defaults
+}
+
+var defaultSkipTypes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/zstd", "application/x-xz", "application/x-bzip2",
+	"application/x-7z-compressed", "application/vnd.rar", "application/pdf",
+}
+
+// skip reports whether name looks like already compressed file.
+func (p *CompressPolicy) skip(name string) bool {
+	t, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(name)), ";")
+	if t == "" {
+		return false
+	}
+	types := p.SkipTypes
+	if types == nil {
+		types = defaultSkipTypes
+	}
+	for _, s := range types {
+		if t == s || (strings.HasSuffix(s, "/") && strings.HasPrefix(t, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CompressPolicy) minRatio() float64 {
+	if p.MinRatio == 0 {
+		return 1.2
+	}
+	return p.MinRatio
+}
+
+// zstdDecoder is safe for concurrent DecodeAll.
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0),
+	zstd.WithDecoderMaxMemory(2*compressFrameSize))
+
+type seekEntry struct {
+	compressed uint32
+	size       uint32
+}
+
+// seekTable returns skippable frame with table of frames.
+func seekTable(frames []seekEntry) []byte {
+	b := make([]byte, 0, 8+len(frames)*8+seekFooterSize)
+	b = binary.LittleEndian.AppendUint32(b, seekTableMagic)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(frames)*8+seekFooterSize))
+	for _, f := range frames {
+		b = binary.LittleEndian.AppendUint32(b, f.compressed)
+		b = binary.LittleEndian.AppendUint32(b, f.size)
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(frames)))
+	b = append(b, 0) // no checksums
+	return binary.LittleEndian.AppendUint32(b, seekableMagic)
+}
+
+func markerFrame() []byte {
+	b := binary.LittleEndian.AppendUint32(nil, markerFrameMagic)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(compressMarker)))
+	return append(b, compressMarker...)
+}
+
+// readSeekTable returns frames of compressed file of size bytes,
+// ok is false for file stored as is.
+func readSeekTable(r io.ReaderAt, size int64) (frames []seekEntry, ok bool, err error) {
+	if size < int64(markerFrameSize+8+seekFooterSize) {
+		return nil, false, nil
+	}
+	marker := make([]byte, markerFrameSize)
+	_, err = r.ReadAt(marker, 0)
+	if err != nil || !bytes.Equal(marker, markerFrame()) {
+		return nil, false, err
+	}
+	footer := make([]byte, seekFooterSize)
+	_, err = r.ReadAt(footer, size-seekFooterSize)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, false, nil
+	}
+	n := int64(binary.LittleEndian.Uint32(footer))
+	tableSize := 8 + n*8 + seekFooterSize
+	if tableSize > size-int64(markerFrameSize) {
+		return nil, false, errors.New("seek table is damaged")
+	}
+	table := make([]byte, tableSize-seekFooterSize)
+	_, err = r.ReadAt(table, size-tableSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableMagic {
+		return nil, false, errors.New("seek table is damaged")
+	}
+	frames = make([]seekEntry, n)
+	for i := range frames {
+		e := table[8+i*8:]
+		frames[i] = seekEntry{
+			compressed: binary.LittleEndian.Uint32(e),
+			size:       binary.LittleEndian.Uint32(e[4:]),
+		}
+	}
+	return frames, true, nil
+}
+
+// compressedFile reads compressed content, the last
+// decompressed frame is kept.
+type compressedFile struct {
+	r       io.ReaderAt
+	offsets []int64 // of frames in file, the last one is end of frames
+	starts  []int64 // of frames in content, the last one is content size
+	index   int
+	buf     []byte
+}
+
+func newCompressedFile(r io.ReaderAt, frames []seekEntry) *compressedFile {
+	c := &compressedFile{r: r, index: -1}
+	var offset, start int64
+	for _, f := range frames {
+		c.offsets = append(c.offsets, offset)
+		c.starts = append(c.starts, start)
+		offset += int64(f.compressed)
+		start += int64(f.size)
+	}
+	c.offsets = append(c.offsets, offset)
+	c.starts = append(c.starts, start)
+	return c
+}
+
+func (c *compressedFile) Size() int64 {
+	return c.starts[len(c.starts)-1]
+}
+
+func (c *compressedFile) load(index int) error {
+	if index == c.index {
+		return nil
+	}
+	c.index = -1
+	b := make([]byte, c.offsets[index+1]-c.offsets[index])
+	_, err := c.r.ReadAt(b, c.offsets[index])
+	if err != nil && err != io.EOF {
+		return err
+	}
+	c.buf, err = zstdDecoder.DecodeAll(b, c.buf[:0])
+	if err != nil {
+		return err
+	}
+	if int64(len(c.buf)) != c.starts[index+1]-c.starts[index] {
+		return fmt.Errorf("frame %d is damaged", index)
+	}
+	c.index = index
+	return nil
+}
+
+func (c *compressedFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off+int64(n) < c.Size() {
+		pos := off + int64(n)
+		// the last frame containing pos, empty marker frame is skipped
+		index := sort.Search(len(c.starts), func(i int) bool { return c.starts[i] > pos }) - 1
+		err := c.load(index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], c.buf[pos-c.starts[index]:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// compressFile compresses content written to w frame by frame.
+// Frames are buffered until they are received completely, content
+// of the first one decides whether file is compressed at all.
+type compressFile struct {
+	layer    *compressLayer
+	w        *File
+	frames   []seekEntry
+	written  int64 // of compressed file
+	flushed  int64 // of content in written frames
+	pending  map[int64][]byte
+	received []ByteRange // of content after flushed
+	size     int64
+	plain    bool // written as is
+}
+
+func (c *compressFile) Size() int64 {
+	return c.size
+}
+
+func (c *compressFile) ReadAt(p []byte, off int64) (int, error) {
+	if c.plain {
+		return c.w.ReadAt(p, off)
+	}
+	return 0, errors.New("compressed file could not be read while written")
+}
+
+func (c *compressFile) WriteAt(p []byte, off int64) (int, error) {
+	if c.plain {
+		n, err := c.w.WriteAt(p, off)
+		c.size = max(c.size, off+int64(n))
+		return n, err
+	}
+	if off < c.flushed {
+		return 0, ErrNotSequential
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / compressFrameSize
+		buf, ok := c.pending[index]
+		if !ok {
+			if len(c.pending) >= compressMaxPending {
+				c.received = addRange(c.received, ByteRange{Offset: off, Length: int64(n)})
+				return n, ErrNotSequential
+			}
+			buf = make([]byte, 0, compressFrameSize)
+		}
+		start := int(pos - index*compressFrameSize)
+		end := min(start+len(p)-n, compressFrameSize)
+		if end > len(buf) {
+			buf = buf[:end]
+		}
+		n += copy(buf[start:end], p[n:])
+		c.pending[index] = buf
+		c.size = max(c.size, index*compressFrameSize+int64(len(buf)))
+	}
+	c.received = addRange(c.received, ByteRange{Offset: off, Length: int64(n)})
+	return n, c.flush(false)
+}
+
+// flush writes frames received completely, all of them if file is
+// closed. Content which was not received is zeros.
+func (c *compressFile) flush(closing bool) error {
+	for !c.plain && c.flushed < c.size {
+		if !closing && (len(c.received) == 0 || c.received[0].Offset > c.flushed ||
+			c.received[0].End() < c.flushed+compressFrameSize) {
+			return nil
+		}
+		index := c.flushed / compressFrameSize
+		buf := c.pending[index]
+		if buf == nil {
+			buf = make([]byte, 0, compressFrameSize)
+		}
+		buf = buf[:min(compressFrameSize, c.size-c.flushed)]
+		err := c.writeFrame(buf)
+		if err != nil {
+			return err
+		}
+		delete(c.pending, index)
+		if len(c.received) > 0 && c.received[0].End() <= c.flushed {
+			c.received = c.received[1:]
+		} else if len(c.received) > 0 && c.received[0].Offset < c.flushed {
+			c.received[0] = ByteRange{Offset: c.flushed, Length: c.received[0].End() - c.flushed}
+		}
+	}
+	return nil
+}
+
+func (c *compressFile) writeFrame(buf []byte) error {
+	compressed := c.layer.enc.EncodeAll(buf, nil)
+	if len(c.frames) == 0 {
+		overhead := markerFrameSize + 8 + 2*8 + seekFooterSize
+		if float64(len(buf)) < float64(len(compressed)+overhead)*c.layer.policy.minRatio() {
+			return c.writePlain()
+		}
+		marker := markerFrame()
+		_, err := c.w.WriteAt(marker, 0)
+		if err != nil {
+			return err
+		}
+		c.written = int64(len(marker))
+		c.frames = append(c.frames, seekEntry{compressed: uint32(len(marker))})
+	}
+	_, err := c.w.WriteAt(compressed, c.written)
+	if err != nil {
+		return err
+	}
+	c.written += int64(len(compressed))
+	c.frames = append(c.frames, seekEntry{compressed: uint32(len(compressed)), size: uint32(len(buf))})
+	c.flushed += int64(len(buf))
+	return nil
+}
+
+// writePlain stores incompressible content as is.
+func (c *compressFile) writePlain() error {
+	c.plain = true
+	for index, buf := range c.pending {
+		_, err := c.w.WriteAt(buf, index*compressFrameSize)
+		if err != nil {
+			return err
+		}
+	}
+	c.pending = nil
+	return c.w.Truncate(c.size)
+}
+
+func (c *compressFile) Truncate(size int64) error {
+	if c.plain {
+		err := c.w.Truncate(size)
+		if err == nil {
+			c.size = size
+		}
+		return err
+	}
+	if size < c.flushed {
+		return ErrNotSequential
+	}
+	if size > c.size {
+		// extension is zeros, as if it was written
+		c.received = addRange(c.received, ByteRange{Offset: c.size, Length: size - c.size})
+	}
+	for index, buf := range c.pending {
+		start := index * compressFrameSize
+		if start >= size {
+			delete(c.pending, index)
+		} else if start+int64(len(buf)) > size {
+			clear(buf[size-start:])
+			c.pending[index] = buf[:size-start]
+		}
+	}
+	received := c.received[:0]
+	for _, r := range c.received {
+		if r.Offset < size {
+			r.Length = min(r.End(), size) - r.Offset
+			received = append(received, r)
+		}
+	}
+	c.received = received
+	c.size = size
+	return c.flush(false)
+}
+
+func (c *compressFile) Close() error {
+	err := c.flush(true)
+	if err == nil && len(c.frames) > 0 {
+		_, err = c.w.WriteAt(seekTable(c.frames), c.written)
+	}
+	if cerr := c.w.Close(); err == nil {
+		err = cerr
+	}
+	c.layer.forget(c.w)
+	return err
+}
+
+type cachedSize struct {
+	size     int64
+	modified time.Time
+	content  int64
+}
+
+// compressLayer compresses content with zstd.
+type compressLayer struct {
+	policy *CompressPolicy
+	enc    *zstd.Encoder
+
+	mu    sync.Mutex
+	sizes map[string]cachedSize // by full path
+}
+
+// NewCompressFS compresses content of files written through wfs,
+// files which look already compressed are stored as is.
+func NewCompressFS(wfs WriteFS, policy *CompressPolicy) (WriteFS, error) {
+	level := zstd.SpeedDefault
+	if policy.Level != 0 {
+		level = zstd.EncoderLevel(policy.Level)
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return newLayerFS(wfs, &compressLayer{
+		policy: policy,
+		enc:    enc,
+		sizes:  make(map[string]cachedSize),
+	}), nil
+}
+
+func (l *compressLayer) open(r io.ReaderAt, size int64) (layerReaderAt, error) {
+	frames, ok, err := readSeekTable(r, size)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return newCompressedFile(r, frames), nil
+}
+
+// size reads seek table, sizes are cached since
+// underlying fs could read whole file to open it.
+func (l *compressLayer) size(wfs WriteFS, name string, info fs.FileInfo) (int64, error) {
+	key := path.Join(wfs.Root(), name)
+	l.mu.Lock()
+	cached, ok := l.sizes[key]
+	l.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modified.Equal(info.ModTime()) {
+		return cached.content, nil
+	}
+
+	f, err := wfs.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	ra, err := ReaderAt(f)
+	if err != nil {
+		return 0, err
+	}
+	content := info.Size()
+	frames, ok, err := readSeekTable(ra, info.Size())
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		content = newCompressedFile(ra, frames).Size()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.sizes) >= compressSizesMax {
+		l.sizes = make(map[string]cachedSize)
+	}
+	l.sizes[key] = cachedSize{size: info.Size(), modified: info.ModTime(), content: content}
+	return content, nil
+}
+
+// forget drops cached size of file written through layer.
+func (l *compressLayer) forget(f *File) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sizes, f.Name())
+}
+
+func (l *compressLayer) create(name string, f *File) (layer, error) {
+	if l.policy.skip(name) {
+		return nil, nil
+	}
+	return &compressFile{layer: l, w: f, pending: make(map[int64][]byte)}, nil
+}
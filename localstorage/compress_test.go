@@ -0,0 +1,110 @@
+package localstorage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024}).(WriteFS)
+	cfs, err := NewCompressFS(lfs, &CompressPolicy{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var log bytes.Buffer
+	for i := 0; log.Len() < 3*compressFrameSize; i++ {
+		fmt.Fprintf(&log, "2024-01-01 12:00:%02d INFO request %d served\n", i%60, i)
+	}
+	content := log.Bytes()
+
+	// frames out of order are buffered
+	f, err := cfs.Create("subfolder1/app.log")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.(*File).WriteAt(content[compressFrameSize:], compressFrameSize)
+	_, _ = f.(*File).WriteAt(content[:compressFrameSize], 0)
+	_, err = f.(*File).WriteAt([]byte("late"), 0)
+	if !errors.Is(err, ErrNotSequential) {
+		t.Error("compressed content should not be rewritten: ", err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	raw, _ := os.Stat(path.Join(p, "subfolder1/app.log"))
+	if raw.Size()*5 > int64(len(content)) {
+		t.Error("content is not compressed: ", raw.Size())
+	}
+	info, err := fs.Stat(cfs, "subfolder1/app.log")
+	if err != nil || info.Size() != int64(len(content)) {
+		t.Error("wrong size: ", info, err)
+	}
+	b, err := fs.ReadFile(cfs, "subfolder1/app.log")
+	if err != nil || !bytes.Equal(b, content) {
+		t.Error("wrong content: ", err)
+	}
+	r, _ := cfs.Open("subfolder1/app.log")
+	buf := make([]byte, 100)
+	_, err = r.(io.ReaderAt).ReadAt(buf, 2*compressFrameSize-50)
+	if err != nil || !bytes.Equal(buf, content[2*compressFrameSize-50:2*compressFrameSize+50]) {
+		t.Error("wrong range: ", err)
+	}
+	_ = r.Close()
+
+	// incompressible content and compressed types are stored as is
+	random := make([]byte, compressFrameSize+10)
+	rand.New(rand.NewSource(1)).Read(random)
+	for _, name := range []string{"subfolder2/random.bin", "subfolder2/photo.png"} {
+		data := random
+		if path.Ext(name) == ".png" {
+			data = content[:1000]
+		}
+		f, err = cfs.Create(name)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = f.Write(data)
+		_ = f.Close()
+		b, _ = os.ReadFile(path.Join(p, name))
+		if !bytes.Equal(b, data) {
+			t.Error(name, " should be stored as is")
+		}
+	}
+
+	// over encryption
+	master, _ := NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	keys, _ := OpenKeyRing(p, master)
+	cfs, _ = NewCompressFS(NewCryptFS(lfs, keys), &CompressPolicy{})
+	f, _ = cfs.Create("subfolder2/secret.log")
+	_, _ = f.Write(content)
+	_ = f.Close()
+	b, err = fs.ReadFile(cfs, "subfolder2/secret.log")
+	if err != nil || !bytes.Equal(b, content) {
+		t.Error("wrong content: ", err)
+	}
+	info, err = fs.Stat(cfs, "subfolder2/secret.log")
+	if err != nil || info.Size() != int64(len(content)) {
+		t.Error("wrong size: ", info, err)
+	}
+}
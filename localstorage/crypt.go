@@ -0,0 +1,400 @@
+package localstorage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted file starts with header naming data key and salt of
+// per-file key, followed by chunks sealed with AES-GCM, so content
+// could be read and written at random offsets. Every chunk has its
+// own random nonce and is bound to its index, the last one is marked
+// final, so file cut at chunk boundary is not taken as complete.
+// Empty file has one empty final chunk.
+const (
+	cryptMagic      = "CRD\x01"
+	cryptSaltSize   = 16
+	cryptHeaderSize = len(cryptMagic) + 4 + cryptSaltSize
+	cryptChunkSize  = 64 * 1024
+	cryptNonceSize  = 12
+	cryptOverhead   = cryptNonceSize + 16 // nonce and tag
+	cryptSealedSize = cryptChunkSize + cryptOverhead
+)
+
+// ErrDecrypt is returned when content is damaged or its key is unknown.
+var ErrDecrypt = errors.New("cannot decrypt file")
+
+// plainSize returns size of content sealed in file of size bytes.
+func plainSize(size int64) int64 {
+	body := size - int64(cryptHeaderSize)
+	if body <= 0 {
+		return 0
+	}
+	full, rest := body/cryptSealedSize, body%cryptSealedSize
+	return full*cryptChunkSize + max(rest-cryptOverhead, 0)
+}
+
+func chunkOffset(index int64) int64 {
+	return int64(cryptHeaderSize) + index*cryptSealedSize
+}
+
+// lastChunk returns index of final chunk of content of size bytes.
+func lastChunk(size int64) int64 {
+	return max(size-1, 0) / cryptChunkSize
+}
+
+// chunkAAD binds chunk to its index and position.
+func chunkAAD(index int64, final bool) []byte {
+	aad := make([]byte, 9)
+	binary.BigEndian.PutUint64(aad, uint64(index))
+	if final {
+		aad[8] = 1
+	}
+	return aad
+}
+
+func fileCipher(key []byte, salt []byte) (cipher.AEAD, error) {
+	fileKey := make([]byte, dataKeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("cardia file key")), fileKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cryptFile buffers one chunk of plain content at a time.
+type cryptFile struct {
+	aead  cipher.AEAD
+	r     io.ReaderAt // sealed content
+	w     *File       // sealed content, nil if read only
+	size  int64       // of plain content
+	index int64       // of buffered chunk, -1 if none
+	buf   []byte
+	dirty bool
+	// chunks sealed as final, there could be several
+	// while file is written, only the last one is left
+	// once it is closed
+	finals map[int64]bool
+}
+
+func (c *cryptFile) load(index int64) error {
+	if index == c.index {
+		return nil
+	}
+	err := c.flush()
+	if err != nil {
+		return err
+	}
+	if c.buf == nil {
+		c.buf = make([]byte, 0, cryptChunkSize)
+	}
+	c.buf = c.buf[:0]
+	c.index = -1
+	start := index * cryptChunkSize
+	if start < c.size || c.finals[index] {
+		sealed := make([]byte, max(min(cryptChunkSize, c.size-start), 0)+cryptOverhead)
+		n, err := c.r.ReadAt(sealed, chunkOffset(index))
+		if n < len(sealed) {
+			if err == nil || err == io.EOF {
+				err = fmt.Errorf("%w: chunk %d is cut", ErrDecrypt, index)
+			}
+			return err
+		}
+		aad := chunkAAD(index, c.finals[index])
+		c.buf, err = c.aead.Open(c.buf, sealed[:cryptNonceSize], sealed[cryptNonceSize:], aad)
+		if err != nil {
+			return fmt.Errorf("%w: chunk %d is damaged", ErrDecrypt, index)
+		}
+	}
+	c.index = index
+	return nil
+}
+
+func (c *cryptFile) flush() error {
+	if !c.dirty {
+		return nil
+	}
+	sealed := make([]byte, cryptNonceSize, len(c.buf)+cryptOverhead)
+	_, err := rand.Read(sealed)
+	if err != nil {
+		return err
+	}
+	final := c.index == lastChunk(c.size)
+	sealed = c.aead.Seal(sealed, sealed, c.buf, chunkAAD(c.index, final))
+	_, err = c.w.WriteAt(sealed, chunkOffset(c.index))
+	if err != nil {
+		return err
+	}
+	if final {
+		c.finals[c.index] = true
+	} else {
+		delete(c.finals, c.index)
+	}
+	c.dirty = false
+	return nil
+}
+
+// finish seals the last chunk as final and chunks sealed
+// as final before file grew as regular ones.
+func (c *cryptFile) finish() error {
+	last := lastChunk(c.size)
+	stale := []int64{last}
+	for index := range c.finals {
+		if index != last {
+			stale = append(stale, index)
+		}
+	}
+	for _, index := range stale {
+		if c.finals[index] == (index == last) {
+			continue
+		}
+		err := c.load(index)
+		if err != nil {
+			return err
+		}
+		c.dirty = true
+	}
+	return c.flush()
+}
+
+func (c *cryptFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off+int64(n) < c.size {
+		pos := off + int64(n)
+		index := pos / cryptChunkSize
+		err := c.load(index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], c.buf[pos-index*cryptChunkSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (c *cryptFile) WriteAt(p []byte, off int64) (int, error) {
+	if off > c.size {
+		// gap is filled with zeros, so all chunks but the last are full
+		zeros := make([]byte, min(cryptChunkSize, off-c.size))
+		for c.size < off {
+			_, err := c.WriteAt(zeros[:min(int64(len(zeros)), off-c.size)], c.size)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / cryptChunkSize
+		err := c.load(index)
+		if err != nil {
+			return n, err
+		}
+		start := int(pos - index*cryptChunkSize)
+		end := min(start+len(p)-n, cryptChunkSize)
+		if end > len(c.buf) {
+			c.buf = c.buf[:end]
+		}
+		n += copy(c.buf[start:end], p[n:])
+		c.dirty = true
+		c.size = max(c.size, index*cryptChunkSize+int64(len(c.buf)))
+	}
+	return n, nil
+}
+
+func (c *cryptFile) Truncate(size int64) error {
+	if size >= c.size {
+		_, err := c.WriteAt(nil, size)
+		return err
+	}
+	index, rest := size/cryptChunkSize, size%cryptChunkSize
+	if rest > 0 {
+		err := c.load(index)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := c.flush()
+		if err != nil {
+			return err
+		}
+		c.index = -1
+	}
+	err := c.w.Truncate(chunkOffset(index))
+	if err != nil {
+		return err
+	}
+	for i := range c.finals {
+		if i >= index {
+			delete(c.finals, i)
+		}
+	}
+	c.size = size
+	if rest > 0 {
+		// the last chunk is sealed again being shorter
+		c.buf = c.buf[:rest]
+		c.dirty = true
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *cryptFile) Size() int64 {
+	return c.size
+}
+
+func (c *cryptFile) Close() error {
+	err := c.finish()
+	if cerr := c.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readCryptHeader returns key id and salt of sealed file, ok is false for plain one.
+func readCryptHeader(r io.ReaderAt) (id uint32, salt []byte, ok bool, err error) {
+	hdr := make([]byte, cryptHeaderSize)
+	n, err := r.ReadAt(hdr, 0)
+	if n < len(hdr) {
+		if err == io.EOF {
+			err = nil
+		}
+		return 0, nil, false, err
+	}
+	if !bytes.HasPrefix(hdr, []byte(cryptMagic)) {
+		return 0, nil, false, nil
+	}
+	hdr = hdr[len(cryptMagic):]
+	return binary.BigEndian.Uint32(hdr), hdr[4:], true, nil
+}
+
+// cryptLayer encrypts content with keys of KeyRing.
+type cryptLayer struct {
+	keys *KeyRing
+}
+
+// NewCryptFS encrypts content of files written through wfs with keys
+// of KeyRing. Files written before encryption was enabled are read
+// as is and encrypted once rewritten. Staged uploads are XORed with
+// key stream derived from current key until committed.
+func NewCryptFS(wfs WriteFS, keys *KeyRing) WriteFS {
+	return newLayerFS(wfs, &cryptLayer{keys: keys})
+}
+
+func (l *cryptLayer) open(r io.ReaderAt, size int64) (layerReaderAt, error) {
+	id, salt, ok, err := readCryptHeader(r)
+	if err != nil || !ok {
+		return nil, err
+	}
+	key, err := l.keys.key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	plain := plainSize(size)
+	c := &cryptFile{aead: aead, r: r, size: plain, index: -1,
+		finals: map[int64]bool{lastChunk(plain): true}}
+	// cut files are rejected up front
+	err = c.load(lastChunk(plain))
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// size reads header of file, it is opened through wfs,
+// so names of snapshots are resolved as well.
+func (l *cryptLayer) size(wfs WriteFS, name string, info fs.FileInfo) (int64, error) {
+	f, err := wfs.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	ra, err := ReaderAt(f)
+	if err != nil {
+		return 0, err
+	}
+	_, _, ok, err := readCryptHeader(ra)
+	if err != nil || !ok {
+		return info.Size(), err
+	}
+	return plainSize(info.Size()), nil
+}
+
+// newSeal names current key and salt of key stream of staged upload.
+func (l *cryptLayer) newSeal() ([]byte, error) {
+	id, _ := l.keys.current()
+	seal := make([]byte, 4+cryptSaltSize)
+	binary.BigEndian.PutUint32(seal, id)
+	_, err := rand.Read(seal[4:])
+	return seal, err
+}
+
+// stream returns AES-CTR key stream of staged upload at offset,
+// its data is verified by content hash instead of tags.
+func (l *cryptLayer) stream(seal []byte, offset int64) (cipher.Stream, error) {
+	if len(seal) != 4+cryptSaltSize {
+		return nil, ErrDecrypt
+	}
+	key, err := l.keys.key(binary.BigEndian.Uint32(seal))
+	if err != nil {
+		return nil, err
+	}
+	streamKey := make([]byte, dataKeySize)
+	_, err = io.ReadFull(hkdf.New(sha256.New, key, seal[4:], []byte("cardia upload key")), streamKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[8:], uint64(offset/aes.BlockSize))
+	s := cipher.NewCTR(block, iv[:])
+	skip := make([]byte, offset%aes.BlockSize)
+	s.XORKeyStream(skip, skip)
+	return s, nil
+}
+
+// create seals content with current key.
+func (l *cryptLayer) create(name string, f *File) (layer, error) {
+	id, key := l.keys.current()
+	hdr := make([]byte, cryptHeaderSize)
+	copy(hdr, cryptMagic)
+	binary.BigEndian.PutUint32(hdr[len(cryptMagic):], id)
+	salt := hdr[len(cryptMagic)+4:]
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(hdr)
+	if err != nil {
+		return nil, err
+	}
+	return &cryptFile{aead: aead, r: f, w: f, index: -1, finals: make(map[int64]bool)}, nil
+}
@@ -0,0 +1,238 @@
+package localstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCrypt(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	master, _ := NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	keys, err := OpenKeyRing(p, master)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024}).(WriteFS)
+	cfs := NewCryptFS(lfs, keys)
+
+	content := make([]byte, 3*cryptChunkSize+100)
+	rand.New(rand.NewSource(1)).Read(content)
+	f, err := cfs.Create("subfolder1/secret.bin")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write(content[:1000])
+	_, _ = f.(*File).WriteAt(content[cryptChunkSize:], cryptChunkSize)
+	_, _ = f.(*File).WriteAt(content[1000:cryptChunkSize], 1000)
+	info, _ := f.Stat()
+	if info.Size() != int64(len(content)) {
+		t.Error("wrong size of open file: ", info.Size())
+	}
+	_ = f.Close()
+
+	raw, _ := os.ReadFile(path.Join(p, "subfolder1/secret.bin"))
+	if bytes.Contains(raw, content[:64]) {
+		t.Error("content is stored plain")
+	}
+	b, err := fs.ReadFile(cfs, "subfolder1/secret.bin")
+	if err != nil || !bytes.Equal(b, content) {
+		t.Error("wrong content: ", err)
+	}
+	info, err = fs.Stat(cfs, "subfolder1/secret.bin")
+	if err != nil || info.Size() != int64(len(content)) {
+		t.Error("wrong size: ", info, err)
+	}
+
+	// random access reads
+	r, _ := cfs.Open("subfolder1/secret.bin")
+	buf := make([]byte, 200)
+	_, err = r.(io.ReaderAt).ReadAt(buf, 2*cryptChunkSize-100)
+	if err != nil || !bytes.Equal(buf, content[2*cryptChunkSize-100:2*cryptChunkSize+100]) {
+		t.Error("wrong range: ", err)
+	}
+	_ = r.Close()
+
+	// files written before encryption are read as is
+	b, err = fs.ReadFile(cfs, "subfolder1/hello.txt")
+	if err != nil || string(b) != "hello" {
+		t.Error("plain file should be readable: ", string(b), err)
+	}
+
+	// rotated keys keep older files readable
+	_, err = keys.Rotate()
+	if err != nil {
+		t.Error(err)
+	}
+	f, _ = cfs.Create("subfolder2/new.txt")
+	_, _ = f.Write([]byte("new content"))
+	_ = f.(*File).Truncate(3)
+	_ = f.Close()
+	b, err = fs.ReadFile(cfs, "subfolder2/new.txt")
+	if err != nil || string(b) != "new" {
+		t.Error("wrong truncated content: ", string(b), err)
+	}
+
+	f, _ = cfs.Create("subfolder2/empty.txt")
+	_ = f.Close()
+	b, err = fs.ReadFile(cfs, "subfolder2/empty.txt")
+	if err != nil || len(b) != 0 {
+		t.Error("empty file should be readable: ", string(b), err)
+	}
+
+	// rewrapped keys are opened by new master key only
+	other, _ := NewPasswordKey("password", []byte("salt of user"))
+	err = keys.Rewrap(other)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = OpenKeyRing(p, master)
+	if err == nil {
+		t.Error("keys should not be unwrapped by old master key")
+	}
+	keys, err = OpenKeyRing(p, other)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cfs = NewCryptFS(lfs, keys)
+	b, err = fs.ReadFile(cfs, "subfolder1/secret.bin")
+	if err != nil || !bytes.Equal(b, content) {
+		t.Error("content of older key should be readable: ", err)
+	}
+
+	// damaged chunk, fs is opened again to skip its cache
+	raw[len(raw)-1] ^= 1
+	_ = os.WriteFile(path.Join(p, "subfolder1/secret.bin"), raw, 0640)
+	cfs = NewCryptFS(NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024}).(WriteFS), keys)
+	_, err = fs.ReadFile(cfs, "subfolder1/secret.bin")
+	if !errors.Is(err, ErrDecrypt) {
+		t.Error("damage should be detected: ", err)
+	}
+
+	// file cut at chunk boundary, or to its header
+	raw[len(raw)-1] ^= 1
+	for _, cut := range [][]byte{raw[:chunkOffset(2)], raw[:cryptHeaderSize]} {
+		_ = os.WriteFile(path.Join(p, "subfolder1/secret.bin"), cut, 0640)
+		cfs = NewCryptFS(NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024}).(WriteFS), keys)
+		_, err = fs.ReadFile(cfs, "subfolder1/secret.bin")
+		if !errors.Is(err, ErrDecrypt) {
+			t.Error("cut of ", len(cut), " bytes should be detected: ", err)
+		}
+	}
+}
+
+func TestCryptUpload(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	master, _ := NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	keys, err := OpenKeyRing(p, master)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	quota := NewQuota(0, 0)
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	used, _ := quota.Usage()
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024, Quota: quota}).(WriteFS)
+	cfs := NewCryptFS(lfs, keys)
+	ufs := cfs.(UploadFS)
+
+	content := bytes.Repeat([]byte("staged secret "), 100)
+	sum := sha256.Sum256(content)
+	upload := func() string {
+		s, err := ufs.CreateUpload(int64(len(content)), hex.EncodeToString(sum[:]))
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		_, _ = ufs.WriteUpload(s.ID, 700, content[700:])
+		_, err = ufs.WriteUpload(s.ID, 0, content[:700])
+		if err != nil {
+			t.Error(err)
+		}
+		return s.ID
+	}
+
+	// staged data is not kept plain
+	id := upload()
+	raw, _ := os.ReadFile(path.Join(p, reservedDir, uploadsDir, id, uploadDataFile))
+	if len(raw) != len(content) || bytes.Contains(raw, []byte("staged secret")) {
+		t.Error("staged data is stored plain")
+	}
+	r, err := ufs.OpenUpload(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	buf := make([]byte, 14)
+	_, err = r.(io.ReaderAt).ReadAt(buf, 707)
+	_ = r.Close()
+	if err != nil || !bytes.Equal(buf, content[707:721]) {
+		t.Error("wrong staged range: ", string(buf), err)
+	}
+	if _, err = NewLocalFs(p, &Config{}).(UploadFS).OpenUpload(id); err == nil {
+		t.Error("sealed upload should not be opened without layer")
+	}
+
+	// staged space is not counted twice, so commit fits into
+	// quota of a single copy
+	quota.SetLimits(used+int64(len(content))+200, 0)
+	err = ufs.CommitUpload(id, "subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	b, err := fs.ReadFile(cfs, "subfolder1/hello.txt")
+	if err != nil || !bytes.Equal(b, content) {
+		t.Error("wrong committed content: ", err)
+	}
+	if _, err = ufs.Upload(id); err == nil {
+		t.Error("committed session should be removed")
+	}
+
+	// failed commit keeps existing file and session
+	quota.SetLimits(0, 0)
+	id = upload()
+	staged, _ := quota.Usage()
+	quota.SetLimits(staged, 0)
+	err = ufs.CommitUpload(id, "subfolder2/goodbye.txt")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("commit should exceed quota: ", err)
+	}
+	b, _ = fs.ReadFile(cfs, "subfolder2/goodbye.txt")
+	if string(b) != "friend" {
+		t.Error("existing file should be kept: ", string(b))
+	}
+	if _, err = ufs.Upload(id); err != nil {
+		t.Error("session should be kept: ", err)
+	}
+	entries, _ := fs.ReadDir(cfs, "subfolder2")
+	if len(entries) != 2 {
+		t.Error("temporary file should be removed: ", entries)
+	}
+}
@@ -0,0 +1,218 @@
+package localstorage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math"
+)
+
+// Delta transfer works the way rsync does. Receiver holding an old
+// copy of file sends signature of its blocks, sender finds those blocks
+// in new content using rolling checksum and sends only what is missing.
+
+const (
+	MinBlockSize = 2 * 1024
+	MaxBlockSize = 128 * 1024
+)
+
+// BlockSize picks block size for file of size bytes,
+// balancing signature size against transferred data.
+func BlockSize(size int64) int {
+	bs := int(math.Sqrt(float64(size)))
+	bs = (bs + 1023) &^ 1023
+	return min(max(bs, MinBlockSize), MaxBlockSize)
+}
+
+type BlockSignature struct {
+	Weak   uint32
+	Strong [sha256.Size]byte
+	Size   int // last block could be short
+}
+
+type Signature struct {
+	BlockSize int
+	Blocks    []BlockSignature
+}
+
+// DeltaOp either copies block of old content or adds literal data.
+type DeltaOp struct {
+	Block int // index of block to copy, used if Data is empty
+	Data  []byte
+}
+
+// rollsum is weak checksum of rsync, it could be rolled
+// over content one byte at a time.
+type rollsum struct {
+	a, b uint32
+	n    uint32
+}
+
+func newRollsum(p []byte) rollsum {
+	var r rollsum
+	for i, c := range p {
+		r.a += uint32(c)
+		r.b += uint32(len(p)-i) * uint32(c)
+	}
+	r.n = uint32(len(p))
+	return r
+}
+
+func (r rollsum) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// roll removes out and adds in.
+func (r *rollsum) roll(out byte, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+// shrink removes out at the end of content.
+func (r *rollsum) shrink(out byte) {
+	r.a -= uint32(out)
+	r.b -= r.n * uint32(out)
+	r.n--
+}
+
+// Sign emits signatures of consecutive blocks of content.
+func Sign(r io.Reader, blockSize int, emit func(BlockSignature) error) error {
+	if blockSize <= 0 {
+		return errors.New("invalid block size")
+	}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			err := emit(BlockSignature{
+				Weak:   newRollsum(buf[:n]).sum(),
+				Strong: sha256.Sum256(buf[:n]),
+				Size:   n,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Delta emits operations turning content described by sig into content
+// of r. Literal data is emitted in pieces of up to maxLiteral bytes.
+func Delta(sig Signature, r io.Reader, maxLiteral int, emit func(DeltaOp) error) error {
+	bs := sig.BlockSize
+	if bs <= 0 {
+		return errors.New("invalid block size")
+	}
+	index := make(map[uint32][]int, len(sig.Blocks))
+	for i, b := range sig.Blocks {
+		index[b.Weak] = append(index[b.Weak], i)
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	// window is buf[start:end], moved to front when buf is exhausted
+	buf := make([]byte, 4*bs)
+	start, end := 0, 0
+	var literal []byte
+	flush := func() error {
+		if len(literal) == 0 {
+			return nil
+		}
+		err := emit(DeltaOp{Data: literal})
+		literal = nil
+		return err
+	}
+	fill := func() error {
+		start, end = 0, 0
+		n, err := io.ReadFull(br, buf[:bs])
+		end = n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	}
+	match := func(sum rollsum) int {
+		candidates := index[sum.sum()]
+		if len(candidates) == 0 {
+			return -1
+		}
+		strong := sha256.Sum256(buf[start:end])
+		for _, i := range candidates {
+			b := sig.Blocks[i]
+			if b.Size == end-start && bytes.Equal(b.Strong[:], strong[:]) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	err := fill()
+	if err != nil {
+		return err
+	}
+	sum := newRollsum(buf[start:end])
+	for end > start {
+		if i := match(sum); i >= 0 {
+			err = flush()
+			if err == nil {
+				err = emit(DeltaOp{Block: i})
+			}
+			if err == nil {
+				err = fill()
+			}
+			if err != nil {
+				return err
+			}
+			sum = newRollsum(buf[start:end])
+			continue
+		}
+
+		out := buf[start]
+		literal = append(literal, out)
+		if len(literal) >= maxLiteral {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+		in, err := br.ReadByte()
+		if err == io.EOF {
+			start++
+			sum.shrink(out)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if end == len(buf) {
+			copy(buf, buf[start:end])
+			end -= start
+			start = 0
+		}
+		buf[end] = in
+		start++
+		end++
+		sum.roll(out, in)
+	}
+	return flush()
+}
+
+// ApplyDelta writes result of op, blocks are read from old content.
+func ApplyDelta(old io.ReaderAt, blockSize int, op DeltaOp, w io.Writer) error {
+	if len(op.Data) > 0 {
+		_, err := w.Write(op.Data)
+		return err
+	}
+	if op.Block < 0 {
+		return errors.New("invalid block")
+	}
+	_, err := io.Copy(w, io.NewSectionReader(old, int64(op.Block)*int64(blockSize), int64(blockSize)))
+	return err
+}
@@ -0,0 +1,67 @@
+package localstorage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	old := make([]byte, 1000*1000+123)
+	rnd.Read(old)
+
+	// insertion, modification and truncated tail
+	modified := append([]byte{}, old[:300000]...)
+	modified = append(modified, []byte("inserted")...)
+	modified = append(modified, old[300000:600000]...)
+	modified = append(modified, bytes.Repeat([]byte{1}, 5000)...)
+	modified = append(modified, old[605000:999000]...)
+
+	bs := BlockSize(int64(len(old)))
+	if bs%1024 != 0 || bs < MinBlockSize || bs > MaxBlockSize {
+		t.Error("wrong block size: ", bs)
+	}
+	sig := Signature{BlockSize: bs}
+	err := Sign(bytes.NewReader(old), bs, func(b BlockSignature) error {
+		sig.Blocks = append(sig.Blocks, b)
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(sig.Blocks) != (len(old)+bs-1)/bs {
+		t.Error("wrong number of blocks: ", len(sig.Blocks))
+	}
+
+	var result bytes.Buffer
+	var literal int
+	err = Delta(sig, bytes.NewReader(modified), 4096, func(op DeltaOp) error {
+		if len(op.Data) > 4096 {
+			t.Error("literal is too long: ", len(op.Data))
+		}
+		literal += len(op.Data)
+		return ApplyDelta(bytes.NewReader(old), bs, op, &result)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(result.Bytes(), modified) {
+		t.Error("content does not match")
+	}
+	// changed blocks only, each change spoils at most two blocks
+	if literal > 8+5000+4*bs {
+		t.Error("too much literal data: ", literal)
+	}
+
+	// nothing in common
+	result.Reset()
+	err = Delta(Signature{BlockSize: bs}, bytes.NewReader(old[:100]), 4096, func(op DeltaOp) error {
+		return ApplyDelta(nil, bs, op, &result)
+	})
+	if err != nil || !bytes.Equal(result.Bytes(), old[:100]) {
+		t.Error("wrong delta of new file: ", err)
+	}
+}
@@ -0,0 +1,290 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// File is opened for writing through WriteFS.
+// It wraps os.File and accounts size growth against quota.
+// Content of layered file, e.g. encrypted one, goes through layer,
+// which writes transformed content to the underlying accounted File.
+type File struct {
+	*os.File
+	mu    sync.Mutex
+	quota *Quota
+	size  int64 // accounted size
+	hash  *writeHash
+
+	layer  layer
+	offset int64 // of layered file
+
+	// commit is called after successful close,
+	// e.g. to move staged content into place
+	commit func() error
+	// closed is called once file is closed anyway
+	closed func()
+}
+
+type layerReaderAt interface {
+	io.ReaderAt
+	Size() int64 // of content
+}
+
+// layer transforms content written through File.
+type layer interface {
+	layerReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+	Close() error
+}
+
+func newFile(f *os.File, quota *Quota) (*File, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &File{File: f, quota: quota, size: info.Size()}, nil
+}
+
+// grow reserves quota for write of n bytes at off.
+func (f *File) grow(off int64, n int) error {
+	end := off + int64(n)
+	if end <= f.size {
+		return nil
+	}
+	err := f.quota.reserve(end-f.size, 0)
+	if err != nil {
+		return err
+	}
+	f.size = end
+	return nil
+}
+
+// shrink gives back reservation not used by a short write.
+func (f *File) shrink(prev int64, off int64, written int) {
+	end := max(prev, off+int64(written))
+	if end < f.size {
+		f.quota.release(f.size-end, 0)
+		f.size = end
+	}
+}
+
+func (f *File) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer != nil {
+		n, err := f.layer.WriteAt(b, f.offset)
+		f.offset += int64(n)
+		return n, err
+	}
+	off, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	prev := f.size
+	err = f.grow(off, len(b))
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(b)
+	f.shrink(prev, off, n)
+	f.hash.write(off, b[:n])
+	return n, err
+}
+
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer != nil {
+		return f.layer.WriteAt(b, off)
+	}
+	prev := f.size
+	err := f.grow(off, len(b))
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.File.WriteAt(b, off)
+	f.shrink(prev, off, n)
+	f.hash.write(off, b[:n])
+	return n, err
+}
+
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// ReadFrom hides os.File implementation, which would bypass accounting.
+func (f *File) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{f}, r)
+}
+
+// WriteTo hides os.File implementation, which would bypass encryption.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, struct{ io.Reader }{f})
+}
+
+func (f *File) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer == nil {
+		return f.File.Read(b)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n, err := f.layer.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer == nil {
+		return f.File.ReadAt(b, off)
+	}
+	return f.layer.ReadAt(b, off)
+}
+
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer == nil {
+		return f.File.Seek(offset, whence)
+	}
+	var err error
+	f.offset, err = seekOffset(f.offset, f.layer.Size(), offset, whence)
+	return f.offset, err
+}
+
+func (f *File) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil || f.layer == nil {
+		return info, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return plainInfo{FileInfo: info, size: f.layer.Size()}, nil
+}
+
+func (f *File) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer != nil {
+		return f.layer.Truncate(size)
+	}
+	if size > f.size {
+		err := f.quota.reserve(size-f.size, 0)
+		if err != nil {
+			return err
+		}
+	}
+	err := f.File.Truncate(size)
+	if err != nil {
+		if size > f.size {
+			f.quota.release(size-f.size, 0)
+		}
+		return err
+	}
+	if size < f.size {
+		f.quota.release(f.size-size, 0)
+	}
+	f.size = size
+	f.hash.truncate(size)
+	return nil
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.layer != nil {
+		return f.layer.Close()
+	}
+	err := f.File.Close()
+	if f.closed != nil {
+		f.closed()
+	}
+	if err == nil && f.commit != nil {
+		err = f.commit()
+	}
+	f.commit = nil
+	return err
+}
+
+// seekOffset returns new offset of file of size bytes.
+func seekOffset(cur int64, size int64, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += cur
+	case io.SeekEnd:
+		offset += size
+	case io.SeekStart:
+	default:
+		return cur, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return cur, errors.New("negative offset")
+	}
+	return offset, nil
+}
+
+// plainInfo reports size of content stored by layer.
+type plainInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i plainInfo) Size() int64 {
+	return i.size
+}
+
+// layerReader reads content of layered file opened for reading.
+type layerReader struct {
+	fs.File
+	mu     sync.Mutex
+	r      layerReaderAt
+	offset int64
+}
+
+func (r *layerReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *layerReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.ReadAt(p, off)
+}
+
+func (r *layerReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	r.offset, err = seekOffset(r.offset, r.r.Size(), offset, whence)
+	return r.offset, err
+}
+
+func (r *layerReader) Stat() (fs.FileInfo, error) {
+	info, err := r.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return plainInfo{FileInfo: info, size: r.r.Size()}, nil
+}
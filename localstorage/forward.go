@@ -0,0 +1,289 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// Forward implements optional interfaces of file system wrapping
+// another one by passing calls to it unchanged, those it does not
+// implement return errors.ErrUnsupported. Wrappers embed it and
+// override methods which resolve names or check access.
+type Forward struct {
+	To WriteFS
+	// staged replaces upload sessions of To, e.g. sealed ones
+	staged UploadFS
+}
+
+var (
+	_ TrashFS    = Forward{}
+	_ VersionFS  = Forward{}
+	_ UploadFS   = Forward{}
+	_ SnapshotFS = Forward{}
+)
+
+func (f Forward) trash() (TrashFS, error) {
+	tfs, ok := f.To.(TrashFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return tfs, nil
+}
+
+func (f Forward) Trash(name string) (TrashItem, error) {
+	tfs, err := f.trash()
+	if err != nil {
+		return TrashItem{}, err
+	}
+	return tfs.Trash(name)
+}
+
+func (f Forward) ListTrash() ([]TrashItem, error) {
+	tfs, err := f.trash()
+	if err != nil {
+		return nil, err
+	}
+	return tfs.ListTrash()
+}
+
+func (f Forward) RestoreTrash(id string) (string, error) {
+	tfs, err := f.trash()
+	if err != nil {
+		return "", err
+	}
+	return tfs.RestoreTrash(id)
+}
+
+func (f Forward) EmptyTrash() (int, error) {
+	tfs, err := f.trash()
+	if err != nil {
+		return 0, err
+	}
+	return tfs.EmptyTrash()
+}
+
+func (f Forward) PurgeTrash() (int, error) {
+	tfs, err := f.trash()
+	if err != nil {
+		return 0, err
+	}
+	return tfs.PurgeTrash()
+}
+
+func (f Forward) versions() (VersionFS, error) {
+	vfs, ok := f.To.(VersionFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return vfs, nil
+}
+
+func (f Forward) Versions(name string) ([]Version, error) {
+	vfs, err := f.versions()
+	if err != nil {
+		return nil, err
+	}
+	return vfs.Versions(name)
+}
+
+func (f Forward) OpenVersion(name string, id string) (fs.File, error) {
+	vfs, err := f.versions()
+	if err != nil {
+		return nil, err
+	}
+	return vfs.OpenVersion(name, id)
+}
+
+func (f Forward) RestoreVersion(name string, id string) error {
+	vfs, err := f.versions()
+	if err != nil {
+		return err
+	}
+	return vfs.RestoreVersion(name, id)
+}
+
+func (f Forward) PruneVersions() (int, error) {
+	vfs, err := f.versions()
+	if err != nil {
+		return 0, err
+	}
+	return vfs.PruneVersions()
+}
+
+func (f Forward) uploads() (UploadFS, error) {
+	if f.staged != nil {
+		return f.staged, nil
+	}
+	ufs, ok := f.To.(UploadFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return ufs, nil
+}
+
+func (f Forward) CreateUpload(size int64, hash string) (UploadSession, error) {
+	ufs, err := f.uploads()
+	if err != nil {
+		return UploadSession{}, err
+	}
+	return ufs.CreateUpload(size, hash)
+}
+
+func (f Forward) WriteUpload(id string, offset int64, data []byte) (UploadSession, error) {
+	ufs, err := f.uploads()
+	if err != nil {
+		return UploadSession{}, err
+	}
+	return ufs.WriteUpload(id, offset, data)
+}
+
+func (f Forward) Upload(id string) (UploadSession, error) {
+	ufs, err := f.uploads()
+	if err != nil {
+		return UploadSession{}, err
+	}
+	return ufs.Upload(id)
+}
+
+func (f Forward) CommitUpload(id string, name string) error {
+	ufs, err := f.uploads()
+	if err != nil {
+		return err
+	}
+	return ufs.CommitUpload(id, name)
+}
+
+// commitWith passes staged data of session to write,
+// so layers above copy it through themselves.
+func (f Forward) commitWith(id string, write func(staged io.Reader) error) error {
+	ufs, err := f.uploads()
+	if err != nil {
+		return err
+	}
+	sfs, ok := ufs.(stagedUploads)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return sfs.commitWith(id, write)
+}
+
+func (f Forward) OpenUpload(id string) (fs.File, error) {
+	ufs, err := f.uploads()
+	if err != nil {
+		return nil, err
+	}
+	return ufs.OpenUpload(id)
+}
+
+func (f Forward) AbortUpload(id string) error {
+	ufs, err := f.uploads()
+	if err != nil {
+		return err
+	}
+	return ufs.AbortUpload(id)
+}
+
+func (f Forward) ExpireUploads() (int, error) {
+	ufs, err := f.uploads()
+	if err != nil {
+		return 0, err
+	}
+	return ufs.ExpireUploads()
+}
+
+func (f Forward) snapshots() (SnapshotFS, error) {
+	sfs, ok := f.To.(SnapshotFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return sfs, nil
+}
+
+func (f Forward) Snapshot(label string) (Snapshot, error) {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return sfs.Snapshot(label)
+}
+
+func (f Forward) ListSnapshots() ([]Snapshot, error) {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	return sfs.ListSnapshots()
+}
+
+func (f Forward) RestoreSnapshot(id string, name string) (string, error) {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return "", err
+	}
+	return sfs.RestoreSnapshot(id, name)
+}
+
+func (f Forward) DeleteSnapshot(id string) error {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return err
+	}
+	return sfs.DeleteSnapshot(id)
+}
+
+func (f Forward) ScheduleSnapshot() (bool, error) {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return false, err
+	}
+	return sfs.ScheduleSnapshot()
+}
+
+func (f Forward) PruneSnapshots() (int, error) {
+	sfs, err := f.snapshots()
+	if err != nil {
+		return 0, err
+	}
+	return sfs.PruneSnapshots()
+}
+
+func (f Forward) Hash(name string) (FileHash, error) {
+	hfs, ok := f.To.(HashFS)
+	if !ok {
+		return FileHash{}, errors.ErrUnsupported
+	}
+	return hfs.Hash(name)
+}
+
+func (f Forward) Scrub(dir string, report func(ScrubIssue) error) (int, error) {
+	hfs, ok := f.To.(HashFS)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return hfs.Scrub(dir, report)
+}
+
+func (f Forward) CreateNew(name string) (FileWriter, error) {
+	efs, ok := f.To.(ExclusiveFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return efs.CreateNew(name)
+}
+
+func (f Forward) Metadata(name string) (map[string]string, error) {
+	mfs, ok := f.To.(MetadataFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return mfs.Metadata(name)
+}
+
+func (f Forward) SetMetadata(name string, metadata map[string]string) error {
+	mfs, ok := f.To.(MetadataFS)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return mfs.SetMetadata(name, metadata)
+}
@@ -0,0 +1,458 @@
+package localstorage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+	"lukechampine.com/blake3"
+)
+
+// HashFS is WriteFS keeping checksums of files.
+type HashFS interface {
+	WriteFS
+	// Hash returns checksums of file, they are computed
+	// if file was changed bypassing fs.
+	Hash(name string) (FileHash, error)
+	// Scrub re-hashes files inside dir, reports problems
+	// and returns number of checked files.
+	Scrub(dir string, report func(ScrubIssue) error) (int, error)
+}
+
+type FileHash struct {
+	SHA256   string
+	BLAKE3   string // empty unless enabled
+	Size     int64
+	Modified time.Time
+}
+
+type ScrubProblem int
+
+const (
+	// ScrubCorrupted is content changed while size and
+	// modification time were not, e.g. bit rot.
+	ScrubCorrupted ScrubProblem = iota
+	// ScrubModified is file changed bypassing fs.
+	ScrubModified
+	// ScrubMissing is file removed bypassing fs.
+	ScrubMissing
+)
+
+type ScrubIssue struct {
+	Name     string
+	Problem  ScrubProblem
+	Expected FileHash
+	Actual   FileHash // zero for missing files
+}
+
+// HashStore keeps checksums of files under trusted root
+// in database inside reserved directory.
+type HashStore struct {
+	root   string
+	db     *dbx.DB
+	blake3 bool
+}
+
+type fileHash struct {
+	Name     string `db:"name"`
+	Size     int64  `db:"size"`
+	Modified int64  `db:"modified"` // unix nano
+	SHA256   string `db:"sha256"`
+	BLAKE3   string `db:"blake3"`
+}
+
+func (h fileHash) Export() FileHash {
+	return FileHash{
+		SHA256:   h.SHA256,
+		BLAKE3:   h.BLAKE3,
+		Size:     h.Size,
+		Modified: time.Unix(0, h.Modified),
+	}
+}
+
+const (
+	hashesDatabase    = "hashes.db"
+	tableHashes       = "hashes"
+	fieldHashName     = "name"
+	fieldHashSize     = "size"
+	fieldHashModified = "modified"
+	fieldHashSHA256   = "sha256"
+	fieldHashBLAKE3   = "blake3"
+)
+
+func initHashesTable(db *dbx.DB) error {
+	exists, err := database.TableExists(db, tableHashes)
+	if err != nil || exists {
+		return err
+	}
+	hashes := make(map[string]string)
+	hashes[fieldHashName] = "TEXT PRIMARY KEY NOT NULL"
+	hashes[fieldHashSize] = "INTEGER NOT NULL"
+	hashes[fieldHashModified] = "INTEGER NOT NULL"
+	hashes[fieldHashSHA256] = "TEXT NOT NULL"
+	hashes[fieldHashBLAKE3] = "TEXT DEFAULT '' NOT NULL"
+	_, err = db.CreateTable(tableHashes, hashes).Execute()
+	return err
+}
+
+// NewHashStore opens checksums of dir, BLAKE3 is computed
+// along with SHA-256 if withBLAKE3 is set.
+func NewHashStore(dir string, withBLAKE3 bool) (*HashStore, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(path.Join(dir, reservedDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.ConnectDB(path.Join(dir, reservedDir, hashesDatabase))
+	if err != nil {
+		return nil, err
+	}
+	err = initHashesTable(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &HashStore{root: dir, db: db, blake3: withBLAKE3}, nil
+}
+
+func (s *HashStore) Close() error {
+	return s.db.Close()
+}
+
+// hasher computes all checksums of store at once.
+type hasher struct {
+	sha256 hash.Hash
+	blake3 hash.Hash
+	w      io.Writer
+}
+
+func (s *HashStore) newHasher() *hasher {
+	h := &hasher{sha256: sha256.New()}
+	h.w = h.sha256
+	if s.blake3 {
+		h.blake3 = blake3.New(32, nil)
+		h.w = io.MultiWriter(h.sha256, h.blake3)
+	}
+	return h
+}
+
+func (h *hasher) Write(p []byte) (int, error) {
+	return h.w.Write(p)
+}
+
+func (h *hasher) sum(info fs.FileInfo) fileHash {
+	r := fileHash{
+		Size:     info.Size(),
+		Modified: info.ModTime().UnixNano(),
+		SHA256:   hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+	if h.blake3 != nil {
+		r.BLAKE3 = hex.EncodeToString(h.blake3.Sum(nil))
+	}
+	return r
+}
+
+// writeHash follows sequential writes of file, so checksums are known
+// when it is closed without reading it again.
+type writeHash struct {
+	h      *hasher
+	offset int64
+	broken bool // written out of order
+}
+
+func (w *writeHash) write(off int64, p []byte) {
+	if w == nil || w.broken {
+		return
+	}
+	if off != w.offset {
+		w.broken = true
+		return
+	}
+	_, _ = w.h.Write(p)
+	w.offset += int64(len(p))
+}
+
+func (w *writeHash) truncate(size int64) {
+	if w != nil && size != w.offset {
+		w.broken = true
+	}
+}
+
+func (s *HashStore) newWriteHash() *writeHash {
+	if s == nil {
+		return nil
+	}
+	return &writeHash{h: s.newHasher()}
+}
+
+func (s *HashStore) get(name string) (fileHash, error) {
+	var h fileHash
+	err := s.db.Select(fieldHashName, fieldHashSize, fieldHashModified,
+		fieldHashSHA256, fieldHashBLAKE3).
+		From(tableHashes).
+		Where(dbx.HashExp{fieldHashName: name}).
+		One(&h)
+	return h, err
+}
+
+func (s *HashStore) put(h fileHash) error {
+	_, err := s.db.NewQuery("INSERT OR REPLACE INTO " + tableHashes +
+		" (name, size, modified, sha256, blake3)" +
+		" VALUES ({:name}, {:size}, {:modified}, {:sha256}, {:blake3})").
+		Bind(dbx.Params{
+			"name":     h.Name,
+			"size":     h.Size,
+			"modified": h.Modified,
+			"sha256":   h.SHA256,
+			"blake3":   h.BLAKE3,
+		}).Execute()
+	return err
+}
+
+// under matches name itself and everything inside it.
+func under(name string) dbx.Expression {
+	if name == "." {
+		return dbx.NewExp("1=1")
+	}
+	return dbx.Or(
+		dbx.HashExp{fieldHashName: name},
+		dbx.Like(fieldHashName, name+"/").Match(false, true),
+	)
+}
+
+// written stores checksums of file written through fs, out of order
+// writes make them unknown until file is hashed again.
+func (s *HashStore) written(fullPath string, w *writeHash) {
+	if s == nil {
+		return
+	}
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil || w.broken || w.offset != info.Size() {
+		s.forget(fullPath)
+		return
+	}
+	h := w.h.sum(info)
+	h.Name = name
+	_ = s.put(h)
+}
+
+// forget drops checksums of file or directory.
+func (s *HashStore) forget(fullPath string) {
+	if s == nil {
+		return
+	}
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return
+	}
+	_, _ = s.db.Delete(tableHashes, under(name)).Execute()
+}
+
+// refresh hashes file put in place without writing it through fs.
+func (s *HashStore) refresh(fullPath string) {
+	if s == nil {
+		return
+	}
+	s.forget(fullPath)
+	info, err := os.Lstat(fullPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	_, _ = s.current(fullPath)
+}
+
+// renamed moves checksums of file or directory.
+func (s *HashStore) renamed(oldPath string, newPath string) {
+	if s == nil {
+		return
+	}
+	oldName, ok := relativeName(s.root, oldPath)
+	newName, ok2 := relativeName(s.root, newPath)
+	if !ok || !ok2 {
+		return
+	}
+	_, _ = s.db.Delete(tableHashes, under(newName)).Execute()
+	_, _ = s.db.NewQuery("UPDATE " + tableHashes +
+		" SET name = {:new} || substr(name, length({:old}) + 1)" +
+		" WHERE name = {:old} OR substr(name, 1, length({:old}) + 1) = {:old} || '/'").
+		Bind(dbx.Params{
+			"new": newName,
+			"old": oldName,
+		}).Execute()
+}
+
+// compute reads file and returns its checksums.
+func (s *HashStore) compute(fullPath string) (fileHash, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return fileHash{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fileHash{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return fileHash{}, errors.New("not a regular file")
+	}
+	h := s.newHasher()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return fileHash{}, err
+	}
+	return h.sum(info), nil
+}
+
+// current returns checksums of file, computing them if stored
+// ones are missing or outdated.
+func (s *HashStore) current(fullPath string) (fileHash, error) {
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return fileHash{}, errors.New("path is outside of hash store")
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fileHash{}, err
+	}
+	stored, err := s.get(name)
+	if err == nil && stored.Size == info.Size() && stored.Modified == info.ModTime().UnixNano() &&
+		(!s.blake3 || stored.BLAKE3 != "") {
+		return stored, nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fileHash{}, err
+	}
+	h, err := s.compute(fullPath)
+	if err != nil {
+		return h, err
+	}
+	h.Name = name
+	return h, s.put(h)
+}
+
+// scrub checks files inside dir against stored checksums.
+func (s *HashStore) scrub(dir string, report func(ScrubIssue) error) (int, error) {
+	prefix, ok := relativeName(s.root, dir)
+	if !ok {
+		return 0, errors.New("path is outside of hash store")
+	}
+	seen := make(map[string]bool)
+	checked := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, ok := relativeName(s.root, p)
+		if !ok {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		seen[name] = true
+		checked++
+
+		actual, err := s.compute(p)
+		if err != nil {
+			return err
+		}
+		actual.Name = name
+		stored, err := s.get(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.put(actual)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case stored.Size != actual.Size || stored.Modified != actual.Modified:
+			err = report(ScrubIssue{Name: name, Problem: ScrubModified,
+				Expected: stored.Export(), Actual: actual.Export()})
+			if err == nil {
+				err = s.put(actual)
+			}
+			return err
+		case stored.SHA256 != actual.SHA256 ||
+			(stored.BLAKE3 != "" && actual.BLAKE3 != "" && stored.BLAKE3 != actual.BLAKE3):
+			// stored checksums are kept, so it is reported until repaired
+			return report(ScrubIssue{Name: name, Problem: ScrubCorrupted,
+				Expected: stored.Export(), Actual: actual.Export()})
+		case stored.BLAKE3 == "" && actual.BLAKE3 != "":
+			return s.put(actual)
+		}
+		return nil
+	})
+	if err != nil {
+		return checked, err
+	}
+
+	var stored []fileHash
+	err = s.db.Select(fieldHashName, fieldHashSize, fieldHashModified,
+		fieldHashSHA256, fieldHashBLAKE3).
+		From(tableHashes).
+		Where(under(prefix)).
+		All(&stored)
+	if err != nil {
+		return checked, err
+	}
+	for _, h := range stored {
+		if seen[h.Name] {
+			continue
+		}
+		err = report(ScrubIssue{Name: h.Name, Problem: ScrubMissing, Expected: h.Export()})
+		if err != nil {
+			return checked, err
+		}
+		_, err = s.db.Delete(tableHashes, dbx.HashExp{fieldHashName: h.Name}).Execute()
+		if err != nil {
+			return checked, err
+		}
+	}
+	return checked, nil
+}
+
+// Hash returns checksums of file, they are not stored
+// if fs is not configured with HashStore.
+func (t *localfs) Hash(name string) (FileHash, error) {
+	if _, ok := snapshotName(name); ok {
+		// files of snapshots are not tracked, callers hash content
+		return FileHash{}, errors.ErrUnsupported
+	}
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return FileHash{}, err
+	}
+	if t.config.Hashes == nil {
+		h, err := (&HashStore{}).compute(fullPath)
+		return h.Export(), err
+	}
+	h, err := t.config.Hashes.current(fullPath)
+	return h.Export(), err
+}
+
+func (t *localfs) Scrub(dir string, report func(ScrubIssue) error) (int, error) {
+	fullPath := path.Join(t.trustedRoot, dir)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return 0, err
+	}
+	if t.config.Hashes == nil {
+		return 0, errors.ErrUnsupported
+	}
+	return t.config.Hashes.scrub(fullPath, report)
+}
@@ -0,0 +1,110 @@
+package localstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashes(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	hashes, err := NewHashStore(p, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer hashes.Close()
+	hfs := NewLocalFs(p, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Hashes:    hashes,
+	}).(HashFS)
+
+	f, err := hfs.Create("subfolder1/new.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("new "))
+	_, _ = f.Write([]byte("content"))
+	_ = f.Close()
+	stored, err := hashes.get("subfolder1/new.txt")
+	if err != nil || stored.SHA256 != sha256Hex([]byte("new content")) || stored.BLAKE3 == "" {
+		t.Error("hash should be stored on write: ", stored, err)
+	}
+
+	// files existing before are hashed on demand
+	h, err := hfs.Hash("subfolder1/hello.txt")
+	if err != nil || h.SHA256 != sha256Hex([]byte("hello")) || h.Size != 5 {
+		t.Error("wrong hash: ", h, err)
+	}
+
+	err = hfs.Rename("subfolder1", "moved")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = hashes.get("moved/new.txt")
+	if err != nil {
+		t.Error("hash should follow rename: ", err)
+	}
+
+	// out of band modification keeps mtime to pose as bit rot
+	full := path.Join(p, "moved/hello.txt")
+	info, _ := os.Stat(full)
+	_ = os.WriteFile(full, []byte("jello"), 0640)
+	_ = os.Chtimes(full, info.ModTime(), info.ModTime())
+	_ = os.WriteFile(path.Join(p, "moved/new.txt"), []byte("changed"), 0640)
+	_ = os.Chtimes(path.Join(p, "moved/new.txt"), time.Now(), time.Now().Add(time.Hour))
+	_ = os.Remove(path.Join(p, "moved/dir12/friend.txt"))
+	_, _ = hfs.Hash("subfolder2/goodbye.txt")
+
+	problems := make(map[string]ScrubProblem)
+	checked, err := hfs.Scrub("moved", func(issue ScrubIssue) error {
+		problems[issue.Name] = issue.Problem
+		return nil
+	})
+	if err != nil || checked != 2 {
+		t.Error("checked = ", checked, "; err = ", err)
+	}
+	if len(problems) != 2 ||
+		problems["moved/hello.txt"] != ScrubCorrupted ||
+		problems["moved/new.txt"] != ScrubModified {
+		t.Error("wrong problems: ", problems)
+	}
+
+	// friend.txt was not hashed yet, so it is not missing
+	_ = os.Remove(path.Join(p, "subfolder2/goodbye.txt"))
+	problems = make(map[string]ScrubProblem)
+	_, err = hfs.Scrub(".", func(issue ScrubIssue) error {
+		problems[issue.Name] = issue.Problem
+		return nil
+	})
+	if err != nil || len(problems) != 2 ||
+		problems["subfolder2/goodbye.txt"] != ScrubMissing ||
+		problems["moved/hello.txt"] != ScrubCorrupted {
+		t.Error("wrong problems: ", problems, err)
+	}
+
+	err = hfs.Remove("moved/new.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = hashes.get("moved/new.txt")
+	if err == nil {
+		t.Error("hash should be removed along with file")
+	}
+}
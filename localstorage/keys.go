@@ -0,0 +1,232 @@
+package localstorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	keysFile    = "keys.json"
+	dataKeySize = 32
+)
+
+// KeyWrapper protects data keys stored along with files.
+type KeyWrapper interface {
+	Wrap(key []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// KeyWrappers resolves wrapper of data keys of each user's home,
+// e.g. one derived from password user has just logged in with.
+type KeyWrappers interface {
+	// KeyWrapper returns nil if home is wrapped with server key.
+	KeyWrapper(username string) (KeyWrapper, error)
+}
+
+type aeadWrapper struct {
+	aead cipher.AEAD
+}
+
+// NewMasterKey wraps data keys with 32 bytes server key.
+func NewMasterKey(key []byte) (KeyWrapper, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("master key should be %d bytes", dataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadWrapper{aead: aead}, nil
+}
+
+// NewPasswordKey wraps data keys with key derived from password,
+// salt should be unique per user and kept along with password hash.
+// It is returned for user by KeyWrappers.
+func NewPasswordKey(password string, salt []byte) (KeyWrapper, error) {
+	return NewMasterKey(argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, dataKeySize))
+}
+
+func (w *aeadWrapper) Wrap(key []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return w.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (w *aeadWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	n := w.aead.NonceSize()
+	if len(wrapped) < n {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return w.aead.Open(nil, wrapped[:n], wrapped[n:], nil)
+}
+
+type storedKey struct {
+	ID      uint32    `json:"id"`
+	Wrapped []byte    `json:"wrapped"`
+	Created time.Time `json:"created"`
+}
+
+type storedKeys struct {
+	Current uint32      `json:"current"`
+	Keys    []storedKey `json:"keys"`
+}
+
+// KeyRing keeps data keys of directory. Files name the key they are
+// encrypted with, so after rotation new files get the current key
+// while older ones stay readable, and are re-encrypted once rewritten.
+// Keys are never removed.
+type KeyRing struct {
+	mu      sync.Mutex
+	file    string
+	wrapper KeyWrapper
+	stored  storedKeys
+	keys    map[uint32][]byte
+}
+
+// OpenKeyRing opens keys of dir, the first one
+// is generated if there is none.
+func OpenKeyRing(dir string, wrapper KeyWrapper) (*KeyRing, error) {
+	err := os.MkdirAll(path.Join(dir, reservedDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	r := &KeyRing{
+		file:    path.Join(dir, reservedDir, keysFile),
+		wrapper: wrapper,
+		keys:    make(map[uint32][]byte),
+	}
+	data, err := os.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		_, err = r.Rotate()
+		return r, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &r.stored)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range r.stored.Keys {
+		key, err := wrapper.Unwrap(k.Wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unwrap key %d: %w", k.ID, err)
+		}
+		r.keys[k.ID] = key
+	}
+	if _, ok := r.keys[r.stored.Current]; !ok {
+		return nil, errors.New("current key is missing")
+	}
+	return r, nil
+}
+
+// save replaces keys file at once, so it is never left half written.
+func (r *KeyRing) save() error {
+	data, err := json.Marshal(r.stored)
+	if err != nil {
+		return err
+	}
+	tmp := r.file + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err == nil {
+		err = os.Rename(tmp, r.file)
+	}
+	return err
+}
+
+// Rotate generates new current key and returns its id.
+func (r *KeyRing) Rotate() (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return 0, err
+	}
+	wrapped, err := r.wrapper.Wrap(key)
+	if err != nil {
+		return 0, err
+	}
+	id := r.stored.Current + 1
+	prev := r.stored
+	r.stored.Current = id
+	r.stored.Keys = append(r.stored.Keys[:len(r.stored.Keys):len(r.stored.Keys)],
+		storedKey{ID: id, Wrapped: wrapped, Created: time.Now()})
+	err = r.save()
+	if err != nil {
+		r.stored = prev
+		return 0, err
+	}
+	r.keys[id] = key
+	return id, nil
+}
+
+// RotateOlder rotates current key if it was created before maxAge ago.
+func (r *KeyRing) RotateOlder(maxAge time.Duration) (bool, error) {
+	r.mu.Lock()
+	created := r.stored.Keys[len(r.stored.Keys)-1].Created
+	r.mu.Unlock()
+	if time.Since(created) < maxAge {
+		return false, nil
+	}
+	_, err := r.Rotate()
+	return err == nil, err
+}
+
+// Rewrap protects keys with another wrapper, e.g. after master key
+// or password change. Files are not touched.
+func (r *KeyRing) Rewrap(wrapper KeyWrapper) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := storedKeys{Current: r.stored.Current}
+	for _, k := range r.stored.Keys {
+		wrapped, err := wrapper.Wrap(r.keys[k.ID])
+		if err != nil {
+			return err
+		}
+		k.Wrapped = wrapped
+		stored.Keys = append(stored.Keys, k)
+	}
+	prev := r.stored
+	r.stored = stored
+	err := r.save()
+	if err != nil {
+		r.stored = prev
+		return err
+	}
+	r.wrapper = wrapper
+	return nil
+}
+
+func (r *KeyRing) current() (uint32, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stored.Current, r.keys[r.stored.Current]
+}
+
+func (r *KeyRing) key(id uint32) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %d", ErrDecrypt, id)
+	}
+	return key, nil
+}
@@ -0,0 +1,251 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// fsLayer transforms content of files stored by underlying fs.
+type fsLayer interface {
+	// open returns content of regular file of size bytes,
+	// nil if it is stored as is.
+	open(r io.ReaderAt, size int64) (layerReaderAt, error)
+	// size returns size of content of regular file.
+	size(wfs WriteFS, name string, info fs.FileInfo) (int64, error)
+	// create returns layer of file just created by underlying fs,
+	// nil to store content as is.
+	create(name string, f *File) (layer, error)
+}
+
+// layerFS is WriteFS storing content transformed by layer,
+// the rest is forwarded to underlying fs. Versions, trash and
+// snapshots report sizes of stored content, scrub checks it.
+type layerFS struct {
+	Forward
+	wfs   WriteFS
+	layer fsLayer
+}
+
+// newLayerFS returns wfs storing content through layer,
+// uploads are staged sealed if layer does so.
+func newLayerFS(wfs WriteFS, layer fsLayer) *layerFS {
+	l := &layerFS{Forward: Forward{To: wfs}, wfs: wfs, layer: layer}
+	if sealer, ok := layer.(uploadSealer); ok {
+		if t, ok := wfs.(*localfs); ok {
+			l.staged = &sealedUploads{t: t, sealer: sealer}
+		}
+	}
+	return l
+}
+
+// wrap returns content of file opened by underlying fs.
+func (l *layerFS) wrap(f fs.File) (fs.File, error) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return f, err
+	}
+	ra, err := ReaderAt(f)
+	if err != nil {
+		return nil, err
+	}
+	r, err := l.layer.open(ra, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		// header could be read by seeking
+		if s, ok := f.(io.Seeker); ok {
+			_, err = s.Seek(0, io.SeekStart)
+		}
+		return f, err
+	}
+	return &layerReader{File: f, r: r}, nil
+}
+
+func (l *layerFS) Open(name string) (fs.File, error) {
+	f, err := l.wfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := l.wrap(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// info returns info of name reporting size of its content.
+func (l *layerFS) info(name string, info fs.FileInfo) (fs.FileInfo, error) {
+	if !info.Mode().IsRegular() {
+		return info, nil
+	}
+	size, err := l.layer.size(l.wfs, name, info)
+	if err != nil {
+		return nil, err
+	}
+	return plainInfo{FileInfo: info, size: size}, nil
+}
+
+func (l *layerFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(l.wfs, name)
+	if err != nil {
+		return nil, err
+	}
+	return l.info(name, info)
+}
+
+type layerEntry struct {
+	fs.DirEntry
+	fsys *layerFS
+	name string
+}
+
+func (e layerEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.fsys.info(e.name, info)
+}
+
+func (l *layerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(l.wfs, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		entries[i] = layerEntry{DirEntry: e, fsys: l, name: path.Join(name, e.Name())}
+	}
+	return entries, nil
+}
+
+func (l *layerFS) Create(name string) (FileWriter, error) {
+	created, err := l.wfs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.created(name, created)
+}
+
+func (l *layerFS) CreateNew(name string) (FileWriter, error) {
+	efs, ok := l.wfs.(ExclusiveFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	created, err := efs.CreateNew(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.created(name, created)
+}
+
+// created writes content of file just created by underlying fs through layer.
+func (l *layerFS) created(name string, created FileWriter) (FileWriter, error) {
+	f, ok := created.(*File)
+	if !ok {
+		_ = created.Close()
+		return nil, &fs.PathError{Op: "create", Path: name,
+			Err: errors.New("layers are supported on local backends only")}
+	}
+	w, err := l.layer.create(name, f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if w == nil {
+		return f, nil
+	}
+	return &File{File: f.File, layer: w}, nil
+}
+
+func (l *layerFS) Mkdir(name string, perm fs.FileMode) error {
+	return l.wfs.Mkdir(name, perm)
+}
+
+func (l *layerFS) Remove(name string) error {
+	return l.wfs.Remove(name)
+}
+
+func (l *layerFS) Rename(oldname string, newname string) error {
+	return l.wfs.Rename(oldname, newname)
+}
+
+func (l *layerFS) Root() string {
+	return l.wfs.Root()
+}
+
+func (l *layerFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fs.Sub(l.wfs, dir)
+	if err != nil {
+		return nil, err
+	}
+	wfs, ok := sub.(WriteFS)
+	if !ok {
+		return nil, errors.New("sub is not writable")
+	}
+	return newLayerFS(wfs, l.layer), nil
+}
+
+func (l *layerFS) OpenVersion(name string, id string) (fs.File, error) {
+	f, err := l.Forward.OpenVersion(name, id)
+	if err != nil {
+		return nil, err
+	}
+	r, err := l.wrap(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// CommitUpload writes staged content through layer, so it is
+// copied instead of being moved. Existing file is replaced
+// only once copy is complete.
+func (l *layerFS) CommitUpload(id string, name string) error {
+	return l.commitWith(id, func(staged io.Reader) error {
+		return ReplaceFile(l, name, staged, nil)
+	})
+}
+
+// Hash is not supported, checksums of underlying fs
+// are of stored content.
+func (l *layerFS) Hash(name string) (FileHash, error) {
+	return FileHash{}, errors.ErrUnsupported
+}
+
+// seekReaderAt reads at offsets of file which is only a seeker.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.rs.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// ReaderAt returns f as io.ReaderAt, reads of files which are
+// only seekers are serialized.
+func ReaderAt(f fs.File) (io.ReaderAt, error) {
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return &seekReaderAt{rs: rs}, nil
+	}
+	return nil, errors.New("file does not support random access")
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package localstorage
+
+import "io/fs"
+
+// hard links count is not known elsewhere,
+// so snapshots could not be protected from later writes
+
+const snapshotsSupported = false
+
+type inode struct{}
+
+func inodeOf(info fs.FileInfo) (inode, bool) {
+	return inode{}, false
+}
+
+func hardLinks(info fs.FileInfo) uint64 {
+	return 1
+}
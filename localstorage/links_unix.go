@@ -0,0 +1,33 @@
+//go:build unix
+
+package localstorage
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// snapshotsSupported reports whether hard links count is known,
+// so files shared with snapshots are copied before they are changed.
+const snapshotsSupported = true
+
+// inode identifies file content shared by its hard links.
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+func inodeOf(info fs.FileInfo) (inode, bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+	}
+	return inode{}, false
+}
+
+// hardLinks returns number of hard links of file.
+func hardLinks(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
@@ -3,20 +3,52 @@ package localstorage
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"time"
-
-	"github.com/ancientlore/cachefs"
-	"github.com/google/uuid"
 )
 
+// WriteFS is file system of backend, files are read through fs.FS.
+// Front ends and services use it only, so they run on any backend.
 type WriteFS interface {
 	fs.FS
-	Create(name string) (*os.File, error)
-	Root() string // root path
+	Create(name string) (FileWriter, error)
+	Mkdir(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldname string, newname string) error
+	Root() string // root path, empty if backend is not on local disk
+}
+
+// FileWriter is file opened for writing through WriteFS, content is
+// in place once it is closed. Files of local backends are *File,
+// which also implements io.WriterAt, io.Seeker and Truncate.
+type FileWriter interface {
+	io.WriteCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// ExclusiveFS creates files only if they do not exist yet.
+type ExclusiveFS interface {
+	// CreateNew is Create failing with fs.ErrExist if name exists.
+	CreateNew(name string) (FileWriter, error)
+}
+
+// CreateNew creates file which does not exist yet, atomically
+// if wfs supports it, otherwise name is checked first.
+func CreateNew(wfs WriteFS, name string) (FileWriter, error) {
+	if efs, ok := wfs.(ExclusiveFS); ok {
+		f, err := efs.CreateNew(name)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return f, err
+		}
+	}
+	if _, err := fs.Stat(wfs, name); err == nil {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	return wfs.Create(name)
 }
 
 // localfs should serve isolated directories
@@ -25,12 +57,34 @@ type WriteFS interface {
 type localfs struct {
 	config      *Config
 	trustedRoot string
-	subFs       fs.FS
+	subFs       fs.FS // reads are not cached, so writes are seen at once
 }
 
+// reservedDir inside trusted root keeps service data
+// and is not accessible through fs interfaces.
+const reservedDir = ".cardia"
+
 type Config struct {
-	CacheSize     int64         // size in bytes
-	CacheDuration time.Duration // duration, 0 to disable
+	CacheSize     int64           // Deprecated: ignored, reads are not cached since files change through fs
+	CacheDuration time.Duration   // Deprecated: ignored along with CacheSize
+	UploadTTL     time.Duration   // abandoned uploads lifetime, 0 for default
+	Quota         *Quota          // shared by all subs, nil for unlimited
+	Versions      *VersionPolicy  // nil to disable versioning
+	TrashMaxAge   time.Duration   // trash items lifetime, 0 to keep until emptied
+	Changes       *ChangeFeed     // shared by all subs, nil to disable
+	Hashes        *HashStore      // shared by all subs, nil to disable
+	Metadata      *MetadataStore  // shared by all subs, nil to disable
+	BLAKE3        bool            // compute BLAKE3 along with SHA-256 in stores opened for homes
+	Encryption    KeyWrapper      // wraps data keys of homes, nil to store them plain
+	KeyWrappers   KeyWrappers     // wrap data keys of user homes instead of Encryption, nil to use it for all
+	KeyMaxAge     time.Duration   // data keys of homes are rotated after it, 0 to keep
+	Compression   *CompressPolicy // compress files of homes, nil to store them as is
+	Search        bool            // index homes for search, text content only if not encrypted
+	Previews      *PreviewCache   // shared by all homes, nil to disable
+	PreviewMaxAge time.Duration   // unused previews lifetime, 0 to keep them
+	Base          fs.FS           // read-only starter files shown in every user home, nil to disable
+	Snapshots     *SnapshotPolicy // scheduled snapshots, nil to take them on demand only
+	StateDir      string          // local dir for stores of homes on backends without local disk
 }
 
 func NewLocalFs(dir string, config *Config) fs.FS {
@@ -42,12 +96,7 @@ func NewLocalFs(dir string, config *Config) fs.FS {
 	return &localfs{
 		config:      config,
 		trustedRoot: dir,
-		subFs: cachefs.New(os.DirFS(dir),
-			&cachefs.Config{
-				GroupName:   uuid.NewString(),
-				SizeInBytes: config.CacheSize,
-				Duration:    config.CacheDuration,
-			}),
+		subFs:       os.DirFS(dir),
 	}
 }
 
@@ -70,6 +119,12 @@ func (t *localfs) verifyPath(path string) (string, error) {
 	if err != nil {
 		return c, fmt.Errorf("unsafe or invalid path specified: %w", err)
 	}
+	if inTrustedRoot(c, filepath.Join(t.trustedRoot, reservedDir)) == nil {
+		return c, errors.New("unsafe or invalid path specified: path is reserved")
+	}
+	if inTrustedRoot(c, filepath.Join(t.trustedRoot, SnapshotsDir)) == nil {
+		return c, errors.New("unsafe or invalid path specified: snapshots are read-only")
+	}
 
 	r, err := filepath.EvalSymlinks(c)
 	if err != nil {
@@ -91,12 +146,27 @@ func (t *localfs) verifyPath(path string) (string, error) {
 }
 
 func (t *localfs) Open(name string) (fs.File, error) {
+	if sub, ok := snapshotName(name); ok {
+		return t.snapshotView().Open(sub)
+	}
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
 	fullPath := path.Join(t.trustedRoot, name)
-	_, err := t.verifyPath(fullPath)
+	resolved, err := t.verifyPath(fullPath)
 	if err != nil {
 		return nil, err
 	}
-	return t.subFs.Open(name)
+	// verified path is opened, so links are not resolved again
+	f, err := os.Open(resolved)
+	if err != nil {
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			pe.Path = name
+		}
+		return nil, err
+	}
+	return f, nil
 }
 
 func (t *localfs) Sub(name string) (fs.FS, error) {
@@ -109,6 +179,9 @@ func (t *localfs) Sub(name string) (fs.FS, error) {
 }
 
 func (t *localfs) ReadFile(name string) ([]byte, error) {
+	if sub, ok := snapshotName(name); ok {
+		return fs.ReadFile(t.snapshotView(), sub)
+	}
 	fullPath := path.Join(t.trustedRoot, name)
 	_, err := t.verifyPath(fullPath)
 	if err != nil {
@@ -118,16 +191,34 @@ func (t *localfs) ReadFile(name string) ([]byte, error) {
 }
 
 func (t *localfs) ReadDir(name string) ([]fs.DirEntry, error) {
+	if sub, ok := snapshotName(name); ok {
+		return fs.ReadDir(t.snapshotView(), sub)
+	}
 	fullPath := path.Join(t.trustedRoot, name)
 	_, err := t.verifyPath(fullPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return fs.ReadDir(t.subFs, name)
+	entries, err := fs.ReadDir(t.subFs, name)
+	if err != nil {
+		return nil, err
+	}
+	if path.Clean(name) == "." {
+		for i, e := range entries {
+			if e.Name() == reservedDir {
+				entries = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+	}
+	return entries, nil
 }
 
 func (t *localfs) Stat(name string) (fs.FileInfo, error) {
+	if sub, ok := snapshotName(name); ok {
+		return fs.Stat(t.snapshotView(), sub)
+	}
 	fullPath := path.Join(t.trustedRoot, name)
 	_, err := t.verifyPath(fullPath)
 	if err != nil {
@@ -137,13 +228,198 @@ func (t *localfs) Stat(name string) (fs.FileInfo, error) {
 }
 
 // Create extending a bit standard fs interfaces.
-func (t *localfs) Create(name string) (*os.File, error) {
+func (t *localfs) Create(name string) (FileWriter, error) {
+	return t.create(name, false)
+}
+
+// CreateNew opens file exclusively, so it is never taken
+// from another writer creating it at the same time.
+func (t *localfs) CreateNew(name string) (FileWriter, error) {
+	return t.create(name, true)
+}
+
+func (t *localfs) create(name string, exclusive bool) (FileWriter, error) {
 	fullPath := path.Join(t.trustedRoot, name)
 	_, err := t.verifyPath(fullPath)
 	if err != nil {
 		return nil, err
 	}
-	return os.Create(fullPath)
+
+	// existing file is either truncated or kept as a version,
+	// new one takes a slot, as well as one replacing file
+	// shared with snapshots, since they still hold its content
+	info, err := os.Lstat(fullPath)
+	if exclusive && err == nil {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	existing := err == nil && info.Mode().IsRegular()
+	versioned := existing && t.config.Versions != nil
+	shared := existing && !versioned && hardLinks(info) > 1
+	slot := !existing || versioned || shared
+	if !slot {
+		t.config.Quota.release(info.Size(), 0)
+	} else {
+		err = t.config.Quota.reserve(0, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var metadata map[string]string
+	if versioned {
+		// previous content is moved to versions along with its
+		// extended attributes, rewritten file keeps them too
+		metadata = t.config.Metadata.keep(fullPath)
+		err = t.keepVersion(fullPath)
+		if err != nil {
+			t.config.Quota.release(0, 1)
+			return nil, err
+		}
+	}
+
+	op := ChangeCreate
+	if existing {
+		op = ChangeModify
+	}
+	t.config.Changes.writeStarted(fullPath)
+	closed := writerOpened(fullPath)
+	if shared {
+		// content is shared with snapshots, so file is
+		// replaced with a new one instead of truncating it
+		metadata = t.config.Metadata.keep(fullPath)
+		err = os.Remove(fullPath)
+		if err != nil {
+			t.config.Quota.release(0, 1)
+			closed()
+			t.config.Changes.writeFailed(fullPath)
+			return nil, err
+		}
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if exclusive {
+		flag = os.O_RDWR | os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(fullPath, flag, 0666)
+	if err != nil {
+		if slot {
+			t.config.Quota.release(0, 1)
+		}
+		closed()
+		t.config.Changes.writeFailed(fullPath)
+		return nil, err
+	}
+	t.config.Metadata.restore(fullPath, metadata)
+	file, err := newFile(f, t.config.Quota)
+	if err != nil {
+		closed()
+		t.config.Changes.writeFailed(fullPath)
+		return nil, err
+	}
+	file.closed = closed
+	file.hash = t.config.Hashes.newWriteHash()
+	file.commit = func() error {
+		t.config.Hashes.written(fullPath, file.hash)
+		t.config.Changes.writeDone(fullPath, op)
+		return nil
+	}
+	return file, nil
+}
+
+func (t *localfs) Mkdir(name string, perm fs.FileMode) error {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return err
+	}
+	err = os.Mkdir(fullPath, perm)
+	if err != nil {
+		return err
+	}
+	t.config.Changes.publish(ChangeCreate, fullPath, "", true)
+	return nil
+}
+
+// Remove removes file or empty directory.
+func (t *localfs) Remove(name string) error {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return err
+	}
+	if path.Clean(name) == "." {
+		return errors.New("cannot remove root")
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() && t.config.Versions != nil {
+		// stays in version store, so usage is not changed
+		err = t.keepVersion(fullPath)
+	} else {
+		// content shared with snapshots still takes space
+		err = os.Remove(fullPath)
+		if err == nil && info.Mode().IsRegular() && hardLinks(info) <= 1 {
+			t.config.Quota.release(info.Size(), 1)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	t.config.Hashes.forget(fullPath)
+	t.config.Metadata.forget(fullPath)
+	t.config.Changes.publish(ChangeDelete, fullPath, "", info.IsDir())
+	return nil
+}
+
+func (t *localfs) Rename(oldname string, newname string) error {
+	oldPath := path.Join(t.trustedRoot, oldname)
+	_, err := t.verifyPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newPath := path.Join(t.trustedRoot, newname)
+	_, err = t.verifyPath(newPath)
+	if err != nil {
+		return err
+	}
+	if path.Clean(oldname) == "." || path.Clean(newname) == "." {
+		return errors.New("cannot rename root")
+	}
+	if path.Clean(oldPath) == path.Clean(newPath) {
+		return nil
+	}
+
+	err = t.replace(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	t.config.Hashes.renamed(oldPath, newPath)
+	t.config.Metadata.renamed(oldPath, newPath)
+	info, err := os.Lstat(newPath)
+	t.config.Changes.publish(ChangeRename, newPath, oldPath, err == nil && info.IsDir())
+	return nil
+}
+
+// replace moves file to fullPath, replaced file is either
+// kept as a version or frees its space.
+func (t *localfs) replace(from string, fullPath string) error {
+	replaced, _ := os.Lstat(fullPath)
+	versioned := replaced != nil && replaced.Mode().IsRegular() && t.config.Versions != nil
+	if versioned {
+		err := t.keepVersion(fullPath)
+		if err != nil {
+			return err
+		}
+	}
+	err := os.Rename(from, fullPath)
+	if err != nil {
+		return err
+	}
+	if replaced != nil && replaced.Mode().IsRegular() && !versioned && hardLinks(replaced) <= 1 {
+		t.config.Quota.release(replaced.Size(), 1)
+	}
+	return nil
 }
 
 func (t *localfs) Root() string {
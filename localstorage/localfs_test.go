@@ -2,6 +2,7 @@ package localstorage
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -187,3 +188,114 @@ func TestLocalStorage(t *testing.T) {
 	}
 
 }
+
+// TestReadAfterWrite checks changes made through fs are seen
+// by the next read at once.
+func TestReadAfterWrite(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	wfs := NewLocalFs(p, &Config{}).(WriteFS)
+
+	for _, content := range []string{"one", "rewritten"} {
+		f, err := wfs.Create("subfolder1/notes.txt")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = f.Write([]byte(content))
+		_ = f.Close()
+		all, err := fs.ReadFile(wfs, "subfolder1/notes.txt")
+		if err != nil || string(all) != content {
+			t.Error("stale read: ", string(all), err)
+		}
+		info, err := fs.Stat(wfs, "subfolder1/notes.txt")
+		if err != nil || info.Size() != int64(len(content)) {
+			t.Error("stale info: ", info, err)
+		}
+	}
+
+	err = wfs.Rename("subfolder1/notes.txt", "subfolder2/notes.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = fs.Stat(wfs, "subfolder1/notes.txt"); err == nil {
+		t.Error("renamed file should not be found")
+	}
+	err = wfs.Remove("subfolder2/notes.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = fs.Stat(wfs, "subfolder2/notes.txt"); err == nil {
+		t.Error("removed file should not be found")
+	}
+	entries, _ := fs.ReadDir(wfs, "subfolder2")
+	for _, e := range entries {
+		if e.Name() == "notes.txt" {
+			t.Error("removed file should not be listed")
+		}
+	}
+}
+
+func TestCreateNew(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	quota := NewQuota(0, 0)
+	lfs := NewLocalFs(p, &Config{Quota: quota}).(WriteFS)
+	cfs, err := NewCompressFS(lfs, &CompressPolicy{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mfs := NewMemFS()
+	_ = mfs.Mkdir("subfolder1", 0750)
+	f, err := mfs.Create("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("hello"))
+	_ = f.Close()
+	for i, wfs := range []WriteFS{
+		lfs,
+		cfs,
+		NewOverlayFS(os.DirFS(p), NewLocalFs(t.TempDir(), &Config{}).(WriteFS)),
+		mfs, // checked before it is created
+	} {
+		used, files := quota.Usage()
+		_, err = CreateNew(wfs, "subfolder1/hello.txt")
+		if !errors.Is(err, fs.ErrExist) {
+			t.Error(i, ": existing file should not be taken: ", err)
+		}
+		if u, n := quota.Usage(); u != used || n != files {
+			t.Error(i, ": usage is changed to ", u, n)
+		}
+		content, err := fs.ReadFile(wfs, "subfolder1/hello.txt")
+		if err != nil || string(content) != "hello" {
+			t.Error(i, ": existing file should be kept: ", string(content), err)
+		}
+		name := fmt.Sprintf("subfolder1/new%d.txt", i)
+		f, err := CreateNew(wfs, name)
+		if err != nil {
+			t.Error(i, ": ", err)
+			continue
+		}
+		_, _ = f.Write([]byte("new"))
+		_ = f.Close()
+		content, err = fs.ReadFile(wfs, name)
+		if err != nil || string(content) != "new" {
+			t.Error(i, ": wrong content of new file: ", string(content), err)
+		}
+	}
+}
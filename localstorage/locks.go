@@ -0,0 +1,304 @@
+package localstorage
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+)
+
+type LockKind int
+
+const (
+	// LockShared is held by several owners at once,
+	// others could not write.
+	LockShared LockKind = iota
+	// LockExclusive is held by single owner,
+	// nobody else could lock or write.
+	LockExclusive
+)
+
+// Lock is advisory lease of file or directory with everything
+// inside it. It is dropped once expired unless renewed.
+type Lock struct {
+	Token   string
+	Name    string
+	Kind    LockKind
+	Owner   string
+	Created time.Time
+	Expires time.Time
+}
+
+var (
+	// ErrLocked is returned for conflicting locks and for
+	// writes to names locked by other owners.
+	ErrLocked = errors.New("locked by another owner")
+	// ErrLockNotHeld is returned for expired and unknown
+	// locks and for locks of other owners.
+	ErrLockNotHeld = errors.New("lock is not held")
+)
+
+const (
+	// DefaultLease is lease of locks requested without duration.
+	DefaultLease = 5 * time.Minute
+	// MaxLease limits lease of locks, so forgotten
+	// ones do not block files forever.
+	MaxLease = 24 * time.Hour
+)
+
+const (
+	locksDatabase = "locks.db"
+	tableLocks    = "locks"
+)
+
+// LockStore keeps locks of names under root in database inside
+// reserved directory, so they are shared by all front ends
+// and survive restarts.
+type LockStore struct {
+	mu sync.Mutex // conflicts are checked and locks taken at once
+	db *dbx.DB
+}
+
+type storedLock struct {
+	Token   string `db:"token"`
+	Name    string `db:"name"`
+	Kind    int    `db:"kind"`
+	Owner   string `db:"owner"`
+	Created int64  `db:"created"` // unix nano
+	Expires int64  `db:"expires"`
+}
+
+func (l storedLock) Export() Lock {
+	return Lock{
+		Token:   l.Token,
+		Name:    l.Name,
+		Kind:    LockKind(l.Kind),
+		Owner:   l.Owner,
+		Created: time.Unix(0, l.Created),
+		Expires: time.Unix(0, l.Expires),
+	}
+}
+
+func initLocksTable(db *dbx.DB) error {
+	exists, err := database.TableExists(db, tableLocks)
+	if err != nil || exists {
+		return err
+	}
+	locks := make(map[string]string)
+	locks["token"] = "TEXT PRIMARY KEY NOT NULL"
+	locks["name"] = "TEXT NOT NULL"
+	locks["kind"] = "INTEGER NOT NULL"
+	locks["owner"] = "TEXT NOT NULL"
+	locks["created"] = "INTEGER NOT NULL"
+	locks["expires"] = "INTEGER NOT NULL"
+	_, err = db.CreateTable(tableLocks, locks).Execute()
+	if err != nil {
+		return err
+	}
+	_, err = db.CreateIndex(tableLocks, tableLocks+"_name", "name").Execute()
+	return err
+}
+
+// NewLockStore opens locks of dir.
+func NewLockStore(dir string) (*LockStore, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(path.Join(dir, reservedDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.ConnectDB(path.Join(dir, reservedDir, locksDatabase))
+	if err != nil {
+		return nil, err
+	}
+	err = initLocksTable(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &LockStore{db: db}, nil
+}
+
+func (s *LockStore) Close() error {
+	return s.db.Close()
+}
+
+func lease(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultLease
+	}
+	return min(d, MaxLease)
+}
+
+// ancestors returns name and directories it is inside.
+func ancestors(name string) []string {
+	r := []string{name}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		r = append(r, dir)
+	}
+	return r
+}
+
+// related returns active locks of name, directories it is
+// inside and, if deep is set, names inside it.
+func (s *LockStore) related(name string, deep bool) ([]storedLock, error) {
+	var names []any
+	for _, n := range ancestors(name) {
+		names = append(names, n)
+	}
+	cond := dbx.In("name", names...)
+	if deep {
+		cond = dbx.Or(cond, dbx.Like("name", name+"/").Match(false, true))
+	}
+	var r []storedLock
+	err := s.db.Select("token", "name", "kind", "owner", "created", "expires").
+		From(tableLocks).
+		Where(dbx.And(cond, dbx.NewExp("expires > {:now}", dbx.Params{"now": time.Now().UnixNano()}))).
+		OrderBy("created").
+		All(&r)
+	return r, err
+}
+
+// Acquire locks name for owner. Exclusive lock conflicts with any
+// lock of name, directories it is inside or names inside it,
+// shared one with exclusive locks only.
+func (s *LockStore) Acquire(name string, kind LockKind, owner string, d time.Duration) (Lock, error) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) || name == "." {
+		return Lock{}, errors.New("invalid name to lock")
+	}
+	if kind != LockShared && kind != LockExclusive {
+		return Lock{}, errors.New("invalid lock kind")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.related(name, true)
+	if err != nil {
+		return Lock{}, err
+	}
+	for _, l := range active {
+		if kind == LockExclusive || LockKind(l.Kind) == LockExclusive {
+			return Lock{}, ErrLocked
+		}
+	}
+	now := time.Now()
+	l := storedLock{
+		Token:   uuid.NewString(),
+		Name:    name,
+		Kind:    int(kind),
+		Owner:   owner,
+		Created: now.UnixNano(),
+		Expires: now.Add(lease(d)).UnixNano(),
+	}
+	_, err = s.db.Insert(tableLocks, dbx.Params{
+		"token":   l.Token,
+		"name":    l.Name,
+		"kind":    l.Kind,
+		"owner":   l.Owner,
+		"created": l.Created,
+		"expires": l.Expires,
+	}).Execute()
+	return l.Export(), err
+}
+
+func (s *LockStore) held(name string, token string, owner string) (storedLock, error) {
+	var l storedLock
+	err := s.db.Select("token", "name", "kind", "owner", "created", "expires").
+		From(tableLocks).
+		Where(dbx.HashExp{"token": token}).
+		One(&l)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (l.Name != path.Clean(name) ||
+		l.Owner != owner || l.Expires <= time.Now().UnixNano())) {
+		return l, ErrLockNotHeld
+	}
+	return l, err
+}
+
+// Renew extends lease of lock of name held by owner.
+func (s *LockStore) Renew(name string, token string, owner string, d time.Duration) (Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, err := s.held(name, token, owner)
+	if err != nil {
+		return Lock{}, err
+	}
+	l.Expires = time.Now().Add(lease(d)).UnixNano()
+	_, err = s.db.Update(tableLocks, dbx.Params{"expires": l.Expires},
+		dbx.HashExp{"token": token}).Execute()
+	return l.Export(), err
+}
+
+// Release drops lock of name held by owner.
+func (s *LockStore) Release(name string, token string, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.held(name, token, owner)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Delete(tableLocks, dbx.HashExp{"token": token}).Execute()
+	return err
+}
+
+// Locks returns active locks of name and directories it is inside.
+func (s *LockStore) Locks(name string) ([]Lock, error) {
+	if s == nil {
+		return nil, nil
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return nil, nil
+	}
+	stored, err := s.related(name, false)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]Lock, 0, len(stored))
+	for _, l := range stored {
+		r = append(r, l.Export())
+	}
+	return r, nil
+}
+
+// Check returns ErrLocked if name, directory it is inside or
+// name inside it is locked, unless owner holds the lock and presents
+// its token. So other clients of the same owner are rejected too.
+func (s *LockStore) Check(name string, owner string, tokens []string) error {
+	if s == nil {
+		return nil
+	}
+	name = path.Clean(name)
+	if name == "." {
+		// root itself is never modified, only names inside
+		return nil
+	}
+	active, err := s.related(name, true)
+	if err != nil {
+		return err
+	}
+	for _, l := range active {
+		if l.Owner != owner || !slices.Contains(tokens, l.Token) {
+			return ErrLocked
+		}
+	}
+	return nil
+}
+
+// Expire drops expired locks, returns number of dropped ones.
+func (s *LockStore) Expire() (int, error) {
+	r, err := s.db.Delete(tableLocks,
+		dbx.NewExp("expires <= {:now}", dbx.Params{"now": time.Now().UnixNano()})).Execute()
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	return int(n), err
+}
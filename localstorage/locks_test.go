@@ -0,0 +1,127 @@
+package localstorage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLocks(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	store, err := NewLockStore(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer store.Close()
+
+	l, err := store.Acquire("subfolder1/hello.txt", LockExclusive, "alice", 0)
+	if err != nil || l.Token == "" || l.Owner != "alice" {
+		t.Error("lock should be taken: ", l, err)
+		return
+	}
+	if l.Expires.Sub(l.Created) != DefaultLease {
+		t.Error("default lease expected: ", l.Expires.Sub(l.Created))
+	}
+
+	// conflicts with locks of file, directories it is inside and names inside it
+	for _, c := range []struct {
+		name string
+		kind LockKind
+	}{
+		{"subfolder1/hello.txt", LockShared},
+		{"subfolder1/hello.txt", LockExclusive},
+		{"subfolder1", LockShared},
+	} {
+		_, err = store.Acquire(c.name, c.kind, "bob", 0)
+		if !errors.Is(err, ErrLocked) {
+			t.Error("lock should conflict: ", c.name, err)
+		}
+	}
+	_, err = store.Acquire("subfolder1/dir11", LockExclusive, "bob", 0)
+	if err != nil {
+		t.Error("unrelated name should be locked: ", err)
+	}
+
+	// owner presenting token could still write
+	if err = store.Check("subfolder1/hello.txt", "alice", []string{l.Token}); err != nil {
+		t.Error(err)
+	}
+	if err = store.Check("subfolder1/hello.txt", "alice", nil); !errors.Is(err, ErrLocked) {
+		t.Error("write of owner without token should be rejected: ", err)
+	}
+	for _, name := range []string{"subfolder1/hello.txt", "subfolder1"} {
+		if err = store.Check(name, "bob", []string{l.Token}); !errors.Is(err, ErrLocked) {
+			t.Error("write should be rejected: ", name, err)
+		}
+	}
+	if err = store.Check("subfolder2/goodbye.txt", "bob", nil); err != nil {
+		t.Error(err)
+	}
+
+	// shared locks are held by several owners at once
+	_, err = store.Acquire("subfolder2", LockShared, "alice", 0)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = store.Acquire("subfolder2/goodbye.txt", LockShared, "bob", 0)
+	if err != nil {
+		t.Error(err)
+	}
+	locks, err := store.Locks("subfolder2/goodbye.txt")
+	if err != nil || len(locks) != 2 {
+		t.Error("both locks expected: ", locks, err)
+	}
+	_, err = store.Acquire("subfolder2/dir21", LockExclusive, "carol", 0)
+	if !errors.Is(err, ErrLocked) {
+		t.Error("exclusive lock should conflict with shared one: ", err)
+	}
+
+	// renewed and released by owner only
+	_, err = store.Renew("subfolder1/hello.txt", l.Token, "bob", time.Hour)
+	if !errors.Is(err, ErrLockNotHeld) {
+		t.Error("lock of other owner should not be renewed: ", err)
+	}
+	renewed, err := store.Renew("subfolder1/hello.txt", l.Token, "alice", 2*MaxLease)
+	if err != nil || renewed.Expires.Sub(time.Now()) > MaxLease {
+		t.Error("lease should be limited: ", renewed, err)
+	}
+	err = store.Release("subfolder1", l.Token, "alice")
+	if !errors.Is(err, ErrLockNotHeld) {
+		t.Error("lock of other name should not be released: ", err)
+	}
+	err = store.Release("subfolder1/hello.txt", l.Token, "alice")
+	if err != nil {
+		t.Error(err)
+	}
+	if err = store.Check("subfolder1/hello.txt", "bob", nil); err != nil {
+		t.Error("released lock should not be enforced: ", err)
+	}
+
+	// dropped once expired
+	l, err = store.Acquire("subfolder1/dir12/friend.txt", LockExclusive, "alice", 10*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err = store.Check("subfolder1/dir12/friend.txt", "bob", nil); err != nil {
+		t.Error("expired lock should not be enforced: ", err)
+	}
+	_, err = store.Renew("subfolder1/dir12/friend.txt", l.Token, "alice", 0)
+	if !errors.Is(err, ErrLockNotHeld) {
+		t.Error("expired lock should not be renewed: ", err)
+	}
+	n, err := store.Expire()
+	if err != nil || n != 1 {
+		t.Error("expired lock should be dropped: ", n, err)
+	}
+}
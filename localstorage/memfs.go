@@ -0,0 +1,318 @@
+package localstorage
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memfs keeps files in memory, e.g. for tests of code running on
+// WriteFS. Content of file is replaced on Close of its writer.
+type memfs struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode // by path, "." is root
+}
+
+const (
+	memDirMode  = fs.ModeDir | 0750
+	memFileMode = 0640
+)
+
+type memNode struct {
+	name     string
+	isDir    bool
+	data     []byte // never modified in place, readers keep it
+	modified time.Time
+}
+
+// NewMemFS returns empty in-memory file system.
+func NewMemFS() WriteFS {
+	return &memfs{nodes: map[string]*memNode{
+		".": {name: ".", isDir: true, modified: time.Now()},
+	}}
+}
+
+type memInfo struct {
+	n memNode
+}
+
+func (i memInfo) Name() string {
+	return path.Base(i.n.name)
+}
+
+func (i memInfo) Size() int64 {
+	return int64(len(i.n.data))
+}
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.n.isDir {
+		return memDirMode
+	}
+	return memFileMode
+}
+
+func (i memInfo) ModTime() time.Time {
+	return i.n.modified
+}
+
+func (i memInfo) IsDir() bool {
+	return i.n.isDir
+}
+
+func (i memInfo) Sys() any {
+	return nil
+}
+
+// memFile reads content file had when it was opened.
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+func validMemName(op string, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// node returns copy of node at name, caller holds lock.
+func (m *memfs) node(op string, name string) (memNode, error) {
+	err := validMemName(op, name)
+	if err != nil {
+		return memNode{}, err
+	}
+	n, ok := m.nodes[name]
+	if !ok {
+		return memNode{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return *n, nil
+}
+
+// parentDir checks directory of name exists, caller holds lock.
+func (m *memfs) parentDir(op string, name string) error {
+	parent, ok := m.nodes[path.Dir(name)]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.isDir {
+		return &fs.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+	}
+	return nil
+}
+
+// children returns names inside dir sorted, caller holds lock.
+func (m *memfs) children(dir string) []string {
+	var r []string
+	for name := range m.nodes {
+		if name != "." && path.Dir(name) == dir {
+			r = append(r, name)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (m *memfs) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	n, err := m.node("open", name)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if n.isDir {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: memInfo{n}, entries: entries}, nil
+	}
+	return &memFile{Reader: bytes.NewReader(n.data), info: memInfo{n}}, nil
+}
+
+func (m *memfs) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.node("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memInfo{n}, nil
+}
+
+func (m *memfs) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.node("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	children := m.children(name)
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, c := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(memInfo{*m.nodes[c]}))
+	}
+	return entries, nil
+}
+
+// memWriter collects content, which replaces file on Close.
+type memWriter struct {
+	fsys   *memfs
+	name   string
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memWriter) Stat() (fs.FileInfo, error) {
+	return memInfo{memNode{name: w.name, data: w.buf.Bytes(), modified: time.Now()}}, nil
+}
+
+func (w *memWriter) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+	m := w.fsys
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.parentDir("create", w.name)
+	if err != nil {
+		return err
+	}
+	if n, ok := m.nodes[w.name]; ok && n.isDir {
+		return &fs.PathError{Op: "create", Path: w.name, Err: errors.New("is a directory")}
+	}
+	m.nodes[w.name] = &memNode{
+		name:     w.name,
+		data:     bytes.Clone(w.buf.Bytes()),
+		modified: time.Now(),
+	}
+	return nil
+}
+
+func (m *memfs) Create(name string) (FileWriter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	err := validMemName("create", name)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := m.nodes[name]; ok && n.isDir {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("is a directory")}
+	}
+	err = m.parentDir("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memWriter{fsys: m, name: name}, nil
+}
+
+func (m *memfs) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := validMemName("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.nodes[name]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	err = m.parentDir("mkdir", name)
+	if err != nil {
+		return err
+	}
+	m.nodes[name] = &memNode{name: name, isDir: true, modified: time.Now()}
+	return nil
+}
+
+// Remove removes file or empty directory.
+func (m *memfs) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return errors.New("cannot remove root")
+	}
+	if n.isDir && len(m.children(name)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+// Rename moves node with all its descendants,
+// existing file is replaced, directory is not.
+func (m *memfs) Rename(oldname string, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("rename", oldname)
+	if err != nil {
+		return err
+	}
+	err = validMemName("rename", newname)
+	if err != nil {
+		return err
+	}
+	if oldname == "." || newname == "." {
+		return errors.New("cannot rename root")
+	}
+	if oldname == newname {
+		return nil
+	}
+	if n.isDir && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errors.New("cannot move directory into itself")}
+	}
+	err = m.parentDir("rename", newname)
+	if err != nil {
+		return err
+	}
+	if replaced, ok := m.nodes[newname]; ok && (replaced.isDir || n.isDir) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+
+	moved := make(map[string]*memNode)
+	for name, node := range m.nodes {
+		if name == oldname || strings.HasPrefix(name, oldname+"/") {
+			moved[name] = node
+		}
+	}
+	for name, node := range moved {
+		delete(m.nodes, name)
+		node.name = newname + strings.TrimPrefix(name, oldname)
+	}
+	for _, node := range moved {
+		m.nodes[node.name] = node
+	}
+	return nil
+}
+
+// Root is empty, files are not on disk.
+func (m *memfs) Root() string {
+	return ""
+}
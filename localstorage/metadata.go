@@ -0,0 +1,266 @@
+package localstorage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+)
+
+// MetadataFS is WriteFS keeping user metadata of files,
+// e.g. tags of their provenance.
+type MetadataFS interface {
+	WriteFS
+	// Metadata returns user metadata of file or directory.
+	Metadata(name string) (map[string]string, error)
+	// SetMetadata sets given keys, empty values remove them.
+	SetMetadata(name string, metadata map[string]string) error
+}
+
+const (
+	metadataDatabase = "metadata.db"
+	tableMetadata    = "metadata"
+	// xattrPrefix is namespace of extended attributes metadata is kept in.
+	xattrPrefix      = "user.cardia."
+	maxMetadataKey   = 200
+	maxMetadataValue = 64 << 10
+)
+
+// MetadataStore keeps user metadata of files under trusted root.
+// It is stored in extended attributes of files, so it moves along
+// with them, and in database inside reserved directory if file
+// system does not support them or value does not fit.
+type MetadataStore struct {
+	root string
+	db   *dbx.DB
+}
+
+type metadataEntry struct {
+	Name  string `db:"name"`
+	Key   string `db:"key"`
+	Value string `db:"value"`
+}
+
+func initMetadataTable(db *dbx.DB) error {
+	exists, err := database.TableExists(db, tableMetadata)
+	if err != nil || exists {
+		return err
+	}
+	metadata := make(map[string]string)
+	metadata["name"] = "TEXT NOT NULL"
+	metadata["key"] = "TEXT NOT NULL"
+	metadata["value"] = "TEXT NOT NULL"
+	_, err = db.CreateTable(tableMetadata, metadata).Execute()
+	if err != nil {
+		return err
+	}
+	_, err = db.CreateUniqueIndex(tableMetadata, tableMetadata+"_name_key", "name", "key").Execute()
+	return err
+}
+
+// NewMetadataStore opens metadata of dir.
+func NewMetadataStore(dir string) (*MetadataStore, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(path.Join(dir, reservedDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.ConnectDB(path.Join(dir, reservedDir, metadataDatabase))
+	if err != nil {
+		return nil, err
+	}
+	err = initMetadataTable(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &MetadataStore{root: dir, db: db}, nil
+}
+
+func (s *MetadataStore) Close() error {
+	return s.db.Close()
+}
+
+func validMetadata(key string, value string) error {
+	if key == "" || len(key) > maxMetadataKey || !utf8.ValidString(key) ||
+		strings.ContainsAny(key, "\x00/") {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+	if len(value) > maxMetadataValue || !utf8.ValidString(value) {
+		return fmt.Errorf("invalid value of metadata key %q", key)
+	}
+	return nil
+}
+
+// get returns metadata of file, kept in extended attributes
+// along with those in database.
+func (s *MetadataStore) get(fullPath string) (map[string]string, error) {
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return nil, fmt.Errorf("%s is outside of metadata store", fullPath)
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return nil, err
+	}
+	r, err := getXattrs(fullPath)
+	if err != nil && !xattrUnsupported(err) {
+		return nil, err
+	}
+	if r == nil {
+		r = make(map[string]string)
+	}
+	var stored []metadataEntry
+	err = s.db.Select("name", "key", "value").
+		From(tableMetadata).
+		Where(dbx.HashExp{"name": name}).
+		All(&stored)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range stored {
+		r[e.Key] = e.Value
+	}
+	return r, nil
+}
+
+// set stores keys in extended attributes if possible,
+// every key is kept in one place only.
+func (s *MetadataStore) set(fullPath string, metadata map[string]string) error {
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return fmt.Errorf("%s is outside of metadata store", fullPath)
+	}
+	for k, v := range metadata {
+		err := validMetadata(k, v)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return err
+	}
+	for k, v := range metadata {
+		inDB := false
+		if v == "" {
+			err := removeXattr(fullPath, k)
+			if err != nil && !xattrUnsupported(err) {
+				return err
+			}
+		} else {
+			err := setXattr(fullPath, k, v)
+			if err != nil && !xattrUnsupported(err) {
+				return err
+			}
+			inDB = err != nil
+		}
+		var err error
+		if inDB {
+			_, err = s.db.NewQuery("INSERT OR REPLACE INTO " + tableMetadata +
+				" (name, key, value) VALUES ({:name}, {:key}, {:value})").
+				Bind(dbx.Params{"name": name, "key": k, "value": v}).Execute()
+		} else {
+			_, err = s.db.Delete(tableMetadata, dbx.HashExp{"name": name, "key": k}).Execute()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forget drops metadata of file or directory kept in database.
+func (s *MetadataStore) forget(fullPath string) {
+	if s == nil {
+		return
+	}
+	name, ok := relativeName(s.root, fullPath)
+	if !ok {
+		return
+	}
+	_, _ = s.db.Delete(tableMetadata, under(name)).Execute()
+}
+
+// renamed moves metadata of file or directory kept in database,
+// extended attributes are moved along with files.
+func (s *MetadataStore) renamed(oldPath string, newPath string) {
+	if s == nil {
+		return
+	}
+	oldName, ok := relativeName(s.root, oldPath)
+	newName, ok2 := relativeName(s.root, newPath)
+	if !ok || !ok2 {
+		return
+	}
+	_, _ = s.db.Delete(tableMetadata, under(newName)).Execute()
+	_, _ = s.db.NewQuery("UPDATE " + tableMetadata +
+		" SET name = {:new} || substr(name, length({:old}) + 1)" +
+		" WHERE name = {:old} OR substr(name, 1, length({:old}) + 1) = {:old} || '/'").
+		Bind(dbx.Params{
+			"new": newName,
+			"old": oldName,
+		}).Execute()
+}
+
+// keep returns extended attributes of file which is going to be
+// replaced, so rewritten file keeps its metadata.
+func (s *MetadataStore) keep(fullPath string) map[string]string {
+	if s == nil {
+		return nil
+	}
+	r, _ := getXattrs(fullPath)
+	return r
+}
+
+func (s *MetadataStore) restore(fullPath string, kept map[string]string) {
+	for k, v := range kept {
+		_ = setXattr(fullPath, k, v)
+	}
+}
+
+func (t *localfs) Metadata(name string) (map[string]string, error) {
+	if _, ok := snapshotName(name); ok {
+		return nil, errors.ErrUnsupported
+	}
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if t.config.Metadata == nil {
+		return nil, errors.ErrUnsupported
+	}
+	return t.config.Metadata.get(fullPath)
+}
+
+func (t *localfs) SetMetadata(name string, metadata map[string]string) error {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return err
+	}
+	if t.config.Metadata == nil {
+		return errors.ErrUnsupported
+	}
+	// extended attributes belong to content shared with snapshots
+	err = t.unshare(fullPath)
+	if err != nil {
+		return err
+	}
+	err = t.config.Metadata.set(fullPath, metadata)
+	if err != nil {
+		return err
+	}
+	// reported so search index picks it up
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	t.config.Changes.publish(ChangeModify, fullPath, "", info.IsDir())
+	return nil
+}
@@ -0,0 +1,109 @@
+package localstorage
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	store, err := NewMetadataStore(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer store.Close()
+	lfs := NewLocalFs(p, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Metadata:  store,
+		Versions:  &VersionPolicy{Keep: 3},
+	}).(MetadataFS)
+
+	err = lfs.SetMetadata("subfolder1/hello.txt", map[string]string{
+		"source":  "pipeline",
+		"dataset": "2024-01",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m, err := lfs.Metadata("subfolder1/hello.txt")
+	if err != nil || len(m) != 2 || m["source"] != "pipeline" {
+		t.Error("wrong metadata: ", m, err)
+	}
+	err = lfs.SetMetadata("subfolder1/hello.txt", map[string]string{"bad/key": "x"})
+	if err == nil {
+		t.Error("invalid key should not be set")
+	}
+
+	// kept in database if file system does not support it
+	_, err = store.db.NewQuery("INSERT INTO " + tableMetadata +
+		" (name, key, value) VALUES ('subfolder1/hello.txt', 'owner', 'alice')").Execute()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// moved along with file and kept when it is rewritten
+	err = lfs.Rename("subfolder1", "renamed")
+	if err != nil {
+		t.Error(err)
+	}
+	f, _ := lfs.Create("renamed/hello.txt")
+	_, _ = f.Write([]byte("hello again"))
+	_ = f.Close()
+	m, err = lfs.Metadata("renamed/hello.txt")
+	if err != nil || len(m) != 3 || m["dataset"] != "2024-01" || m["owner"] != "alice" {
+		t.Error("wrong metadata after rename: ", m, err)
+	}
+
+	// empty values remove keys
+	err = lfs.SetMetadata("renamed/hello.txt", map[string]string{"source": "", "owner": ""})
+	if err != nil {
+		t.Error(err)
+	}
+	m, _ = lfs.Metadata("renamed/hello.txt")
+	if len(m) != 1 {
+		t.Error("keys should be removed: ", m)
+	}
+
+	err = lfs.Remove("renamed/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = lfs.Metadata("renamed/hello.txt")
+	if !os.IsNotExist(err) {
+		t.Error("removed file has no metadata: ", err)
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	for _, c := range []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"notes.md", []byte("# notes"), "text/markdown"},
+		{"data.json", []byte(`{"a": 1}`), "application/json"},
+		{"photo.jpg", buf.Bytes(), "image/png"},
+		{"noext", buf.Bytes(), "image/png"},
+		{"noext", []byte("plain"), "text/plain"},
+		{"empty.txt", nil, "text/plain"},
+	} {
+		got := DetectType(c.name, c.head)
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package localstorage
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// genericTypes are sniffed when content has no distinct signature,
+// type of extension is more precise then, e.g. for sources or
+// office documents which are zip archives.
+var genericTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+	"application/zip":          true,
+	"text/xml":                 true,
+}
+
+// DetectType returns media type of file by its name and first
+// bytes of content. Type of content wins over extension
+// unless content is recognized only as generic one.
+func DetectType(name string, head []byte) string {
+	byName := mediaType(mime.TypeByExtension(path.Ext(name)))
+	byContent := mediaType(http.DetectContentType(head))
+	if byName != "" && (genericTypes[byContent] || len(head) == 0) {
+		return byName
+	}
+	return byContent
+}
+
+// FileType detects media type of file name of fsys.
+func FileType(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head, err := readHead(f)
+	if err != nil {
+		return "", err
+	}
+	return DetectType(name, head), nil
+}
+
+// readHead reads bytes content is sniffed by.
+func readHead(r io.Reader) ([]byte, error) {
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, head)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return head[:n], err
+}
+
+func mediaType(t string) string {
+	mt, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
@@ -0,0 +1,556 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix starts names of empty files upper layer of overlay
+// keeps to hide lower entries, e.g. "docs/.wh.plan.txt" hides
+// lower "docs/plan.txt" with everything inside. Such names are
+// reserved and not visible through overlay.
+const whiteoutPrefix = ".wh."
+
+// overlayFS shows read-only lower fs merged with writable upper one.
+// Upper entries shadow lower ones, lower files are copied up before
+// they are modified and deleted ones are hidden with whiteouts,
+// so lower fs is shared by many overlays and never changed.
+// Versions, uploads, snapshots and scrub are of upper layer only.
+type overlayFS struct {
+	Forward // to upper
+
+	lower fs.FS // nil if nothing is shown from it
+	upper WriteFS
+}
+
+// NewOverlayFS returns upper merged with lower, e.g. starter files
+// shared by all homes. Lower fs is not modified through it.
+func NewOverlayFS(lower fs.FS, upper WriteFS) WriteFS {
+	return &overlayFS{Forward: Forward{To: upper}, lower: lower, upper: upper}
+}
+
+func whiteout(name string) string {
+	return path.Join(path.Dir(name), whiteoutPrefix+path.Base(name))
+}
+
+func reservedOverlayName(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, whiteoutPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// check validates name, reserved ones do not exist for readers
+// and could not be created by writers.
+func (o *overlayFS) check(op string, name string, write bool) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if !reservedOverlayName(name) {
+		return nil
+	}
+	if write {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+func (o *overlayFS) inUpper(name string) bool {
+	return exists(o.upper, name)
+}
+
+// inLower reports whether lower entry at name is visible:
+// it exists, neither it nor its parents are whited out
+// and no parent is shadowed by upper file.
+func (o *overlayFS) inLower(name string) bool {
+	if o.lower == nil {
+		return false
+	}
+	if name != "." {
+		p := "."
+		for _, part := range strings.Split(name, "/") {
+			if o.inUpper(path.Join(p, whiteoutPrefix+part)) {
+				return false
+			}
+			p = path.Join(p, part)
+			if p == name {
+				break
+			}
+			if info, err := fs.Stat(o.upper, p); err == nil && !info.IsDir() {
+				return false
+			}
+		}
+	}
+	return exists(o.lower, name)
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	err := o.check("stat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(o.upper, name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return info, err
+	}
+	if !o.inLower(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(o.lower, name)
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	info, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: info, entries: entries}, nil
+	}
+	if o.inUpper(name) {
+		return o.upper.Open(name)
+	}
+	return o.lower.Open(name)
+}
+
+// ReadDir merges entries of both layers, upper ones win.
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	merged := make(map[string]fs.DirEntry)
+	hidden := make(map[string]bool)
+	if upperInfo, err := fs.Stat(o.upper, name); err == nil && upperInfo.IsDir() {
+		entries, err := fs.ReadDir(o.upper, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), whiteoutPrefix) {
+				hidden[strings.TrimPrefix(e.Name(), whiteoutPrefix)] = true
+				continue
+			}
+			merged[e.Name()] = e
+		}
+	}
+	if !o.inLower(name) {
+		return sortedEntries(merged), nil
+	}
+	if lowerInfo, err := fs.Stat(o.lower, name); err == nil && lowerInfo.IsDir() {
+		entries, err := fs.ReadDir(o.lower, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if _, ok := merged[e.Name()]; ok || hidden[e.Name()] ||
+				strings.HasPrefix(e.Name(), whiteoutPrefix) {
+				continue
+			}
+			merged[e.Name()] = e
+		}
+	}
+	return sortedEntries(merged), nil
+}
+
+func sortedEntries(entries map[string]fs.DirEntry) []fs.DirEntry {
+	r := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		r = append(r, e)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Name() < r[j].Name()
+	})
+	return r
+}
+
+// copyUpDir creates dir with its parents in upper layer,
+// dir should exist in merged view.
+func (o *overlayFS) copyUpDir(op string, dir string) error {
+	if dir == "." {
+		return nil
+	}
+	p := "."
+	for _, part := range strings.Split(dir, "/") {
+		p = path.Join(p, part)
+		if info, err := fs.Stat(o.upper, p); err == nil {
+			if !info.IsDir() {
+				return &fs.PathError{Op: op, Path: p, Err: errors.New("not a directory")}
+			}
+			continue
+		}
+		info, err := o.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return &fs.PathError{Op: op, Path: p, Err: errors.New("not a directory")}
+		}
+		err = o.upper.Mkdir(p, info.Mode().Perm())
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyUp copies lower content of name missing in upper layer,
+// the whole tree if it is a directory.
+func (o *overlayFS) copyUp(name string) error {
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = o.copyUpDir("copy", name)
+		if err != nil {
+			return err
+		}
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			err = o.copyUp(path.Join(name, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if o.inUpper(name) {
+		return nil
+	}
+	err = o.copyUpDir("copy", path.Dir(name))
+	if err != nil {
+		return err
+	}
+	src, err := o.lower.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := o.upper.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// hideLower creates whiteout of name if lower entry is visible.
+func (o *overlayFS) hideLower(name string) error {
+	if !o.inLower(name) {
+		return nil
+	}
+	err := o.copyUpDir("whiteout", path.Dir(name))
+	if err != nil {
+		return err
+	}
+	f, err := o.upper.Create(whiteout(name))
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Create writes file to upper layer, lower one stays unchanged.
+// Whiteout of name is kept, so lower file does not come back
+// once the new one is removed.
+func (o *overlayFS) Create(name string) (FileWriter, error) {
+	err := o.check("create", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := o.Stat(name); err == nil && info.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("is a directory")}
+	}
+	err = o.copyUpDir("create", path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	return o.upper.Create(name)
+}
+
+// CreateNew writes file to upper layer if name is
+// in neither of layers.
+func (o *overlayFS) CreateNew(name string) (FileWriter, error) {
+	err := o.check("create", name, true)
+	if err != nil {
+		return nil, err
+	}
+	efs, ok := o.upper.(ExclusiveFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if _, err = o.Stat(name); err == nil {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	err = o.copyUpDir("create", path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	return efs.CreateNew(name)
+}
+
+func (o *overlayFS) Mkdir(name string, perm fs.FileMode) error {
+	err := o.check("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	if _, err = o.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	err = o.copyUpDir("mkdir", path.Dir(name))
+	if err != nil {
+		return err
+	}
+	return o.upper.Mkdir(name, perm)
+}
+
+// Remove removes file or empty directory of merged view,
+// lower entries are hidden with whiteouts.
+func (o *overlayFS) Remove(name string) error {
+	err := o.check("remove", name, true)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return errors.New("cannot remove root")
+	}
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	err = o.hideLower(name)
+	if err != nil {
+		return err
+	}
+	if !o.inUpper(name) {
+		return nil
+	}
+	if info.IsDir() {
+		// only whiteouts of lower entries are left inside
+		entries, err := fs.ReadDir(o.upper, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			err = o.upper.Remove(path.Join(name, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return o.upper.Remove(name)
+}
+
+// Rename moves entry inside upper layer, lower content
+// of it is copied up first and hidden afterwards.
+func (o *overlayFS) Rename(oldname string, newname string) error {
+	err := o.check("rename", oldname, true)
+	if err != nil {
+		return err
+	}
+	err = o.check("rename", newname, true)
+	if err != nil {
+		return err
+	}
+	if oldname == "." || newname == "." {
+		return errors.New("cannot rename root")
+	}
+	if oldname == newname {
+		return nil
+	}
+	info, err := o.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errors.New("cannot move directory into itself")}
+	}
+	if replaced, err := o.Stat(newname); err == nil && (replaced.IsDir() || info.IsDir()) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	err = o.copyUpDir("rename", path.Dir(newname))
+	if err != nil {
+		return err
+	}
+	lower := o.inLower(oldname)
+	if lower {
+		err = o.copyUp(oldname)
+		if err != nil {
+			return err
+		}
+	}
+	err = o.upper.Rename(oldname, newname)
+	if err != nil {
+		return err
+	}
+	return o.hideLower(oldname)
+}
+
+func (o *overlayFS) Root() string {
+	return o.upper.Root()
+}
+
+// Sub returns overlay of dir in both layers.
+func (o *overlayFS) Sub(dir string) (fs.FS, error) {
+	info, err := o.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+	err = o.copyUpDir("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := fs.Sub(o.upper, dir)
+	if err != nil {
+		return nil, err
+	}
+	upper, ok := sub.(WriteFS)
+	if !ok {
+		return nil, errors.New("upper layer is not writable")
+	}
+	r := &overlayFS{Forward: Forward{To: upper}, upper: upper}
+	if o.inLower(dir) {
+		r.lower, err = fs.Sub(o.lower, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Trash moves copy of lower content to trash of upper layer,
+// so it could be restored, and hides lower entry.
+func (o *overlayFS) Trash(name string) (TrashItem, error) {
+	tfs, ok := o.upper.(TrashFS)
+	if !ok {
+		return TrashItem{}, errors.ErrUnsupported
+	}
+	err := o.check("trash", name, true)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	lower := o.inLower(name)
+	if lower {
+		err = o.copyUp(name)
+		if err != nil {
+			return TrashItem{}, err
+		}
+	}
+	item, err := tfs.Trash(name)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	return item, o.hideLower(name)
+}
+
+func (o *overlayFS) RestoreVersion(name string, id string) error {
+	vfs, ok := o.upper.(VersionFS)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	err := o.copyUpDir("restore", path.Dir(name))
+	if err != nil {
+		return err
+	}
+	return vfs.RestoreVersion(name, id)
+}
+
+func (o *overlayFS) CommitUpload(id string, name string) error {
+	err := o.check("commit", name, true)
+	if err != nil {
+		return err
+	}
+	err = o.copyUpDir("commit", path.Dir(name))
+	if err != nil {
+		return err
+	}
+	return o.Forward.CommitUpload(id, name)
+}
+
+// layer returns fs name is read from.
+func (o *overlayFS) layer(name string) fs.FS {
+	if o.inUpper(name) {
+		return o.upper
+	}
+	return o.lower
+}
+
+func (o *overlayFS) Hash(name string) (FileHash, error) {
+	_, err := o.Stat(name)
+	if err != nil {
+		return FileHash{}, err
+	}
+	hfs, ok := o.layer(name).(HashFS)
+	if !ok {
+		return FileHash{}, errors.ErrUnsupported
+	}
+	return hfs.Hash(name)
+}
+
+func (o *overlayFS) Metadata(name string) (map[string]string, error) {
+	_, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	mfs, ok := o.layer(name).(MetadataFS)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return mfs.Metadata(name)
+}
+
+// SetMetadata copies file up, then sets metadata of the copy.
+func (o *overlayFS) SetMetadata(name string, metadata map[string]string) error {
+	mfs, ok := o.upper.(MetadataFS)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	err := o.check("setmetadata", name, true)
+	if err != nil {
+		return err
+	}
+	if o.inLower(name) {
+		err = o.copyUp(name)
+		if err != nil {
+			return err
+		}
+	}
+	return mfs.SetMetadata(name, metadata)
+}
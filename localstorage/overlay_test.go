@@ -0,0 +1,158 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	testBackend(t, NewOverlayFS(os.DirFS(t.TempDir()), NewMemFS()))
+
+	lower := os.DirFS(p)
+	ofs := NewOverlayFS(lower, NewMemFS())
+
+	entries, err := fs.ReadDir(ofs, "subfolder1")
+	if err != nil || len(entries) != 3 {
+		t.Error("lower entries should be visible: ", entries, err)
+	}
+
+	// copy-up on write
+	f, err := ofs.Create("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "hello, world")
+	_ = f.Close()
+	content, err := fs.ReadFile(ofs, "subfolder1/hello.txt")
+	if err != nil || string(content) != "hello, world" {
+		t.Error("upper file should shadow lower one: ", string(content), err)
+	}
+	content, _ = os.ReadFile(path.Join(p, "subfolder1/hello.txt"))
+	if string(content) != "hello" {
+		t.Error("lower file should not be changed: ", string(content))
+	}
+
+	// whiteouts
+	err = ofs.Remove("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = fs.Stat(ofs, "subfolder1/hello.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("removed lower file should be hidden: ", err)
+	}
+	if _, err = os.Stat(path.Join(p, "subfolder1/hello.txt")); err != nil {
+		t.Error("lower file should be kept: ", err)
+	}
+	entries, err = fs.ReadDir(ofs, "subfolder1")
+	if err != nil || len(entries) != 2 {
+		t.Error("whiteouts should not be listed: ", entries, err)
+	}
+	if _, err = ofs.Create("subfolder1/" + whiteoutPrefix + "x"); err == nil {
+		t.Error("reserved name should not be created")
+	}
+
+	if err = ofs.Remove("subfolder1"); err == nil {
+		t.Error("directory with lower content should not be removed")
+	}
+	for _, name := range []string{"subfolder1/dir12/friend.txt", "subfolder1/dir12", "subfolder1/dir11", "subfolder1"} {
+		err = ofs.Remove(name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	// recreated directory does not show lower content
+	err = ofs.Mkdir("subfolder1", 0750)
+	if err != nil {
+		t.Error(err)
+	}
+	entries, err = fs.ReadDir(ofs, "subfolder1")
+	if err != nil || len(entries) != 0 {
+		t.Error("recreated directory should be empty: ", entries, err)
+	}
+
+	// rename of lower directory
+	err = ofs.Rename("subfolder2", "moved")
+	if err != nil {
+		t.Error(err)
+	}
+	content, err = fs.ReadFile(ofs, "moved/goodbye.txt")
+	if err != nil || string(content) != "friend" {
+		t.Error("lower file should be copied up: ", string(content), err)
+	}
+	if _, err = fs.Stat(ofs, "subfolder2"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("renamed lower directory should be hidden: ", err)
+	}
+	if _, err = fs.Stat(ofs, "moved/dir21"); err != nil {
+		t.Error("lower directory should be copied up: ", err)
+	}
+
+	// another overlay shares the same lower fs
+	content, err = fs.ReadFile(NewOverlayFS(lower, NewMemFS()), "subfolder2/goodbye.txt")
+	if err != nil || string(content) != "friend" {
+		t.Error("lower fs should not be changed: ", string(content), err)
+	}
+}
+
+func TestOverlayChanges(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	upper := t.TempDir()
+	feed := NewChangeFeed(upper, 100)
+	feed.MapWhiteouts()
+	defer feed.Close()
+	ofs := NewOverlayFS(os.DirFS(p), NewLocalFs(upper, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Changes:   feed,
+	}).(WriteFS))
+
+	s, err := feed.Subscribe(".", 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer s.Close()
+	for _, name := range []string{"subfolder1/hello.txt", "subfolder1/dir12/friend.txt", "subfolder1/dir12"} {
+		err = ofs.Remove(name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	err = ofs.Mkdir("done", 0750)
+	if err != nil {
+		t.Error(err)
+	}
+
+	deleted := make(map[string]bool)
+	for c := nextChange(t, s); c.Name != "done"; c = nextChange(t, s) {
+		if reservedOverlayName(c.Name) || reservedOverlayName(c.OldName) {
+			t.Error("whiteout should not be reported: ", c)
+		}
+		if c.Op == ChangeDelete {
+			deleted[c.Name] = true
+		}
+	}
+	for _, name := range []string{"subfolder1/hello.txt", "subfolder1/dir12/friend.txt", "subfolder1/dir12"} {
+		if !deleted[name] {
+			t.Error("hidden lower entry should be reported as deleted: ", name)
+		}
+	}
+}
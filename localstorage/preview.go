@@ -0,0 +1,317 @@
+package localstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // decoded by image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type PreviewSize int
+
+const (
+	PreviewSmall PreviewSize = iota
+	PreviewMedium
+	PreviewLarge
+)
+
+// previewSizes are bounding boxes of thumbnails and
+// lengths of text previews in bytes.
+var previewSizes = [...]struct {
+	name   string
+	pixels int
+	text   int
+}{
+	PreviewSmall:  {"small", 64, 256},
+	PreviewMedium: {"medium", 256, 1024},
+	PreviewLarge:  {"large", 1024, 4096},
+}
+
+// maxPreviewPixels limits images decoded for thumbnails,
+// since decoded image is kept in memory at once.
+const maxPreviewPixels = 64 << 20
+
+// ErrNoPreview is returned for files of types previews
+// are not generated for.
+var ErrNoPreview = errors.New("preview is not available for file type")
+
+// Preview is thumbnail of image or beginning of text file.
+type Preview struct {
+	MimeType string
+	Data     []byte
+	Width    int // 0 for text
+	Height   int
+}
+
+// PreviewCache generates previews and keeps them in directory outside
+// of homes. Previews are keyed by content hash, so modified files get
+// new ones, while outdated are removed by Prune once they are unused.
+// Nil cache generates previews without keeping them.
+type PreviewCache struct {
+	dir string
+}
+
+func NewPreviewCache(dir string) (*PreviewCache, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewCache{dir: dir}, nil
+}
+
+// previewKind is what preview of type is, stored previews are
+// named after it, so changed type does not reuse them.
+func previewKind(t string) (string, bool) {
+	switch {
+	case t == "image/png" || t == "image/gif":
+		return ".png", true
+	case t == "image/jpeg":
+		return ".jpg", true
+	case isText(t):
+		return ".txt", true
+	}
+	return "", false
+}
+
+// Preview returns preview of file name of fsys with sha256 content hash,
+// it is generated if it is not cached yet. Generated preview is kept
+// only if the file still has the hash, since it could be changed since
+// hash was taken.
+func (c *PreviewCache) Preview(fsys fs.FS, name string, hash string, size PreviewSize) (Preview, error) {
+	if size < PreviewSmall || size > PreviewLarge {
+		return Preview{}, fmt.Errorf("invalid preview size %d", size)
+	}
+	if len(hash) < 2 || strings.ContainsAny(hash, `/\.`) {
+		return Preview{}, errors.New("invalid content hash")
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer f.Close()
+	head, err := readHead(f)
+	if err != nil {
+		return Preview{}, err
+	}
+	t := DetectType(name, head)
+	ext, ok := previewKind(t)
+	if !ok {
+		return Preview{}, ErrNoPreview
+	}
+
+	var stored string
+	if c != nil {
+		stored = path.Join(c.dir, hash[:2], hash+"-"+previewSizes[size].name+ext)
+		p, err := c.load(stored)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return Preview{}, err
+		}
+	}
+	h := sha256.New()
+	r := io.TeeReader(io.MultiReader(bytes.NewReader(head), f), h)
+	var p Preview
+	if ext == ".txt" {
+		p, err = textPreview(r, previewSizes[size].text)
+	} else {
+		p, err = imagePreview(r, previewSizes[size].pixels, ext)
+	}
+	if err != nil || c == nil {
+		return p, err
+	}
+	_, err = io.Copy(io.Discard, r)
+	if err != nil || hex.EncodeToString(h.Sum(nil)) != hash {
+		return p, nil
+	}
+	return p, c.store(stored, p.Data)
+}
+
+// load reads stored preview, its modification time is
+// refreshed so Prune keeps it.
+func (c *PreviewCache) load(stored string) (Preview, error) {
+	data, err := os.ReadFile(stored)
+	if err != nil {
+		return Preview{}, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(stored, now, now)
+	p := Preview{Data: data}
+	switch path.Ext(stored) {
+	case ".txt":
+		p.MimeType = "text/plain"
+		return p, nil
+	case ".png":
+		p.MimeType = "image/png"
+	default:
+		p.MimeType = "image/jpeg"
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return p, err
+	}
+	p.Width, p.Height = config.Width, config.Height
+	return p, nil
+}
+
+// store replaces preview at once, so concurrent
+// readers never see it half written.
+func (c *PreviewCache) store(stored string, data []byte) error {
+	err := os.MkdirAll(path.Dir(stored), 0750)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(path.Dir(stored), ".preview*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), stored)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Prune removes previews not used for maxAge,
+// returns number of removed ones.
+func (c *PreviewCache) Prune(maxAge time.Duration) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < maxAge {
+			return nil
+		}
+		err = os.Remove(p)
+		if err == nil {
+			removed++
+		}
+		return err
+	})
+	return removed, err
+}
+
+func textPreview(r io.Reader, length int) (Preview, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return Preview{}, err
+	}
+	text, ok := validText(data, len(data) == length)
+	if !ok {
+		return Preview{}, ErrNoPreview
+	}
+	return Preview{MimeType: "text/plain", Data: []byte(text)}, nil
+}
+
+func imagePreview(r io.Reader, box int, ext string) (Preview, error) {
+	var buf bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &buf))
+	if err != nil {
+		return Preview{}, fmt.Errorf("%w: %v", ErrNoPreview, err)
+	}
+	if config.Width*config.Height > maxPreviewPixels {
+		return Preview{}, fmt.Errorf("%w: image is too large", ErrNoPreview)
+	}
+	var img image.Image
+	r = io.MultiReader(&buf, r)
+	switch ext {
+	case ".jpg":
+		img, err = jpeg.Decode(r)
+	default:
+		// first frame of animated gif
+		img, _, err = image.Decode(r)
+	}
+	if err != nil {
+		return Preview{}, fmt.Errorf("%w: %v", ErrNoPreview, err)
+	}
+	thumb := scaleDown(img, box)
+
+	var out bytes.Buffer
+	p := Preview{Width: thumb.Bounds().Dx(), Height: thumb.Bounds().Dy()}
+	if ext == ".jpg" {
+		p.MimeType = "image/jpeg"
+		err = jpeg.Encode(&out, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		// transparency is kept
+		p.MimeType = "image/png"
+		err = png.Encode(&out, thumb)
+	}
+	p.Data = out.Bytes()
+	return p, err
+}
+
+// scaleDown fits image into box keeping its aspect ratio, every pixel
+// of thumbnail is the average of pixels of source it covers.
+// Smaller images are kept as is.
+func scaleDown(src image.Image, box int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= box && h <= box {
+		return src
+	}
+	tw, th := box, box
+	if w > h {
+		th = max(1, h*box/w)
+	} else {
+		tw = max(1, w*box/h)
+	}
+	in := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+	out := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max(y*h/th+1, (y+1)*h/th)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max(x*w/tw+1, (x+1)*w/tw)
+			// colors are weighted by alpha, so transparent
+			// pixels do not darken edges
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					bl += uint64(p[2]) * pa
+					a += pa
+					n++
+				}
+			}
+			d := out.Pix[y*out.Stride+x*4:]
+			if a > 0 {
+				d[0], d[1], d[2] = uint8(r/a), uint8(g/a), uint8(bl/a)
+			}
+			d[3] = uint8(a / n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package localstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestPreview(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cacheDir, err := os.MkdirTemp("", "previews*")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(cacheDir)
+	cache, err := NewPreviewCache(cacheDir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024})
+
+	img := image.NewNRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	// named wrong, detected by content
+	_ = os.WriteFile(path.Join(p, "subfolder1/photo.jpg"), buf.Bytes(), 0640)
+	sum := func(data []byte) string {
+		h := sha256.Sum256(data)
+		return hex.EncodeToString(h[:])
+	}
+	photo := sum(buf.Bytes())
+
+	r, err := cache.Preview(lfs, "subfolder1/photo.jpg", photo, PreviewMedium)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if r.MimeType != "image/png" || r.Width != 256 || r.Height != 170 {
+		t.Error("wrong thumbnail: ", r.MimeType, r.Width, r.Height)
+	}
+	thumb, err := png.Decode(bytes.NewReader(r.Data))
+	if err != nil || thumb.Bounds().Dx() != 256 {
+		t.Error("wrong thumbnail data: ", err)
+	}
+	cached, err := cache.Preview(lfs, "subfolder1/photo.jpg", photo, PreviewMedium)
+	if err != nil || !bytes.Equal(cached.Data, r.Data) || cached.Width != 256 {
+		t.Error("thumbnail should be cached: ", err)
+	}
+	small, _ := cache.Preview(lfs, "subfolder1/photo.jpg", photo, PreviewSmall)
+	if small.Width != 64 || small.Height != 42 {
+		t.Error("wrong small thumbnail: ", small.Width, small.Height)
+	}
+
+	r, err = cache.Preview(lfs, "subfolder1/hello.txt", sum([]byte("hello")), PreviewSmall)
+	if err != nil || r.MimeType != "text/plain" || string(r.Data) != "hello" {
+		t.Error("wrong text preview: ", string(r.Data), err)
+	}
+
+	_ = os.WriteFile(path.Join(p, "subfolder2/data.bin"), []byte{0, 1, 2, 3}, 0640)
+	_, err = cache.Preview(lfs, "subfolder2/data.bin", "cc33", PreviewSmall)
+	if !errors.Is(err, ErrNoPreview) {
+		t.Error("binary files have no preview: ", err)
+	}
+
+	// file changed since hash was taken, its preview is not kept
+	r, err = cache.Preview(lfs, "subfolder2/goodbye.txt", sum([]byte("goodbye")), PreviewSmall)
+	if err != nil || string(r.Data) != "friend" {
+		t.Error("preview should be of current content: ", string(r.Data), err)
+	}
+	// nil cache does not keep previews
+	r, err = (*PreviewCache)(nil).Preview(lfs, "subfolder2/goodbye.txt", sum([]byte("friend")), PreviewSmall)
+	if err != nil || string(r.Data) != "friend" {
+		t.Error("wrong preview without cache: ", string(r.Data), err)
+	}
+
+	n, err := cache.Prune(time.Hour)
+	if err != nil || n != 0 {
+		t.Error("used previews should be kept: ", n, err)
+	}
+	n, err = cache.Prune(0)
+	if err != nil || n != 3 {
+		t.Error("wrong number of pruned previews: ", n, err)
+	}
+}
@@ -0,0 +1,195 @@
+package localstorage
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota accounts usage of trusted root incrementally.
+// Counters could drift because of changes made bypassing localfs,
+// so they should be corrected from time to time with Reconcile.
+type Quota struct {
+	mu       sync.Mutex
+	maxBytes int64 // 0 for unlimited
+	maxFiles int64 // 0 for unlimited
+	bytes    int64
+	files    int64
+}
+
+func NewQuota(maxBytes int64, maxFiles int64) *Quota {
+	return &Quota{maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+func (q *Quota) SetLimits(maxBytes int64, maxFiles int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxBytes = maxBytes
+	q.maxFiles = maxFiles
+}
+
+func (q *Quota) Limits() (maxBytes int64, maxFiles int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.maxBytes, q.maxFiles
+}
+
+func (q *Quota) Usage() (bytes int64, files int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.bytes, q.files
+}
+
+// reserve accounts growth if it fits into limits.
+// Nil quota is unlimited.
+func (q *Quota) reserve(bytes int64, files int64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if bytes > 0 && q.maxBytes > 0 && q.bytes+bytes > q.maxBytes {
+		return ErrQuotaExceeded
+	}
+	if files > 0 && q.maxFiles > 0 && q.files+files > q.maxFiles {
+		return ErrQuotaExceeded
+	}
+	q.bytes += bytes
+	q.files += files
+	return nil
+}
+
+func (q *Quota) release(bytes int64, files int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes -= bytes
+	q.files -= files
+	if q.bytes < 0 {
+		q.bytes = 0
+	}
+	if q.files < 0 {
+		q.files = 0
+	}
+}
+
+// restore accounts growth regardless of limits, it takes back
+// space released in advance by operation which failed.
+func (q *Quota) restore(bytes int64, files int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes += bytes
+	q.files += files
+}
+
+// usage counts regular files under root, hard linked
+// ones, e.g. shared with snapshots, are counted once.
+// Service files kept right in reserved dir, e.g. databases
+// of stores, are not user content and are not counted.
+func usage(root string) (int64, int64, error) {
+	var bytes, files int64
+	seen := make(map[inode]bool)
+	service := filepath.Join(root, reservedDir)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed during scan
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || filepath.Dir(p) == service {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if hardLinks(info) > 1 {
+			id, ok := inodeOf(info)
+			if ok && seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+		bytes += info.Size()
+		files += 1
+		return nil
+	})
+	return bytes, files, err
+}
+
+// soleUsage counts regular files under p not hard linked
+// elsewhere, i.e. space freed once p is removed.
+func soleUsage(p string) (int64, int64) {
+	var bytes, files int64
+	_ = filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err == nil && hardLinks(info) <= 1 {
+			bytes += info.Size()
+			files += 1
+		}
+		return nil
+	})
+	return bytes, files
+}
+
+// Reconcile recounts usage by scanning root. Service data inside
+// reserved dir is counted as well, including files kept by snapshots
+// only, since they still take space.
+func (q *Quota) Reconcile(root string) error {
+	bytes, files, err := usage(root)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes = bytes
+	q.files = files
+	return nil
+}
+
+// ReconcileFS recounts usage by scanning file system
+// not on local disk.
+func (q *Quota) ReconcileFS(fsys fs.FS) error {
+	var bytes, files int64
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		bytes += info.Size()
+		files += 1
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bytes = bytes
+	q.files = files
+	return nil
+}
@@ -0,0 +1,101 @@
+package localstorage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	p, c, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// stores keep their databases in reserved dir
+	hashes, err := NewHashStore(p, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer hashes.Close()
+
+	// tmpDir content: 3 files, 17 bytes
+	quota := NewQuota(30, 5)
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	bytes, files := quota.Usage()
+	if bytes != 17 || files != 3 {
+		t.Error("bytes = ", bytes, "; files = ", files, "; entries = ", c)
+	}
+
+	wfs := NewLocalFs(p, &Config{
+		CacheSize:     10 * 1024 * 1024,
+		CacheDuration: 0,
+		Quota:         quota,
+	}).(WriteFS)
+
+	f, err := wfs.Create("subfolder2/new.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = f.Write([]byte("0123456789"))
+	if err != nil {
+		t.Error(err)
+	}
+	// io.Copy should not bypass accounting
+	_, err = io.Copy(f, strings.NewReader("0123456789"))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("should exceed bytes quota, got ", err)
+	}
+	err = f.(*File).Truncate(5)
+	if err != nil {
+		t.Error(err)
+	}
+	f.Close()
+
+	bytes, files = quota.Usage()
+	if bytes != 22 || files != 4 {
+		t.Error("bytes = ", bytes, "; files = ", files)
+	}
+
+	_, err = wfs.Create("subfolder2/second.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = wfs.Create("subfolder2/third.txt")
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("should exceed files quota, got ", err)
+	}
+
+	err = wfs.Rename("subfolder2/new.txt", "subfolder2/goodbye.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	err = wfs.Remove("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	bytes, files = quota.Usage()
+	if bytes != 11 || files != 3 {
+		t.Error("bytes = ", bytes, "; files = ", files)
+	}
+
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	reconciled, _ := quota.Usage()
+	if reconciled != bytes {
+		t.Error("incremental usage ", bytes, " differs from reconciled ", reconciled)
+	}
+}
@@ -0,0 +1,552 @@
+package localstorage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+)
+
+// SearchQuery filters indexed files, zero fields match everything.
+type SearchQuery struct {
+	Text           string // words of name or text content, matched by prefix
+	Prefix         string // directory to search in, "." or empty for all
+	MimePrefix     string // e.g. "image/" or "text/plain"
+	MinSize        int64
+	MaxSize        int64 // 0 for unlimited
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Metadata       map[string]string // user metadata, empty value matches any
+	Limit          int               // 0 for default
+}
+
+type SearchResult struct {
+	Name     string
+	IsDir    bool
+	Size     int64
+	Modified time.Time
+	MimeType string  // empty for directories
+	Snippet  string  // matched text content with words in brackets
+	Rank     float64 // lower is better, 0 without text
+}
+
+// DefaultSearchLimit is number of results returned if query has no limit.
+const DefaultSearchLimit = 100
+
+const (
+	searchDatabase = "search.db"
+	tableFiles     = "search_files"
+	tableText      = "search_text"
+	tableMeta      = "search_metadata"
+
+	maxSearchLimit = 1000
+	// maxIndexedText is how much of text file is indexed.
+	maxIndexedText = 1 << 20
+	sniffSize      = 512
+)
+
+// SearchIndex keeps names, sizes, modification times, types, user
+// metadata and text content of files under root in full text index
+// inside reserved directory. Files are read through fsys, so content stored
+// compressed or encrypted is indexed as seen by users.
+type SearchIndex struct {
+	db          *dbx.DB
+	fsys        fs.FS
+	withContent bool
+}
+
+type indexedFile struct {
+	RowID    int64  `db:"rowid"`
+	Name     string `db:"name"`
+	IsDir    bool   `db:"is_dir"`
+	Size     int64  `db:"size"`
+	Modified int64  `db:"modified"` // unix nano
+	MimeType string `db:"mime"`
+}
+
+func initSearchTables(db *dbx.DB) error {
+	exists, err := database.TableExists(db, tableFiles)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = initFilesTables(db)
+		if err != nil {
+			return err
+		}
+	}
+	metaExists, err := database.TableExists(db, tableMeta)
+	if err != nil || metaExists {
+		return err
+	}
+	meta := make(map[string]string)
+	meta["file"] = "INTEGER NOT NULL" // rowid of file
+	meta["key"] = "TEXT NOT NULL"
+	meta["value"] = "TEXT NOT NULL"
+	_, err = db.CreateTable(tableMeta, meta).Execute()
+	if err != nil {
+		return err
+	}
+	_, err = db.CreateUniqueIndex(tableMeta, tableMeta+"_file_key", "file", "key").Execute()
+	if err != nil || !exists {
+		return err
+	}
+	// index made before metadata was kept, files are read again
+	_, err = db.NewQuery("DELETE FROM " + tableText).Execute()
+	if err != nil {
+		return err
+	}
+	_, err = db.Delete(tableFiles, nil).Execute()
+	return err
+}
+
+func initFilesTables(db *dbx.DB) error {
+	files := make(map[string]string)
+	files["name"] = "TEXT UNIQUE NOT NULL"
+	files["is_dir"] = "BOOLEAN NOT NULL"
+	files["size"] = "INTEGER NOT NULL"
+	files["modified"] = "INTEGER NOT NULL"
+	files["mime"] = "TEXT DEFAULT '' NOT NULL"
+	_, err := db.CreateTable(tableFiles, files).Execute()
+	if err != nil {
+		return err
+	}
+	// rows share rowid with files
+	_, err = db.NewQuery("CREATE VIRTUAL TABLE " + tableText +
+		" USING fts5(base, body)").Execute()
+	return err
+}
+
+// NewSearchIndex opens index of dir, files are read through fsys
+// rooted at dir. Text content is indexed only if withContent is set.
+func NewSearchIndex(dir string, fsys fs.FS, withContent bool) (*SearchIndex, error) {
+	dir = filepath.Clean(dir)
+	err := os.MkdirAll(path.Join(dir, reservedDir), 0750)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.ConnectDB(path.Join(dir, reservedDir, searchDatabase))
+	if err != nil {
+		return nil, err
+	}
+	err = initSearchTables(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &SearchIndex{db: db, fsys: fsys, withContent: withContent}, nil
+}
+
+func (s *SearchIndex) Close() error {
+	return s.db.Close()
+}
+
+// Follow keeps index up to date with changes of feed until it is
+// closed. Index is brought up to date first and every time changes
+// are lost.
+func (s *SearchIndex) Follow(feed *ChangeFeed) {
+	for {
+		sub, err := feed.Subscribe(".", 0)
+		if err != nil {
+			log.Printf("cannot follow changes: %v", err)
+			return
+		}
+		err = s.Rebuild()
+		if err != nil {
+			log.Printf("cannot rebuild search index: %v", err)
+		}
+		for c := range sub.C {
+			err = s.apply(c)
+			if err != nil {
+				log.Printf("cannot index %s: %v", c.Name, err)
+			}
+		}
+		if sub.Err() == nil {
+			return
+		}
+	}
+}
+
+func (s *SearchIndex) apply(c Change) error {
+	switch {
+	case c.Name == ".":
+		return s.Rebuild()
+	case c.Op == ChangeDelete:
+		return s.remove(c.Name)
+	case c.Op == ChangeRename:
+		err := s.rename(c.OldName, c.Name)
+		if err != nil {
+			return err
+		}
+	}
+	err := s.Update(c.Name)
+	if err != nil {
+		return err
+	}
+	// metadata is changed without touching content
+	return s.indexMetadata(c.Name)
+}
+
+// Rebuild brings whole index up to date, only files changed
+// since they were indexed are read.
+func (s *SearchIndex) Rebuild() error {
+	return s.Update(".")
+}
+
+// Update indexes file or directory with everything inside it,
+// and drops it from index if it no longer exists.
+func (s *SearchIndex) Update(name string) error {
+	seen := make(map[string]bool)
+	err := fs.WalkDir(s.fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == name && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			// unreadable entries are left as indexed
+			seen[p] = true
+			return nil
+		}
+		if p == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			seen[p] = true
+			return nil
+		}
+		seen[p] = true
+		return s.index(p, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	err = s.db.Select("name").From(tableFiles).Where(under(name)).Column(&names)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if !seen[n] {
+			err = s.remove(n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// index stores file unless it is indexed already.
+func (s *SearchIndex) index(name string, info fs.FileInfo) error {
+	var stored indexedFile
+	err := s.db.Select("rowid", "name", "is_dir", "size", "modified", "mime").
+		From(tableFiles).
+		Where(dbx.HashExp{"name": name}).
+		One(&stored)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	found := err == nil
+	if found && stored.IsDir == info.IsDir() && stored.Size == info.Size() &&
+		stored.Modified == info.ModTime().UnixNano() {
+		return nil
+	}
+
+	f := indexedFile{
+		RowID:    stored.RowID,
+		Name:     name,
+		IsDir:    info.IsDir(),
+		Size:     info.Size(),
+		Modified: info.ModTime().UnixNano(),
+	}
+	var body string
+	if info.Mode().IsRegular() {
+		f.MimeType, body = s.read(name)
+	}
+	err = s.db.Transactional(func(tx *dbx.Tx) error {
+		params := dbx.Params{
+			"rowid":    f.RowID,
+			"name":     f.Name,
+			"is_dir":   f.IsDir,
+			"size":     f.Size,
+			"modified": f.Modified,
+			"mime":     f.MimeType,
+			"base":     path.Base(name),
+			"body":     body,
+		}
+		if found {
+			_, err := tx.NewQuery("UPDATE " + tableFiles +
+				" SET is_dir = {:is_dir}, size = {:size}, modified = {:modified}, mime = {:mime}" +
+				" WHERE rowid = {:rowid}").Bind(params).Execute()
+			if err != nil {
+				return err
+			}
+			_, err = tx.NewQuery("DELETE FROM " + tableText + " WHERE rowid = {:rowid}").
+				Bind(params).Execute()
+			if err != nil {
+				return err
+			}
+		} else {
+			r, err := tx.NewQuery("INSERT INTO " + tableFiles +
+				" (name, is_dir, size, modified, mime)" +
+				" VALUES ({:name}, {:is_dir}, {:size}, {:modified}, {:mime})").
+				Bind(params).Execute()
+			if err != nil {
+				return err
+			}
+			params["rowid"], err = r.LastInsertId()
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.NewQuery("INSERT INTO " + tableText +
+			" (rowid, base, body) VALUES ({:rowid}, {:base}, {:body})").
+			Bind(params).Execute()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return s.indexMetadata(name)
+}
+
+// indexMetadata stores user metadata of indexed file,
+// if it is kept by fsys.
+func (s *SearchIndex) indexMetadata(name string) error {
+	mfs, ok := s.fsys.(MetadataFS)
+	if !ok {
+		return nil
+	}
+	var rowid int64
+	err := s.db.Select("rowid").From(tableFiles).Where(dbx.HashExp{"name": name}).Row(&rowid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	metadata, err := mfs.Metadata(name)
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, fs.ErrNotExist) {
+		metadata = nil
+	} else if err != nil {
+		return err
+	}
+	return s.db.Transactional(func(tx *dbx.Tx) error {
+		_, err := tx.Delete(tableMeta, dbx.HashExp{"file": rowid}).Execute()
+		if err != nil {
+			return err
+		}
+		for k, v := range metadata {
+			_, err = tx.Insert(tableMeta, dbx.Params{"file": rowid, "key": k, "value": v}).Execute()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// read detects type of file and returns its text content
+// if it is indexed.
+func (s *SearchIndex) read(name string) (string, string) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	head, err := readHead(f)
+	if err != nil {
+		return "", ""
+	}
+	t := DetectType(name, head)
+	if !s.withContent || !isText(t) {
+		return t, ""
+	}
+	n := len(head)
+	rest, err := io.ReadAll(io.LimitReader(f, maxIndexedText-int64(n)))
+	if err != nil {
+		return t, ""
+	}
+	text, ok := validText(append(head, rest...), n+len(rest) == maxIndexedText)
+	if !ok {
+		return t, ""
+	}
+	return t, text
+}
+
+// validText returns text if it is UTF-8, last character is
+// dropped if text could be cut in the middle of it.
+func validText(text []byte, cut bool) (string, bool) {
+	if cut {
+		for i := 0; i < utf8.UTFMax && len(text) > 0 && !utf8.Valid(text); i++ {
+			text = text[:len(text)-1]
+		}
+	}
+	return string(text), utf8.Valid(text)
+}
+
+var textTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/yaml":       true,
+	"application/toml":       true,
+	"image/svg+xml":          true,
+}
+
+func isText(t string) bool {
+	return strings.HasPrefix(t, "text/") || textTypes[t]
+}
+
+// remove drops file or directory with everything inside it.
+func (s *SearchIndex) remove(name string) error {
+	return s.db.Transactional(func(tx *dbx.Tx) error {
+		for _, table := range []string{tableText + " WHERE rowid", tableMeta + " WHERE file"} {
+			_, err := tx.NewQuery("DELETE FROM " + table + " IN (SELECT rowid FROM " + tableFiles +
+				" WHERE name = {:name} OR substr(name, 1, length({:name}) + 1) = {:name} || '/')").
+				Bind(dbx.Params{"name": name}).Execute()
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Delete(tableFiles, under(name)).Execute()
+		return err
+	})
+}
+
+// rename moves indexed file or directory without reading it again.
+func (s *SearchIndex) rename(oldName string, newName string) error {
+	err := s.remove(newName)
+	if err != nil {
+		return err
+	}
+	return s.db.Transactional(func(tx *dbx.Tx) error {
+		params := dbx.Params{"old": oldName, "new": newName, "base": path.Base(newName)}
+		_, err := tx.NewQuery("UPDATE " + tableFiles +
+			" SET name = {:new} || substr(name, length({:old}) + 1)" +
+			" WHERE name = {:old} OR substr(name, 1, length({:old}) + 1) = {:old} || '/'").
+			Bind(params).Execute()
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewQuery("UPDATE " + tableText + " SET base = {:base}" +
+			" WHERE rowid = (SELECT rowid FROM " + tableFiles + " WHERE name = {:new})").
+			Bind(params).Execute()
+		return err
+	})
+}
+
+// matchQuery turns words into prefix queries, so user input
+// is never parsed as query syntax.
+func matchQuery(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"*`
+	}
+	return strings.Join(words, " ")
+}
+
+// Search returns files matching q, best matches first if text
+// is given, otherwise ordered by name.
+func (s *SearchIndex) Search(q SearchQuery) ([]SearchResult, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	where := []string{"1=1"}
+	params := dbx.Params{"limit": limit}
+	if q.Prefix != "" && q.Prefix != "." {
+		where = append(where, "(f.name = {:prefix} OR substr(f.name, 1, length({:prefix}) + 1) = {:prefix} || '/')")
+		params["prefix"] = path.Clean(q.Prefix)
+	}
+	if q.MimePrefix != "" {
+		where = append(where, "substr(f.mime, 1, length({:mime})) = {:mime}")
+		params["mime"] = q.MimePrefix
+	}
+	if q.MinSize > 0 {
+		where = append(where, "f.size >= {:min_size}")
+		params["min_size"] = q.MinSize
+	}
+	if q.MaxSize > 0 {
+		where = append(where, "f.size <= {:max_size}")
+		params["max_size"] = q.MaxSize
+	}
+	if !q.ModifiedAfter.IsZero() {
+		where = append(where, "f.modified > {:after}")
+		params["after"] = q.ModifiedAfter.UnixNano()
+	}
+	if !q.ModifiedBefore.IsZero() {
+		where = append(where, "f.modified < {:before}")
+		params["before"] = q.ModifiedBefore.UnixNano()
+	}
+	keys := make([]string, 0, len(q.Metadata))
+	for k := range q.Metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for i, k := range keys {
+		key, value := fmt.Sprintf("meta_key%d", i), fmt.Sprintf("meta_value%d", i)
+		cond := "m.key = {:" + key + "}"
+		if q.Metadata[k] != "" {
+			cond += " AND m.value = {:" + value + "}"
+		}
+		where = append(where, "EXISTS (SELECT 1 FROM "+tableMeta+" m WHERE m.file = f.rowid AND "+cond+")")
+		params[key] = k
+		params[value] = q.Metadata[k]
+	}
+
+	var sqlText string
+	match := matchQuery(q.Text)
+	if match == "" {
+		sqlText = "SELECT f.name, f.is_dir, f.size, f.modified, f.mime, '' AS snippet, 0.0 AS rank" +
+			" FROM " + tableFiles + " f WHERE " + strings.Join(where, " AND ") +
+			" ORDER BY f.name LIMIT {:limit}"
+	} else {
+		where = append(where, tableText+" MATCH {:match}")
+		params["match"] = match
+		sqlText = "SELECT f.name, f.is_dir, f.size, f.modified, f.mime," +
+			" snippet(" + tableText + ", 1, '[', ']', '...', 16) AS snippet, " + tableText + ".rank AS rank" +
+			" FROM " + tableText + " JOIN " + tableFiles + " f ON f.rowid = " + tableText + ".rowid" +
+			" WHERE " + strings.Join(where, " AND ") +
+			" ORDER BY " + tableText + ".rank LIMIT {:limit}"
+	}
+
+	var rows []struct {
+		indexedFile
+		Snippet string  `db:"snippet"`
+		Rank    float64 `db:"rank"`
+	}
+	err := s.db.NewQuery(sqlText).Bind(params).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		r = append(r, SearchResult{
+			Name:     row.Name,
+			IsDir:    row.IsDir,
+			Size:     row.Size,
+			Modified: time.Unix(0, row.Modified),
+			MimeType: row.MimeType,
+			Snippet:  row.Snippet,
+			Rank:     row.Rank,
+		})
+	}
+	return r, nil
+}
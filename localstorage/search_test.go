@@ -0,0 +1,132 @@
+package localstorage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// waitSearch polls index followed in background until query
+// returns want results.
+func waitSearch(t *testing.T, s *SearchIndex, q SearchQuery, want int) []SearchResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := s.Search(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r) == want {
+			return r
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%+v: expected %d results, got %+v", q, want, r)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	metadata, err := NewMetadataStore(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer metadata.Close()
+	feed := NewChangeFeed(p, 100)
+	lfs := NewLocalFs(p, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Changes:   feed,
+		Metadata:  metadata,
+	}).(WriteFS)
+	index, err := NewSearchIndex(p, lfs, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer index.Close()
+	done := make(chan struct{})
+	go func() {
+		index.Follow(feed)
+		close(done)
+	}()
+
+	// by name and by content
+	r := waitSearch(t, index, SearchQuery{Text: "friend"}, 2)
+	if r[0].Name != "subfolder1/dir12/friend.txt" || r[0].MimeType != "text/plain" {
+		t.Error("name match should be first: ", r)
+	}
+	if r[1].Name != "subfolder2/goodbye.txt" || r[1].Snippet != "[friend]" {
+		t.Error("wrong content match: ", r[1])
+	}
+	waitSearch(t, index, SearchQuery{Text: "fri", Prefix: "subfolder2"}, 1)
+	waitSearch(t, index, SearchQuery{Prefix: "subfolder1"}, 5)
+	waitSearch(t, index, SearchQuery{MimePrefix: "text/", MinSize: 6}, 2)
+
+	// written through fs
+	f, err := lfs.Create("subfolder2/notes.md")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("meeting about the quarterly budget"))
+	_ = f.Close()
+	r = waitSearch(t, index, SearchQuery{Text: "quarter budget"}, 1)
+	if r[0].Name != "subfolder2/notes.md" || r[0].Size != 34 {
+		t.Error("wrong result: ", r[0])
+	}
+
+	// by user metadata, set without changing content
+	err = lfs.(MetadataFS).SetMetadata("subfolder2/notes.md", map[string]string{"source": "pipeline", "stage": "raw"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r = waitSearch(t, index, SearchQuery{Metadata: map[string]string{"source": "pipeline", "stage": ""}}, 1)
+	if r[0].Name != "subfolder2/notes.md" {
+		t.Error("wrong result: ", r[0])
+	}
+	waitSearch(t, index, SearchQuery{Text: "budget", Metadata: map[string]string{"source": "other"}}, 0)
+	err = lfs.(MetadataFS).SetMetadata("subfolder2/notes.md", map[string]string{"stage": ""})
+	if err != nil {
+		t.Error(err)
+	}
+	waitSearch(t, index, SearchQuery{Metadata: map[string]string{"stage": ""}}, 0)
+
+	// renamed and removed
+	err = lfs.Rename("subfolder2", "archive")
+	if err != nil {
+		t.Error(err)
+	}
+	waitSearch(t, index, SearchQuery{Text: "budget", Prefix: "archive", Metadata: map[string]string{"source": "pipeline"}}, 1)
+	err = lfs.Remove("archive/notes.md")
+	if err != nil {
+		t.Error(err)
+	}
+	waitSearch(t, index, SearchQuery{Text: "budget"}, 0)
+
+	_ = feed.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("index should stop following closed feed")
+	}
+
+	// changed bypassing fs is noticed by rebuild,
+	// fs is opened again to skip its cache
+	_ = os.WriteFile(p+"/subfolder1/hello.txt", []byte("hello again"), 0640)
+	index.fsys = NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024})
+	err = index.Rebuild()
+	if err != nil {
+		t.Error(err)
+	}
+	waitSearch(t, index, SearchQuery{Text: "again"}, 1)
+}
@@ -0,0 +1,506 @@
+package localstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotsDir     = "snapshots"
+	snapshotInfoExt  = ".json"
+	snapshotIDFormat = "2006-01-02_15-04-05"
+	scheduledLabel   = "scheduled"
+	// SnapshotsDir is read-only virtual directory in root of localfs
+	// snapshots are browsed in, one directory per snapshot named by
+	// its ID. It is not listed in root, so walks do not descend into it.
+	SnapshotsDir = ".snapshots"
+)
+
+// SnapshotFS is implemented by file systems taking point-in-time
+// copies of the whole tree. Files are hard linked into snapshots
+// and copied before they are changed in place.
+type SnapshotFS interface {
+	Snapshot(label string) (Snapshot, error)
+	ListSnapshots() ([]Snapshot, error)
+	// RestoreSnapshot copies file or directory from snapshot back
+	// to its path and returns it, taken path is not replaced.
+	RestoreSnapshot(id string, name string) (string, error)
+	DeleteSnapshot(id string) error
+	// ScheduleSnapshot takes snapshot if one is due by policy.
+	ScheduleSnapshot() (bool, error)
+	// PruneSnapshots removes scheduled snapshots beyond retention.
+	PruneSnapshots() (int, error)
+}
+
+// SnapshotPolicy controls scheduled snapshots. Both limits are
+// applied to scheduled ones only, taken on demand are kept until
+// deleted. Zero limits keep snapshots forever.
+type SnapshotPolicy struct {
+	Interval time.Duration // between scheduled snapshots, 0 to disable them
+	Keep     int           // scheduled snapshots, 0 for unlimited
+	MaxAge   time.Duration // 0 for unlimited
+}
+
+type Snapshot struct {
+	ID        string    `json:"id"` // name of directory inside SnapshotsDir
+	Label     string    `json:"label"`
+	Created   time.Time `json:"created"`
+	Scheduled bool      `json:"scheduled"`
+	Size      int64     `json:"size"`  // bytes of all files
+	Files     int64     `json:"files"` // regular files count
+	// Held is size of files kept by snapshot only, which is freed once
+	// it is deleted. It counts toward quota and is computed when
+	// snapshots are listed.
+	Held int64 `json:"-"`
+}
+
+// openWriters counts files of all roots being written in place,
+// since several localfs instances could share the same root.
+// Snapshots skip them, since linked content would change along.
+var openWriters = struct {
+	sync.Mutex
+	m map[string]int // by full path
+}{m: make(map[string]int)}
+
+// writerOpened marks file as being written and returns function
+// called once it is closed.
+func writerOpened(fullPath string) func() {
+	openWriters.Lock()
+	openWriters.m[fullPath]++
+	openWriters.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			openWriters.Lock()
+			defer openWriters.Unlock()
+			openWriters.m[fullPath]--
+			if openWriters.m[fullPath] <= 0 {
+				delete(openWriters.m, fullPath)
+			}
+		})
+	}
+}
+
+func beingWritten(fullPath string) bool {
+	openWriters.Lock()
+	defer openWriters.Unlock()
+	return openWriters.m[fullPath] > 0
+}
+
+func (t *localfs) snapshotsBase() string {
+	return path.Join(t.trustedRoot, reservedDir, snapshotsDir)
+}
+
+func validSnapshotID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) ||
+		strings.HasSuffix(id, snapshotInfoExt) {
+		return fmt.Errorf("invalid snapshot id %q", id)
+	}
+	return nil
+}
+
+func readSnapshot(base string, id string) (Snapshot, error) {
+	var s Snapshot
+	data, err := os.ReadFile(path.Join(base, id+snapshotInfoExt))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, fmt.Errorf("snapshot %s: %w", id, fs.ErrNotExist)
+		}
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// linkTree hard links regular files of src into dst created
+// along with directories, other files and files being written
+// are skipped. It returns size and count of linked files.
+func linkTree(src string, dst string, skip string) (int64, int64, error) {
+	var bytes, files int64
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed during snapshot
+				return nil
+			}
+			return err
+		}
+		if p == skip {
+			return fs.SkipDir
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			err = os.Mkdir(target, info.Mode().Perm())
+			if rel == "." && errors.Is(err, fs.ErrExist) {
+				return nil
+			}
+			return err
+		case d.Type().IsRegular() && !beingWritten(p):
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			err = os.Link(p, target)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			bytes += info.Size()
+			files += 1
+			return err
+		}
+		return nil
+	})
+	return bytes, files, err
+}
+
+// Snapshot hard links current tree into reserved dir. Taking it
+// costs no quota, since files are shared with the tree, space they
+// hold once files are changed or removed stays counted until
+// snapshot is deleted and is reported as Held.
+func (t *localfs) Snapshot(label string) (Snapshot, error) {
+	return t.snapshot(label, false)
+}
+
+func (t *localfs) snapshot(label string, scheduled bool) (Snapshot, error) {
+	if !snapshotsSupported {
+		return Snapshot{}, errors.ErrUnsupported
+	}
+	base := t.snapshotsBase()
+	err := os.MkdirAll(base, 0750)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s := Snapshot{
+		Label:     label,
+		Created:   time.Now(),
+		Scheduled: scheduled,
+	}
+	// ids are timestamps, so snapshots are listed in order they were taken
+	s.ID = freeName(base, s.Created.UTC().Format(snapshotIDFormat))
+	dir := path.Join(base, s.ID)
+	err = os.Mkdir(dir, 0750)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s.Size, s.Files, err = linkTree(t.trustedRoot, dir, path.Join(t.trustedRoot, reservedDir))
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(s)
+	}
+	if err == nil {
+		err = os.WriteFile(path.Join(base, s.ID+snapshotInfoExt), data, 0640)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return Snapshot{}, err
+	}
+	return s, nil
+}
+
+// ListSnapshots returns recent snapshots first.
+func (t *localfs) ListSnapshots() ([]Snapshot, error) {
+	base := t.snapshotsBase()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Snapshot{}, nil
+		}
+		return nil, err
+	}
+	r := make([]Snapshot, 0, len(entries))
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), snapshotInfoExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		s, err := readSnapshot(base, id)
+		if err != nil {
+			continue
+		}
+		s.Held, err = heldSize(path.Join(base, id))
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, s)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Created.After(r[j].Created)
+	})
+	return r, nil
+}
+
+// heldSize sums files of snapshot in dir not linked elsewhere,
+// i.e. changed or removed in tree since and not kept by other
+// snapshots.
+func heldSize(dir string) (int64, error) {
+	var held int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if hardLinks(info) == 1 {
+			held += info.Size()
+		}
+		return nil
+	})
+	return held, err
+}
+
+func (t *localfs) snapshotDir(id string) (string, error) {
+	err := validSnapshotID(id)
+	if err != nil {
+		return "", err
+	}
+	base := t.snapshotsBase()
+	_, err = readSnapshot(base, id)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(base, id), nil
+}
+
+// RestoreSnapshot links file or directory of snapshot back into tree,
+// under a new name if its path is taken. Restored files are shared
+// with snapshot, so they cost no quota.
+func (t *localfs) RestoreSnapshot(id string, name string) (string, error) {
+	dir, err := t.snapshotDir(id)
+	if err != nil {
+		return "", err
+	}
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "restore", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return "", errors.New("cannot restore root, restore its entries instead")
+	}
+	src := path.Join(dir, name)
+	info, err := os.Lstat(src)
+	if err != nil {
+		return "", &fs.PathError{Op: "restore", Path: name, Err: fs.ErrNotExist}
+	}
+
+	restored := freeName(t.trustedRoot, name)
+	fullPath := path.Join(t.trustedRoot, restored)
+	_, err = t.verifyPath(fullPath)
+	if err != nil {
+		return "", err
+	}
+	// parent could be removed since
+	err = os.MkdirAll(path.Dir(fullPath), 0750)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		_, _, err = linkTree(src, fullPath, "")
+	} else {
+		err = os.Link(src, fullPath)
+	}
+	if err != nil {
+		_ = os.RemoveAll(fullPath)
+		return "", err
+	}
+	t.config.Hashes.refresh(fullPath)
+	t.config.Changes.publish(ChangeCreate, fullPath, "", info.IsDir())
+	return restored, nil
+}
+
+// DeleteSnapshot gives back space held by snapshot.
+func (t *localfs) DeleteSnapshot(id string) error {
+	dir, err := t.snapshotDir(id)
+	if err != nil {
+		return err
+	}
+	size, files := soleUsage(dir)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	t.config.Quota.release(size, files)
+	return os.Remove(dir + snapshotInfoExt)
+}
+
+// ScheduleSnapshot takes snapshot if the last scheduled one
+// is older than interval of configured policy.
+func (t *localfs) ScheduleSnapshot() (bool, error) {
+	policy := t.config.Snapshots
+	if policy == nil || policy.Interval <= 0 {
+		return false, nil
+	}
+	snapshots, err := t.ListSnapshots()
+	if err != nil {
+		return false, err
+	}
+	for _, s := range snapshots {
+		if s.Scheduled && time.Since(s.Created) < policy.Interval {
+			return false, nil
+		}
+	}
+	_, err = t.snapshot(scheduledLabel, true)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneSnapshots deletes scheduled snapshots beyond retention
+// of configured policy.
+func (t *localfs) PruneSnapshots() (int, error) {
+	policy := t.config.Snapshots
+	if policy == nil {
+		return 0, nil
+	}
+	snapshots, err := t.ListSnapshots()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	cnt, kept := 0, 0
+	for _, s := range snapshots {
+		if !s.Scheduled {
+			continue
+		}
+		if (policy.Keep <= 0 || kept < policy.Keep) &&
+			(policy.MaxAge <= 0 || now.Sub(s.Created) <= policy.MaxAge) {
+			kept += 1
+			continue
+		}
+		err = t.DeleteSnapshot(s.ID)
+		if err != nil {
+			return cnt, err
+		}
+		cnt += 1
+	}
+	return cnt, nil
+}
+
+// unshare gives file its own copy of content if it is hard linked
+// with snapshots, so they are not changed along with it. The copy
+// takes space, content kept by snapshots stays counted.
+func (t *localfs) unshare(fullPath string) (err error) {
+	info, err := os.Lstat(fullPath)
+	if err != nil || !info.Mode().IsRegular() || hardLinks(info) < 2 {
+		return err
+	}
+	err = t.config.Quota.reserve(info.Size(), 1)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			t.config.Quota.release(info.Size(), 1)
+		}
+	}()
+	src, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(path.Join(t.trustedRoot, reservedDir), "unshare*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		return err
+	}
+	t.config.Metadata.restore(tmp.Name(), t.config.Metadata.keep(fullPath))
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+// snapshotName returns name inside SnapshotsDir.
+func snapshotName(name string) (string, bool) {
+	if name == SnapshotsDir {
+		return ".", true
+	}
+	return strings.CutPrefix(name, SnapshotsDir+"/")
+}
+
+// snapshotView serves SnapshotsDir from reserved dir,
+// where snapshots are kept along with their info files.
+type snapshotView struct {
+	base string
+}
+
+type snapshotsInfo struct {
+	fs.FileInfo
+}
+
+func (i snapshotsInfo) Name() string {
+	return SnapshotsDir
+}
+
+// taken reports whether snapshot is complete,
+// info file is written once its tree is linked.
+func (v snapshotView) taken(id string) bool {
+	if validSnapshotID(id) != nil {
+		return false
+	}
+	_, err := os.Stat(path.Join(v.base, id+snapshotInfoExt))
+	return err == nil
+}
+
+func (v snapshotView) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		id, _, _ := strings.Cut(name, "/")
+		if !v.taken(id) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return os.DirFS(v.base).Open(name)
+	}
+
+	info, err := os.Stat(v.base)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: SnapshotsDir, Err: fs.ErrNotExist}
+	}
+	entries, err := os.ReadDir(v.base)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() && v.taken(e.Name()) {
+			dirs = append(dirs, e)
+		}
+	}
+	return &dirFile{info: snapshotsInfo{info}, entries: dirs}, nil
+}
+
+func (t *localfs) snapshotView() fs.FS {
+	return snapshotView{base: t.snapshotsBase()}
+}
@@ -0,0 +1,257 @@
+package localstorage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	quota := NewQuota(0, 0)
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	metadata, err := NewMetadataStore(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer metadata.Close()
+	lfs := NewLocalFs(p, &Config{
+		CacheSize: 10 * 1024 * 1024,
+		Quota:     quota,
+		Metadata:  metadata,
+		Snapshots: &SnapshotPolicy{Interval: time.Hour, Keep: 1},
+	})
+	wfs := lfs.(WriteFS)
+	sfs := lfs.(SnapshotFS)
+	err = lfs.(MetadataFS).SetMetadata("subfolder1/hello.txt", map[string]string{"color": "red"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	before, beforeFiles := quota.Usage()
+
+	s, err := sfs.Snapshot("before cleanup")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s.Files != 3 || s.Size != 17 || s.Scheduled {
+		t.Error("wrong snapshot: ", s)
+	}
+	// taking snapshot costs no quota, files are shared with the tree
+	if used, _ := quota.Usage(); used != before {
+		t.Error("snapshot should not be counted: ", used-before)
+	}
+
+	// later writes do not change snapshot
+	f, err := wfs.Create("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "changed")
+	_ = f.Close()
+	err = lfs.(MetadataFS).SetMetadata("subfolder1/hello.txt", map[string]string{"color": "blue"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = wfs.Remove("subfolder1/dir12/friend.txt")
+	if err != nil {
+		t.Error(err)
+	}
+
+	list, _ := sfs.ListSnapshots()
+	if len(list) != 1 || list[0].Held != 11 {
+		t.Error("changed and removed files should be held by snapshot: ", list)
+	}
+	// held content stays counted, new one of changed file is added
+	if used, files := quota.Usage(); used != before+7 || files != beforeFiles+1 {
+		t.Error("held files should be counted: ", used-before, files-beforeFiles)
+	}
+
+	entries, err := fs.ReadDir(lfs, SnapshotsDir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != s.ID {
+		t.Error("snapshot should be browsed: ", entries, err)
+	}
+	content, err := fs.ReadFile(lfs, SnapshotsDir+"/"+s.ID+"/subfolder1/hello.txt")
+	if err != nil || string(content) != "hello" {
+		t.Error("snapshot should keep content: ", string(content), err)
+	}
+	if _, err = wfs.Create(SnapshotsDir + "/" + s.ID + "/new.txt"); err == nil {
+		t.Error("snapshot should be read-only")
+	}
+	root, _ := fs.ReadDir(lfs, ".")
+	for _, e := range root {
+		if e.Name() == SnapshotsDir {
+			t.Error("snapshots should not be listed in root")
+		}
+	}
+
+	// restore of removed file and of changed one under a new name
+	used, files := quota.Usage()
+	restored, err := sfs.RestoreSnapshot(s.ID, "subfolder1/dir12/friend.txt")
+	if err != nil || restored != "subfolder1/dir12/friend.txt" {
+		t.Error("wrong restored file: ", restored, err)
+	}
+	restored, err = sfs.RestoreSnapshot(s.ID, "subfolder1/hello.txt")
+	if err != nil || restored != "subfolder1/hello (1).txt" {
+		t.Error("wrong restored file: ", restored, err)
+	}
+	content, _ = fs.ReadFile(lfs, "subfolder1/hello (1).txt")
+	md, _ := lfs.(MetadataFS).Metadata("subfolder1/hello (1).txt")
+	if string(content) != "hello" || md["color"] != "red" {
+		t.Error("restored file should have content of snapshot: ", string(content), md)
+	}
+	if u, f := quota.Usage(); u != used || f != files {
+		t.Error("restored files are shared with snapshot: ", u-used, f-files)
+	}
+
+	// files being written are not linked
+	w, err := wfs.Create("subfolder2/writing.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(w, "partial")
+	open, err := sfs.Snapshot("while writing")
+	_ = w.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = fs.Stat(lfs, SnapshotsDir+"/"+open.ID+"/subfolder2/writing.txt"); err == nil {
+		t.Error("file being written should be skipped")
+	}
+	err = sfs.DeleteSnapshot(open.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// scheduled snapshots
+	taken, err := sfs.ScheduleSnapshot()
+	if err != nil || !taken {
+		t.Error("scheduled snapshot should be taken: ", err)
+	}
+	if taken, _ = sfs.ScheduleSnapshot(); taken {
+		t.Error("snapshot should not be taken before interval")
+	}
+	list, _ = sfs.ListSnapshots()
+	if len(list) != 2 || !list[0].Scheduled {
+		t.Error("wrong snapshots: ", list)
+	}
+	lfs.(*localfs).config.Snapshots.Keep = 0
+	lfs.(*localfs).config.Snapshots.MaxAge = time.Nanosecond
+	cnt, err := sfs.PruneSnapshots()
+	if err != nil || cnt != 1 {
+		t.Error("scheduled snapshot should be pruned: ", cnt, err)
+	}
+	err = sfs.DeleteSnapshot(s.ID)
+	if err != nil {
+		t.Error(err)
+	}
+	if list, _ = sfs.ListSnapshots(); len(list) != 0 {
+		t.Error("snapshots should be deleted: ", list)
+	}
+	content, err = fs.ReadFile(lfs, "subfolder1/dir12/friend.txt")
+	if err != nil || string(content) != "friend" {
+		t.Error("restored file should outlive snapshot: ", string(content), err)
+	}
+
+	// space of removed files is given back once snapshot is deleted
+	writeFile := func(name string, content string) error {
+		f, err := wfs.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	used, _ = quota.Usage()
+	quota.SetLimits(used, 0)
+	s, err = sfs.Snapshot("full")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = wfs.Remove("subfolder1/dir12/friend.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if err = writeFile("subfolder1/new.txt", "friend"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("space held by snapshot should not be reused: ", err)
+	}
+	err = sfs.DeleteSnapshot(s.ID)
+	if err != nil {
+		t.Error(err)
+	}
+	if err = writeFile("subfolder1/new.txt", "friend"); err != nil {
+		t.Error("space of deleted snapshot should be reused: ", err)
+	}
+}
+
+func TestCryptSnapshots(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	master, _ := NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	keys, err := OpenKeyRing(p, master)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lfs := NewLocalFs(p, &Config{CacheSize: 10 * 1024 * 1024})
+	cfs := NewCryptFS(lfs.(WriteFS), keys)
+	f, err := cfs.Create("subfolder1/secret.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "sealed content")
+	_ = f.Close()
+
+	s, err := cfs.(SnapshotFS).Snapshot("encrypted")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	name := SnapshotsDir + "/" + s.ID + "/subfolder1/secret.txt"
+	info, err := fs.Stat(cfs, name)
+	if err != nil || info.Size() != 14 {
+		t.Error("wrong size of file in snapshot: ", info, err)
+	}
+	entries, err := fs.ReadDir(cfs, SnapshotsDir+"/"+s.ID+"/subfolder1")
+	if err != nil || len(entries) != 4 {
+		t.Error("wrong entries of snapshot: ", entries, err)
+	}
+	content, err := fs.ReadFile(cfs, name)
+	if err != nil || string(content) != "sealed content" {
+		t.Error("wrong content of file in snapshot: ", string(content), err)
+	}
+}
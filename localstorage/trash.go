@@ -0,0 +1,237 @@
+package localstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	trashDir      = "trash"
+	trashItemFile = "item"
+	trashInfoFile = "info.json"
+)
+
+// TrashFS is implemented by file systems moving removed
+// entries into trash instead of unlinking them.
+type TrashFS interface {
+	Trash(name string) (TrashItem, error)
+	ListTrash() ([]TrashItem, error)
+	RestoreTrash(id string) (string, error)
+	EmptyTrash() (int, error)
+	PurgeTrash() (int, error)
+}
+
+type TrashItem struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"` // original path
+	Deleted time.Time `json:"deleted"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`  // bytes of all files
+	Files   int64     `json:"files"` // regular files count
+}
+
+func (t *localfs) trashItemDir(id string) (string, error) {
+	// id is used as a directory name, so it should be strictly uuid
+	if _, err := uuid.Parse(id); err != nil {
+		return "", fmt.Errorf("invalid trash item id: %w", err)
+	}
+	return path.Join(t.trustedRoot, reservedDir, trashDir, id), nil
+}
+
+func readTrashItem(dir string) (TrashItem, error) {
+	var item TrashItem
+	data, err := os.ReadFile(path.Join(dir, trashInfoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return item, errors.New("trash item not found")
+		}
+		return item, err
+	}
+	err = json.Unmarshal(data, &item)
+	return item, err
+}
+
+// Trash moves file or directory into trash,
+// it still counts toward quota until trash is emptied.
+func (t *localfs) Trash(name string) (TrashItem, error) {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	if path.Clean(name) == "." {
+		return TrashItem{}, errors.New("cannot remove root")
+	}
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	item := TrashItem{
+		ID:      uuid.NewString(),
+		Path:    path.Clean(name),
+		Deleted: time.Now(),
+		IsDir:   info.IsDir(),
+	}
+	item.Size, item.Files, err = usage(fullPath)
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	dir, _ := t.trashItemDir(item.ID)
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	data, err := json.Marshal(item)
+	if err == nil {
+		err = os.WriteFile(path.Join(dir, trashInfoFile), data, 0640)
+	}
+	if err == nil {
+		err = os.Rename(fullPath, path.Join(dir, trashItemFile))
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return TrashItem{}, err
+	}
+	t.config.Hashes.forget(fullPath)
+	t.config.Metadata.forget(fullPath)
+	t.config.Changes.publish(ChangeDelete, fullPath, "", item.IsDir)
+	return item, nil
+}
+
+func (t *localfs) ListTrash() ([]TrashItem, error) {
+	base := path.Join(t.trustedRoot, reservedDir, trashDir)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TrashItem{}, nil
+		}
+		return nil, err
+	}
+
+	items := make([]TrashItem, 0, len(entries))
+	for _, e := range entries {
+		item, err := readTrashItem(path.Join(base, e.Name()))
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	// recently deleted first
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Deleted.After(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// freeName returns name not taken in root,
+// adding " (1)", " (2)"... before extension if needed.
+func freeName(root string, name string) string {
+	if _, err := os.Lstat(path.Join(root, name)); os.IsNotExist(err) {
+		return name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(path.Join(root, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// RestoreTrash moves item back to its original path and returns it.
+// If path is taken, item is restored under a new name.
+func (t *localfs) RestoreTrash(id string) (string, error) {
+	dir, err := t.trashItemDir(id)
+	if err != nil {
+		return "", err
+	}
+	item, err := readTrashItem(dir)
+	if err != nil {
+		return "", err
+	}
+
+	name := freeName(t.trustedRoot, item.Path)
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err = t.verifyPath(fullPath)
+	if err != nil {
+		return "", err
+	}
+	// parent could be removed since
+	err = os.MkdirAll(path.Dir(fullPath), 0750)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(path.Join(dir, trashItemFile), fullPath)
+	if err != nil {
+		return "", err
+	}
+	t.config.Hashes.refresh(fullPath)
+	t.config.Changes.publish(ChangeCreate, fullPath, "", item.IsDir)
+	return name, os.RemoveAll(dir)
+}
+
+// removeTrashItem frees space of item,
+// except content shared with snapshots.
+func (t *localfs) removeTrashItem(dir string) error {
+	size, files := soleUsage(path.Join(dir, trashItemFile))
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	t.config.Quota.release(size, files)
+	return nil
+}
+
+// EmptyTrash removes all items permanently.
+func (t *localfs) EmptyTrash() (int, error) {
+	return t.purgeTrash(func(TrashItem) bool { return true })
+}
+
+// PurgeTrash removes items older than configured age.
+func (t *localfs) PurgeTrash() (int, error) {
+	maxAge := t.config.TrashMaxAge
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	return t.purgeTrash(func(item TrashItem) bool {
+		return now.Sub(item.Deleted) > maxAge
+	})
+}
+
+func (t *localfs) purgeTrash(expired func(TrashItem) bool) (int, error) {
+	base := path.Join(t.trustedRoot, reservedDir, trashDir)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	cnt := 0
+	for _, e := range entries {
+		dir := path.Join(base, e.Name())
+		item, err := readTrashItem(dir)
+		// broken items are removed as well
+		if err == nil && !expired(item) {
+			continue
+		}
+		err = t.removeTrashItem(dir)
+		if err != nil {
+			return cnt, err
+		}
+		cnt += 1
+	}
+	return cnt, nil
+}
@@ -0,0 +1,91 @@
+package localstorage
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	quota := NewQuota(0, 0)
+	err = quota.Reconcile(p)
+	if err != nil {
+		t.Error(err)
+	}
+	lfs := NewLocalFs(p, &Config{
+		CacheSize:     10 * 1024 * 1024,
+		CacheDuration: 0,
+		Quota:         quota,
+	})
+	tfs := lfs.(TrashFS)
+
+	item, err := tfs.Trash("subfolder1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !item.IsDir || item.Files != 2 || item.Size != 11 {
+		t.Error("wrong item: ", item)
+	}
+	_, err = fs.Stat(lfs, "subfolder1")
+	if err == nil {
+		t.Error("directory should be moved to trash")
+	}
+
+	// trash still counts toward quota
+	used, files := quota.Usage()
+	if used != 17 || files != 3 {
+		t.Error("bytes = ", used, "; files = ", files)
+	}
+
+	// restore with conflict renaming
+	err = os.MkdirAll(p+"/subfolder1", 0750)
+	if err != nil {
+		t.Error(err)
+	}
+	restored, err := tfs.RestoreTrash(item.ID)
+	if err != nil {
+		t.Error(err)
+	}
+	if restored != "subfolder1 (1)" {
+		t.Error("restored = ", restored)
+	}
+	all, err := os.ReadFile(p + "/subfolder1 (1)/hello.txt")
+	if err != nil || !bytes.Equal(all, []byte("hello")) {
+		t.Error("wrong read of restored file: ", err)
+	}
+
+	_, err = tfs.Trash("subfolder2/goodbye.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	items, err := tfs.ListTrash()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(items) != 1 || items[0].Path != "subfolder2/goodbye.txt" {
+		t.Error("wrong trash list: ", items)
+	}
+
+	cnt, err := tfs.EmptyTrash()
+	if err != nil {
+		t.Error(err)
+	}
+	if cnt != 1 {
+		t.Error("cnt = ", cnt)
+	}
+	used, files = quota.Usage()
+	if used != 11 || files != 2 {
+		t.Error("bytes = ", used, "; files = ", files)
+	}
+}
@@ -0,0 +1,620 @@
+package localstorage
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	uploadsDir       = "uploads"
+	uploadDataFile   = "data"
+	uploadMetaFile   = "session.json"
+	uploadSealFile   = "seal"
+	defaultUploadTTL = 24 * time.Hour
+)
+
+// UploadFS is implemented by file systems accepting resumable uploads.
+// Chunks could be written in any order, partial data is kept
+// in the staging area inside trusted root until commit.
+type UploadFS interface {
+	CreateUpload(size int64, hash string) (UploadSession, error)
+	WriteUpload(id string, offset int64, data []byte) (UploadSession, error)
+	Upload(id string) (UploadSession, error)
+	CommitUpload(id string, name string) error
+	OpenUpload(id string) (fs.File, error)
+	AbortUpload(id string) error
+	ExpireUploads() (int, error)
+}
+
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+func (r ByteRange) End() int64 {
+	return r.Offset + r.Length
+}
+
+type UploadSession struct {
+	ID       string      `json:"id"`
+	Size     int64       `json:"size"`
+	Hash     string      `json:"sha256"` // hex encoded sha256 of whole content
+	Created  time.Time   `json:"created"`
+	Expires  time.Time   `json:"expires"`
+	Received []ByteRange `json:"received"` // sorted, non-overlapping
+}
+
+// Complete reports whether all bytes of upload were received.
+func (s UploadSession) Complete() bool {
+	if s.Size == 0 {
+		return true
+	}
+	return len(s.Received) == 1 &&
+		s.Received[0].Offset == 0 &&
+		s.Received[0].Length == s.Size
+}
+
+// addRange inserts r and coalesces adjacent and overlapping ranges.
+func addRange(ranges []ByteRange, r ByteRange) []ByteRange {
+	if r.Length == 0 {
+		return ranges
+	}
+	ranges = append(ranges, r)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Offset < ranges[j].Offset
+	})
+	merged := ranges[:1]
+	for _, c := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if c.Offset <= last.End() {
+			if c.End() > last.End() {
+				last.Length = c.End() - last.Offset
+			}
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// sessionLocks serialize operations on the same upload session
+// of all roots, since several localfs instances could share the
+// same root. Sessions do not block each other.
+var sessionLocks = struct {
+	sync.Mutex
+	m map[string]*sessionLock // by session dir
+}{m: make(map[string]*sessionLock)}
+
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockSession locks session kept in dir and returns function
+// unlocking it.
+func lockSession(dir string) func() {
+	sessionLocks.Lock()
+	l, ok := sessionLocks.m[dir]
+	if !ok {
+		l = &sessionLock{}
+		sessionLocks.m[dir] = l
+	}
+	l.refs += 1
+	sessionLocks.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		sessionLocks.Lock()
+		l.refs -= 1
+		if l.refs == 0 {
+			delete(sessionLocks.m, dir)
+		}
+		sessionLocks.Unlock()
+	}
+}
+
+// uploadSealer protects staged data of layers transforming content,
+// so e.g. encrypted homes do not keep uploads in plain. Chunks are
+// written at any offsets, so data is XORed with key stream.
+type uploadSealer interface {
+	// newSeal returns parameters of key stream of new session.
+	newSeal() ([]byte, error)
+	// stream returns key stream of session seal starting at offset.
+	stream(seal []byte, offset int64) (cipher.Stream, error)
+}
+
+// stagedUploads are upload sessions committed through layers,
+// which copy staged data instead of moving it.
+type stagedUploads interface {
+	UploadFS
+	commitWith(id string, write func(staged io.Reader) error) error
+}
+
+// readSeal returns seal of session in dir, nil if data is plain.
+// Sealed data could be accessed only with sealer.
+func readSeal(dir string, sealer uploadSealer) ([]byte, error) {
+	seal, err := os.ReadFile(path.Join(dir, uploadSealFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err == nil && sealer == nil {
+		err = errors.New("upload is sealed by layer")
+	}
+	return seal, err
+}
+
+// sealedData reads staged data unsealing it.
+type sealedData struct {
+	f      *os.File
+	seal   []byte
+	sealer uploadSealer
+	off    int64
+}
+
+func (d *sealedData) ReadAt(p []byte, off int64) (int, error) {
+	n, err := d.f.ReadAt(p, off)
+	s, serr := d.sealer.stream(d.seal, off)
+	if serr != nil {
+		return 0, serr
+	}
+	s.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+func (d *sealedData) Read(p []byte) (int, error) {
+	n, err := d.ReadAt(p, d.off)
+	d.off += int64(n)
+	return n, err
+}
+
+func (d *sealedData) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += d.off
+	case io.SeekEnd:
+		info, err := d.f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	d.off = offset
+	return offset, nil
+}
+
+func (d *sealedData) Stat() (fs.FileInfo, error) {
+	return d.f.Stat()
+}
+
+func (d *sealedData) Close() error {
+	return d.f.Close()
+}
+
+// openData opens staged data of session in dir.
+func openData(dir string, sealer uploadSealer) (fs.File, error) {
+	seal, err := readSeal(dir, sealer)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path.Join(dir, uploadDataFile))
+	if err != nil || seal == nil {
+		return f, err
+	}
+	return &sealedData{f: f, seal: seal, sealer: sealer}, nil
+}
+
+// sealedUploads are upload sessions of localfs keeping data sealed.
+type sealedUploads struct {
+	t      *localfs
+	sealer uploadSealer
+}
+
+func (u *sealedUploads) CreateUpload(size int64, hash string) (UploadSession, error) {
+	return u.t.createUpload(size, hash, u.sealer)
+}
+
+func (u *sealedUploads) WriteUpload(id string, offset int64, data []byte) (UploadSession, error) {
+	return u.t.writeUpload(id, offset, data, u.sealer)
+}
+
+func (u *sealedUploads) Upload(id string) (UploadSession, error) {
+	return u.t.Upload(id)
+}
+
+// CommitUpload is not supported, since sealed data
+// should be copied through layer sealing it.
+func (u *sealedUploads) CommitUpload(id string, name string) error {
+	return errors.ErrUnsupported
+}
+
+func (u *sealedUploads) OpenUpload(id string) (fs.File, error) {
+	return u.t.openUpload(id, u.sealer)
+}
+
+func (u *sealedUploads) AbortUpload(id string) error {
+	return u.t.AbortUpload(id)
+}
+
+func (u *sealedUploads) ExpireUploads() (int, error) {
+	return u.t.ExpireUploads()
+}
+
+func (u *sealedUploads) commitWith(id string, write func(staged io.Reader) error) error {
+	return u.t.commitUpload(id, u.sealer, write)
+}
+
+func (t *localfs) uploadTTL() time.Duration {
+	if t.config.UploadTTL > 0 {
+		return t.config.UploadTTL
+	}
+	return defaultUploadTTL
+}
+
+func (t *localfs) uploadDir(id string) (string, error) {
+	// id is used as a directory name, so it should be strictly uuid
+	if _, err := uuid.Parse(id); err != nil {
+		return "", fmt.Errorf("invalid upload id: %w", err)
+	}
+	return path.Join(t.trustedRoot, reservedDir, uploadsDir, id), nil
+}
+
+func readSession(dir string) (UploadSession, error) {
+	var s UploadSession
+	data, err := os.ReadFile(path.Join(dir, uploadMetaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, errors.New("upload session not found")
+		}
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+func writeSession(dir string, s UploadSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// write then rename, so session survives crash in the middle
+	tmp := path.Join(dir, uploadMetaFile+".tmp")
+	err = os.WriteFile(tmp, data, 0640)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path.Join(dir, uploadMetaFile))
+}
+
+func (t *localfs) CreateUpload(size int64, hash string) (UploadSession, error) {
+	return t.createUpload(size, hash, nil)
+}
+
+func (t *localfs) createUpload(size int64, hash string, sealer uploadSealer) (UploadSession, error) {
+	if size < 0 {
+		return UploadSession{}, errors.New("invalid upload size")
+	}
+	sum, err := hex.DecodeString(hash)
+	if err != nil || len(sum) != sha256.Size {
+		return UploadSession{}, errors.New("invalid sha256 hash")
+	}
+
+	now := time.Now()
+	s := UploadSession{
+		ID:       uuid.NewString(),
+		Size:     size,
+		Hash:     hex.EncodeToString(sum),
+		Created:  now,
+		Expires:  now.Add(t.uploadTTL()),
+		Received: []ByteRange{},
+	}
+	dir, _ := t.uploadDir(s.ID)
+	// staged data is preallocated, so it counts toward quota
+	err = t.config.Quota.reserve(size, 1)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	defer lockSession(dir)()
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		t.config.Quota.release(size, 1)
+		return UploadSession{}, err
+	}
+
+	f, err := os.Create(path.Join(dir, uploadDataFile))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.config.Quota.release(size, 1)
+		return UploadSession{}, err
+	}
+	err = f.Truncate(size)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && sealer != nil {
+		var seal []byte
+		seal, err = sealer.newSeal()
+		if err == nil {
+			err = os.WriteFile(path.Join(dir, uploadSealFile), seal, 0640)
+		}
+	}
+	if err == nil {
+		err = writeSession(dir, s)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.config.Quota.release(size, 1)
+		return UploadSession{}, err
+	}
+
+	return s, nil
+}
+
+func (t *localfs) WriteUpload(id string, offset int64, data []byte) (UploadSession, error) {
+	return t.writeUpload(id, offset, data, nil)
+}
+
+func (t *localfs) writeUpload(id string, offset int64, data []byte, sealer uploadSealer) (UploadSession, error) {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	// lock is held until range is recorded, so chunk could not
+	// land in data already verified and committed meanwhile
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err != nil {
+		return s, err
+	}
+	if offset < 0 || offset+int64(len(data)) > s.Size {
+		return s, fmt.Errorf("chunk [%d, %d) is out of upload size %d",
+			offset, offset+int64(len(data)), s.Size)
+	}
+	seal, err := readSeal(dir, sealer)
+	if err != nil {
+		return s, err
+	}
+	if seal != nil {
+		stream, err := sealer.stream(seal, offset)
+		if err != nil {
+			return s, err
+		}
+		sealed := make([]byte, len(data))
+		stream.XORKeyStream(sealed, data)
+		data = sealed
+	}
+
+	f, err := os.OpenFile(path.Join(dir, uploadDataFile), os.O_WRONLY, 0)
+	if err != nil {
+		return s, err
+	}
+	_, err = f.WriteAt(data, offset)
+	if err == nil {
+		// range is recorded only after data reached the disk
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return s, err
+	}
+	s.Received = addRange(s.Received, ByteRange{Offset: offset, Length: int64(len(data))})
+	s.Expires = time.Now().Add(t.uploadTTL())
+	return s, writeSession(dir, s)
+}
+
+func (t *localfs) Upload(id string) (UploadSession, error) {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	defer lockSession(dir)()
+	return readSession(dir)
+}
+
+// verifyUpload checks that staged data matches hash of session.
+func verifyUpload(dir string, s UploadSession, sealer uploadSealer) error {
+	f, err := openData(dir, sealer)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != s.Hash {
+		return errors.New("uploaded content does not match sha256 hash")
+	}
+	return nil
+}
+
+// OpenUpload verifies content hash and opens uploaded data, so it
+// could be processed in place, e.g. extracted. Session stays
+// until committed or aborted.
+func (t *localfs) OpenUpload(id string) (fs.File, error) {
+	return t.openUpload(id, nil)
+}
+
+func (t *localfs) openUpload(id string, sealer uploadSealer) (fs.File, error) {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return nil, err
+	}
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Complete() {
+		return nil, errors.New("upload is not complete")
+	}
+	err = verifyUpload(dir, s, sealer)
+	if err != nil {
+		return nil, err
+	}
+	return openData(dir, sealer)
+}
+
+// CommitUpload verifies content hash and moves uploaded file to name.
+func (t *localfs) CommitUpload(id string, name string) error {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return err
+	}
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err = t.verifyPath(fullPath)
+	if err != nil {
+		return err
+	}
+
+	// content is hashed holding lock of this session only
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err != nil {
+		return err
+	}
+	if !s.Complete() {
+		return errors.New("upload is not complete")
+	}
+
+	err = verifyUpload(dir, s, nil)
+	if err != nil {
+		return err
+	}
+
+	data := path.Join(dir, uploadDataFile)
+	op := ChangeCreate
+	if _, err := os.Lstat(fullPath); err == nil {
+		op = ChangeModify
+	}
+	err = t.replace(data, fullPath)
+	if err != nil {
+		return err
+	}
+	t.config.Hashes.refresh(fullPath)
+	t.config.Changes.publish(op, fullPath, "", false)
+	return os.RemoveAll(dir)
+}
+
+func (t *localfs) commitWith(id string, write func(staged io.Reader) error) error {
+	return t.commitUpload(id, nil, write)
+}
+
+// commitUpload verifies content hash and passes staged data to write,
+// e.g. to be copied through layer. Space of staged data is released
+// meanwhile, so content is not counted twice.
+func (t *localfs) commitUpload(id string, sealer uploadSealer, write func(staged io.Reader) error) error {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return err
+	}
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err != nil {
+		return err
+	}
+	if !s.Complete() {
+		return errors.New("upload is not complete")
+	}
+	err = verifyUpload(dir, s, sealer)
+	if err != nil {
+		return err
+	}
+	f, err := openData(dir, sealer)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t.config.Quota.release(s.Size, 1)
+	err = write(f)
+	if err != nil {
+		// staged data is kept, so is its space
+		t.config.Quota.restore(s.Size, 1)
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (t *localfs) AbortUpload(id string) error {
+	dir, err := t.uploadDir(id)
+	if err != nil {
+		return err
+	}
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	t.config.Quota.release(s.Size, 1)
+	return nil
+}
+
+// ExpireUploads removes abandoned sessions and returns its count.
+func (t *localfs) ExpireUploads() (int, error) {
+	base := path.Join(t.trustedRoot, reservedDir, uploadsDir)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	now := time.Now()
+	cnt := 0
+	for _, e := range entries {
+		expired, err := t.expireUpload(path.Join(base, e.Name()), now)
+		if err != nil {
+			return cnt, err
+		}
+		if expired {
+			cnt += 1
+		}
+	}
+	return cnt, nil
+}
+
+// expireUpload removes session in dir if it is expired or broken.
+func (t *localfs) expireUpload(dir string, now time.Time) (bool, error) {
+	defer lockSession(dir)()
+	s, err := readSession(dir)
+	if err == nil && now.Before(s.Expires) {
+		return false, nil
+	}
+	if _, serr := os.Stat(dir); os.IsNotExist(serr) {
+		// committed or aborted meanwhile
+		return false, nil
+	}
+	// broken sessions are removed as well
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return false, err
+	}
+	t.config.Quota.release(s.Size, 1)
+	return true, nil
+}
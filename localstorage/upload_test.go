@@ -0,0 +1,175 @@
+package localstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpload(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	config := &Config{
+		CacheSize:     10 * 1024 * 1024,
+		CacheDuration: 0,
+	}
+	ufs := NewLocalFs(p, config).(UploadFS)
+
+	content := []byte("resumable upload content")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	s, err := ufs.CreateUpload(int64(len(content)), hash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// chunks in any order
+	_, err = ufs.WriteUpload(s.ID, 10, content[10:])
+	if err != nil {
+		t.Error(err)
+	}
+	err = ufs.CommitUpload(s.ID, "subfolder2/uploaded.txt")
+	if err == nil {
+		t.Error("should not commit incomplete upload")
+	}
+	_, err = ufs.WriteUpload(s.ID, int64(len(content)), []byte("overflow"))
+	if err == nil {
+		t.Error("should not accept chunk out of upload size")
+	}
+
+	// session persists in staging area, so new instance could resume
+	ufs = NewLocalFs(p, config).(UploadFS)
+	s, err = ufs.Upload(s.ID)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(s.Received) != 1 || s.Received[0] != (ByteRange{Offset: 10, Length: int64(len(content) - 10)}) {
+		t.Error("wrong received ranges: ", s.Received)
+	}
+
+	s, err = ufs.WriteUpload(s.ID, 0, content[:12])
+	if err != nil {
+		t.Error(err)
+	}
+	if !s.Complete() {
+		t.Error("upload should be complete: ", s.Received)
+	}
+
+	// staging area is not visible
+	entries, err := fs.ReadDir(ufs.(fs.FS), ".")
+	if err != nil {
+		t.Error(err)
+	}
+	for _, e := range entries {
+		if e.Name() == reservedDir {
+			t.Error("reserved dir should not be listed")
+		}
+	}
+	_, err = ufs.(fs.FS).Open(reservedDir + "/" + uploadsDir)
+	if err == nil {
+		t.Error("reserved dir should not be accessible")
+	}
+
+	// complete upload could be read in place
+	f, err := ufs.OpenUpload(s.ID)
+	if err != nil {
+		t.Error(err)
+	} else {
+		staged, _ := io.ReadAll(f)
+		_ = f.Close()
+		if !bytes.Equal(staged, content) {
+			t.Error("wrong staged content")
+		}
+	}
+
+	err = ufs.CommitUpload(s.ID, "subfolder2/uploaded.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	all, err := os.ReadFile(p + "/subfolder2/uploaded.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(all, content) {
+		t.Error("wrong content")
+	}
+	_, err = ufs.Upload(s.ID)
+	if err == nil {
+		t.Error("committed session should be removed")
+	}
+	_, err = ufs.WriteUpload(s.ID, 0, []byte("late"))
+	if err == nil {
+		t.Error("chunk should not be written to committed session")
+	}
+
+	// chunks racing with commit either fail it or are rejected,
+	// committed file always matches hash
+	s, err = ufs.CreateUpload(int64(len(content)), hash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = ufs.WriteUpload(s.ID, 0, content)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = ufs.WriteUpload(s.ID, 0, []byte("XXXX"))
+		}()
+	}
+	err = ufs.CommitUpload(s.ID, "subfolder2/raced.txt")
+	wg.Wait()
+	if err == nil {
+		all, _ = os.ReadFile(p + "/subfolder2/raced.txt")
+		if !bytes.Equal(all, content) {
+			t.Error("committed content should match hash: ", string(all))
+		}
+	}
+
+	// content not matching hash
+	s, err = ufs.CreateUpload(3, hash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = ufs.WriteUpload(s.ID, 0, []byte("bad"))
+	if err != nil {
+		t.Error(err)
+	}
+	err = ufs.CommitUpload(s.ID, "subfolder2/bad.txt")
+	if err == nil {
+		t.Error("should not commit content with wrong hash")
+	}
+
+	// expiry of abandoned sessions
+	config.UploadTTL = time.Nanosecond
+	ufs = NewLocalFs(p, config).(UploadFS)
+	_, err = ufs.CreateUpload(1, hash)
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Millisecond)
+	cnt, err := ufs.ExpireUploads()
+	if err != nil {
+		t.Error(err)
+	}
+	if cnt != 1 {
+		t.Error("cnt = ", cnt, "; expected 1 expired session")
+	}
+}
@@ -0,0 +1,283 @@
+package localstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	versionsDir     = "versions"
+	versionPathFile = "path"
+)
+
+// VersionPolicy controls how many previous versions are kept.
+// Both limits are applied, zero value keeps versions forever.
+type VersionPolicy struct {
+	Keep   int           // versions per file, 0 for unlimited
+	MaxAge time.Duration // 0 for unlimited
+}
+
+// VersionFS is implemented by file systems keeping
+// previous content of overwritten and removed files.
+type VersionFS interface {
+	Versions(name string) ([]Version, error)
+	OpenVersion(name string, id string) (fs.File, error)
+	RestoreVersion(name string, id string) error
+	PruneVersions() (int, error)
+}
+
+type Version struct {
+	ID       string
+	Size     int64
+	ModTime  time.Time // modification time of content
+	Replaced time.Time // when content was overwritten or removed
+}
+
+// versionDir returns directory keeping versions of file at fullPath.
+// Relative path is hashed, so there is no clash between file
+// and directory names.
+func (t *localfs) versionDir(fullPath string) (string, string) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(fullPath, t.Root()), "/")
+	sum := sha256.Sum256([]byte(rel))
+	return path.Join(t.trustedRoot, reservedDir, versionsDir, hex.EncodeToString(sum[:])), rel
+}
+
+// keepVersion moves regular file at fullPath into version store.
+// Versioning should be enabled in config.
+func (t *localfs) keepVersion(fullPath string) error {
+	dir, rel := t.versionDir(fullPath)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path.Join(dir, versionPathFile), []byte(rel), 0640)
+	if err != nil {
+		return err
+	}
+
+	id, err := reserveVersion(dir, time.Now())
+	if err != nil {
+		return err
+	}
+	err = os.Rename(fullPath, path.Join(dir, id))
+	if err != nil {
+		_ = os.Remove(path.Join(dir, id))
+		return err
+	}
+	_, err = t.pruneVersionDir(dir, time.Now())
+	return err
+}
+
+// reserveVersion creates empty file for version replaced at now
+// and returns its id. Ids are nanoseconds, next free one is taken,
+// so versions replaced at the same tick do not overwrite each other.
+func reserveVersion(dir string, now time.Time) (string, error) {
+	for nsec := now.UnixNano(); ; nsec++ {
+		id := strconv.FormatInt(nsec, 10)
+		f, err := os.OpenFile(path.Join(dir, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return id, f.Close()
+	}
+}
+
+func readVersions(dir string) ([]Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Version{}, nil
+		}
+		return nil, err
+	}
+
+	versions := make([]Version, 0, len(entries))
+	for _, e := range entries {
+		nsec, err := strconv.ParseInt(e.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		versions = append(versions, Version{
+			ID:       e.Name(),
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Replaced: time.Unix(0, nsec),
+		})
+	}
+	// newest first
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Replaced.After(versions[j].Replaced)
+	})
+	return versions, nil
+}
+
+// pruneVersionDir applies retention policy to versions of one file.
+func (t *localfs) pruneVersionDir(dir string, now time.Time) (int, error) {
+	policy := t.config.Versions
+	if policy == nil {
+		return 0, nil
+	}
+	versions, err := readVersions(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	for i, v := range versions {
+		expired := policy.MaxAge > 0 && now.Sub(v.Replaced) > policy.MaxAge
+		extra := policy.Keep > 0 && i >= policy.Keep
+		if !expired && !extra {
+			continue
+		}
+		info, err := os.Lstat(path.Join(dir, v.ID))
+		err = os.Remove(path.Join(dir, v.ID))
+		if err != nil && !os.IsNotExist(err) {
+			return cnt, err
+		}
+		if err == nil && hardLinks(info) <= 1 {
+			t.config.Quota.release(v.Size, 1)
+		}
+		cnt += 1
+	}
+	if cnt == len(versions) {
+		_ = os.RemoveAll(dir)
+	}
+	return cnt, nil
+}
+
+// PruneVersions applies retention policy to all files,
+// it should be called periodically to drop aged versions.
+func (t *localfs) PruneVersions() (int, error) {
+	base := path.Join(t.trustedRoot, reservedDir, versionsDir)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	now := time.Now()
+	cnt := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		n, err := t.pruneVersionDir(path.Join(base, e.Name()), now)
+		cnt += n
+		if err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+func (t *localfs) Versions(name string) ([]Version, error) {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	dir, _ := t.versionDir(fullPath)
+	return readVersions(dir)
+}
+
+func (t *localfs) versionPath(name string, id string) (string, string, error) {
+	fullPath := path.Join(t.trustedRoot, name)
+	_, err := t.verifyPath(fullPath)
+	if err != nil {
+		return "", "", err
+	}
+	// id is used as a file name
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", "", fmt.Errorf("invalid version id: %w", err)
+	}
+	dir, _ := t.versionDir(fullPath)
+	return fullPath, path.Join(dir, id), nil
+}
+
+func (t *localfs) OpenVersion(name string, id string) (fs.File, error) {
+	_, p, err := t.versionPath(name, id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("version not found")
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// RestoreVersion makes version current content of name.
+// Replaced content is kept as a new version, so restore could be undone.
+func (t *localfs) RestoreVersion(name string, id string) error {
+	fullPath, p, err := t.versionPath(name, id)
+	if err != nil {
+		return err
+	}
+	// version is moved aside, so keeping current content
+	// could not prune it
+	staged := path.Dir(p) + "." + id
+	err = os.Rename(p, staged)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("version not found")
+		}
+		return err
+	}
+	defer os.Rename(staged, p)
+
+	info, err := os.Lstat(fullPath)
+	if err == nil {
+		if !info.Mode().IsRegular() {
+			return errors.New("cannot restore over non-regular file")
+		}
+		if t.config.Versions != nil {
+			err = t.keepVersion(fullPath)
+		} else {
+			err = os.Remove(fullPath)
+			if err == nil && hardLinks(info) <= 1 {
+				t.config.Quota.release(info.Size(), 1)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// parent could be removed since
+	err = os.MkdirAll(filepath.Dir(fullPath), 0750)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(staged, fullPath)
+	if err != nil {
+		return err
+	}
+	op := ChangeCreate
+	if info != nil {
+		op = ChangeModify
+	}
+	t.config.Hashes.refresh(fullPath)
+	t.config.Changes.publish(op, fullPath, "", false)
+	return nil
+}
@@ -0,0 +1,109 @@
+package localstorage
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVersions(t *testing.T) {
+	p, _, err := tmpDir()
+	if p != "" {
+		defer os.RemoveAll(p)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	lfs := NewLocalFs(p, &Config{
+		CacheSize:     10 * 1024 * 1024,
+		CacheDuration: 0,
+		Versions:      &VersionPolicy{Keep: 2},
+	})
+	wfs := lfs.(WriteFS)
+	vfs := lfs.(VersionFS)
+
+	for _, content := range []string{"second", "third", "fourth"} {
+		f, err := wfs.Create("subfolder1/hello.txt")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		f.Write([]byte(content))
+		f.Close()
+	}
+
+	// "hello" is pruned by policy
+	versions, err := vfs.Versions("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(versions) != 2 {
+		t.Error("versions = ", versions)
+		return
+	}
+
+	f, err := vfs.OpenVersion("subfolder1/hello.txt", versions[0].ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	all, _ := io.ReadAll(f)
+	f.Close()
+	if !bytes.Equal(all, []byte("third")) {
+		t.Error("wrong read of latest version: ", string(all))
+	}
+
+	_, err = vfs.OpenVersion("subfolder1/hello.txt", "../../../hello.txt")
+	if err == nil {
+		t.Error("should not accept invalid version id")
+	}
+
+	// removal keeps content as well
+	err = wfs.Remove("subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = fs.Stat(lfs, "subfolder1/hello.txt")
+	if err == nil {
+		t.Error("file should be removed")
+	}
+	versions, _ = vfs.Versions("subfolder1/hello.txt")
+	if len(versions) != 2 {
+		t.Error("versions = ", versions)
+		return
+	}
+
+	// restore the oldest kept one
+	err = vfs.RestoreVersion("subfolder1/hello.txt", versions[1].ID)
+	if err != nil {
+		t.Error(err)
+	}
+	all, err = os.ReadFile(p + "/subfolder1/hello.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(all, []byte("third")) {
+		t.Error("wrong restored content: ", string(all))
+	}
+}
+
+func TestVersionIDs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	first, err := reserveVersion(dir, now)
+	if err != nil {
+		t.Error(err)
+	}
+	second, err := reserveVersion(dir, now)
+	if err != nil {
+		t.Error(err)
+	}
+	if first == second {
+		t.Error("versions replaced at the same tick should get own ids: ", first)
+	}
+}
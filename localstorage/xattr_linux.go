@@ -0,0 +1,89 @@
+//go:build linux
+
+package localstorage
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// getXattrs returns metadata kept in extended attributes of file.
+func getXattrs(fullPath string) (map[string]string, error) {
+	var names []byte
+	for {
+		size, err := unix.Listxattr(fullPath, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		names = make([]byte, size)
+		size, err = unix.Listxattr(fullPath, names)
+		if errors.Is(err, unix.ERANGE) {
+			// attributes were added meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = names[:size]
+		break
+	}
+
+	r := make(map[string]string)
+	for _, attr := range strings.Split(string(names), "\x00") {
+		key, ok := strings.CutPrefix(attr, xattrPrefix)
+		if !ok {
+			continue
+		}
+		value, err := getXattr(fullPath, attr)
+		if errors.Is(err, unix.ENODATA) {
+			// removed meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r[key] = value
+	}
+	return r, nil
+}
+
+func getXattr(fullPath string, attr string) (string, error) {
+	for {
+		size, err := unix.Getxattr(fullPath, attr, nil)
+		if err != nil {
+			return "", err
+		}
+		value := make([]byte, size)
+		size, err = unix.Getxattr(fullPath, attr, value)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(value[:size]), nil
+	}
+}
+
+func setXattr(fullPath string, key string, value string) error {
+	return unix.Setxattr(fullPath, xattrPrefix+key, []byte(value), 0)
+}
+
+func removeXattr(fullPath string, key string) error {
+	err := unix.Removexattr(fullPath, xattrPrefix+key)
+	if errors.Is(err, unix.ENODATA) {
+		return nil
+	}
+	return err
+}
+
+// xattrUnsupported reports whether metadata could not be kept in
+// extended attributes, e.g. file system does not support user ones
+// or value does not fit.
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.E2BIG) || errors.Is(err, unix.ENOSPC) ||
+		errors.Is(err, unix.EPERM)
+}
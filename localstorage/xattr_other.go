@@ -0,0 +1,24 @@
+//go:build !linux
+
+package localstorage
+
+import "errors"
+
+// extended attributes are used on linux only,
+// metadata is kept in database elsewhere
+
+func getXattrs(fullPath string) (map[string]string, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func setXattr(fullPath string, key string, value string) error {
+	return errors.ErrUnsupported
+}
+
+func removeXattr(fullPath string, key string) error {
+	return errors.ErrUnsupported
+}
+
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported)
+}
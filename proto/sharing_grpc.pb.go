@@ -0,0 +1,294 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.0
+// source: sharing.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Sharing_Create_FullMethodName      = "/Sharing/Create"
+	Sharing_List_FullMethodName        = "/Sharing/List"
+	Sharing_Revoke_FullMethodName      = "/Sharing/Revoke"
+	Sharing_GrantAccess_FullMethodName = "/Sharing/GrantAccess"
+	Sharing_ListGrants_FullMethodName  = "/Sharing/ListGrants"
+	Sharing_RevokeGrant_FullMethodName = "/Sharing/RevokeGrant"
+)
+
+// SharingClient is the client API for Sharing service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SharingClient interface {
+	Create(ctx context.Context, in *CreateShareReq, opts ...grpc.CallOption) (*CreateShareRes, error)
+	List(ctx context.Context, in *ListSharesReq, opts ...grpc.CallOption) (*ListSharesRes, error)
+	Revoke(ctx context.Context, in *RevokeShareReq, opts ...grpc.CallOption) (*RevokeShareRes, error)
+	GrantAccess(ctx context.Context, in *GrantAccessReq, opts ...grpc.CallOption) (*GrantAccessRes, error)
+	ListGrants(ctx context.Context, in *ListGrantsReq, opts ...grpc.CallOption) (*ListGrantsRes, error)
+	RevokeGrant(ctx context.Context, in *RevokeGrantReq, opts ...grpc.CallOption) (*RevokeGrantRes, error)
+}
+
+type sharingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSharingClient(cc grpc.ClientConnInterface) SharingClient {
+	return &sharingClient{cc}
+}
+
+func (c *sharingClient) Create(ctx context.Context, in *CreateShareReq, opts ...grpc.CallOption) (*CreateShareRes, error) {
+	out := new(CreateShareRes)
+	err := c.cc.Invoke(ctx, Sharing_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sharingClient) List(ctx context.Context, in *ListSharesReq, opts ...grpc.CallOption) (*ListSharesRes, error) {
+	out := new(ListSharesRes)
+	err := c.cc.Invoke(ctx, Sharing_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sharingClient) Revoke(ctx context.Context, in *RevokeShareReq, opts ...grpc.CallOption) (*RevokeShareRes, error) {
+	out := new(RevokeShareRes)
+	err := c.cc.Invoke(ctx, Sharing_Revoke_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sharingClient) GrantAccess(ctx context.Context, in *GrantAccessReq, opts ...grpc.CallOption) (*GrantAccessRes, error) {
+	out := new(GrantAccessRes)
+	err := c.cc.Invoke(ctx, Sharing_GrantAccess_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sharingClient) ListGrants(ctx context.Context, in *ListGrantsReq, opts ...grpc.CallOption) (*ListGrantsRes, error) {
+	out := new(ListGrantsRes)
+	err := c.cc.Invoke(ctx, Sharing_ListGrants_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sharingClient) RevokeGrant(ctx context.Context, in *RevokeGrantReq, opts ...grpc.CallOption) (*RevokeGrantRes, error) {
+	out := new(RevokeGrantRes)
+	err := c.cc.Invoke(ctx, Sharing_RevokeGrant_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SharingServer is the server API for Sharing service.
+// All implementations must embed UnimplementedSharingServer
+// for forward compatibility
+type SharingServer interface {
+	Create(context.Context, *CreateShareReq) (*CreateShareRes, error)
+	List(context.Context, *ListSharesReq) (*ListSharesRes, error)
+	Revoke(context.Context, *RevokeShareReq) (*RevokeShareRes, error)
+	GrantAccess(context.Context, *GrantAccessReq) (*GrantAccessRes, error)
+	ListGrants(context.Context, *ListGrantsReq) (*ListGrantsRes, error)
+	RevokeGrant(context.Context, *RevokeGrantReq) (*RevokeGrantRes, error)
+	mustEmbedUnimplementedSharingServer()
+}
+
+// UnimplementedSharingServer must be embedded to have forward compatible implementations.
+type UnimplementedSharingServer struct {
+}
+
+func (UnimplementedSharingServer) Create(context.Context, *CreateShareReq) (*CreateShareRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedSharingServer) List(context.Context, *ListSharesReq) (*ListSharesRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedSharingServer) Revoke(context.Context, *RevokeShareReq) (*RevokeShareRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revoke not implemented")
+}
+func (UnimplementedSharingServer) GrantAccess(context.Context, *GrantAccessReq) (*GrantAccessRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GrantAccess not implemented")
+}
+func (UnimplementedSharingServer) ListGrants(context.Context, *ListGrantsReq) (*ListGrantsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGrants not implemented")
+}
+func (UnimplementedSharingServer) RevokeGrant(context.Context, *RevokeGrantReq) (*RevokeGrantRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeGrant not implemented")
+}
+func (UnimplementedSharingServer) mustEmbedUnimplementedSharingServer() {}
+
+// UnsafeSharingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SharingServer will
+// result in compilation errors.
+type UnsafeSharingServer interface {
+	mustEmbedUnimplementedSharingServer()
+}
+
+func RegisterSharingServer(s grpc.ServiceRegistrar, srv SharingServer) {
+	s.RegisterService(&Sharing_ServiceDesc, srv)
+}
+
+func _Sharing_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateShareReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).Create(ctx, req.(*CreateShareReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sharing_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSharesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).List(ctx, req.(*ListSharesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sharing_Revoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeShareReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).Revoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_Revoke_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).Revoke(ctx, req.(*RevokeShareReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sharing_GrantAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GrantAccessReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).GrantAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_GrantAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).GrantAccess(ctx, req.(*GrantAccessReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sharing_ListGrants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGrantsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).ListGrants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_ListGrants_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).ListGrants(ctx, req.(*ListGrantsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sharing_RevokeGrant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeGrantReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SharingServer).RevokeGrant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sharing_RevokeGrant_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SharingServer).RevokeGrant(ctx, req.(*RevokeGrantReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Sharing_ServiceDesc is the grpc.ServiceDesc for Sharing service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sharing_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Sharing",
+	HandlerType: (*SharingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _Sharing_Create_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Sharing_List_Handler,
+		},
+		{
+			MethodName: "Revoke",
+			Handler:    _Sharing_Revoke_Handler,
+		},
+		{
+			MethodName: "GrantAccess",
+			Handler:    _Sharing_GrantAccess_Handler,
+		},
+		{
+			MethodName: "ListGrants",
+			Handler:    _Sharing_ListGrants_Handler,
+		},
+		{
+			MethodName: "RevokeGrant",
+			Handler:    _Sharing_RevokeGrant_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sharing.proto",
+}
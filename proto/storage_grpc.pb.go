@@ -0,0 +1,1527 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.0
+// source: storage.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Storage_CreateUpload_FullMethodName    = "/Storage/CreateUpload"
+	Storage_PutChunk_FullMethodName        = "/Storage/PutChunk"
+	Storage_GetUpload_FullMethodName       = "/Storage/GetUpload"
+	Storage_CommitUpload_FullMethodName    = "/Storage/CommitUpload"
+	Storage_AbortUpload_FullMethodName     = "/Storage/AbortUpload"
+	Storage_GetUsage_FullMethodName        = "/Storage/GetUsage"
+	Storage_ListVersions_FullMethodName    = "/Storage/ListVersions"
+	Storage_ReadVersion_FullMethodName     = "/Storage/ReadVersion"
+	Storage_RestoreVersion_FullMethodName  = "/Storage/RestoreVersion"
+	Storage_Remove_FullMethodName          = "/Storage/Remove"
+	Storage_ListTrash_FullMethodName       = "/Storage/ListTrash"
+	Storage_RestoreTrash_FullMethodName    = "/Storage/RestoreTrash"
+	Storage_EmptyTrash_FullMethodName      = "/Storage/EmptyTrash"
+	Storage_CreateSnapshot_FullMethodName  = "/Storage/CreateSnapshot"
+	Storage_ListSnapshots_FullMethodName   = "/Storage/ListSnapshots"
+	Storage_RestoreSnapshot_FullMethodName = "/Storage/RestoreSnapshot"
+	Storage_DeleteSnapshot_FullMethodName  = "/Storage/DeleteSnapshot"
+	Storage_Watch_FullMethodName           = "/Storage/Watch"
+	Storage_GetSignature_FullMethodName    = "/Storage/GetSignature"
+	Storage_GetDelta_FullMethodName        = "/Storage/GetDelta"
+	Storage_PutDelta_FullMethodName        = "/Storage/PutDelta"
+	Storage_CompareManifest_FullMethodName = "/Storage/CompareManifest"
+	Storage_ReadArchive_FullMethodName     = "/Storage/ReadArchive"
+	Storage_ExtractArchive_FullMethodName  = "/Storage/ExtractArchive"
+	Storage_Stat_FullMethodName            = "/Storage/Stat"
+	Storage_Scrub_FullMethodName           = "/Storage/Scrub"
+	Storage_Search_FullMethodName          = "/Storage/Search"
+	Storage_Preview_FullMethodName         = "/Storage/Preview"
+	Storage_SetMetadata_FullMethodName     = "/Storage/SetMetadata"
+	Storage_GetMetadata_FullMethodName     = "/Storage/GetMetadata"
+	Storage_Lock_FullMethodName            = "/Storage/Lock"
+	Storage_RenewLock_FullMethodName       = "/Storage/RenewLock"
+	Storage_Unlock_FullMethodName          = "/Storage/Unlock"
+)
+
+// StorageClient is the client API for Storage service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type StorageClient interface {
+	CreateUpload(ctx context.Context, in *CreateUploadReq, opts ...grpc.CallOption) (*CreateUploadRes, error)
+	PutChunk(ctx context.Context, in *PutChunkReq, opts ...grpc.CallOption) (*PutChunkRes, error)
+	GetUpload(ctx context.Context, in *GetUploadReq, opts ...grpc.CallOption) (*GetUploadRes, error)
+	CommitUpload(ctx context.Context, in *CommitUploadReq, opts ...grpc.CallOption) (*CommitUploadRes, error)
+	AbortUpload(ctx context.Context, in *AbortUploadReq, opts ...grpc.CallOption) (*AbortUploadRes, error)
+	GetUsage(ctx context.Context, in *GetUsageReq, opts ...grpc.CallOption) (*GetUsageRes, error)
+	ListVersions(ctx context.Context, in *ListVersionsReq, opts ...grpc.CallOption) (*ListVersionsRes, error)
+	ReadVersion(ctx context.Context, in *ReadVersionReq, opts ...grpc.CallOption) (Storage_ReadVersionClient, error)
+	RestoreVersion(ctx context.Context, in *RestoreVersionReq, opts ...grpc.CallOption) (*RestoreVersionRes, error)
+	Remove(ctx context.Context, in *RemoveReq, opts ...grpc.CallOption) (*RemoveRes, error)
+	ListTrash(ctx context.Context, in *ListTrashReq, opts ...grpc.CallOption) (*ListTrashRes, error)
+	RestoreTrash(ctx context.Context, in *RestoreTrashReq, opts ...grpc.CallOption) (*RestoreTrashRes, error)
+	EmptyTrash(ctx context.Context, in *EmptyTrashReq, opts ...grpc.CallOption) (*EmptyTrashRes, error)
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotReq, opts ...grpc.CallOption) (*CreateSnapshotRes, error)
+	ListSnapshots(ctx context.Context, in *ListSnapshotsReq, opts ...grpc.CallOption) (*ListSnapshotsRes, error)
+	RestoreSnapshot(ctx context.Context, in *RestoreSnapshotReq, opts ...grpc.CallOption) (*RestoreSnapshotRes, error)
+	DeleteSnapshot(ctx context.Context, in *DeleteSnapshotReq, opts ...grpc.CallOption) (*DeleteSnapshotRes, error)
+	Watch(ctx context.Context, in *WatchReq, opts ...grpc.CallOption) (Storage_WatchClient, error)
+	GetSignature(ctx context.Context, in *GetSignatureReq, opts ...grpc.CallOption) (Storage_GetSignatureClient, error)
+	GetDelta(ctx context.Context, opts ...grpc.CallOption) (Storage_GetDeltaClient, error)
+	PutDelta(ctx context.Context, opts ...grpc.CallOption) (Storage_PutDeltaClient, error)
+	CompareManifest(ctx context.Context, opts ...grpc.CallOption) (Storage_CompareManifestClient, error)
+	ReadArchive(ctx context.Context, in *ReadArchiveReq, opts ...grpc.CallOption) (Storage_ReadArchiveClient, error)
+	ExtractArchive(ctx context.Context, in *ExtractArchiveReq, opts ...grpc.CallOption) (*ExtractArchiveRes, error)
+	Stat(ctx context.Context, in *StatReq, opts ...grpc.CallOption) (*StatRes, error)
+	Scrub(ctx context.Context, in *ScrubReq, opts ...grpc.CallOption) (Storage_ScrubClient, error)
+	Search(ctx context.Context, in *SearchReq, opts ...grpc.CallOption) (*SearchRes, error)
+	Preview(ctx context.Context, in *PreviewReq, opts ...grpc.CallOption) (*PreviewRes, error)
+	SetMetadata(ctx context.Context, in *SetMetadataReq, opts ...grpc.CallOption) (*SetMetadataRes, error)
+	GetMetadata(ctx context.Context, in *GetMetadataReq, opts ...grpc.CallOption) (*GetMetadataRes, error)
+	Lock(ctx context.Context, in *LockReq, opts ...grpc.CallOption) (*LockRes, error)
+	RenewLock(ctx context.Context, in *RenewLockReq, opts ...grpc.CallOption) (*RenewLockRes, error)
+	Unlock(ctx context.Context, in *UnlockReq, opts ...grpc.CallOption) (*UnlockRes, error)
+}
+
+type storageClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStorageClient(cc grpc.ClientConnInterface) StorageClient {
+	return &storageClient{cc}
+}
+
+func (c *storageClient) CreateUpload(ctx context.Context, in *CreateUploadReq, opts ...grpc.CallOption) (*CreateUploadRes, error) {
+	out := new(CreateUploadRes)
+	err := c.cc.Invoke(ctx, Storage_CreateUpload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) PutChunk(ctx context.Context, in *PutChunkReq, opts ...grpc.CallOption) (*PutChunkRes, error) {
+	out := new(PutChunkRes)
+	err := c.cc.Invoke(ctx, Storage_PutChunk_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) GetUpload(ctx context.Context, in *GetUploadReq, opts ...grpc.CallOption) (*GetUploadRes, error) {
+	out := new(GetUploadRes)
+	err := c.cc.Invoke(ctx, Storage_GetUpload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) CommitUpload(ctx context.Context, in *CommitUploadReq, opts ...grpc.CallOption) (*CommitUploadRes, error) {
+	out := new(CommitUploadRes)
+	err := c.cc.Invoke(ctx, Storage_CommitUpload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) AbortUpload(ctx context.Context, in *AbortUploadReq, opts ...grpc.CallOption) (*AbortUploadRes, error) {
+	out := new(AbortUploadRes)
+	err := c.cc.Invoke(ctx, Storage_AbortUpload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) GetUsage(ctx context.Context, in *GetUsageReq, opts ...grpc.CallOption) (*GetUsageRes, error) {
+	out := new(GetUsageRes)
+	err := c.cc.Invoke(ctx, Storage_GetUsage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) ListVersions(ctx context.Context, in *ListVersionsReq, opts ...grpc.CallOption) (*ListVersionsRes, error) {
+	out := new(ListVersionsRes)
+	err := c.cc.Invoke(ctx, Storage_ListVersions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) ReadVersion(ctx context.Context, in *ReadVersionReq, opts ...grpc.CallOption) (Storage_ReadVersionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[0], Storage_ReadVersion_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageReadVersionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ReadVersionClient interface {
+	Recv() (*FileChunk, error)
+	grpc.ClientStream
+}
+
+type storageReadVersionClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageReadVersionClient) Recv() (*FileChunk, error) {
+	m := new(FileChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) RestoreVersion(ctx context.Context, in *RestoreVersionReq, opts ...grpc.CallOption) (*RestoreVersionRes, error) {
+	out := new(RestoreVersionRes)
+	err := c.cc.Invoke(ctx, Storage_RestoreVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Remove(ctx context.Context, in *RemoveReq, opts ...grpc.CallOption) (*RemoveRes, error) {
+	out := new(RemoveRes)
+	err := c.cc.Invoke(ctx, Storage_Remove_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) ListTrash(ctx context.Context, in *ListTrashReq, opts ...grpc.CallOption) (*ListTrashRes, error) {
+	out := new(ListTrashRes)
+	err := c.cc.Invoke(ctx, Storage_ListTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) RestoreTrash(ctx context.Context, in *RestoreTrashReq, opts ...grpc.CallOption) (*RestoreTrashRes, error) {
+	out := new(RestoreTrashRes)
+	err := c.cc.Invoke(ctx, Storage_RestoreTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) EmptyTrash(ctx context.Context, in *EmptyTrashReq, opts ...grpc.CallOption) (*EmptyTrashRes, error) {
+	out := new(EmptyTrashRes)
+	err := c.cc.Invoke(ctx, Storage_EmptyTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotReq, opts ...grpc.CallOption) (*CreateSnapshotRes, error) {
+	out := new(CreateSnapshotRes)
+	err := c.cc.Invoke(ctx, Storage_CreateSnapshot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) ListSnapshots(ctx context.Context, in *ListSnapshotsReq, opts ...grpc.CallOption) (*ListSnapshotsRes, error) {
+	out := new(ListSnapshotsRes)
+	err := c.cc.Invoke(ctx, Storage_ListSnapshots_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) RestoreSnapshot(ctx context.Context, in *RestoreSnapshotReq, opts ...grpc.CallOption) (*RestoreSnapshotRes, error) {
+	out := new(RestoreSnapshotRes)
+	err := c.cc.Invoke(ctx, Storage_RestoreSnapshot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) DeleteSnapshot(ctx context.Context, in *DeleteSnapshotReq, opts ...grpc.CallOption) (*DeleteSnapshotRes, error) {
+	out := new(DeleteSnapshotRes)
+	err := c.cc.Invoke(ctx, Storage_DeleteSnapshot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Watch(ctx context.Context, in *WatchReq, opts ...grpc.CallOption) (Storage_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[1], Storage_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_WatchClient interface {
+	Recv() (*Change, error)
+	grpc.ClientStream
+}
+
+type storageWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageWatchClient) Recv() (*Change, error) {
+	m := new(Change)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) GetSignature(ctx context.Context, in *GetSignatureReq, opts ...grpc.CallOption) (Storage_GetSignatureClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[2], Storage_GetSignature_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageGetSignatureClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_GetSignatureClient interface {
+	Recv() (*FileSignature, error)
+	grpc.ClientStream
+}
+
+type storageGetSignatureClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageGetSignatureClient) Recv() (*FileSignature, error) {
+	m := new(FileSignature)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) GetDelta(ctx context.Context, opts ...grpc.CallOption) (Storage_GetDeltaClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[3], Storage_GetDelta_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageGetDeltaClient{stream}
+	return x, nil
+}
+
+type Storage_GetDeltaClient interface {
+	Send(*FileSignature) error
+	Recv() (*GetDeltaRes, error)
+	grpc.ClientStream
+}
+
+type storageGetDeltaClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageGetDeltaClient) Send(m *FileSignature) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *storageGetDeltaClient) Recv() (*GetDeltaRes, error) {
+	m := new(GetDeltaRes)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) PutDelta(ctx context.Context, opts ...grpc.CallOption) (Storage_PutDeltaClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[4], Storage_PutDelta_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storagePutDeltaClient{stream}
+	return x, nil
+}
+
+type Storage_PutDeltaClient interface {
+	Send(*PutDeltaReq) error
+	CloseAndRecv() (*PutDeltaRes, error)
+	grpc.ClientStream
+}
+
+type storagePutDeltaClient struct {
+	grpc.ClientStream
+}
+
+func (x *storagePutDeltaClient) Send(m *PutDeltaReq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *storagePutDeltaClient) CloseAndRecv() (*PutDeltaRes, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PutDeltaRes)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) CompareManifest(ctx context.Context, opts ...grpc.CallOption) (Storage_CompareManifestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[5], Storage_CompareManifest_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageCompareManifestClient{stream}
+	return x, nil
+}
+
+type Storage_CompareManifestClient interface {
+	Send(*CompareManifestReq) error
+	Recv() (*CompareManifestRes, error)
+	grpc.ClientStream
+}
+
+type storageCompareManifestClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageCompareManifestClient) Send(m *CompareManifestReq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *storageCompareManifestClient) Recv() (*CompareManifestRes, error) {
+	m := new(CompareManifestRes)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) ReadArchive(ctx context.Context, in *ReadArchiveReq, opts ...grpc.CallOption) (Storage_ReadArchiveClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[6], Storage_ReadArchive_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageReadArchiveClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ReadArchiveClient interface {
+	Recv() (*FileChunk, error)
+	grpc.ClientStream
+}
+
+type storageReadArchiveClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageReadArchiveClient) Recv() (*FileChunk, error) {
+	m := new(FileChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) ExtractArchive(ctx context.Context, in *ExtractArchiveReq, opts ...grpc.CallOption) (*ExtractArchiveRes, error) {
+	out := new(ExtractArchiveRes)
+	err := c.cc.Invoke(ctx, Storage_ExtractArchive_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Stat(ctx context.Context, in *StatReq, opts ...grpc.CallOption) (*StatRes, error) {
+	out := new(StatRes)
+	err := c.cc.Invoke(ctx, Storage_Stat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Scrub(ctx context.Context, in *ScrubReq, opts ...grpc.CallOption) (Storage_ScrubClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Storage_ServiceDesc.Streams[7], Storage_Scrub_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &storageScrubClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Storage_ScrubClient interface {
+	Recv() (*ScrubIssue, error)
+	grpc.ClientStream
+}
+
+type storageScrubClient struct {
+	grpc.ClientStream
+}
+
+func (x *storageScrubClient) Recv() (*ScrubIssue, error) {
+	m := new(ScrubIssue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *storageClient) Search(ctx context.Context, in *SearchReq, opts ...grpc.CallOption) (*SearchRes, error) {
+	out := new(SearchRes)
+	err := c.cc.Invoke(ctx, Storage_Search_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Preview(ctx context.Context, in *PreviewReq, opts ...grpc.CallOption) (*PreviewRes, error) {
+	out := new(PreviewRes)
+	err := c.cc.Invoke(ctx, Storage_Preview_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) SetMetadata(ctx context.Context, in *SetMetadataReq, opts ...grpc.CallOption) (*SetMetadataRes, error) {
+	out := new(SetMetadataRes)
+	err := c.cc.Invoke(ctx, Storage_SetMetadata_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) GetMetadata(ctx context.Context, in *GetMetadataReq, opts ...grpc.CallOption) (*GetMetadataRes, error) {
+	out := new(GetMetadataRes)
+	err := c.cc.Invoke(ctx, Storage_GetMetadata_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Lock(ctx context.Context, in *LockReq, opts ...grpc.CallOption) (*LockRes, error) {
+	out := new(LockRes)
+	err := c.cc.Invoke(ctx, Storage_Lock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) RenewLock(ctx context.Context, in *RenewLockReq, opts ...grpc.CallOption) (*RenewLockRes, error) {
+	out := new(RenewLockRes)
+	err := c.cc.Invoke(ctx, Storage_RenewLock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageClient) Unlock(ctx context.Context, in *UnlockReq, opts ...grpc.CallOption) (*UnlockRes, error) {
+	out := new(UnlockRes)
+	err := c.cc.Invoke(ctx, Storage_Unlock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StorageServer is the server API for Storage service.
+// All implementations must embed UnimplementedStorageServer
+// for forward compatibility
+type StorageServer interface {
+	CreateUpload(context.Context, *CreateUploadReq) (*CreateUploadRes, error)
+	PutChunk(context.Context, *PutChunkReq) (*PutChunkRes, error)
+	GetUpload(context.Context, *GetUploadReq) (*GetUploadRes, error)
+	CommitUpload(context.Context, *CommitUploadReq) (*CommitUploadRes, error)
+	AbortUpload(context.Context, *AbortUploadReq) (*AbortUploadRes, error)
+	GetUsage(context.Context, *GetUsageReq) (*GetUsageRes, error)
+	ListVersions(context.Context, *ListVersionsReq) (*ListVersionsRes, error)
+	ReadVersion(*ReadVersionReq, Storage_ReadVersionServer) error
+	RestoreVersion(context.Context, *RestoreVersionReq) (*RestoreVersionRes, error)
+	Remove(context.Context, *RemoveReq) (*RemoveRes, error)
+	ListTrash(context.Context, *ListTrashReq) (*ListTrashRes, error)
+	RestoreTrash(context.Context, *RestoreTrashReq) (*RestoreTrashRes, error)
+	EmptyTrash(context.Context, *EmptyTrashReq) (*EmptyTrashRes, error)
+	CreateSnapshot(context.Context, *CreateSnapshotReq) (*CreateSnapshotRes, error)
+	ListSnapshots(context.Context, *ListSnapshotsReq) (*ListSnapshotsRes, error)
+	RestoreSnapshot(context.Context, *RestoreSnapshotReq) (*RestoreSnapshotRes, error)
+	DeleteSnapshot(context.Context, *DeleteSnapshotReq) (*DeleteSnapshotRes, error)
+	Watch(*WatchReq, Storage_WatchServer) error
+	GetSignature(*GetSignatureReq, Storage_GetSignatureServer) error
+	GetDelta(Storage_GetDeltaServer) error
+	PutDelta(Storage_PutDeltaServer) error
+	CompareManifest(Storage_CompareManifestServer) error
+	ReadArchive(*ReadArchiveReq, Storage_ReadArchiveServer) error
+	ExtractArchive(context.Context, *ExtractArchiveReq) (*ExtractArchiveRes, error)
+	Stat(context.Context, *StatReq) (*StatRes, error)
+	Scrub(*ScrubReq, Storage_ScrubServer) error
+	Search(context.Context, *SearchReq) (*SearchRes, error)
+	Preview(context.Context, *PreviewReq) (*PreviewRes, error)
+	SetMetadata(context.Context, *SetMetadataReq) (*SetMetadataRes, error)
+	GetMetadata(context.Context, *GetMetadataReq) (*GetMetadataRes, error)
+	Lock(context.Context, *LockReq) (*LockRes, error)
+	RenewLock(context.Context, *RenewLockReq) (*RenewLockRes, error)
+	Unlock(context.Context, *UnlockReq) (*UnlockRes, error)
+	mustEmbedUnimplementedStorageServer()
+}
+
+// UnimplementedStorageServer must be embedded to have forward compatible implementations.
+type UnimplementedStorageServer struct {
+}
+
+func (UnimplementedStorageServer) CreateUpload(context.Context, *CreateUploadReq) (*CreateUploadRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUpload not implemented")
+}
+func (UnimplementedStorageServer) PutChunk(context.Context, *PutChunkReq) (*PutChunkRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutChunk not implemented")
+}
+func (UnimplementedStorageServer) GetUpload(context.Context, *GetUploadReq) (*GetUploadRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUpload not implemented")
+}
+func (UnimplementedStorageServer) CommitUpload(context.Context, *CommitUploadReq) (*CommitUploadRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitUpload not implemented")
+}
+func (UnimplementedStorageServer) AbortUpload(context.Context, *AbortUploadReq) (*AbortUploadRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortUpload not implemented")
+}
+func (UnimplementedStorageServer) GetUsage(context.Context, *GetUsageReq) (*GetUsageRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsage not implemented")
+}
+func (UnimplementedStorageServer) ListVersions(context.Context, *ListVersionsReq) (*ListVersionsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedStorageServer) ReadVersion(*ReadVersionReq, Storage_ReadVersionServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReadVersion not implemented")
+}
+func (UnimplementedStorageServer) RestoreVersion(context.Context, *RestoreVersionReq) (*RestoreVersionRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreVersion not implemented")
+}
+func (UnimplementedStorageServer) Remove(context.Context, *RemoveReq) (*RemoveRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedStorageServer) ListTrash(context.Context, *ListTrashReq) (*ListTrashRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedStorageServer) RestoreTrash(context.Context, *RestoreTrashReq) (*RestoreTrashRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreTrash not implemented")
+}
+func (UnimplementedStorageServer) EmptyTrash(context.Context, *EmptyTrashReq) (*EmptyTrashRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EmptyTrash not implemented")
+}
+func (UnimplementedStorageServer) CreateSnapshot(context.Context, *CreateSnapshotReq) (*CreateSnapshotRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSnapshot not implemented")
+}
+func (UnimplementedStorageServer) ListSnapshots(context.Context, *ListSnapshotsReq) (*ListSnapshotsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (UnimplementedStorageServer) RestoreSnapshot(context.Context, *RestoreSnapshotReq) (*RestoreSnapshotRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreSnapshot not implemented")
+}
+func (UnimplementedStorageServer) DeleteSnapshot(context.Context, *DeleteSnapshotReq) (*DeleteSnapshotRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSnapshot not implemented")
+}
+func (UnimplementedStorageServer) Watch(*WatchReq, Storage_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedStorageServer) GetSignature(*GetSignatureReq, Storage_GetSignatureServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetSignature not implemented")
+}
+func (UnimplementedStorageServer) GetDelta(Storage_GetDeltaServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetDelta not implemented")
+}
+func (UnimplementedStorageServer) PutDelta(Storage_PutDeltaServer) error {
+	return status.Errorf(codes.Unimplemented, "method PutDelta not implemented")
+}
+func (UnimplementedStorageServer) CompareManifest(Storage_CompareManifestServer) error {
+	return status.Errorf(codes.Unimplemented, "method CompareManifest not implemented")
+}
+func (UnimplementedStorageServer) ReadArchive(*ReadArchiveReq, Storage_ReadArchiveServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReadArchive not implemented")
+}
+func (UnimplementedStorageServer) ExtractArchive(context.Context, *ExtractArchiveReq) (*ExtractArchiveRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtractArchive not implemented")
+}
+func (UnimplementedStorageServer) Stat(context.Context, *StatReq) (*StatRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedStorageServer) Scrub(*ScrubReq, Storage_ScrubServer) error {
+	return status.Errorf(codes.Unimplemented, "method Scrub not implemented")
+}
+func (UnimplementedStorageServer) Search(context.Context, *SearchReq) (*SearchRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedStorageServer) Preview(context.Context, *PreviewReq) (*PreviewRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Preview not implemented")
+}
+func (UnimplementedStorageServer) SetMetadata(context.Context, *SetMetadataReq) (*SetMetadataRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMetadata not implemented")
+}
+func (UnimplementedStorageServer) GetMetadata(context.Context, *GetMetadataReq) (*GetMetadataRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetadata not implemented")
+}
+func (UnimplementedStorageServer) Lock(context.Context, *LockReq) (*LockRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lock not implemented")
+}
+func (UnimplementedStorageServer) RenewLock(context.Context, *RenewLockReq) (*RenewLockRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewLock not implemented")
+}
+func (UnimplementedStorageServer) Unlock(context.Context, *UnlockReq) (*UnlockRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unlock not implemented")
+}
+func (UnimplementedStorageServer) mustEmbedUnimplementedStorageServer() {}
+
+// UnsafeStorageServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StorageServer will
+// result in compilation errors.
+type UnsafeStorageServer interface {
+	mustEmbedUnimplementedStorageServer()
+}
+
+func RegisterStorageServer(s grpc.ServiceRegistrar, srv StorageServer) {
+	s.RegisterService(&Storage_ServiceDesc, srv)
+}
+
+func _Storage_CreateUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUploadReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).CreateUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_CreateUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).CreateUpload(ctx, req.(*CreateUploadReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_PutChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutChunkReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).PutChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_PutChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).PutChunk(ctx, req.(*PutChunkReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_GetUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUploadReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).GetUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_GetUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).GetUpload(ctx, req.(*GetUploadReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_CommitUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitUploadReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).CommitUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_CommitUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).CommitUpload(ctx, req.(*CommitUploadReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_AbortUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AbortUploadReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).AbortUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_AbortUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).AbortUpload(ctx, req.(*AbortUploadReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).GetUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_GetUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).GetUsage(ctx, req.(*GetUsageReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).ListVersions(ctx, req.(*ListVersionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_ReadVersion_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadVersionReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).ReadVersion(m, &storageReadVersionServer{stream})
+}
+
+type Storage_ReadVersionServer interface {
+	Send(*FileChunk) error
+	grpc.ServerStream
+}
+
+type storageReadVersionServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageReadVersionServer) Send(m *FileChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_RestoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreVersionReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).RestoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_RestoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).RestoreVersion(ctx, req.(*RestoreVersionReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Remove(ctx, req.(*RemoveReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrashReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).ListTrash(ctx, req.(*ListTrashReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_RestoreTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreTrashReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).RestoreTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_RestoreTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).RestoreTrash(ctx, req.(*RestoreTrashReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_EmptyTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyTrashReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).EmptyTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_EmptyTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).EmptyTrash(ctx, req.(*EmptyTrashReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_CreateSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).CreateSnapshot(ctx, req.(*CreateSnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_ListSnapshots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).ListSnapshots(ctx, req.(*ListSnapshotsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_RestoreSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreSnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).RestoreSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_RestoreSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).RestoreSnapshot(ctx, req.(*RestoreSnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_DeleteSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).DeleteSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_DeleteSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).DeleteSnapshot(ctx, req.(*DeleteSnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Watch(m, &storageWatchServer{stream})
+}
+
+type Storage_WatchServer interface {
+	Send(*Change) error
+	grpc.ServerStream
+}
+
+type storageWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageWatchServer) Send(m *Change) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_GetSignature_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetSignatureReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).GetSignature(m, &storageGetSignatureServer{stream})
+}
+
+type Storage_GetSignatureServer interface {
+	Send(*FileSignature) error
+	grpc.ServerStream
+}
+
+type storageGetSignatureServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageGetSignatureServer) Send(m *FileSignature) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_GetDelta_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServer).GetDelta(&storageGetDeltaServer{stream})
+}
+
+type Storage_GetDeltaServer interface {
+	Send(*GetDeltaRes) error
+	Recv() (*FileSignature, error)
+	grpc.ServerStream
+}
+
+type storageGetDeltaServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageGetDeltaServer) Send(m *GetDeltaRes) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *storageGetDeltaServer) Recv() (*FileSignature, error) {
+	m := new(FileSignature)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Storage_PutDelta_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServer).PutDelta(&storagePutDeltaServer{stream})
+}
+
+type Storage_PutDeltaServer interface {
+	SendAndClose(*PutDeltaRes) error
+	Recv() (*PutDeltaReq, error)
+	grpc.ServerStream
+}
+
+type storagePutDeltaServer struct {
+	grpc.ServerStream
+}
+
+func (x *storagePutDeltaServer) SendAndClose(m *PutDeltaRes) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *storagePutDeltaServer) Recv() (*PutDeltaReq, error) {
+	m := new(PutDeltaReq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Storage_CompareManifest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorageServer).CompareManifest(&storageCompareManifestServer{stream})
+}
+
+type Storage_CompareManifestServer interface {
+	Send(*CompareManifestRes) error
+	Recv() (*CompareManifestReq, error)
+	grpc.ServerStream
+}
+
+type storageCompareManifestServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageCompareManifestServer) Send(m *CompareManifestRes) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *storageCompareManifestServer) Recv() (*CompareManifestReq, error) {
+	m := new(CompareManifestReq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Storage_ReadArchive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadArchiveReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).ReadArchive(m, &storageReadArchiveServer{stream})
+}
+
+type Storage_ReadArchiveServer interface {
+	Send(*FileChunk) error
+	grpc.ServerStream
+}
+
+type storageReadArchiveServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageReadArchiveServer) Send(m *FileChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_ExtractArchive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractArchiveReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).ExtractArchive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_ExtractArchive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).ExtractArchive(ctx, req.(*ExtractArchiveReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Stat(ctx, req.(*StatReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Scrub_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScrubReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StorageServer).Scrub(m, &storageScrubServer{stream})
+}
+
+type Storage_ScrubServer interface {
+	Send(*ScrubIssue) error
+	grpc.ServerStream
+}
+
+type storageScrubServer struct {
+	grpc.ServerStream
+}
+
+func (x *storageScrubServer) Send(m *ScrubIssue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Storage_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Search(ctx, req.(*SearchReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Preview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreviewReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Preview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Preview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Preview(ctx, req.(*PreviewReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_SetMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMetadataReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).SetMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_SetMetadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).SetMetadata(ctx, req.(*SetMetadataReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_GetMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetadataReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).GetMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_GetMetadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).GetMetadata(ctx, req.(*GetMetadataReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Lock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Lock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Lock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Lock(ctx, req.(*LockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_RenewLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewLockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).RenewLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_RenewLock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).RenewLock(ctx, req.(*RenewLockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Storage_Unlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Unlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Unlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Unlock(ctx, req.(*UnlockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Storage_ServiceDesc is the grpc.ServiceDesc for Storage service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Storage_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Storage",
+	HandlerType: (*StorageServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateUpload",
+			Handler:    _Storage_CreateUpload_Handler,
+		},
+		{
+			MethodName: "PutChunk",
+			Handler:    _Storage_PutChunk_Handler,
+		},
+		{
+			MethodName: "GetUpload",
+			Handler:    _Storage_GetUpload_Handler,
+		},
+		{
+			MethodName: "CommitUpload",
+			Handler:    _Storage_CommitUpload_Handler,
+		},
+		{
+			MethodName: "AbortUpload",
+			Handler:    _Storage_AbortUpload_Handler,
+		},
+		{
+			MethodName: "GetUsage",
+			Handler:    _Storage_GetUsage_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _Storage_ListVersions_Handler,
+		},
+		{
+			MethodName: "RestoreVersion",
+			Handler:    _Storage_RestoreVersion_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _Storage_Remove_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _Storage_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreTrash",
+			Handler:    _Storage_RestoreTrash_Handler,
+		},
+		{
+			MethodName: "EmptyTrash",
+			Handler:    _Storage_EmptyTrash_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _Storage_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _Storage_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "RestoreSnapshot",
+			Handler:    _Storage_RestoreSnapshot_Handler,
+		},
+		{
+			MethodName: "DeleteSnapshot",
+			Handler:    _Storage_DeleteSnapshot_Handler,
+		},
+		{
+			MethodName: "ExtractArchive",
+			Handler:    _Storage_ExtractArchive_Handler,
+		},
+		{
+			MethodName: "Stat",
+			Handler:    _Storage_Stat_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Storage_Search_Handler,
+		},
+		{
+			MethodName: "Preview",
+			Handler:    _Storage_Preview_Handler,
+		},
+		{
+			MethodName: "SetMetadata",
+			Handler:    _Storage_SetMetadata_Handler,
+		},
+		{
+			MethodName: "GetMetadata",
+			Handler:    _Storage_GetMetadata_Handler,
+		},
+		{
+			MethodName: "Lock",
+			Handler:    _Storage_Lock_Handler,
+		},
+		{
+			MethodName: "RenewLock",
+			Handler:    _Storage_RenewLock_Handler,
+		},
+		{
+			MethodName: "Unlock",
+			Handler:    _Storage_Unlock_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReadVersion",
+			Handler:       _Storage_ReadVersion_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Storage_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetSignature",
+			Handler:       _Storage_GetSignature_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetDelta",
+			Handler:       _Storage_GetDelta_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "PutDelta",
+			Handler:       _Storage_PutDelta_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "CompareManifest",
+			Handler:       _Storage_CompareManifest_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ReadArchive",
+			Handler:       _Storage_ReadArchive_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Scrub",
+			Handler:       _Storage_Scrub_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "storage.proto",
+}
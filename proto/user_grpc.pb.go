@@ -19,12 +19,19 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	UserManager_List_FullMethodName           = "/UserManager/List"
-	UserManager_Get_FullMethodName            = "/UserManager/Get"
-	UserManager_Create_FullMethodName         = "/UserManager/Create"
-	UserManager_Update_FullMethodName         = "/UserManager/Update"
-	UserManager_Delete_FullMethodName         = "/UserManager/Delete"
-	UserManager_ChangePassword_FullMethodName = "/UserManager/ChangePassword"
+	UserManager_List_FullMethodName            = "/UserManager/List"
+	UserManager_Get_FullMethodName             = "/UserManager/Get"
+	UserManager_Create_FullMethodName          = "/UserManager/Create"
+	UserManager_Update_FullMethodName          = "/UserManager/Update"
+	UserManager_Delete_FullMethodName          = "/UserManager/Delete"
+	UserManager_ChangePassword_FullMethodName  = "/UserManager/ChangePassword"
+	UserManager_SetQuota_FullMethodName        = "/UserManager/SetQuota"
+	UserManager_ListPublicKeys_FullMethodName  = "/UserManager/ListPublicKeys"
+	UserManager_AddPublicKey_FullMethodName    = "/UserManager/AddPublicKey"
+	UserManager_RemovePublicKey_FullMethodName = "/UserManager/RemovePublicKey"
+	UserManager_ListAccessKeys_FullMethodName  = "/UserManager/ListAccessKeys"
+	UserManager_CreateAccessKey_FullMethodName = "/UserManager/CreateAccessKey"
+	UserManager_RemoveAccessKey_FullMethodName = "/UserManager/RemoveAccessKey"
 )
 
 // UserManagerClient is the client API for UserManager service.
@@ -37,6 +44,13 @@ type UserManagerClient interface {
 	Update(ctx context.Context, in *UpdateUserReq, opts ...grpc.CallOption) (*UpdateUserRes, error)
 	Delete(ctx context.Context, in *DeleteUserReq, opts ...grpc.CallOption) (*DeleteUserRes, error)
 	ChangePassword(ctx context.Context, in *ChangePasswordReq, opts ...grpc.CallOption) (*ChangePasswordRes, error)
+	SetQuota(ctx context.Context, in *SetUserQuotaReq, opts ...grpc.CallOption) (*SetUserQuotaRes, error)
+	ListPublicKeys(ctx context.Context, in *ListPublicKeysReq, opts ...grpc.CallOption) (*ListPublicKeysRes, error)
+	AddPublicKey(ctx context.Context, in *AddPublicKeyReq, opts ...grpc.CallOption) (*AddPublicKeyRes, error)
+	RemovePublicKey(ctx context.Context, in *RemovePublicKeyReq, opts ...grpc.CallOption) (*RemovePublicKeyRes, error)
+	ListAccessKeys(ctx context.Context, in *ListAccessKeysReq, opts ...grpc.CallOption) (*ListAccessKeysRes, error)
+	CreateAccessKey(ctx context.Context, in *CreateAccessKeyReq, opts ...grpc.CallOption) (*CreateAccessKeyRes, error)
+	RemoveAccessKey(ctx context.Context, in *RemoveAccessKeyReq, opts ...grpc.CallOption) (*RemoveAccessKeyRes, error)
 }
 
 type userManagerClient struct {
@@ -101,6 +115,69 @@ func (c *userManagerClient) ChangePassword(ctx context.Context, in *ChangePasswo
 	return out, nil
 }
 
+func (c *userManagerClient) SetQuota(ctx context.Context, in *SetUserQuotaReq, opts ...grpc.CallOption) (*SetUserQuotaRes, error) {
+	out := new(SetUserQuotaRes)
+	err := c.cc.Invoke(ctx, UserManager_SetQuota_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) ListPublicKeys(ctx context.Context, in *ListPublicKeysReq, opts ...grpc.CallOption) (*ListPublicKeysRes, error) {
+	out := new(ListPublicKeysRes)
+	err := c.cc.Invoke(ctx, UserManager_ListPublicKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) AddPublicKey(ctx context.Context, in *AddPublicKeyReq, opts ...grpc.CallOption) (*AddPublicKeyRes, error) {
+	out := new(AddPublicKeyRes)
+	err := c.cc.Invoke(ctx, UserManager_AddPublicKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) RemovePublicKey(ctx context.Context, in *RemovePublicKeyReq, opts ...grpc.CallOption) (*RemovePublicKeyRes, error) {
+	out := new(RemovePublicKeyRes)
+	err := c.cc.Invoke(ctx, UserManager_RemovePublicKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) ListAccessKeys(ctx context.Context, in *ListAccessKeysReq, opts ...grpc.CallOption) (*ListAccessKeysRes, error) {
+	out := new(ListAccessKeysRes)
+	err := c.cc.Invoke(ctx, UserManager_ListAccessKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) CreateAccessKey(ctx context.Context, in *CreateAccessKeyReq, opts ...grpc.CallOption) (*CreateAccessKeyRes, error) {
+	out := new(CreateAccessKeyRes)
+	err := c.cc.Invoke(ctx, UserManager_CreateAccessKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userManagerClient) RemoveAccessKey(ctx context.Context, in *RemoveAccessKeyReq, opts ...grpc.CallOption) (*RemoveAccessKeyRes, error) {
+	out := new(RemoveAccessKeyRes)
+	err := c.cc.Invoke(ctx, UserManager_RemoveAccessKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserManagerServer is the server API for UserManager service.
 // All implementations must embed UnimplementedUserManagerServer
 // for forward compatibility
@@ -111,6 +188,13 @@ type UserManagerServer interface {
 	Update(context.Context, *UpdateUserReq) (*UpdateUserRes, error)
 	Delete(context.Context, *DeleteUserReq) (*DeleteUserRes, error)
 	ChangePassword(context.Context, *ChangePasswordReq) (*ChangePasswordRes, error)
+	SetQuota(context.Context, *SetUserQuotaReq) (*SetUserQuotaRes, error)
+	ListPublicKeys(context.Context, *ListPublicKeysReq) (*ListPublicKeysRes, error)
+	AddPublicKey(context.Context, *AddPublicKeyReq) (*AddPublicKeyRes, error)
+	RemovePublicKey(context.Context, *RemovePublicKeyReq) (*RemovePublicKeyRes, error)
+	ListAccessKeys(context.Context, *ListAccessKeysReq) (*ListAccessKeysRes, error)
+	CreateAccessKey(context.Context, *CreateAccessKeyReq) (*CreateAccessKeyRes, error)
+	RemoveAccessKey(context.Context, *RemoveAccessKeyReq) (*RemoveAccessKeyRes, error)
 	mustEmbedUnimplementedUserManagerServer()
 }
 
@@ -136,6 +220,27 @@ func (UnimplementedUserManagerServer) Delete(context.Context, *DeleteUserReq) (*
 func (UnimplementedUserManagerServer) ChangePassword(context.Context, *ChangePasswordReq) (*ChangePasswordRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
 }
+func (UnimplementedUserManagerServer) SetQuota(context.Context, *SetUserQuotaReq) (*SetUserQuotaRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetQuota not implemented")
+}
+func (UnimplementedUserManagerServer) ListPublicKeys(context.Context, *ListPublicKeysReq) (*ListPublicKeysRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPublicKeys not implemented")
+}
+func (UnimplementedUserManagerServer) AddPublicKey(context.Context, *AddPublicKeyReq) (*AddPublicKeyRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPublicKey not implemented")
+}
+func (UnimplementedUserManagerServer) RemovePublicKey(context.Context, *RemovePublicKeyReq) (*RemovePublicKeyRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemovePublicKey not implemented")
+}
+func (UnimplementedUserManagerServer) ListAccessKeys(context.Context, *ListAccessKeysReq) (*ListAccessKeysRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAccessKeys not implemented")
+}
+func (UnimplementedUserManagerServer) CreateAccessKey(context.Context, *CreateAccessKeyReq) (*CreateAccessKeyRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccessKey not implemented")
+}
+func (UnimplementedUserManagerServer) RemoveAccessKey(context.Context, *RemoveAccessKeyReq) (*RemoveAccessKeyRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveAccessKey not implemented")
+}
 func (UnimplementedUserManagerServer) mustEmbedUnimplementedUserManagerServer() {}
 
 // UnsafeUserManagerServer may be embedded to opt out of forward compatibility for this service.
@@ -257,6 +362,132 @@ func _UserManager_ChangePassword_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserManager_SetQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserQuotaReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).SetQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_SetQuota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).SetQuota(ctx, req.(*SetUserQuotaReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_ListPublicKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPublicKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).ListPublicKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_ListPublicKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).ListPublicKeys(ctx, req.(*ListPublicKeysReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_AddPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPublicKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).AddPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_AddPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).AddPublicKey(ctx, req.(*AddPublicKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_RemovePublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemovePublicKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).RemovePublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_RemovePublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).RemovePublicKey(ctx, req.(*RemovePublicKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_ListAccessKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAccessKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).ListAccessKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_ListAccessKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).ListAccessKeys(ctx, req.(*ListAccessKeysReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_CreateAccessKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAccessKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).CreateAccessKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_CreateAccessKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).CreateAccessKey(ctx, req.(*CreateAccessKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserManager_RemoveAccessKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveAccessKeyReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserManagerServer).RemoveAccessKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserManager_RemoveAccessKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserManagerServer).RemoveAccessKey(ctx, req.(*RemoveAccessKeyReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserManager_ServiceDesc is the grpc.ServiceDesc for UserManager service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -288,6 +519,34 @@ var UserManager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ChangePassword",
 			Handler:    _UserManager_ChangePassword_Handler,
 		},
+		{
+			MethodName: "SetQuota",
+			Handler:    _UserManager_SetQuota_Handler,
+		},
+		{
+			MethodName: "ListPublicKeys",
+			Handler:    _UserManager_ListPublicKeys_Handler,
+		},
+		{
+			MethodName: "AddPublicKey",
+			Handler:    _UserManager_AddPublicKey_Handler,
+		},
+		{
+			MethodName: "RemovePublicKey",
+			Handler:    _UserManager_RemovePublicKey_Handler,
+		},
+		{
+			MethodName: "ListAccessKeys",
+			Handler:    _UserManager_ListAccessKeys_Handler,
+		},
+		{
+			MethodName: "CreateAccessKey",
+			Handler:    _UserManager_CreateAccessKey_Handler,
+		},
+		{
+			MethodName: "RemoveAccessKey",
+			Handler:    _UserManager_RemoveAccessKey_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
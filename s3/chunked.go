@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const maxChunkSize = 16 * 1024 * 1024
+
+var errMalformedChunk = errors.New("malformed aws-chunked payload")
+
+// emptySHA256 is hex of sha256 of empty string.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// chunkedReader decodes aws-chunked body, every chunk is verified
+// against signature chained from the seed one if signingKey is set.
+// Trailing headers of unsigned payload are skipped.
+type chunkedReader struct {
+	r          *bufio.Reader
+	body       io.Closer
+	signingKey []byte
+	amzDate    string
+	scope      string
+	prevSig    string
+
+	chunk []byte
+	done  bool
+	err   error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.readChunk()
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", errMalformedChunk
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+func (c *chunkedReader) readChunk() error {
+	header, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeHex, ext, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errMalformedChunk
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(c.r, data)
+	if err != nil {
+		return errMalformedChunk
+	}
+
+	if c.signingKey != nil {
+		sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok {
+			return errMalformedChunk
+		}
+		sum := sha256.Sum256(data)
+		stringToSign := strings.Join([]string{
+			"AWS4-HMAC-SHA256-PAYLOAD",
+			c.amzDate,
+			c.scope,
+			c.prevSig,
+			emptySHA256,
+			hex.EncodeToString(sum[:]),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.signingKey, stringToSign))
+		if !hmac.Equal([]byte(expected), []byte(sig)) {
+			return errPayloadMismatch
+		}
+		c.prevSig = sig
+	}
+
+	if size == 0 {
+		c.done = true
+		// trailing headers end with empty line
+		for {
+			line, err := c.readLine()
+			if err != nil || line == "" {
+				return err
+			}
+		}
+	}
+	line, err := c.readLine()
+	if err != nil || line != "" {
+		return errMalformedChunk
+	}
+	c.chunk = data
+	return nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.body.Close()
+}
@@ -0,0 +1,744 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// BucketConfig describes bucket of S3 compatible service
+// files of BucketFS are kept in.
+type BucketConfig struct {
+	Endpoint  string // e.g. "https://s3.amazonaws.com"
+	Region    string // "us-east-1" if empty
+	Bucket    string
+	Prefix    string // of keys, files are under it
+	AccessKey string
+	Secret    string
+	TempDir   string       // staging of written files, system one if empty
+	Client    *http.Client // http.DefaultClient if nil
+}
+
+// bucketFS is client backend keeping files as objects of bucket,
+// addressed by path. Directories are common prefixes of keys and
+// markers ending with slash, so they are implicit for objects.
+type bucketFS struct {
+	cfg      BucketConfig
+	endpoint *url.URL
+}
+
+const (
+	defaultRegion  = "us-east-1"
+	bucketDirMode  = fs.ModeDir | 0750
+	bucketFileMode = 0640
+)
+
+func init() {
+	localstorage.RegisterBackend("s3", openBucketBackend)
+}
+
+// openBucketBackend opens bucket by URL like
+// s3://key:secret@host/bucket/prefix?region=eu-west-1,
+// insecure=1 query parameter makes it use plain HTTP.
+func openBucketBackend(u *url.URL) (localstorage.WriteFS, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	secret, _ := u.User.Password()
+	scheme := "https"
+	if u.Query().Get("insecure") == "1" {
+		scheme = "http"
+	}
+	return NewBucketFS(BucketConfig{
+		Endpoint:  scheme + "://" + u.Host,
+		Region:    u.Query().Get("region"),
+		Bucket:    bucket,
+		Prefix:    prefix,
+		AccessKey: u.User.Username(),
+		Secret:    secret,
+	})
+}
+
+// NewBucketFS returns file system of bucket.
+func NewBucketFS(cfg BucketConfig) (localstorage.WriteFS, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Host == "" || cfg.Bucket == "" {
+		return nil, errors.New("endpoint and bucket are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &bucketFS{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// key returns object key of file name.
+func (b *bucketFS) key(name string) string {
+	if name == "." {
+		return b.cfg.Prefix
+	}
+	if b.cfg.Prefix == "" {
+		return name
+	}
+	return b.cfg.Prefix + "/" + name
+}
+
+// dirKey returns prefix of keys inside directory name.
+func (b *bucketFS) dirKey(name string) string {
+	if k := b.key(name); k != "" {
+		return k + "/"
+	}
+	return ""
+}
+
+// sign adds SigV4 signature of request with payload hash to it.
+func (b *bucketFS) sign(r *http.Request, payloadHash string) {
+	now := time.Now().UTC()
+	s := signedRequest{
+		accessKey:     b.cfg.AccessKey,
+		date:          now.Format("20060102"),
+		region:        b.cfg.Region,
+		service:       "s3",
+		amzDate:       now.Format(amzDateFormat),
+		signedHeaders: []string{"host", "x-amz-content-sha256", "x-amz-date"},
+		payloadHash:   payloadHash,
+	}
+	s.scope = strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+	r.Header.Set("X-Amz-Date", s.amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.accessKey, s.scope, strings.Join(s.signedHeaders, ";"),
+		s.expectedSignature(r, b.cfg.Secret)))
+}
+
+// bucketRequest describes call of bucket API.
+type bucketRequest struct {
+	method      string
+	key         string
+	query       url.Values
+	header      http.Header
+	body        io.Reader
+	size        int64
+	payloadHash string // empty for empty body
+}
+
+// emptyHash is SHA-256 of empty payload.
+var emptyHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// do sends signed request, responses with error status are
+// converted to errors, 404 to fs.ErrNotExist.
+func (b *bucketFS) do(op string, name string, req bucketRequest) (*http.Response, error) {
+	p := "/" + b.cfg.Bucket + "/" + req.key
+	u := *b.endpoint
+	u.Path = p
+	u.RawPath = awsEncode(p, true)
+	u.RawQuery = req.query.Encode()
+	r, err := http.NewRequest(req.method, u.String(), req.body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	if req.body != nil {
+		r.ContentLength = req.size
+		if req.size == 0 {
+			// zero length of body is otherwise treated as unknown
+			r.Body = http.NoBody
+		}
+	}
+	payloadHash := req.payloadHash
+	if payloadHash == "" {
+		payloadHash = emptyHash
+	}
+	b.sign(r, payloadHash)
+
+	res, err := b.cfg.Client.Do(r)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	var e errorResponse
+	_ = xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		err = fs.ErrNotExist
+	case e.Code == "QuotaExceeded":
+		err = localstorage.ErrQuotaExceeded
+	case res.StatusCode == http.StatusForbidden:
+		err = fs.ErrPermission
+	default:
+		err = fmt.Errorf("%s: %s", res.Status, e.Message)
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// listPage is one page of listed keys.
+type listPage struct {
+	objects  []object
+	prefixes []string
+	next     string
+}
+
+func (b *bucketFS) list(prefix string, delimiter string, token string) (listPage, error) {
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		q.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	res, err := b.do("list", prefix, bucketRequest{method: http.MethodGet, query: q})
+	if err != nil {
+		return listPage{}, err
+	}
+	defer res.Body.Close()
+	var l listBucketResult
+	err = xml.NewDecoder(res.Body).Decode(&l)
+	if err != nil {
+		return listPage{}, err
+	}
+	r := listPage{objects: l.Contents}
+	for _, p := range l.CommonPrefixes {
+		r.prefixes = append(r.prefixes, p.Prefix)
+	}
+	if l.IsTruncated {
+		r.next = l.NextContinuationToken
+	}
+	return r, nil
+}
+
+// walk calls fn for every listed page until it returns false.
+func (b *bucketFS) walk(prefix string, delimiter string, fn func(listPage) bool) error {
+	token := ""
+	for {
+		l, err := b.list(prefix, delimiter, token)
+		if err != nil {
+			return err
+		}
+		if !fn(l) || l.next == "" {
+			return nil
+		}
+		token = l.next
+	}
+}
+
+// bucketInfo describes object or directory.
+type bucketInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+	isDir    bool
+}
+
+func (i bucketInfo) Name() string {
+	return path.Base(i.name)
+}
+
+func (i bucketInfo) Size() int64 {
+	return i.size
+}
+
+func (i bucketInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return bucketDirMode
+	}
+	return bucketFileMode
+}
+
+func (i bucketInfo) ModTime() time.Time {
+	return i.modified
+}
+
+func (i bucketInfo) IsDir() bool {
+	return i.isDir
+}
+
+func (i bucketInfo) Sys() any {
+	return nil
+}
+
+func (b *bucketFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return bucketInfo{name: name, isDir: true}, nil
+	}
+	res, err := b.do("stat", name, bucketRequest{method: http.MethodHead, key: b.key(name)})
+	if err == nil {
+		_ = res.Body.Close()
+		modified, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+		return bucketInfo{name: name, size: res.ContentLength, modified: modified}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// directory is common prefix of keys inside it or marker,
+	// listed keys are sorted, so search stops after its one
+	dir := b.dirKey(name)
+	found := false
+	err = b.walk(b.key(name), "/", func(l listPage) bool {
+		for _, p := range l.prefixes {
+			found = found || p == dir
+		}
+		for _, o := range l.objects {
+			found = found || o.Key == dir
+		}
+		last := ""
+		if len(l.objects) > 0 {
+			last = l.objects[len(l.objects)-1].Key
+		}
+		if len(l.prefixes) > 0 {
+			last = max(last, l.prefixes[len(l.prefixes)-1])
+		}
+		return !found && last < dir
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return bucketInfo{name: name, isDir: true}, nil
+}
+
+func (b *bucketFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	dir := b.dirKey(name)
+	entries := make(map[string]bucketInfo)
+	err := b.walk(dir, "/", func(l listPage) bool {
+		for _, p := range l.prefixes {
+			n := path.Join(name, strings.TrimSuffix(strings.TrimPrefix(p, dir), "/"))
+			entries[n] = bucketInfo{name: n, isDir: true}
+		}
+		for _, o := range l.objects {
+			if o.Key == dir {
+				// marker of directory itself
+				continue
+			}
+			n := path.Join(name, strings.TrimPrefix(o.Key, dir))
+			if strings.HasSuffix(o.Key, "/") {
+				entries[n] = bucketInfo{name: n, isDir: true}
+				continue
+			}
+			modified, _ := time.Parse(timeFormat, o.LastModified)
+			entries[n] = bucketInfo{name: n, size: o.Size, modified: modified}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 && name != "." {
+		info, err := b.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+	r := make([]fs.DirEntry, 0, len(entries))
+	for _, info := range entries {
+		r = append(r, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name() < r[j].Name() })
+	return r, nil
+}
+
+// bucketFile reads object, sequential reads stream it from current
+// offset, ReadAt requests ranges.
+type bucketFile struct {
+	b    *bucketFS
+	info bucketInfo
+
+	mu     sync.Mutex
+	offset int64
+	body   io.ReadCloser // from offset
+}
+
+// get returns content of object from off, n bytes or the rest if n < 0.
+func (b *bucketFS) get(name string, off int64, n int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if off > 0 || n >= 0 {
+		rng := "bytes=" + strconv.FormatInt(off, 10) + "-"
+		if n >= 0 {
+			rng += strconv.FormatInt(off+n-1, 10)
+		}
+		header.Set("Range", rng)
+	}
+	res, err := b.do("read", name, bucketRequest{method: http.MethodGet, key: b.key(name), header: header})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (f *bucketFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *bucketFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		var err error
+		f.body, err = f.b.get(f.info.name, f.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF {
+		_ = f.body.Close()
+		f.body = nil
+		if n > 0 || f.offset < f.info.size {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+func (f *bucketFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), f.info.size-off)
+	body, err := f.b.get(f.info.name, off, n)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	read, err := io.ReadFull(body, p[:n])
+	if err == nil && read < len(p) {
+		err = io.EOF
+	}
+	return read, err
+}
+
+func (f *bucketFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	case io.SeekStart:
+	default:
+		return f.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return f.offset, errors.New("negative offset")
+	}
+	if offset != f.offset && f.body != nil {
+		_ = f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *bucketFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.body != nil {
+		_ = f.body.Close()
+		f.body = nil
+	}
+	return nil
+}
+
+// bucketDir is directory listed on open.
+type bucketDir struct {
+	info    bucketInfo
+	entries []fs.DirEntry
+}
+
+func (d *bucketDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *bucketDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *bucketDir) Close() error {
+	return nil
+}
+
+func (d *bucketDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if count <= 0 {
+		r := d.entries
+		d.entries = nil
+		return r, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	r := d.entries[:count]
+	d.entries = d.entries[count:]
+	return r, nil
+}
+
+func (b *bucketFS) Open(name string) (fs.File, error) {
+	info, err := b.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &bucketFile{b: b, info: info.(bucketInfo)}, nil
+	}
+	entries, err := b.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketDir{info: info.(bucketInfo), entries: entries}, nil
+}
+
+// bucketWriter stages content in temporary file,
+// which is uploaded on Close.
+type bucketWriter struct {
+	b    *bucketFS
+	name string
+	tmp  *os.File
+	hash hash.Hash
+	size int64
+}
+
+func (w *bucketWriter) Write(p []byte) (int, error) {
+	n, err := w.tmp.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *bucketWriter) Stat() (fs.FileInfo, error) {
+	return bucketInfo{name: w.name, size: w.size, modified: time.Now()}, nil
+}
+
+func (w *bucketWriter) Close() error {
+	defer os.Remove(w.tmp.Name())
+	defer w.tmp.Close()
+	_, err := w.tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return w.b.put(w.name, w.tmp, w.size, hex.EncodeToString(w.hash.Sum(nil)))
+}
+
+func (b *bucketFS) put(name string, r io.Reader, size int64, payloadHash string) error {
+	res, err := b.do("create", name, bucketRequest{
+		method:      http.MethodPut,
+		key:         b.key(name),
+		body:        r,
+		size:        size,
+		payloadHash: payloadHash,
+	})
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (b *bucketFS) Create(name string) (localstorage.FileWriter, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	if info, err := b.Stat(name); err == nil && info.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("is a directory")}
+	}
+	if dir := path.Dir(name); dir != "." {
+		info, err := b.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("not a directory")}
+		}
+	}
+	tmp, err := os.CreateTemp(b.cfg.TempDir, "bucket-*")
+	if err != nil {
+		return nil, err
+	}
+	return &bucketWriter{b: b, name: name, tmp: tmp, hash: sha256.New()}, nil
+}
+
+// Mkdir puts marker of directory.
+func (b *bucketFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if _, err := b.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	res, err := b.do("mkdir", name, bucketRequest{method: http.MethodPut, key: b.dirKey(name)})
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (b *bucketFS) delete(name string, key string) error {
+	res, err := b.do("remove", name, bucketRequest{method: http.MethodDelete, key: key})
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Remove removes object or marker of empty directory.
+func (b *bucketFS) Remove(name string) error {
+	info, err := b.Stat(name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return errors.New("cannot remove root")
+	}
+	if !info.IsDir() {
+		return b.delete(name, b.key(name))
+	}
+	entries, err := b.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+	return b.delete(name, b.dirKey(name))
+}
+
+// copyObject copies content of object through client,
+// since copying is not supported by every service.
+func (b *bucketFS) copyObject(oldname string, newname string) error {
+	res, err := b.do("rename", oldname, bucketRequest{method: http.MethodGet, key: b.key(oldname)})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return b.put(newname, res.Body, res.ContentLength, unsignedPayload)
+}
+
+// Rename copies objects and removes old ones, so it is not atomic.
+// Existing file is replaced, directory is not.
+func (b *bucketFS) Rename(oldname string, newname string) error {
+	info, err := b.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	if oldname == "." || newname == "." {
+		return errors.New("cannot rename root")
+	}
+	if oldname == newname {
+		return nil
+	}
+	if info.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: errors.New("cannot move directory into itself")}
+	}
+	if replaced, err := b.Stat(newname); err == nil && (replaced.IsDir() || info.IsDir()) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	if !info.IsDir() {
+		err = b.copyObject(oldname, newname)
+		if err != nil {
+			return err
+		}
+		return b.delete(oldname, b.key(oldname))
+	}
+
+	var keys []string
+	err = b.walk(b.dirKey(oldname), "", func(l listPage) bool {
+		for _, o := range l.objects {
+			keys = append(keys, o.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	// directories without markers are left empty by some services,
+	// they are removed deepest first once their content is moved
+	dirs := map[string]bool{b.dirKey(oldname): true}
+	for _, k := range keys {
+		rel := strings.TrimPrefix(k, b.dirKey(oldname))
+		moved := path.Join(newname, rel)
+		if strings.HasSuffix(k, "/") {
+			err = b.Mkdir(moved, bucketDirMode)
+			if err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			dirs[k] = true
+			continue
+		}
+		err = b.copyObject(path.Join(oldname, rel), moved)
+		if err != nil {
+			return err
+		}
+		err = b.delete(oldname, k)
+		if err != nil {
+			return err
+		}
+		for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+			dirs[b.dirKey(path.Join(oldname, d))] = true
+		}
+	}
+	if len(keys) == 0 {
+		err = b.Mkdir(newname, bucketDirMode)
+		if err != nil {
+			return err
+		}
+	}
+	sorted := make([]string, 0, len(dirs))
+	for d := range dirs {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, d := range sorted {
+		err = b.delete(oldname, d)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Root is empty, files are not on local disk.
+func (b *bucketFS) Root() string {
+	return ""
+}
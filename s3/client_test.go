@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+type testKeys struct{}
+
+func (testKeys) LookupAccessKey(id string) (authentication.User, string, error) {
+	switch id {
+	case "AKTEST":
+		return authentication.User{Name: "tester", Enabled: true}, "secret", nil
+	case "AKPEER":
+		return authentication.User{Name: "other", Enabled: true}, "secret", nil
+	}
+	return authentication.User{}, "", errors.New("unknown key")
+}
+
+// TestBucketFS runs client backend against gateway
+// serving bucket kept in memory.
+func TestBucketFS(t *testing.T) {
+	g := NewGateway(testKeys{}, nil, t.TempDir())
+	g.AddBucket("data", "tester", localstorage.NewMemFS())
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	b, err := localstorage.OpenBackend("s3://AKTEST:secret@" + u.Host + "/data/cardia?insecure=1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = b.Mkdir("docs", 0750)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = b.Mkdir("docs", 0750); !errors.Is(err, fs.ErrExist) {
+		t.Error("existing directory should not be created: ", err)
+	}
+	f, err := b.Create("docs/plan of launch.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.Copy(f, strings.NewReader("0123456789"))
+	err = f.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	empty, _ := b.Create("docs/empty.txt")
+	_ = empty.Close()
+
+	info, err := fs.Stat(b, "docs/plan of launch.txt")
+	if err != nil || info.Size() != 10 || info.IsDir() {
+		t.Error("wrong info: ", info, err)
+	}
+	entries, err := fs.ReadDir(b, "docs")
+	if err != nil || len(entries) != 2 || entries[1].Name() != "plan of launch.txt" {
+		t.Error("wrong entries: ", entries, err)
+	}
+
+	// ranges are requested for seeks and ReadAt
+	r, err := b.Open("docs/plan of launch.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = r.(io.Seeker).Seek(4, io.SeekStart)
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(r, buf)
+	if err != nil || string(buf) != "456" {
+		t.Error("wrong content after seek: ", string(buf), err)
+	}
+	n, err := r.(io.ReaderAt).ReadAt(buf, 8)
+	if n != 2 || err != io.EOF || string(buf[:n]) != "89" {
+		t.Error("wrong content at offset: ", string(buf[:n]), err)
+	}
+	_ = r.Close()
+
+	err = b.Rename("docs", "archive")
+	if err != nil {
+		t.Error(err)
+	}
+	content, err := fs.ReadFile(b, "archive/plan of launch.txt")
+	if err != nil || string(content) != "0123456789" {
+		t.Error("file should be moved along with directory: ", string(content), err)
+	}
+	if _, err = fs.Stat(b, "docs"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("renamed directory should not exist: ", err)
+	}
+
+	if err = b.Remove("archive"); err == nil {
+		t.Error("directory with content should not be removed")
+	}
+	for _, name := range []string{"archive/plan of launch.txt", "archive/empty.txt", "archive"} {
+		err = b.Remove(name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	entries, err = fs.ReadDir(b, ".")
+	if err != nil || len(entries) != 0 {
+		t.Error("bucket should be empty: ", entries, err)
+	}
+}
@@ -0,0 +1,184 @@
+package s3
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// AccessKeys resolves access key to its owner and secret.
+// Implemented by authentication.Authenticator.
+type AccessKeys interface {
+	LookupAccessKey(id string) (authentication.User, string, error)
+}
+
+// Gateway serves subset of S3 API with path-style addressing.
+// Home of caller is a bucket named after user, buckets added
+// with AddBucket are accessible by their owners only.
+type Gateway struct {
+	keys    AccessKeys
+	homes   *storage.Homes
+	tempDir string // multipart uploads staging
+	region  string // signatures are accepted only for it
+
+	mu      sync.Mutex
+	buckets map[string]bucket
+	staging map[string]int64 // bytes of parts being written by user
+}
+
+func NewGateway(keys AccessKeys, homes *storage.Homes, tempDir string) *Gateway {
+	return &Gateway{
+		keys:    keys,
+		homes:   homes,
+		tempDir: tempDir,
+		region:  defaultRegion,
+		buckets: make(map[string]bucket),
+		staging: make(map[string]int64),
+	}
+}
+
+// SetRegion sets region clients sign requests for, "us-east-1" by default.
+func (g *Gateway) SetRegion(region string) {
+	g.region = region
+}
+
+// bucket is configured root exposed to its owner.
+type bucket struct {
+	owner string
+	wfs   localstorage.WriteFS
+}
+
+// AddBucket exposes configured root to user named owner.
+func (g *Gateway) AddBucket(name string, owner string, wfs localstorage.WriteFS) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.buckets[name] = bucket{owner: owner, wfs: wfs}
+}
+
+// bucket returns file system of bucket accessible by u.
+func (g *Gateway) bucket(u authentication.User, name string) (localstorage.WriteFS, bool) {
+	if name == u.Name && u.Home != "" {
+		wfs, err := g.homes.Open(u)
+		return wfs, err == nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.buckets[name]
+	if !ok || b.owner != u.Name {
+		return nil, false
+	}
+	return b.wfs, true
+}
+
+func (g *Gateway) listBuckets(w http.ResponseWriter, r *http.Request, u authentication.User) {
+	res := listAllMyBucketsResult{Xmlns: xmlns, OwnerID: u.Name}
+
+	names := []string{}
+	g.mu.Lock()
+	for name, b := range g.buckets {
+		if b.owner == u.Name {
+			names = append(names, name)
+		}
+	}
+	g.mu.Unlock()
+	if u.Home != "" {
+		names = append(names, u.Name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		wfs, ok := g.bucket(u, name)
+		if !ok {
+			continue
+		}
+		entry := bucketEntry{Name: name}
+		if info, err := fs.Stat(wfs, "."); err == nil {
+			entry.CreationDate = formatTime(info.ModTime())
+		}
+		res.Buckets = append(res.Buckets, entry)
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := g.authenticate(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnknownAccessKey):
+			writeError(w, r, http.StatusForbidden, "InvalidAccessKeyId", err.Error())
+		case errors.Is(err, errSignatureMismatch):
+			writeError(w, r, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		default:
+			writeError(w, r, http.StatusForbidden, "AccessDenied", err.Error())
+		}
+		return
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucketName == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+			return
+		}
+		g.listBuckets(w, r, u)
+		return
+	}
+	wfs, ok := g.bucket(u, bucketName)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+
+	q := r.URL.Query()
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && q.Has("location"):
+			res := locationConstraint{Xmlns: xmlns}
+			if g.region != defaultRegion {
+				res.Region = g.region
+			}
+			writeXML(w, http.StatusOK, res)
+		case r.Method == http.MethodGet && q.Get("list-type") == "2":
+			listObjectsV2(w, r, bucketName, wfs)
+		case r.Method == http.MethodGet:
+			writeError(w, r, http.StatusNotImplemented, "NotImplemented",
+				"only ListObjectsV2 is supported")
+		default:
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+		}
+		return
+	}
+
+	name, ok := objectName(key)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid object key")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		g.createMultipartUpload(w, r, u, bucketName, key)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		g.uploadPart(w, r, u, bucketName, key)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		g.completeMultipartUpload(w, r, u, bucketName, key, wfs)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		g.abortMultipartUpload(w, r, u, bucketName, key)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		getObject(w, r, wfs, name)
+	case r.Method == http.MethodPut:
+		putObject(w, r, wfs, key)
+	case r.Method == http.MethodDelete:
+		deleteObject(w, r, wfs, name)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+	}
+}
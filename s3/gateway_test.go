@@ -0,0 +1,414 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// signer signs requests the way AWS clients do.
+type signer struct {
+	accessKey string
+	secret    string
+	region    string
+	service   string
+	headers   []string
+}
+
+func newSigner() signer {
+	return signer{
+		accessKey: "AKTEST",
+		secret:    "secret",
+		region:    defaultRegion,
+		service:   "s3",
+		headers:   []string{"host", "x-amz-content-sha256", "x-amz-date"},
+	}
+}
+
+func (sg signer) request(now time.Time, payloadHash string) signedRequest {
+	s := signedRequest{
+		accessKey:     sg.accessKey,
+		date:          now.Format("20060102"),
+		region:        sg.region,
+		service:       sg.service,
+		amzDate:       now.Format(amzDateFormat),
+		signedHeaders: sg.headers,
+		payloadHash:   payloadHash,
+	}
+	s.scope = strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+	return s
+}
+
+// sign adds Authorization header and returns seed signature.
+func (sg signer) sign(r *http.Request, payloadHash string) string {
+	s := sg.request(time.Now().UTC(), payloadHash)
+	r.Header.Set("X-Amz-Date", s.amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	sig := s.expectedSignature(r, sg.secret)
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.accessKey, s.scope, strings.Join(s.signedHeaders, ";"), sig))
+	return sig
+}
+
+// presign adds signature to query of request.
+func (sg signer) presign(r *http.Request, expires time.Duration) {
+	s := sg.request(time.Now().UTC(), unsignedPayload)
+	s.presigned = true
+	s.signedHeaders = []string{"host"}
+	q := r.URL.Query()
+	q.Set("X-Amz-Algorithm", sigAlgorithm)
+	q.Set("X-Amz-Credential", s.accessKey+"/"+s.scope)
+	q.Set("X-Amz-Date", s.amzDate)
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	r.URL.RawQuery = q.Encode()
+	q.Set("X-Amz-Signature", s.expectedSignature(r, sg.secret))
+	r.URL.RawQuery = q.Encode()
+}
+
+// chunked encodes chunks as aws-chunked body signed with seed signature.
+func (sg signer) chunked(r *http.Request, chunks ...string) {
+	size := 0
+	for _, c := range chunks {
+		size += len(c)
+	}
+	r.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(size))
+	prev := sg.sign(r, signedChunks)
+	s := sg.request(time.Now().UTC(), signedChunks)
+	s.amzDate = r.Header.Get("X-Amz-Date")
+	key := s.signingKey(sg.secret)
+	var body bytes.Buffer
+	for _, c := range append(chunks, "") {
+		sum := sha256.Sum256([]byte(c))
+		sig := hex.EncodeToString(hmacSHA256(key, strings.Join([]string{
+			"AWS4-HMAC-SHA256-PAYLOAD", s.amzDate, s.scope, prev, emptySHA256,
+			hex.EncodeToString(sum[:]),
+		}, "\n")))
+		fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(c), sig, c)
+		prev = sig
+	}
+	r.Body = io.NopCloser(&body)
+	r.ContentLength = int64(body.Len())
+}
+
+func payloadHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func serve(g *Gateway, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+func TestSignatures(t *testing.T) {
+	g := NewGateway(testKeys{}, nil, t.TempDir())
+	bucket := localstorage.NewMemFS()
+	g.AddBucket("data", "tester", bucket)
+
+	r := httptest.NewRequest(http.MethodPut, "/data/notes.txt", strings.NewReader("signed"))
+	newSigner().sign(r, payloadHash("signed"))
+	if w := serve(g, r); w.Code != http.StatusOK {
+		t.Error("signed request should be accepted: ", w.Code, w.Body.String())
+	}
+
+	// payload differs from signed hash
+	r = httptest.NewRequest(http.MethodPut, "/data/notes.txt", strings.NewReader("forged"))
+	newSigner().sign(r, payloadHash("signed"))
+	if w := serve(g, r); w.Code != http.StatusBadRequest {
+		t.Error("forged payload should be rejected: ", w.Code)
+	}
+	content, _ := fs.ReadFile(bucket, "notes.txt")
+	if string(content) != "signed" {
+		t.Error("rejected payload should not replace object: ", string(content))
+	}
+
+	for _, sg := range []signer{
+		{accessKey: "AKTEST", secret: "wrong", region: defaultRegion, service: "s3", headers: []string{"host"}},
+		{accessKey: "AKTEST", secret: "secret", region: "eu-west-1", service: "s3", headers: []string{"host"}},
+		{accessKey: "AKTEST", secret: "secret", region: defaultRegion, service: "ec2", headers: []string{"host"}},
+		{accessKey: "AKTEST", secret: "secret", region: defaultRegion, service: "s3", headers: []string{"x-amz-date"}},
+		{accessKey: "AKOTHER", secret: "secret", region: defaultRegion, service: "s3", headers: []string{"host"}},
+	} {
+		r = httptest.NewRequest(http.MethodGet, "/data/notes.txt", nil)
+		sg.sign(r, emptySHA256)
+		if w := serve(g, r); w.Code != http.StatusForbidden {
+			t.Error("request should be rejected: ", sg, w.Code)
+		}
+	}
+
+	// other region is accepted once it is configured
+	g.SetRegion("eu-west-1")
+	sg := newSigner()
+	sg.region = "eu-west-1"
+	r = httptest.NewRequest(http.MethodGet, "/data/notes.txt", nil)
+	sg.sign(r, emptySHA256)
+	if w := serve(g, r); w.Code != http.StatusOK || w.Body.String() != "signed" {
+		t.Error("request for configured region should be accepted: ", w.Code)
+	}
+	g.SetRegion(defaultRegion)
+
+	// presigned URL
+	r = httptest.NewRequest(http.MethodGet, "/data/notes.txt", nil)
+	newSigner().presign(r, time.Hour)
+	if w := serve(g, r); w.Code != http.StatusOK || w.Body.String() != "signed" {
+		t.Error("presigned URL should be accepted: ", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/data/notes.txt", nil)
+	newSigner().presign(r, time.Hour)
+	r.URL.Path = "/data/other.txt"
+	if w := serve(g, r); w.Code != http.StatusForbidden {
+		t.Error("presigned URL should not be used for other key: ", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/data/notes.txt", nil)
+	newSigner().presign(r, 8*24*time.Hour)
+	if w := serve(g, r); w.Code != http.StatusForbidden {
+		t.Error("presigned URL should not live longer than a week: ", w.Code)
+	}
+
+	// aws-chunked payload
+	r = httptest.NewRequest(http.MethodPut, "/data/chunked.txt", nil)
+	newSigner().chunked(r, "first ", "second")
+	if w := serve(g, r); w.Code != http.StatusOK {
+		t.Error("chunked payload should be accepted: ", w.Code, w.Body.String())
+	}
+	content, _ = fs.ReadFile(bucket, "chunked.txt")
+	if string(content) != "first second" {
+		t.Error("wrong chunked content: ", string(content))
+	}
+	r = httptest.NewRequest(http.MethodPut, "/data/chunked.txt", nil)
+	newSigner().chunked(r, "first ", "second")
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("second"), []byte("forged"), 1)))
+	if w := serve(g, r); w.Code != http.StatusBadRequest {
+		t.Error("forged chunk should be rejected: ", w.Code)
+	}
+	content, _ = fs.ReadFile(bucket, "chunked.txt")
+	if string(content) != "first second" {
+		t.Error("rejected chunks should not replace object: ", string(content))
+	}
+}
+
+func TestPutObject(t *testing.T) {
+	g := NewGateway(testKeys{}, nil, t.TempDir())
+	bucket := localstorage.NewMemFS()
+	g.AddBucket("data", "tester", bucket)
+
+	put := func(key string, body string, md5sum string) int {
+		r := httptest.NewRequest(http.MethodPut, "/data/"+key, strings.NewReader(body))
+		if md5sum != "" {
+			r.Header.Set("Content-MD5", md5sum)
+		}
+		newSigner().sign(r, unsignedPayload)
+		return serve(g, r).Code
+	}
+	digest := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}
+	if code := put("docs/plan.txt", "first", digest("first")); code != http.StatusOK {
+		t.Error("object should be stored: ", code)
+	}
+	if code := put("docs/plan.txt", "second", digest("other")); code != http.StatusBadRequest {
+		t.Error("wrong digest should be rejected: ", code)
+	}
+	content, _ := fs.ReadFile(bucket, "docs/plan.txt")
+	if string(content) != "first" {
+		t.Error("existing object should be kept: ", string(content))
+	}
+	entries, _ := fs.ReadDir(bucket, "docs")
+	if len(entries) != 1 {
+		t.Error("staged content should be removed: ", entries)
+	}
+	if code := put("docs/plan.txt", "second", ""); code != http.StatusOK {
+		t.Error("object should be replaced: ", code)
+	}
+	content, _ = fs.ReadFile(bucket, "docs/plan.txt")
+	if string(content) != "second" {
+		t.Error("wrong content: ", string(content))
+	}
+}
+
+type homeKeys struct{}
+
+func (homeKeys) LookupAccessKey(id string) (authentication.User, string, error) {
+	if id != "AKTEST" {
+		return authentication.User{}, "", errors.New("unknown key")
+	}
+	return authentication.User{Name: "tester", Home: "tester", Enabled: true}, "secret", nil
+}
+
+// testUsers has tester with quota of 10 bytes.
+func testUsers() authtest.Users {
+	u := authentication.User{Name: "tester", Home: "tester", Enabled: true}
+	u.Quota.MaxBytes = 10
+	return authtest.Users{"tester": u}
+}
+
+func TestMultipartQuota(t *testing.T) {
+	root := t.TempDir()
+	err := os.Mkdir(path.Join(root, "tester"), 0750)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	config := &localstorage.Config{}
+	homes := storage.NewHomes(localstorage.NewLocalFs(root, config), config, testUsers(), nil, nil)
+	g := NewGateway(homeKeys{}, homes, t.TempDir())
+
+	r := httptest.NewRequest(http.MethodPost, "/tester/big.bin?uploads", nil)
+	newSigner().sign(r, emptySHA256)
+	w := serve(g, r)
+	if w.Code != http.StatusOK {
+		t.Error(w.Code, w.Body.String())
+		return
+	}
+	_, rest, _ := strings.Cut(w.Body.String(), "<UploadId>")
+	id, _, _ := strings.Cut(rest, "</UploadId>")
+
+	part := func(n int, body string) int {
+		q := url.Values{"uploadId": {id}, "partNumber": {fmt.Sprint(n)}}
+		r := httptest.NewRequest(http.MethodPut, "/tester/big.bin?"+q.Encode(), strings.NewReader(body))
+		newSigner().sign(r, payloadHash(body))
+		return serve(g, r).Code
+	}
+	if code := part(1, "123456"); code != http.StatusOK {
+		t.Error("part should fit into quota: ", code)
+	}
+	if code := part(2, "123456"); code != http.StatusForbidden {
+		t.Error("staged parts should count toward quota: ", code)
+	}
+	if code := part(2, "1234"); code != http.StatusOK {
+		t.Error("part should fit into quota: ", code)
+	}
+
+	// configured buckets are not limited
+	g.AddBucket("data", "tester", localstorage.NewMemFS())
+	r = httptest.NewRequest(http.MethodPost, "/data/big.bin?uploads", nil)
+	newSigner().sign(r, emptySHA256)
+	w = serve(g, r)
+	_, rest, _ = strings.Cut(w.Body.String(), "<UploadId>")
+	id, _, _ = strings.Cut(rest, "</UploadId>")
+	q := url.Values{"uploadId": {id}, "partNumber": {"1"}}
+	r = httptest.NewRequest(http.MethodPut, "/data/big.bin?"+q.Encode(), strings.NewReader("0123456789abc"))
+	newSigner().sign(r, unsignedPayload)
+	if w = serve(g, r); w.Code != http.StatusOK {
+		t.Error("part of configured bucket should be stored: ", w.Code)
+	}
+}
+
+func TestObjectETag(t *testing.T) {
+	root := t.TempDir()
+	err := os.Mkdir(path.Join(root, "tester"), 0750)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	config := &localstorage.Config{}
+	homes := storage.NewHomes(localstorage.NewLocalFs(root, config), config, testUsers(), nil, nil)
+	g := NewGateway(homeKeys{}, homes, t.TempDir())
+
+	sum := md5.Sum([]byte("etag"))
+	want := `"` + hex.EncodeToString(sum[:]) + `"`
+	r := httptest.NewRequest(http.MethodPut, "/tester/notes.txt", strings.NewReader("etag"))
+	newSigner().sign(r, payloadHash("etag"))
+	w := serve(g, r)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != want {
+		t.Error("etag should be md5 of content: ", w.Code, w.Header().Get("ETag"))
+	}
+	r = httptest.NewRequest(http.MethodHead, "/tester/notes.txt", nil)
+	newSigner().sign(r, emptySHA256)
+	if w = serve(g, r); w.Header().Get("ETag") != want {
+		t.Error("etag should be kept: ", w.Header().Get("ETag"))
+	}
+	r = httptest.NewRequest(http.MethodGet, "/tester?list-type=2", nil)
+	newSigner().sign(r, emptySHA256)
+	if w = serve(g, r); !strings.Contains(w.Body.String(), "<ETag>&#34;"+hex.EncodeToString(sum[:])+"&#34;</ETag>") {
+		t.Error("listed etag should be md5 of content: ", w.Body.String())
+	}
+
+	// content changed other way gets another etag
+	err = os.WriteFile(path.Join(root, "tester/notes.txt"), []byte("changed"), 0640)
+	if err != nil {
+		t.Error(err)
+	}
+	r = httptest.NewRequest(http.MethodHead, "/tester/notes.txt", nil)
+	newSigner().sign(r, emptySHA256)
+	if w = serve(g, r); w.Header().Get("ETag") == want {
+		t.Error("etag of changed content should not be kept")
+	}
+
+	// multipart etag is md5 of md5s of parts
+	r = httptest.NewRequest(http.MethodPost, "/tester/parts.txt?uploads", nil)
+	newSigner().sign(r, emptySHA256)
+	w = serve(g, r)
+	_, rest, _ := strings.Cut(w.Body.String(), "<UploadId>")
+	id, _, _ := strings.Cut(rest, "</UploadId>")
+	var parts strings.Builder
+	sums := md5.New()
+	for n, body := range []string{"ab", "cd"} {
+		q := url.Values{"uploadId": {id}, "partNumber": {fmt.Sprint(n + 1)}}
+		r = httptest.NewRequest(http.MethodPut, "/tester/parts.txt?"+q.Encode(), strings.NewReader(body))
+		newSigner().sign(r, payloadHash(body))
+		w = serve(g, r)
+		fmt.Fprintf(&parts, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", n+1, w.Header().Get("ETag"))
+		sum := md5.Sum([]byte(body))
+		sums.Write(sum[:])
+	}
+	complete := "<CompleteMultipartUpload>" + parts.String() + "</CompleteMultipartUpload>"
+	r = httptest.NewRequest(http.MethodPost, "/tester/parts.txt?uploadId="+id, strings.NewReader(complete))
+	newSigner().sign(r, payloadHash(complete))
+	w = serve(g, r)
+	want = fmt.Sprintf(`"%x-2"`, sums.Sum(nil))
+	if !strings.Contains(w.Body.String(), fmt.Sprintf("<ETag>&#34;%x-2&#34;</ETag>", sums.Sum(nil))) {
+		t.Error("wrong etag of multipart object: ", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodHead, "/tester/parts.txt", nil)
+	newSigner().sign(r, emptySHA256)
+	if w = serve(g, r); w.Header().Get("ETag") != want {
+		t.Error("multipart etag should be kept: ", w.Header().Get("ETag"))
+	}
+}
+
+func TestBucketOwner(t *testing.T) {
+	g := NewGateway(testKeys{}, nil, t.TempDir())
+	g.AddBucket("data", "tester", localstorage.NewMemFS())
+	other := newSigner()
+	other.accessKey = "AKPEER"
+
+	r := httptest.NewRequest(http.MethodHead, "/data", nil)
+	other.sign(r, emptySHA256)
+	if w := serve(g, r); w.Code != http.StatusNotFound {
+		t.Error("bucket should not be accessible by others: ", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	other.sign(r, emptySHA256)
+	if w := serve(g, r); strings.Contains(w.Body.String(), "<Name>data</Name>") {
+		t.Error("bucket should not be listed for others: ", w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	newSigner().sign(r, emptySHA256)
+	if w := serve(g, r); !strings.Contains(w.Body.String(), "<Name>data</Name>") {
+		t.Error("bucket should be listed for owner: ", w.Body.String())
+	}
+}
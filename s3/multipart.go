@@ -0,0 +1,325 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+const (
+	multipartInfoFile = "info.json"
+	maxPartNumber     = 10000
+	// abandoned uploads are removed after
+	multipartTTL = 24 * time.Hour
+)
+
+// Parts are staged in gateway temp directory, they do not count
+// toward quota until upload is completed, but parts of home bucket
+// are accepted only while they would fit into it.
+type multipartUpload struct {
+	Owner   string    `json:"owner"`
+	Bucket  string    `json:"bucket"`
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+func (g *Gateway) uploadDir(id string) (string, error) {
+	// id is used as a directory name, so it should be strictly uuid
+	if _, err := uuid.Parse(id); err != nil {
+		return "", errors.New("invalid upload id")
+	}
+	return path.Join(g.tempDir, id), nil
+}
+
+func partFile(dir string, n int) string {
+	return path.Join(dir, strconv.Itoa(n)+".part")
+}
+
+// openUpload checks that upload was initiated by u for the same object.
+func (g *Gateway) openUpload(r *http.Request, u authentication.User, bucket string, key string) (string, error) {
+	dir, err := g.uploadDir(r.URL.Query().Get("uploadId"))
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path.Join(dir, multipartInfoFile))
+	if err != nil {
+		return "", errors.New("upload does not exist")
+	}
+	var info multipartUpload
+	err = json.Unmarshal(data, &info)
+	if err != nil || info.Owner != u.Name || info.Bucket != bucket || info.Key != key {
+		return "", errors.New("upload does not exist")
+	}
+	return dir, nil
+}
+
+// partSize returns size of part content, aws-chunked
+// bodies carry it in separate header.
+func partSize(r *http.Request) int64 {
+	if s := r.Header.Get("X-Amz-Decoded-Content-Length"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	return r.ContentLength
+}
+
+// stagedBytes returns size of parts staged by owner.
+func (g *Gateway) stagedBytes(owner string) int64 {
+	entries, err := os.ReadDir(g.tempDir)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, e := range entries {
+		dir := path.Join(g.tempDir, e.Name())
+		data, err := os.ReadFile(path.Join(dir, multipartInfoFile))
+		if err != nil {
+			continue
+		}
+		var info multipartUpload
+		if json.Unmarshal(data, &info) != nil || info.Owner != owner {
+			continue
+		}
+		parts, _ := os.ReadDir(dir)
+		for _, p := range parts {
+			if fi, err := p.Info(); err == nil && strings.HasSuffix(p.Name(), ".part") {
+				total += fi.Size()
+			}
+		}
+	}
+	return total
+}
+
+// reservePart checks that part of size fits into quota of home of u
+// along with parts already staged or being written by u, and holds
+// its size until release is called. Configured buckets have no quota.
+func (g *Gateway) reservePart(u authentication.User, bucket string, size int64) (func(), error) {
+	if bucket != u.Name || g.homes == nil {
+		return func() {}, nil
+	}
+	quota, err := g.homes.Quota(u)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if quota != nil {
+		maxBytes, _ := quota.Limits()
+		used, _ := quota.Usage()
+		if maxBytes > 0 && used+g.stagedBytes(u.Name)+g.staging[u.Name]+size > maxBytes {
+			return nil, localstorage.ErrQuotaExceeded
+		}
+	}
+	g.staging[u.Name] += size
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.staging[u.Name] -= size
+		if g.staging[u.Name] == 0 {
+			delete(g.staging, u.Name)
+		}
+	}, nil
+}
+
+// expireUploads removes uploads older than multipartTTL.
+func (g *Gateway) expireUploads() {
+	entries, err := os.ReadDir(g.tempDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < multipartTTL {
+			continue
+		}
+		_ = os.RemoveAll(path.Join(g.tempDir, e.Name()))
+	}
+}
+
+func (g *Gateway) createMultipartUpload(w http.ResponseWriter, r *http.Request,
+	u authentication.User, bucket string, key string) {
+
+	g.expireUploads()
+
+	id := uuid.NewString()
+	dir, _ := g.uploadDir(id)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	data, _ := json.Marshal(multipartUpload{
+		Owner:   u.Name,
+		Bucket:  bucket,
+		Key:     key,
+		Created: time.Now(),
+	})
+	err = os.WriteFile(path.Join(dir, multipartInfoFile), data, 0640)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		writeStorageError(w, r, err)
+		return
+	}
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    xmlns,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: id,
+	})
+}
+
+func (g *Gateway) uploadPart(w http.ResponseWriter, r *http.Request,
+	u authentication.User, bucket string, key string) {
+
+	dir, err := g.openUpload(r, u, bucket, key)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", err.Error())
+		return
+	}
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid part number")
+		return
+	}
+	size := partSize(r)
+	if size < 0 {
+		writeError(w, r, http.StatusLengthRequired, "MissingContentLength", "part size is required")
+		return
+	}
+	release, err := g.reservePart(u, bucket, size)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	defer release()
+
+	// written aside, so retried part does not clobber previous one
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	sum := md5.New()
+	written, err := io.Copy(io.MultiWriter(tmp, sum), io.LimitReader(r.Body, size+1))
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = errSizeMismatch
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), partFile(dir, n))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		if errors.Is(err, errSizeMismatch) {
+			writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+var errSizeMismatch = errors.New("content does not match its length")
+
+func (g *Gateway) completeMultipartUpload(w http.ResponseWriter, r *http.Request,
+	u authentication.User, bucket string, key string, wfs localstorage.WriteFS) {
+
+	dir, err := g.openUpload(r, u, bucket, key)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", err.Error())
+		return
+	}
+	var req completeMultipartUpload
+	err = xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Parts) == 0 {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "invalid part list")
+		return
+	}
+
+	// ETag of multipart object is MD5 of MD5s of its parts
+	// along with their count, as in AWS
+	sums := md5.New()
+	readers := make([]io.Reader, 0, len(req.Parts))
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			writeError(w, r, http.StatusBadRequest, "InvalidPartOrder", "parts should be in ascending order")
+			return
+		}
+		f, err := os.Open(partFile(dir, p.PartNumber))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart",
+				fmt.Sprintf("part %d was not uploaded", p.PartNumber))
+			return
+		}
+		defer f.Close()
+		sum := md5.New()
+		_, err = io.Copy(sum, f)
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		if hex.EncodeToString(sum.Sum(nil)) != strings.Trim(p.ETag, `"`) {
+			writeError(w, r, http.StatusBadRequest, "InvalidPart",
+				fmt.Sprintf("etag of part %d does not match", p.PartNumber))
+			return
+		}
+		sums.Write(sum.Sum(nil))
+		readers = append(readers, f)
+	}
+
+	name, _ := objectName(key)
+	tag := fmt.Sprintf("%x-%d", sums.Sum(nil), len(req.Parts))
+	tag, err = writeObject(wfs, name, io.MultiReader(readers...), nil, tag)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	_ = os.RemoveAll(dir)
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:  xmlns,
+		Bucket: bucket,
+		Key:    key,
+		ETag:   tag,
+	})
+}
+
+func (g *Gateway) abortMultipartUpload(w http.ResponseWriter, r *http.Request,
+	u authentication.User, bucket string, key string) {
+
+	dir, err := g.openUpload(r, u, bucket, key)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "NoSuchUpload", err.Error())
+		return
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,351 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// objectName converts key to file system name,
+// trailing slash denotes directory.
+func objectName(key string) (string, bool) {
+	name := strings.TrimSuffix(key, "/")
+	return name, fs.ValidPath(name) && name != "."
+}
+
+// etagMetadata is metadata key ETag of object written through
+// gateway is kept in, along with stamp of content it belongs to.
+const etagMetadata = "s3.etag"
+
+// stamp identifies content of file by its modification time and size.
+func stamp(info fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+}
+
+// etag returns ETag of object, hex MD5 of content as in AWS if it was
+// written through gateway and has not changed since. Otherwise,
+// e.g. if bucket keeps no metadata, it is derived from stamp.
+func etag(wfs localstorage.WriteFS, name string, info fs.FileInfo) string {
+	if mfs, ok := wfs.(localstorage.MetadataFS); ok {
+		metadata, err := mfs.Metadata(name)
+		tag, s, _ := strings.Cut(metadata[etagMetadata], " ")
+		if err == nil && tag != "" && s == stamp(info) {
+			return `"` + tag + `"`
+		}
+	}
+	return `"` + stamp(info) + `"`
+}
+
+// saveETag keeps tag of object written through gateway and returns it.
+func saveETag(wfs localstorage.WriteFS, name string, tag string) (string, error) {
+	info, err := fs.Stat(wfs, name)
+	if err != nil {
+		return "", err
+	}
+	if mfs, ok := wfs.(localstorage.MetadataFS); ok {
+		err = mfs.SetMetadata(name, map[string]string{etagMetadata: tag + " " + stamp(info)})
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return "", err
+		}
+	}
+	return etag(wfs, name, info), nil
+}
+
+func getObject(w http.ResponseWriter, r *http.Request, wfs localstorage.WriteFS, name string) {
+	f, err := wfs.Open(name)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "key is a directory")
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, "InternalError", "file is not seekable")
+		return
+	}
+	w.Header().Set("ETag", etag(wfs, name, info))
+	// handles Range and conditional headers
+	http.ServeContent(w, r, info.Name(), info.ModTime(), rs)
+}
+
+// writeObject stores content of src under name, existing object is
+// replaced only once content is complete. If digest is set, MD5 of
+// content must match it. It returns ETag of object, which is tag
+// if set, hex MD5 of content otherwise.
+func writeObject(wfs localstorage.WriteFS, name string, src io.Reader, digest []byte, tag string) (string, error) {
+	err := localstorage.MkdirAll(wfs, path.Dir(name), 0750)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.New()
+	err = localstorage.ReplaceFile(wfs, name, io.TeeReader(src, sum), func() error {
+		if digest != nil && !bytes.Equal(digest, sum.Sum(nil)) {
+			return errBadDigest
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if tag == "" {
+		tag = hex.EncodeToString(sum.Sum(nil))
+	}
+	return saveETag(wfs, name, tag)
+}
+
+var errBadDigest = errors.New("content-md5 does not match")
+
+func putObject(w http.ResponseWriter, r *http.Request, wfs localstorage.WriteFS, key string) {
+	name, _ := objectName(key)
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "copy is not supported")
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		// directory marker
+		err := localstorage.MkdirAll(wfs, name, 0750)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var digest []byte
+	if h := r.Header.Get("Content-MD5"); h != "" {
+		var err error
+		digest, err = base64.StdEncoding.DecodeString(h)
+		if err != nil || len(digest) != md5.Size {
+			writeError(w, r, http.StatusBadRequest, "InvalidDigest", "invalid content-md5")
+			return
+		}
+	}
+	tag, err := writeObject(wfs, name, r.Body, digest, "")
+	if errors.Is(err, errBadDigest) {
+		writeError(w, r, http.StatusBadRequest, "BadDigest", err.Error())
+		return
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", tag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteObject moves file into trash if supported,
+// directories are removed only if empty.
+func deleteObject(w http.ResponseWriter, r *http.Request, wfs localstorage.WriteFS, name string) {
+	info, err := fs.Stat(wfs, name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// deletion of missing key succeeds in S3
+	case err != nil:
+		writeStorageError(w, r, err)
+		return
+	case info.IsDir():
+		err = wfs.Remove(name)
+	default:
+		if tfs, ok := wfs.(localstorage.TrashFS); ok {
+			_, err = tfs.Trash(name)
+		} else {
+			err = wfs.Remove(name)
+		}
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const maxListKeys = 1000
+
+// listEntry is either object or common prefix.
+type listEntry struct {
+	key    string
+	info   fs.FileInfo
+	prefix bool
+}
+
+// collectKeys lists objects with prefix, empty directories
+// are listed as keys with trailing slash.
+func collectKeys(wfs localstorage.WriteFS, prefix string, delimiter string) ([]listEntry, error) {
+	base := "."
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		base = prefix[:i]
+	}
+	info, err := fs.Stat(wfs, base)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []listEntry
+	if delimiter == "/" {
+		// only one level is needed
+		dirEntries, err := fs.ReadDir(wfs, base)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range dirEntries {
+			key := path.Join(base, e.Name())
+			if e.IsDir() {
+				key += "/"
+			}
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			entries = append(entries, listEntry{key: key, info: info})
+		}
+		return entries, nil
+	}
+
+	err = fs.WalkDir(wfs, base, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == base {
+			return nil
+		}
+		key := name
+		if d.IsDir() {
+			children, err := fs.ReadDir(wfs, name)
+			if err != nil || len(children) > 0 {
+				return err
+			}
+			key += "/"
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, listEntry{key: key, info: info})
+		return nil
+	})
+	return entries, err
+}
+
+// groupPrefixes replaces keys containing delimiter after prefix
+// with their common prefix.
+func groupPrefixes(entries []listEntry, prefix string, delimiter string) []listEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	if delimiter == "" {
+		return entries
+	}
+	grouped := entries[:0]
+	for _, e := range entries {
+		i := strings.Index(e.key[len(prefix):], delimiter)
+		if i < 0 {
+			grouped = append(grouped, e)
+			continue
+		}
+		p := e.key[:len(prefix)+i+len(delimiter)]
+		last := len(grouped) - 1
+		if last >= 0 && grouped[last].prefix && grouped[last].key == p {
+			continue
+		}
+		grouped = append(grouped, listEntry{key: p, prefix: true})
+	}
+	return grouped
+}
+
+func listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string, wfs localstorage.WriteFS) {
+	q := r.URL.Query()
+	res := listBucketResult{
+		Xmlns:             xmlns,
+		Name:              bucket,
+		Prefix:            q.Get("prefix"),
+		Delimiter:         q.Get("delimiter"),
+		MaxKeys:           maxListKeys,
+		ContinuationToken: q.Get("continuation-token"),
+		StartAfter:        q.Get("start-after"),
+	}
+	if s := q.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		res.MaxKeys = min(n, maxListKeys)
+	}
+	after := res.StartAfter
+	if res.ContinuationToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(res.ContinuationToken)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = max(after, string(token))
+	}
+
+	entries, err := collectKeys(wfs, res.Prefix, res.Delimiter)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	entries = groupPrefixes(entries, res.Prefix, res.Delimiter)
+
+	for _, e := range entries {
+		if e.key <= after {
+			continue
+		}
+		if res.KeyCount == res.MaxKeys {
+			res.IsTruncated = true
+			break
+		}
+		res.KeyCount += 1
+		after = e.key
+		if e.prefix {
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: e.key})
+			continue
+		}
+		o := object{
+			Key:          e.key,
+			LastModified: formatTime(e.info.ModTime()),
+			StorageClass: "STANDARD",
+		}
+		if !e.info.IsDir() {
+			o.Size = e.info.Size()
+			o.ETag = etag(wfs, e.key, e.info)
+		}
+		res.Contents = append(res.Contents, o)
+	}
+	if res.IsTruncated {
+		res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(after))
+	}
+	writeXML(w, http.StatusOK, res)
+}
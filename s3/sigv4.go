@@ -0,0 +1,292 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+)
+
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	signedChunks    = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	unsignedChunks  = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	maxClockSkew    = 15 * time.Minute
+	maxPresignedTTL = 7 * 24 * time.Hour
+)
+
+var (
+	errNoCredentials     = errors.New("request is not signed")
+	errUnknownAccessKey  = errors.New("access key does not exist")
+	errSignatureMismatch = errors.New("signature does not match")
+	errPayloadMismatch   = errors.New("payload hash does not match")
+)
+
+// signedRequest holds parsed signature parameters.
+type signedRequest struct {
+	accessKey     string
+	scope         string // date/region/service/aws4_request
+	date          string // yyyymmdd
+	region        string
+	service       string
+	amzDate       string
+	signedHeaders []string
+	signature     string
+	payloadHash   string
+	presigned     bool
+}
+
+func parseCredential(credential string, s *signedRequest) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return errors.New("malformed credential")
+	}
+	s.accessKey = parts[0]
+	s.date, s.region, s.service = parts[1], parts[2], parts[3]
+	s.scope = strings.Join(parts[1:], "/")
+	return nil
+}
+
+func parseAuthorization(r *http.Request) (signedRequest, error) {
+	var s signedRequest
+	header := r.Header.Get("Authorization")
+	params, ok := strings.CutPrefix(header, sigAlgorithm+" ")
+	if !ok {
+		if header == "" {
+			return s, errNoCredentials
+		}
+		return s, errors.New("signature algorithm is not supported")
+	}
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch k {
+		case "Credential":
+			err := parseCredential(v, &s)
+			if err != nil {
+				return s, err
+			}
+		case "SignedHeaders":
+			s.signedHeaders = strings.Split(v, ";")
+		case "Signature":
+			s.signature = v
+		}
+	}
+	s.amzDate = r.Header.Get("X-Amz-Date")
+	s.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if s.payloadHash == "" {
+		return s, errors.New("x-amz-content-sha256 header is required")
+	}
+	if strings.HasPrefix(s.payloadHash, "STREAMING-") &&
+		s.payloadHash != signedChunks && s.payloadHash != unsignedChunks {
+		return s, errors.New("payload signature is not supported")
+	}
+	return s, checkDate(s.amzDate, maxClockSkew, maxClockSkew)
+}
+
+func parsePresigned(r *http.Request) (signedRequest, error) {
+	s := signedRequest{presigned: true, payloadHash: unsignedPayload}
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != sigAlgorithm {
+		return s, errors.New("signature algorithm is not supported")
+	}
+	err := parseCredential(q.Get("X-Amz-Credential"), &s)
+	if err != nil {
+		return s, err
+	}
+	s.amzDate = q.Get("X-Amz-Date")
+	s.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+	s.signature = q.Get("X-Amz-Signature")
+
+	expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignedTTL {
+		return s, errors.New("invalid expiration")
+	}
+	return s, checkDate(s.amzDate, maxClockSkew, time.Duration(expires)*time.Second)
+}
+
+// checkDate accepts dates at most ahead of now or behind of it.
+func checkDate(amzDate string, ahead time.Duration, behind time.Duration) error {
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return errors.New("invalid x-amz-date")
+	}
+	now := time.Now()
+	if t.After(now.Add(ahead)) || t.Before(now.Add(-behind)) {
+		return errors.New("request has expired")
+	}
+	return nil
+}
+
+// awsEncode escapes everything but unreserved characters.
+func awsEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(r *http.Request, presigned bool) string {
+	var pairs []string
+	for k, values := range r.URL.Query() {
+		if presigned && k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, awsEncode(k, false)+"="+awsEncode(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			var values []string
+			for _, v := range r.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name + ":" + value + "\n")
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func (s signedRequest) expectedSignature(r *http.Request, secret string) string {
+	canonical := strings.Join([]string{
+		r.Method,
+		awsEncode(r.URL.Path, true),
+		canonicalQuery(r, s.presigned),
+		canonicalHeaders(r, s.signedHeaders),
+		strings.Join(s.signedHeaders, ";"),
+		s.payloadHash,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		sigAlgorithm,
+		s.amzDate,
+		s.scope,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(s.signingKey(secret), stringToSign))
+}
+
+func (s signedRequest) signingKey(secret string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), s.date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// authenticate verifies SigV4 signature of request either
+// in Authorization header or in query of presigned URL.
+// Signed payload is verified while request body is read.
+func (g *Gateway) authenticate(r *http.Request) (authentication.User, error) {
+	var s signedRequest
+	var err error
+	if r.URL.Query().Has("X-Amz-Signature") {
+		s, err = parsePresigned(r)
+	} else {
+		s, err = parseAuthorization(r)
+	}
+	if err != nil {
+		return authentication.User{}, err
+	}
+	if !strings.HasPrefix(s.amzDate, s.date) {
+		return authentication.User{}, errors.New("credential date does not match x-amz-date")
+	}
+	if s.region != g.region || s.service != "s3" {
+		return authentication.User{}, errors.New("credential scope does not match region or service")
+	}
+	// signature of request sent to another host should not be accepted
+	if !slices.Contains(s.signedHeaders, "host") {
+		return authentication.User{}, errors.New("host header should be signed")
+	}
+
+	u, secret, err := g.keys.LookupAccessKey(s.accessKey)
+	if err != nil {
+		return authentication.User{}, errUnknownAccessKey
+	}
+	expected := s.expectedSignature(r, secret)
+	if !hmac.Equal([]byte(expected), []byte(s.signature)) {
+		return authentication.User{}, errSignatureMismatch
+	}
+
+	switch s.payloadHash {
+	case unsignedPayload:
+	case signedChunks:
+		r.Body = &chunkedReader{
+			r:          bufio.NewReader(r.Body),
+			body:       r.Body,
+			signingKey: s.signingKey(secret),
+			amzDate:    s.amzDate,
+			scope:      s.scope,
+			prevSig:    s.signature,
+		}
+	case unsignedChunks:
+		r.Body = &chunkedReader{r: bufio.NewReader(r.Body), body: r.Body}
+	default:
+		want, err := hex.DecodeString(s.payloadHash)
+		if err != nil {
+			return authentication.User{}, errPayloadMismatch
+		}
+		r.Body = &verifyingReader{r: r.Body, h: sha256.New(), want: want}
+	}
+	return u, nil
+}
+
+// verifyingReader fails at the end of body if its hash differs.
+type verifyingReader struct {
+	r    io.ReadCloser
+	h    hash.Hash
+	want []byte
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && !hmac.Equal(v.h.Sum(nil), v.want) {
+		return n, errPayloadMismatch
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.r.Close()
+}
@@ -0,0 +1,246 @@
+package sftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// handlers serve sftp requests from user's home.
+type handlers struct {
+	wfs localstorage.WriteFS
+}
+
+// fsName converts absolute sftp path to fs one,
+// sessions always start in home root.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// sftpError converts errors to ones sftp package translates
+// to status codes, it checks them without unwrapping.
+func sftpError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return os.ErrNotExist
+	case errors.Is(err, fs.ErrPermission):
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	return err
+}
+
+func (h *handlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	f, err := h.wfs.Open(fsName(r.Filepath))
+	if err != nil {
+		return nil, sftpError(err)
+	}
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		f.Close()
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+	return &readerAt{rs: rs, c: f}, nil
+}
+
+// Filewrite supports only whole file uploads, since Create truncates
+// existing file. Opens keeping content of existing file, e.g. resumed
+// uploads, are refused rather than losing it.
+func (h *handlers) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	flags := r.Pflags()
+	if !flags.Creat && !flags.Trunc {
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+	name := fsName(r.Filepath)
+	if _, err := fs.Stat(h.wfs, name); err == nil {
+		if flags.Excl {
+			return nil, os.ErrExist
+		}
+		if !flags.Trunc {
+			return nil, sftp.ErrSSHFxOpUnsupported
+		}
+	}
+	f, err := h.wfs.Create(name)
+	if err != nil {
+		return nil, sftpError(err)
+	}
+	if wa, ok := f.(io.WriterAt); ok {
+		return wa, nil
+	}
+	return &writerAt{w: f, pending: make(map[int64][]byte)}, nil
+}
+
+func (h *handlers) Filecmd(r *sftp.Request) error {
+	name := fsName(r.Filepath)
+	switch r.Method {
+	case "Setstat":
+		// attributes are managed by storage,
+		// but clients set them after upload and expect success
+		return nil
+	case "Rename":
+		return sftpError(h.wfs.Rename(name, fsName(r.Target)))
+	case "Mkdir":
+		return sftpError(h.wfs.Mkdir(name, 0750))
+	case "Rmdir":
+		entries, err := fs.ReadDir(h.wfs, name)
+		if err != nil {
+			return sftpError(err)
+		}
+		if len(entries) > 0 {
+			return errors.New("directory is not empty")
+		}
+		return sftpError(h.remove(name))
+	case "Remove":
+		return sftpError(h.remove(name))
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// remove moves entry into trash if supported.
+func (h *handlers) remove(name string) error {
+	if tfs, ok := h.wfs.(localstorage.TrashFS); ok {
+		_, err := tfs.Trash(name)
+		return err
+	}
+	return h.wfs.Remove(name)
+}
+
+func (h *handlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	name := fsName(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := fs.ReadDir(h.wfs, name)
+		if err != nil {
+			return nil, sftpError(err)
+		}
+		infos := make(listerAt, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				// removed meanwhile
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	case "Stat":
+		info, err := fs.Stat(h.wfs, name)
+		if err != nil {
+			return nil, sftpError(err)
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readerAt serializes reads of files opened without ReadAt.
+type readerAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+	c  io.Closer
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.rs.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *readerAt) Close() error {
+	return r.c.Close()
+}
+
+// maxPending limits bytes of chunks kept ahead of offset by writerAt,
+// clients pipeline far fewer requests.
+const maxPending = 16 << 20
+
+// writerAt writes files of backends supporting sequential writes
+// only, chunks arriving ahead of offset are kept until it reaches them.
+type writerAt struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	offset  int64
+	pending map[int64][]byte
+	size    int64 // of pending chunks
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if off < w.offset {
+		return 0, errors.New("file could be written sequentially only")
+	}
+	if off > w.offset {
+		size := w.size + int64(len(p)) - int64(len(w.pending[off]))
+		if size > maxPending {
+			return 0, errors.New("too many chunks ahead of offset")
+		}
+		w.pending[off] = append([]byte(nil), p...)
+		w.size = size
+		return len(p), nil
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	if err != nil {
+		return n, err
+	}
+	for {
+		next, ok := w.pending[w.offset]
+		if !ok {
+			return n, nil
+		}
+		delete(w.pending, w.offset)
+		w.size -= int64(len(next))
+		written, err := w.w.Write(next)
+		w.offset += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+func (w *writerAt) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.w.Close()
+	if err == nil && len(w.pending) > 0 {
+		err = errors.New("file was written with gaps")
+	}
+	return err
+}
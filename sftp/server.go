@@ -0,0 +1,136 @@
+package sftp
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+
+	"github.com/pkg/sftp"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/storage"
+	"golang.org/x/crypto/ssh"
+)
+
+// Users checks SSH credentials.
+// Implemented by authentication.Authenticator.
+type Users interface {
+	AuthenticateWithPassword(username string, password string) (string, error)
+	AuthorizePublicKey(username string, blob []byte) error
+	GetUser(username string) (authentication.User, error)
+}
+
+// extUser holds authenticated username in ssh.Permissions.
+const extUser = "cardia-user"
+
+// Server is an SSH server serving only SFTP subsystem,
+// every session is confined to user's home.
+type Server struct {
+	users  Users
+	homes  *storage.Homes
+	config *ssh.ServerConfig
+}
+
+func NewServer(hostKey ssh.Signer, users Users, homes *storage.Homes) *Server {
+	s := &Server{users: users, homes: homes}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback:  s.passwordCallback,
+		PublicKeyCallback: s.publicKeyCallback,
+	}
+	s.config.AddHostKey(hostKey)
+	return s
+}
+
+func (s *Server) passwordCallback(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	_, err := s.users.AuthenticateWithPassword(c.User(), string(password))
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Permissions{Extensions: map[string]string{extUser: c.User()}}, nil
+}
+
+// publicKeyCallback is called before signature is verified,
+// ssh package rejects connection if it does not match.
+func (s *Server) publicKeyCallback(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	err := s.users.AuthorizePublicKey(c.User(), key.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Permissions{Extensions: map[string]string{extUser: c.User()}}, nil
+}
+
+// Serve accepts connections until listener is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	u, err := s.users.GetUser(sconn.Permissions.Extensions[extUser])
+	if err != nil || !u.Enabled {
+		return
+	}
+	home, err := s.homes.Open(u)
+	if err != nil {
+		log.Printf("cannot open home of %s: %v", u.Name, err)
+		return
+	}
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.serveSession(channel, requests, &handlers{wfs: home})
+	}
+}
+
+func (s *Server) serveSession(channel ssh.Channel, requests <-chan *ssh.Request, h *handlers) {
+	defer channel.Close()
+
+	for req := range requests {
+		// payload is a string with subsystem name
+		ok := req.Type == "subsystem" &&
+			len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+		if !ok {
+			continue
+		}
+
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  h,
+			FilePut:  h,
+			FileCmd:  h,
+			FileList: h,
+		})
+		err := server.Serve()
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("sftp session failed: %v", err)
+		}
+		_ = server.Close()
+		return
+	}
+}
@@ -0,0 +1,197 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+	"golang.org/x/crypto/ssh"
+)
+
+// testUsers accept password "secret" and no keys.
+type testUsers struct {
+	authtest.Users
+}
+
+func (u testUsers) AuthenticateWithPassword(username string, password string) (string, error) {
+	if _, ok := u.Users[username]; !ok || password != "secret" {
+		return "", errors.New("wrong credentials")
+	}
+	return "token", nil
+}
+
+func (u testUsers) AuthorizePublicKey(username string, blob []byte) error {
+	return errors.New("unknown key")
+}
+
+// testServer serves homes of users under temporary root
+// and returns its address.
+func testServer(t *testing.T, users testUsers) string {
+	root := t.TempDir()
+	for _, u := range users.Users {
+		err := os.MkdirAll(path.Join(root, u.Home), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := &localstorage.Config{}
+	homes := storage.NewHomes(localstorage.NewLocalFs(root, config), config, users, nil, nil)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() { _ = NewServer(hostKey, users, homes).Serve(l) }()
+	return l.Addr().String()
+}
+
+func dial(addr string, username string, password string) (*sftp.Client, error) {
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func TestServer(t *testing.T) {
+	addr := testServer(t, testUsers{authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: false},
+	}})
+
+	if _, err := dial(addr, "alice", "wrong"); err == nil {
+		t.Error("wrong password should be rejected")
+	}
+	if _, err := dial(addr, "bob", "secret"); err == nil {
+		t.Error("disabled user should not get sftp session")
+	}
+
+	c, err := dial(addr, "alice", "secret")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+
+	f, err := c.Create("/notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("first draft"))
+	err = f.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// resumed upload would keep content, it is refused instead
+	if _, err = c.OpenFile("/notes.txt", os.O_WRONLY|os.O_CREATE); err == nil {
+		t.Error("open keeping content of existing file should be refused")
+	}
+	if _, err = c.OpenFile("/notes.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL); err == nil {
+		t.Error("exclusive open of existing file should fail")
+	}
+	r, err := c.Open("/notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	content, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(content) != "first draft" {
+		t.Error("refused open should not change file: ", string(content))
+	}
+
+	f, err = c.OpenFile("/notes.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("final"))
+	_ = f.Close()
+	info, err := c.Stat("/notes.txt")
+	if err != nil || info.Size() != 5 {
+		t.Error("truncating open should rewrite file: ", info, err)
+	}
+
+	// sessions are confined to home
+	entries, err := c.ReadDir("/../..")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		t.Error("wrong entries of home: ", entries, err)
+	}
+
+	err = c.Remove("/notes.txt")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Stat("/notes.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("removed file should not exist: ", err)
+	}
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+func TestWriterAt(t *testing.T) {
+	buf := &bufferCloser{}
+	w := &writerAt{w: buf, pending: make(map[int64][]byte)}
+	_, _ = w.WriteAt([]byte("world"), 6)
+	_, _ = w.WriteAt([]byte("hello "), 0)
+	if buf.String() != "hello world" || len(w.pending) != 0 || w.size != 0 {
+		t.Error("chunks should be written in order: ", buf.String())
+	}
+	if _, err := w.WriteAt([]byte("again"), 0); err == nil {
+		t.Error("written offset should not be rewritten")
+	}
+
+	// chunks ahead of offset are limited
+	chunk := make([]byte, maxPending/2)
+	if _, err := w.WriteAt(chunk, 100); err != nil {
+		t.Error(err)
+	}
+	if _, err := w.WriteAt(chunk, 100); err != nil {
+		t.Error("rewritten chunk should replace pending one: ", err)
+	}
+	if _, err := w.WriteAt(chunk, 100+int64(len(chunk))); err != nil {
+		t.Error(err)
+	}
+	if _, err := w.WriteAt([]byte("x"), 1<<30); err == nil {
+		t.Error("chunk beyond limit should be rejected")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("gaps should be reported")
+	}
+}
@@ -0,0 +1,99 @@
+package sharing
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/proto"
+	"github.com/shabunin/cardia/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Grants manages access of users to directories of each other.
+// Implemented by authentication.Authenticator.
+type Grants interface {
+	Grant(owner string, dir string, grantee string, access authentication.Access) (authentication.Grant, error)
+	RevokeGrant(owner string, id string) error
+	GrantsBy(owner string) ([]authentication.Grant, error)
+	GrantsFor(grantee string) ([]authentication.Grant, error)
+}
+
+func exportGrant(g authentication.Grant) *proto.Grant {
+	r := &proto.Grant{
+		Id:      g.ID,
+		Owner:   g.Owner,
+		Path:    g.Path,
+		Grantee: g.Grantee,
+		Created: g.Created.Unix(),
+	}
+	if g.Access == authentication.ReadWrite {
+		r.Access = proto.AccessE_READ_WRITE
+	}
+	return r
+}
+
+func (s *Server) GrantAccess(ctx context.Context, req *proto.GrantAccessReq) (*proto.GrantAccessRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if storage.IsShared(req.GetPath()) || storage.IsGroupSpace(req.GetPath()) {
+		return nil, status.Error(codes.InvalidArgument, "only own directories can be shared")
+	}
+	home, err := s.homes.Open(u)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	info, err := fs.Stat(home, req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if !info.IsDir() {
+		return nil, status.Error(codes.InvalidArgument, "only directories can be granted")
+	}
+
+	access := authentication.Read
+	if req.GetAccess() == proto.AccessE_READ_WRITE {
+		access = authentication.ReadWrite
+	}
+	g, err := s.grants.Grant(u.Name, req.GetPath(), req.GetGrantee(), access)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.GrantAccessRes{Grant: exportGrant(g)}, nil
+}
+
+func (s *Server) ListGrants(ctx context.Context, req *proto.ListGrantsReq) (*proto.ListGrantsRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var grants []authentication.Grant
+	if req.GetReceived() {
+		grants, err = s.grants.GrantsFor(u.Name)
+	} else {
+		grants, err = s.grants.GrantsBy(u.Name)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListGrantsRes{}
+	for _, g := range grants {
+		res.Grants = append(res.Grants, exportGrant(g))
+	}
+	return res, nil
+}
+
+func (s *Server) RevokeGrant(ctx context.Context, req *proto.RevokeGrantReq) (*proto.RevokeGrantRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.grants.RevokeGrant(u.Name, req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.RevokeGrantRes{}, nil
+}
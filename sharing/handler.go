@@ -0,0 +1,265 @@
+package sharing
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// Handler serves share links,
+// URL is prefix + "/" + token [+ "/" + name inside shared directory].
+// Password is accepted as Basic auth password with any username.
+type Handler struct {
+	prefix string
+	svc    *Service
+	homes  *storage.Homes
+	users  storage.Users
+}
+
+func NewHandler(prefix string, svc *Service, homes *storage.Homes, users storage.Users) *Handler {
+	return &Handler{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		svc:    svc,
+		homes:  homes,
+		users:  users,
+	}
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head><body>
+<h1>{{.Title}}</h1>
+{{if .Upload}}<form method="post" enctype="multipart/form-data">
+<input type="file" name="file" multiple> <input type="submit" value="Upload">
+</form>{{else}}<ul>
+{{range .Entries}}<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{end}}</ul>{{end}}
+</body></html>
+`))
+
+type listingEntry struct {
+	Name string
+	Href string
+}
+
+type listing struct {
+	Title   string
+	Upload  bool
+	Entries []listingEntry
+}
+
+// root opens home of share owner and returns shared directory,
+// file shares are served from parent directory with name of the file.
+// Names are resolved against home itself, so writes pass its checks.
+func (h *Handler) root(sh Share) (localstorage.WriteFS, string, string, error) {
+	u, err := h.users.GetUser(sh.Owner)
+	if err != nil || !u.Enabled {
+		return nil, "", "", ErrShareNotFound
+	}
+	home, err := h.homes.Open(u)
+	if err != nil {
+		return nil, "", "", err
+	}
+	info, err := fs.Stat(home, sh.Path)
+	if err != nil {
+		return nil, "", "", ErrShareNotFound
+	}
+	if !info.IsDir() {
+		return home, path.Dir(sh.Path), path.Base(sh.Path), nil
+	}
+	return home, sh.Path, "", nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, h.prefix)
+	token, rest, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+
+	_, password, _ := r.BasicAuth()
+	sh, err := h.svc.Resolve(token, password)
+	if errors.Is(err, ErrWrongPassword) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="cardia share"`)
+		http.Error(w, "password required", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+	wfs, dir, file, err := h.root(sh)
+	if errors.Is(err, ErrShareNotFound) {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+rest), "/")
+	if file != "" {
+		// nothing below shared file
+		if name != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name = file
+	}
+	if name == "" {
+		name = "."
+	}
+
+	switch {
+	case sh.Mode == UploadOnly && r.Method == http.MethodGet && name == ".":
+		h.render(w, listing{Title: path.Base(sh.Path), Upload: true})
+	case sh.Mode == UploadOnly && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		h.upload(w, r, wfs, dir, name)
+	case sh.Mode == ReadOnly && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		h.download(w, r, sh, wfs, dir, name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) render(w http.ResponseWriter, l listing) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = listingTemplate.Execute(w, l)
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request, sh Share,
+	wfs localstorage.WriteFS, dir string, name string) {
+
+	f, err := wfs.Open(path.Join(dir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		entries, err := fs.ReadDir(wfs, path.Join(dir, name))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		l := listing{Title: path.Join(path.Base(sh.Path), name)}
+		// names are escaped, so those with "?" or "#" are not cut
+		base := strings.TrimSuffix(r.URL.EscapedPath(), "/") + "/"
+		for _, e := range entries {
+			n, href := e.Name(), url.PathEscape(e.Name())
+			if e.IsDir() {
+				n += "/"
+				href += "/"
+			}
+			l.Entries = append(l.Entries, listingEntry{Name: n, Href: base + href})
+		}
+		h.render(w, l)
+		return
+	}
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file is not seekable", http.StatusInternalServerError)
+		return
+	}
+	// every request for content is counted, ranges included,
+	// otherwise the whole file could be taken by ranges
+	if r.Method == http.MethodGet {
+		err = h.svc.CountDownload(sh)
+		if errors.Is(err, ErrLimitReached) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), rs)
+}
+
+// upload stores files into shared directory, existing ones
+// are never replaced since uploader cannot see them.
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request,
+	wfs localstorage.WriteFS, dir string, name string) {
+
+	if r.Method == http.MethodPut {
+		if strings.Contains(name, "/") || name == "." {
+			http.Error(w, "only files in shared directory can be uploaded", http.StatusBadRequest)
+			return
+		}
+		status, err := storeUpload(wfs, path.Join(dir, name), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filename := path.Base(part.FileName())
+		if part.FormName() != "file" || filename == "." || filename == "/" {
+			continue
+		}
+		status, err := storeUpload(wfs, path.Join(dir, filename), part)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func storeUpload(wfs localstorage.WriteFS, name string, src io.Reader) (int, error) {
+	f, err := localstorage.CreateNew(wfs, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return http.StatusConflict, errors.New("file already exists")
+		}
+		if errors.Is(err, localstorage.ErrQuotaExceeded) {
+			return http.StatusInsufficientStorage, err
+		}
+		return http.StatusBadRequest, err
+	}
+	_, err = io.Copy(f, src)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = wfs.Remove(name)
+		if errors.Is(err, localstorage.ErrQuotaExceeded) {
+			return http.StatusInsufficientStorage, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
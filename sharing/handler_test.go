@@ -0,0 +1,330 @@
+package sharing
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"github.com/shabunin/cardia/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testEnv struct {
+	root   string
+	key    *rsa.PrivateKey
+	users  authtest.Users
+	grants *authtest.Grants
+	homes  *storage.Homes
+	svc    *Service
+}
+
+// newTestEnv prepares homes of alice, bob and disabled carol,
+// alice has docs/report.txt and empty inbox.
+func newTestEnv(t *testing.T) *testEnv {
+	root := t.TempDir()
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+		"carol": {Name: "carol", Home: "carol", Enabled: false},
+	}
+	for _, dir := range []string{"alice/docs", "alice/inbox", "bob", "carol"} {
+		err := os.MkdirAll(path.Join(root, dir), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(path.Join(root, "alice/docs/report.txt"), []byte("quarterly"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path.Join(root, "carol/notes.txt"), []byte("notes"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewService(path.Join(t.TempDir(), "shares.db"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := &authtest.Grants{Users: users}
+	config := &localstorage.Config{}
+	return &testEnv{
+		root:   root,
+		key:    key,
+		users:  users,
+		grants: grants,
+		homes:  storage.NewHomes(localstorage.NewLocalFs(root, config), config, users, grants, nil),
+		svc:    svc,
+	}
+}
+
+// identityToken signs token the way authentication service does.
+func (e *testEnv) identityToken(username string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims{
+		"user": username,
+		"role": "u",
+		"home": e.users[username].Home,
+		"aud":  authentication.IdentityAudience,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	ss, _ := token.SignedString(e.key)
+	return ss
+}
+
+func (e *testEnv) context(username string) context.Context {
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+e.identityToken(username)))
+}
+
+func (e *testEnv) link(t *testing.T, owner string, name string, opts Options) string {
+	sh, err := e.svc.Create(owner, name, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := e.svc.Token(sh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serve(h http.Handler, method string, target string, body string,
+	header map[string]string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestShareLinks(t *testing.T) {
+	e := newTestEnv(t)
+	h := NewHandler("/s", e.svc, e.homes, e.users)
+
+	// file share with download limit
+	token := e.link(t, "alice", "docs/report.txt", Options{MaxDownloads: 2})
+	w := serve(h, http.MethodGet, "/s/"+token, "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "quarterly" {
+		t.Error("wrong download: ", w.Code, w.Body.String())
+	}
+	w = serve(h, http.MethodGet, "/s/"+token+"/other.txt", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Error("nothing should be served below shared file: ", w.Code)
+	}
+	// ranges are counted as well
+	w = serve(h, http.MethodGet, "/s/"+token, "", map[string]string{"Range": "bytes=5-"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "erly" {
+		t.Error("wrong range: ", w.Code, w.Body.String())
+	}
+	w = serve(h, http.MethodGet, "/s/"+token, "", map[string]string{"Range": "bytes=0-4"})
+	if w.Code != http.StatusGone {
+		t.Error("download limit should be reached: ", w.Code)
+	}
+
+	// directory share with password
+	err := os.WriteFile(path.Join(e.root, "alice/docs/draft #2?.txt"), []byte("draft"), 0640)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	token = e.link(t, "alice", "docs", Options{Password: "open sesame"})
+	w = serve(h, http.MethodGet, "/s/"+token+"/", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Error("password should be required: ", w.Code)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/s/"+token+"/", nil)
+	r.SetBasicAuth("", "open sesame")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "report.txt") {
+		t.Error("wrong listing: ", rec.Code, rec.Body.String())
+	}
+	href := "/s/" + token + "/draft%20%232%3F.txt"
+	if !strings.Contains(rec.Body.String(), `href="`+href+`"`) {
+		t.Error("link should be escaped: ", rec.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, href, nil)
+	r.SetBasicAuth("", "open sesame")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || rec.Body.String() != "draft" {
+		t.Error("escaped link should be served: ", rec.Code, rec.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodPut, "/s/"+token+"/new.txt", strings.NewReader("data"))
+	r.SetBasicAuth("", "open sesame")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error("read-only share should not accept uploads: ", rec.Code)
+	}
+
+	// upload-only share
+	token = e.link(t, "alice", "inbox", Options{Mode: UploadOnly})
+	w = serve(h, http.MethodPut, "/s/"+token+"/scan.pdf", "scanned", nil)
+	if w.Code != http.StatusCreated {
+		t.Error("upload should be stored: ", w.Code, w.Body.String())
+	}
+	content, err := os.ReadFile(path.Join(e.root, "alice/inbox/scan.pdf"))
+	if err != nil || string(content) != "scanned" {
+		t.Error("uploaded file should be in shared directory: ", string(content), err)
+	}
+	w = serve(h, http.MethodPut, "/s/"+token+"/scan.pdf", "replaced", nil)
+	if w.Code != http.StatusConflict {
+		t.Error("existing file should not be replaced: ", w.Code)
+	}
+	w = serve(h, http.MethodPut, "/s/"+token+"/../docs/report.txt", "replaced", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Error("upload outside shared directory should be refused: ", w.Code)
+	}
+	w = serve(h, http.MethodGet, "/s/"+token+"/scan.pdf", "", nil)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Error("upload-only share should not be downloaded: ", w.Code)
+	}
+
+	// revoked share and share of disabled user
+	shares, _ := e.svc.List("alice")
+	for _, sh := range shares {
+		err = e.svc.Revoke("alice", sh.ID)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	w = serve(h, http.MethodPut, "/s/"+token+"/late.pdf", "late", nil)
+	if w.Code != http.StatusNotFound {
+		t.Error("revoked share should not be found: ", w.Code)
+	}
+	token = e.link(t, "carol", "notes.txt", Options{})
+	w = serve(h, http.MethodGet, "/s/"+token, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Error("share of disabled user should not be served: ", w.Code)
+	}
+}
+
+func TestTokenAudience(t *testing.T) {
+	e := newTestEnv(t)
+	verifier := authentication.NewVerifier(&e.key.PublicKey)
+
+	if _, err := verifier.VerifyToken(e.identityToken("alice")); err != nil {
+		t.Error(err)
+	}
+	if _, err := e.svc.Resolve(e.identityToken("alice"), ""); !errors.Is(err, ErrShareNotFound) {
+		t.Error("identity token should not be accepted as link: ", err)
+	}
+	token := e.link(t, "alice", "docs", Options{})
+	if _, err := e.svc.Resolve(token, ""); err != nil {
+		t.Error(err)
+	}
+	if u, err := verifier.VerifyToken(token); err == nil {
+		t.Error("link token should not be accepted as identity: ", u)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	e := newTestEnv(t)
+	verifier := authentication.NewVerifier(&e.key.PublicKey)
+	s := NewServer(e.svc, e.grants, e.homes, verifier, "http://localhost/s")
+	alice := e.context("alice")
+
+	_, err := s.GrantAccess(context.Background(), &proto.GrantAccessReq{Path: "docs", Grantee: "bob"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Error("caller should be authenticated: ", err)
+	}
+	_, err = s.GrantAccess(alice, &proto.GrantAccessReq{Path: "docs/report.txt", Grantee: "bob"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("file should not be granted: ", err)
+	}
+	_, err = s.GrantAccess(alice, &proto.GrantAccessReq{Path: "missing", Grantee: "bob"})
+	if status.Code(err) != codes.NotFound {
+		t.Error("missing directory should not be granted: ", err)
+	}
+	res, err := s.GrantAccess(alice, &proto.GrantAccessReq{Path: "docs", Grantee: "bob"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = s.GrantAccess(alice, &proto.GrantAccessReq{
+		Path:    "inbox",
+		Grantee: "bob",
+		Access:  proto.AccessE_READ_WRITE,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	list, err := s.ListGrants(e.context("bob"), &proto.ListGrantsReq{Received: true})
+	if err != nil || len(list.GetGrants()) != 2 {
+		t.Error("grants should be listed for grantee: ", list, err)
+	}
+	_, err = s.GrantAccess(e.context("bob"), &proto.GrantAccessReq{
+		Path:    storage.SharedMount + "/alice/docs",
+		Grantee: "carol",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("granted directory should not be granted further: ", err)
+	}
+
+	bob, err := e.homes.Open(e.users["bob"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	shared := storage.SharedMount + "/alice/"
+	content, err := fs.ReadFile(bob, shared+"docs/report.txt")
+	if err != nil || string(content) != "quarterly" {
+		t.Error("granted file should be read: ", string(content), err)
+	}
+	if _, err = bob.Create(shared + "docs/new.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("read-only grant should reject writes: ", err)
+	}
+	if err = bob.Remove(shared + "docs/report.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("read-only grant should reject removal: ", err)
+	}
+	f, err := bob.Create(shared + "inbox/reply.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = f.Write([]byte("thanks"))
+	_ = f.Close()
+	content, _ = os.ReadFile(path.Join(e.root, "alice/inbox/reply.txt"))
+	if string(content) != "thanks" {
+		t.Error("read-write grant should allow writes: ", string(content))
+	}
+	if err = bob.Remove(storage.SharedMount + "/alice/inbox"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("granted directory itself should not be removed: ", err)
+	}
+
+	// revocation takes effect immediately
+	_, err = s.RevokeGrant(e.context("bob"), &proto.RevokeGrantReq{Id: res.GetGrant().GetId()})
+	if status.Code(err) != codes.NotFound {
+		t.Error("grantee should not revoke grant: ", err)
+	}
+	_, err = s.RevokeGrant(alice, &proto.RevokeGrantReq{Id: res.GetGrant().GetId()})
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err = fs.Stat(bob, shared+"docs/report.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("revoked grant should not be accessible: ", err)
+	}
+}
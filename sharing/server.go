@@ -0,0 +1,146 @@
+package sharing
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/proto"
+	"github.com/shabunin/cardia/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server manages shares of caller's home over grpc.
+type Server struct {
+	svc      *Service
+	grants   Grants
+	homes    *storage.Homes
+	verifier *authentication.Verifier
+	baseURL  string // of Handler
+	proto.UnimplementedSharingServer
+}
+
+func NewServer(svc *Service, grants Grants, homes *storage.Homes,
+	verifier *authentication.Verifier, baseURL string) *Server {
+
+	return &Server{
+		svc:      svc,
+		grants:   grants,
+		homes:    homes,
+		verifier: verifier,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *Server) caller(ctx context.Context) (authentication.User, error) {
+	u, err := s.verifier.VerifyContext(ctx)
+	if err != nil {
+		return u, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return u, nil
+}
+
+func (s *Server) exportShare(sh Share) (*proto.Share, error) {
+	token, err := s.svc.Token(sh)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	r := &proto.Share{
+		Id:           sh.ID,
+		Path:         sh.Path,
+		HasPassword:  sh.HasPassword,
+		MaxDownloads: sh.MaxDownloads,
+		Downloads:    sh.Downloads,
+		Url:          s.baseURL + "/" + token,
+		Created:      sh.Created.Unix(),
+	}
+	if sh.Mode == UploadOnly {
+		r.Mode = proto.ShareModeE_UPLOAD_ONLY
+	}
+	if !sh.Expires.IsZero() {
+		r.Expires = sh.Expires.Unix()
+	}
+	return r, nil
+}
+
+func (s *Server) Create(ctx context.Context, req *proto.CreateShareReq) (*proto.CreateShareRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if storage.IsShared(req.GetPath()) || storage.IsGroupSpace(req.GetPath()) {
+		return nil, status.Error(codes.InvalidArgument, "only own files can be shared")
+	}
+	home, err := s.homes.Open(u)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	info, err := fs.Stat(home, req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	opts := Options{
+		Mode:         ReadOnly,
+		Password:     req.GetPassword(),
+		MaxDownloads: req.GetMaxDownloads(),
+	}
+	if req.GetMode() == proto.ShareModeE_UPLOAD_ONLY {
+		if !info.IsDir() {
+			return nil, status.Error(codes.InvalidArgument, "upload-only share should be a directory")
+		}
+		opts.Mode = UploadOnly
+	}
+	if req.GetExpires() > 0 {
+		opts.Expires = time.Unix(req.GetExpires(), 0)
+	}
+
+	sh, err := s.svc.Create(u.Name, req.GetPath(), opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	exported, err := s.exportShare(sh)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.CreateShareRes{Share: exported}, nil
+}
+
+func (s *Server) List(ctx context.Context, req *proto.ListSharesReq) (*proto.ListSharesRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := s.svc.List(u.Name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListSharesRes{}
+	for _, sh := range shares {
+		exported, err := s.exportShare(sh)
+		if err != nil {
+			return nil, err
+		}
+		res.Shares = append(res.Shares, exported)
+	}
+	return res, nil
+}
+
+func (s *Server) Revoke(ctx context.Context, req *proto.RevokeShareReq) (*proto.RevokeShareRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = s.svc.Revoke(u.Name, req.GetId())
+	if errors.Is(err, ErrShareNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.RevokeShareRes{}, nil
+}
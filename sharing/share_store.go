@@ -0,0 +1,138 @@
+package sharing
+
+import (
+	"github.com/pocketbase/dbx"
+)
+
+const (
+	tableShares        = "shares"
+	fieldShareID       = "id"
+	fieldShareOwner    = "owner"
+	fieldSharePath     = "path"
+	fieldShareMode     = "mode"
+	fieldSharePassword = "password"
+	fieldShareMaxDl    = "max_downloads"
+	fieldShareDl       = "downloads"
+	fieldShareCreated  = "created"
+	fieldShareExpires  = "expires"
+	indexShareOwner    = "shares_owner_idx"
+)
+
+type share struct {
+	ID           string `db:"id"`
+	Owner        string `db:"owner"`
+	Path         string `db:"path"`
+	Mode         string `db:"mode"`
+	Password     string `db:"password"`
+	MaxDownloads int64  `db:"max_downloads"`
+	Downloads    int64  `db:"downloads"`
+	Created      int64  `db:"created"`
+	Expires      int64  `db:"expires"`
+}
+
+const (
+	modeReadOnly   string = "r"
+	modeUploadOnly string = "u"
+)
+
+func initSharesTable(db *dbx.DB) error {
+	shares := make(map[string]string)
+	shares[fieldShareID] = "TEXT PRIMARY KEY NOT NULL"
+	shares[fieldShareOwner] = "TEXT NOT NULL"
+	shares[fieldSharePath] = "TEXT NOT NULL"
+	shares[fieldShareMode] = "TEXT DEFAULT 'r' NOT NULL"
+	shares[fieldSharePassword] = "TEXT DEFAULT '' NOT NULL"
+	shares[fieldShareMaxDl] = "INTEGER DEFAULT 0 NOT NULL"
+	shares[fieldShareDl] = "INTEGER DEFAULT 0 NOT NULL"
+	shares[fieldShareCreated] = "INTEGER DEFAULT 0 NOT NULL"
+	shares[fieldShareExpires] = "INTEGER DEFAULT 0 NOT NULL"
+
+	query := db.CreateTable(tableShares, shares)
+	_, err := query.Execute()
+	if err != nil {
+		return err
+	}
+
+	query = db.CreateIndex(tableShares, indexShareOwner, fieldShareOwner)
+	_, err = query.Execute()
+	return err
+}
+
+var shareFields = []string{
+	fieldShareID,
+	fieldShareOwner,
+	fieldSharePath,
+	fieldShareMode,
+	fieldSharePassword,
+	fieldShareMaxDl,
+	fieldShareDl,
+	fieldShareCreated,
+	fieldShareExpires,
+}
+
+func selectShare(db *dbx.DB, id string) (share, error) {
+	var s share
+	e := db.Select(shareFields...).
+		From(tableShares).
+		Where(dbx.HashExp{
+			fieldShareID: id,
+		}).
+		One(&s)
+	return s, e
+}
+
+func selectShares(db *dbx.DB, owner string) ([]share, error) {
+	var s []share
+	e := db.Select(shareFields...).
+		From(tableShares).
+		Where(dbx.HashExp{
+			fieldShareOwner: owner,
+		}).
+		OrderBy(fieldShareCreated).
+		All(&s)
+	return s, e
+}
+
+func insertShare(db *dbx.DB, s share) error {
+	_, e := db.Insert(tableShares,
+		dbx.Params{
+			fieldShareID:       s.ID,
+			fieldShareOwner:    s.Owner,
+			fieldSharePath:     s.Path,
+			fieldShareMode:     s.Mode,
+			fieldSharePassword: s.Password,
+			fieldShareMaxDl:    s.MaxDownloads,
+			fieldShareCreated:  s.Created,
+			fieldShareExpires:  s.Expires,
+		}).Execute()
+	return e
+}
+
+func deleteShare(db *dbx.DB, owner string, id string) (int64, error) {
+	res, e := db.Delete(tableShares,
+		dbx.HashExp{
+			fieldShareOwner: owner,
+			fieldShareID:    id,
+		}).Execute()
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
+
+// countDownload takes one download of share if limit allows.
+func countDownload(db *dbx.DB, id string) (bool, error) {
+	res, e := db.Update(tableShares,
+		dbx.Params{fieldShareDl: dbx.NewExp(fieldShareDl + " + 1")},
+		dbx.And(
+			dbx.HashExp{fieldShareID: id},
+			dbx.Or(
+				dbx.HashExp{fieldShareMaxDl: 0},
+				dbx.NewExp(fieldShareDl+" < "+fieldShareMaxDl)))).
+		Execute()
+	if e != nil {
+		return false, e
+	}
+	n, e := res.RowsAffected()
+	return n > 0, e
+}
@@ -0,0 +1,221 @@
+package sharing
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/shabunin/cardia/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Mode int
+
+const (
+	ReadOnly Mode = iota
+	UploadOnly
+)
+
+// Share is a link to file or directory in owner's home.
+// Zero Expires and MaxDownloads mean no limit.
+type Share struct {
+	ID           string
+	Owner        string
+	Path         string
+	Mode         Mode
+	HasPassword  bool
+	MaxDownloads int64
+	Downloads    int64
+	Created      time.Time
+	Expires      time.Time
+}
+
+func (s share) Export() Share {
+	r := Share{
+		ID:           s.ID,
+		Owner:        s.Owner,
+		Path:         s.Path,
+		Mode:         ReadOnly,
+		HasPassword:  s.Password != "",
+		MaxDownloads: s.MaxDownloads,
+		Downloads:    s.Downloads,
+		Created:      time.Unix(s.Created, 0),
+	}
+	if s.Mode == modeUploadOnly {
+		r.Mode = UploadOnly
+	}
+	if s.Expires > 0 {
+		r.Expires = time.Unix(s.Expires, 0)
+	}
+	return r
+}
+
+// Options of new share.
+type Options struct {
+	Mode         Mode
+	Password     string
+	MaxDownloads int64
+	Expires      time.Time
+}
+
+var (
+	ErrShareNotFound = errors.New("share not found or expired")
+	ErrWrongPassword = errors.New("wrong share password")
+	ErrLimitReached  = errors.New("download limit reached")
+)
+
+// shareAudience is audience of link tokens, so they are not
+// mistaken for identity tokens signed with the same key.
+const shareAudience = "cardia-share"
+
+// shareClaims are carried by link token.
+type shareClaims struct {
+	jwt.RegisteredClaims
+}
+
+// Service keeps shares and signs their link tokens
+// with the server key, so links cannot be forged.
+type Service struct {
+	db     *dbx.DB
+	signer *rsa.PrivateKey
+}
+
+func NewService(dbpath string, signer *rsa.PrivateKey) (*Service, error) {
+	if !filepath.IsAbs(dbpath) {
+		base, _ := os.Getwd()
+		dbpath = path.Join(base, dbpath)
+	}
+	db, err := database.ConnectDB(dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = initSharesTable(db)
+
+	return &Service{db: db, signer: signer}, nil
+}
+
+// Create stores new share, name should be already verified
+// to exist in owner's home.
+func (s *Service) Create(owner string, name string, opts Options) (Share, error) {
+	if opts.MaxDownloads < 0 {
+		return Share{}, errors.New("invalid download limit")
+	}
+	if !opts.Expires.IsZero() && opts.Expires.Before(time.Now()) {
+		return Share{}, errors.New("expiration is in the past")
+	}
+	sh := share{
+		ID:           uuid.NewString(),
+		Owner:        owner,
+		Path:         path.Clean(name),
+		Mode:         modeReadOnly,
+		MaxDownloads: opts.MaxDownloads,
+		Created:      time.Now().Unix(),
+	}
+	if opts.Mode == UploadOnly {
+		sh.Mode = modeUploadOnly
+	}
+	if !opts.Expires.IsZero() {
+		sh.Expires = opts.Expires.Unix()
+	}
+	if opts.Password != "" {
+		phash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return Share{}, err
+		}
+		sh.Password = string(phash)
+	}
+	err := insertShare(s.db, sh)
+	if err != nil {
+		return Share{}, err
+	}
+	return sh.Export(), nil
+}
+
+func (s *Service) List(owner string) ([]Share, error) {
+	shares, err := selectShares(s.db, owner)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]Share, 0, len(shares))
+	for _, sh := range shares {
+		r = append(r, sh.Export())
+	}
+	return r, nil
+}
+
+func (s *Service) Revoke(owner string, id string) error {
+	n, err := deleteShare(s.db, owner, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// Token returns signed link token of share.
+func (s *Service) Token(sh Share) (string, error) {
+	claims := shareClaims{}
+	claims.ID = sh.ID
+	claims.Audience = jwt.ClaimStrings{shareAudience}
+	if !sh.Expires.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(sh.Expires)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
+	return token.SignedString(s.signer)
+}
+
+// Resolve verifies token signature and password
+// and returns share if it is still valid.
+func (s *Service) Resolve(token string, password string) (Share, error) {
+	parsed, err := jwt.ParseWithClaims(token, &shareClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.New("signing method not supported")
+			}
+			return &s.signer.PublicKey, nil
+		}, jwt.WithAudience(shareAudience))
+	if err != nil {
+		return Share{}, fmt.Errorf("%w: %v", ErrShareNotFound, err)
+	}
+	claims, ok := parsed.Claims.(*shareClaims)
+	if !ok || !parsed.Valid {
+		return Share{}, ErrShareNotFound
+	}
+
+	sh, err := selectShare(s.db, claims.ID)
+	if err != nil {
+		// revoked
+		return Share{}, ErrShareNotFound
+	}
+	if sh.Expires > 0 && time.Now().Unix() > sh.Expires {
+		return Share{}, ErrShareNotFound
+	}
+	if sh.Password != "" &&
+		bcrypt.CompareHashAndPassword([]byte(sh.Password), []byte(password)) != nil {
+		return Share{}, ErrWrongPassword
+	}
+	return sh.Export(), nil
+}
+
+// CountDownload takes one download of share,
+// it fails if limit is reached.
+func (s *Service) CountDownload(sh Share) error {
+	ok, err := countDownload(s.db, sh.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLimitReached
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func importArchiveFormat(f proto.ArchiveFormatE) localstorage.ArchiveFormat {
+	if f == proto.ArchiveFormatE_TAR_GZ {
+		return localstorage.TarGz
+	}
+	return localstorage.Zip
+}
+
+// archiveError maps extraction errors to grpc status.
+func archiveError(err error) error {
+	if errors.Is(err, localstorage.ErrUnsafeArchive) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return storageError(err, codes.FailedPrecondition)
+}
+
+// ReadArchive streams directory as archive built on the fly.
+func (s *Server) ReadArchive(req *proto.ReadArchiveReq, srv proto.Storage_ReadArchiveServer) error {
+	_, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(home, req.GetPath())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if !info.IsDir() {
+		return status.Error(codes.InvalidArgument, "only directories could be archived")
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		err := localstorage.WriteArchive(pw, home, req.GetPath(), importArchiveFormat(req.GetFormat()))
+		_ = pw.CloseWithError(err)
+	}()
+	return sendFile(pr, srv.Send)
+}
+
+// ExtractArchive extracts uploaded archive into directory,
+// it is created if needed. Existing files are replaced.
+func (s *Server) ExtractArchive(ctx context.Context, req *proto.ExtractArchiveReq) (*proto.ExtractArchiveRes, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ufs, ok := home.(localstorage.UploadFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "uploads are not supported")
+	}
+	if !fs.ValidPath(req.GetPath()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid path")
+	}
+	f, err := ufs.OpenUpload(req.GetUploadId())
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer func() {
+		_ = f.Close()
+		_ = ufs.AbortUpload(req.GetUploadId())
+	}()
+
+	err = localstorage.MkdirAll(home, req.GetPath(), 0750)
+	if err != nil {
+		return nil, storageError(err, codes.FailedPrecondition)
+	}
+	var files int
+	if importArchiveFormat(req.GetFormat()) == localstorage.TarGz {
+		files, err = localstorage.ExtractTarGz(home, req.GetPath(), f)
+	} else {
+		var info fs.FileInfo
+		var ra io.ReaderAt
+		info, err = f.Stat()
+		if err == nil {
+			ra, err = localstorage.ReaderAt(f)
+		}
+		if err == nil {
+			files, err = localstorage.ExtractZip(home, req.GetPath(), ra, info.Size())
+		}
+	}
+	if err != nil {
+		return nil, archiveError(err)
+	}
+	return &proto.ExtractArchiveRes{Files: int64(files)}, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// ArchiveHandler serves directories of user homes as archives on GET
+// and extracts archives sent with POST or PUT. Format is chosen with
+// "format" query parameter, zip by default. Clients authenticate
+// with bearer token.
+type ArchiveHandler struct {
+	prefix   string
+	verifier *authentication.Verifier
+	homes    *Homes
+	tempDir  string // zip archives are spooled here, since they are read from the end
+}
+
+func NewArchiveHandler(prefix string, verifier *authentication.Verifier,
+	homes *Homes, tempDir string) *ArchiveHandler {
+
+	return &ArchiveHandler{
+		prefix:   prefix,
+		verifier: verifier,
+		homes:    homes,
+		tempDir:  tempDir,
+	}
+}
+
+func (h *ArchiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	u, err := h.verifier.VerifyToken(token)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	home, err := h.homes.Open(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, h.prefix), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	format, err := localstorage.ParseArchiveFormat(r.URL.Query().Get("format"))
+	if r.URL.Query().Get("format") == "" {
+		format, err = localstorage.Zip, nil
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.download(w, r, home, name, format)
+	case http.MethodPost, http.MethodPut:
+		h.extract(w, r, u, home, name, format)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ArchiveHandler) download(w http.ResponseWriter, r *http.Request,
+	home localstorage.WriteFS, name string, format localstorage.ArchiveFormat) {
+
+	info, err := fs.Stat(home, name)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !info.IsDir() {
+		http.Error(w, "only directories could be archived", http.StatusBadRequest)
+		return
+	}
+	base := path.Base(name)
+	if name == "." {
+		base = "home"
+	}
+	if format == localstorage.TarGz {
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+	}
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": base + format.Ext()}))
+	if r.Method == http.MethodHead {
+		return
+	}
+	err = localstorage.WriteArchive(w, home, name, format)
+	if err != nil {
+		// headers are sent already, so broken connection
+		// is the only way to tell client archive is incomplete
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (h *ArchiveHandler) extract(w http.ResponseWriter, r *http.Request, u authentication.User,
+	home localstorage.WriteFS, name string, format localstorage.ArchiveFormat) {
+
+	err := localstorage.MkdirAll(home, name, 0750)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	var files int
+	if format == localstorage.TarGz {
+		files, err = localstorage.ExtractTarGz(home, name, r.Body)
+	} else {
+		files, err = h.extractZip(home, name, h.limitBody(w, r, u))
+	}
+	switch {
+	case errors.Is(err, localstorage.ErrQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "files extracted: %d\n", files)
+	}
+}
+
+// limitBody limits zip archive spooled before it is extracted to space
+// left in user's home, content of larger one would not fit anyway.
+func (h *ArchiveHandler) limitBody(w http.ResponseWriter, r *http.Request, u authentication.User) io.Reader {
+	quota, err := h.homes.Quota(u)
+	if err != nil {
+		return r.Body
+	}
+	maxBytes, _ := quota.Limits()
+	if maxBytes <= 0 {
+		return r.Body
+	}
+	used, _ := quota.Usage()
+	return http.MaxBytesReader(w, r.Body, max(maxBytes-used, 0))
+}
+
+func (h *ArchiveHandler) extractZip(home localstorage.WriteFS, name string, body io.Reader) (int, error) {
+	f, err := os.CreateTemp(h.tempDir, "archive-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return 0, localstorage.ErrQuotaExceeded
+	}
+	if err != nil {
+		return 0, err
+	}
+	return localstorage.ExtractZip(home, name, f, size)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+func TestArchiveHandler(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{CacheSize: 1024 * 1024})
+	alice := e.users["alice"]
+	alice.Quota = authentication.Quota{MaxBytes: 1000}
+	e.users["alice"] = alice
+	h := NewArchiveHandler("/archive/", authentication.NewVerifier(&e.key.PublicKey), e.homes, t.TempDir())
+
+	extract := func(name string, archive []byte) int {
+		r := httptest.NewRequest(http.MethodPost, "/archive/"+name, bytes.NewReader(archive))
+		r.Header.Set("Authorization", "Bearer "+e.token("alice"))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	archive := func(names ...string) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, name := range names {
+			w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			_, _ = w.Write(bytes.Repeat([]byte{'x'}, 100))
+		}
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+
+	if code := extract("docs", archive("a.txt", "b.txt")); code != http.StatusCreated {
+		t.Error("archive should be extracted: ", code)
+	}
+	if _, err := os.Stat(path.Join(e.root, "alice/docs/b.txt")); err != nil {
+		t.Error(err)
+	}
+
+	// nothing is extracted from archive with unsafe entry
+	if code := extract("docs", archive("c.txt", "../evil.txt")); code != http.StatusBadRequest {
+		t.Error("unsafe archive should be rejected: ", code)
+	}
+	if _, err := os.Stat(path.Join(e.root, "alice/docs/c.txt")); err == nil {
+		t.Error("entries before unsafe one should not be extracted")
+	}
+
+	// archive larger than space left is not spooled
+	big := make([]string, 20)
+	for i := range big {
+		big[i] = string(rune('a'+i)) + ".bin"
+	}
+	if code := extract("big", archive(big...)); code != http.StatusInsufficientStorage {
+		t.Error("archive over quota should be rejected: ", code)
+	}
+	if _, err := os.Stat(path.Join(e.root, "alice/big/a.bin")); err == nil {
+		t.Error("archive over quota should not be extracted")
+	}
+	entries, _ := os.ReadDir(h.tempDir)
+	if len(entries) != 0 {
+		t.Error("spooled archive should be removed: ", entries)
+	}
+}
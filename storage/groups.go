@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// GroupsMount is a virtual directory in home root, spaces
+// of groups user is member of appear as GroupsMount/<group>.
+const GroupsMount = "Groups"
+
+// Groups provides group spaces and collects their usage reports.
+// Implemented by authentication.Authenticator.
+type Groups interface {
+	GetGroup(name string) (authentication.Group, error)
+	MemberGroups(username string) ([]string, error)
+	ReportGroupUsage(name string, usedBytes int64, usedFiles int64) error
+}
+
+// IsGroupSpace reports whether name is inside GroupsMount.
+func IsGroupSpace(name string) bool {
+	first, _, _ := strings.Cut(path.Clean(name), "/")
+	return first == GroupsMount
+}
+
+// memberGroups returns groups of user, from token claims if present.
+func (h *Homes) memberGroups(u authentication.User) ([]string, error) {
+	if u.Groups != nil {
+		return u.Groups, nil
+	}
+	return h.groups.MemberGroups(u.Name)
+}
+
+// provision creates group home under the storage root if needed.
+func (h *Homes) provision(dir string) error {
+	wfs, ok := h.root.(localstorage.WriteFS)
+	if !ok {
+		return errors.New("storage root is not writable")
+	}
+	return localstorage.MkdirAll(wfs, dir, 0750)
+}
+
+// openGroup returns space of group, provisioned on first use.
+func (h *Homes) openGroup(name string) (*home, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if opened, ok := h.spaces[name]; ok {
+		return opened, nil
+	}
+
+	g, err := h.groups.GetGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fs.ValidPath(g.Home) || g.Home == "." {
+		return nil, errors.New("group has invalid home")
+	}
+	err = h.provision(g.Home)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := h.newHome(g.Home, nil, h.config.Encryption, g.Quota.MaxBytes, g.Quota.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	h.spaces[name] = opened
+	return opened, nil
+}
+
+func (h *Homes) housekeepGroup(name string, opened *home) {
+	if !h.clean(name, opened) {
+		return
+	}
+	g, err := h.groups.GetGroup(name)
+	if err != nil {
+		// group is deleted, its files are kept until removed by admin,
+		// stores are closed on next round, since members could still
+		// use them
+		h.mu.Lock()
+		delete(h.spaces, name)
+		h.retired = append(h.retired, opened)
+		h.mu.Unlock()
+		return
+	}
+	opened.quota.SetLimits(g.Quota.MaxBytes, g.Quota.MaxFiles)
+	bytes, files := opened.quota.Usage()
+	err = h.groups.ReportGroupUsage(name, bytes, files)
+	if err != nil {
+		log.Printf("cannot report usage of group %s: %v", name, err)
+	}
+}
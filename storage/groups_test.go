@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// testGroups keeps groups and memberships in memory.
+type testGroups struct {
+	groups  map[string]authentication.Group
+	members map[string][]string // by username
+	lookups int
+}
+
+func (g *testGroups) GetGroup(name string) (authentication.Group, error) {
+	group, ok := g.groups[name]
+	if !ok {
+		return authentication.Group{}, errors.New("group not found")
+	}
+	return group, nil
+}
+
+func (g *testGroups) MemberGroups(username string) ([]string, error) {
+	g.lookups++
+	return g.members[username], nil
+}
+
+func (g *testGroups) ReportGroupUsage(name string, usedBytes int64, usedFiles int64) error {
+	return nil
+}
+
+func TestGroupSpaces(t *testing.T) {
+	root := t.TempDir()
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	for _, dir := range []string{"alice", "bob"} {
+		err := os.MkdirAll(path.Join(root, dir), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups := &testGroups{
+		groups: map[string]authentication.Group{
+			"team": {Name: "team", Home: "groups/team", Quota: authentication.Quota{MaxBytes: 8}},
+		},
+		members: map[string][]string{"alice": {"team"}},
+	}
+	config := &localstorage.Config{}
+	homes := NewHomes(localstorage.NewLocalFs(root, config), config, users, nil, groups)
+
+	// membership is looked up without group claims
+	alice, err := homes.Open(users["alice"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entries, err := fs.ReadDir(alice, GroupsMount)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "team" {
+		t.Error("wrong groups of member: ", entries, err)
+	}
+	if groups.lookups == 0 {
+		t.Error("membership should be looked up")
+	}
+	f, err := alice.Create(GroupsMount + "/team/plan.txt")
+	if err != nil {
+		t.Error("member should write to group space: ", err)
+		return
+	}
+	_, _ = f.Write([]byte("draft"))
+	_ = f.Close()
+	if _, err = os.Stat(path.Join(root, "groups/team/plan.txt")); err != nil {
+		t.Error("group space should be provisioned under root: ", err)
+	}
+	f, err = alice.Create(GroupsMount + "/team/big.txt")
+	if err == nil {
+		_, err = f.Write([]byte("over quota"))
+		if err == nil {
+			err = f.Close()
+		}
+	}
+	if !errors.Is(err, localstorage.ErrQuotaExceeded) {
+		t.Error("group quota should be applied: ", err)
+	}
+	if err = alice.Remove(GroupsMount + "/team"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("group space itself should not be removed: ", err)
+	}
+
+	bob, _ := homes.Open(users["bob"])
+	if _, err = fs.Stat(bob, GroupsMount+"/team/plan.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("space should not be visible to others: ", err)
+	}
+	if _, err = bob.Create(GroupsMount + "/team/evil.txt"); err == nil {
+		t.Error("others should not write to group space")
+	}
+
+	// claims of token are used without lookup
+	lookups := groups.lookups
+	claimed := users["bob"]
+	claimed.Groups = []string{"team"}
+	bob, _ = homes.Open(claimed)
+	content, err := fs.ReadFile(bob, GroupsMount+"/team/plan.txt")
+	if err != nil || string(content) != "draft" {
+		t.Error("member by claims should read group space: ", string(content), err)
+	}
+	if groups.lookups != lookups {
+		t.Error("membership should be taken from claims")
+	}
+	claimed = users["alice"]
+	claimed.Groups = []string{}
+	alice, _ = homes.Open(claimed)
+	if _, err = fs.Stat(alice, GroupsMount+"/team"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("claims without group should hide space: ", err)
+	}
+
+	// stores of deleted group are closed once members are done
+	w, err := bob.Create(GroupsMount + "/team/note.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	space := homes.spaces["team"]
+	delete(groups.groups, "team")
+	homes.housekeepGroup("team", space)
+	if _, ok := homes.spaces["team"]; ok || len(homes.retired) != 1 {
+		t.Error("space of deleted group should be retired")
+	}
+	_, _ = w.Write([]byte("late"))
+	if err = w.Close(); err != nil {
+		t.Error("write started before group was deleted should finish: ", err)
+	}
+	if err = space.locks.Check("note.txt", "bob", nil); err != nil {
+		t.Error("stores of retired space should be open until next round: ", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	homes.Housekeeping(ctx, 10*time.Millisecond)
+	if len(homes.retired) != 0 {
+		t.Error("retired space should be closed on next round")
+	}
+}
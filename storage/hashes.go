@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stat returns info of file or directory with its metadata and locks,
+// regular files come with stored checksums, so clients could skip
+// unchanged downloads, and with detected media type.
+func (s *Server) Stat(ctx context.Context, req *proto.StatReq) (*proto.StatRes, error) {
+	u, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Clean(req.GetPath())
+	info, err := fs.Stat(home, name)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	r := &proto.FileInfo{
+		Path:     name,
+		IsDir:    info.IsDir(),
+		Size:     info.Size(),
+		Modified: info.ModTime().Unix(),
+	}
+	if lfs, ok := home.(LockFS); ok {
+		locks, err := lfs.Locks(name)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		for _, l := range locks {
+			r.Locks = append(r.Locks, exportLock(l))
+		}
+	}
+	if mfs, ok := home.(localstorage.MetadataFS); ok {
+		r.Metadata, err = mfs.Metadata(name)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	if info.Mode().IsRegular() {
+		r.MimeType, err = localstorage.FileType(home, name)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if hfs, ok := home.(localstorage.HashFS); ok {
+			h, err := hfs.Hash(name)
+			if err == nil {
+				r.Sha256, r.Blake3 = h.SHA256, h.BLAKE3
+			} else if !errors.Is(err, errors.ErrUnsupported) {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+		if r.Sha256 == "" {
+			r.Sha256, err = s.fileHash(u, home, name, info)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+	}
+	return &proto.StatRes{Info: r}, nil
+}
+
+func exportScrubIssue(issue localstorage.ScrubIssue) *proto.ScrubIssue {
+	r := &proto.ScrubIssue{
+		Path:           issue.Name,
+		ExpectedSha256: issue.Expected.SHA256,
+		ActualSha256:   issue.Actual.SHA256,
+	}
+	switch issue.Problem {
+	case localstorage.ScrubCorrupted:
+		r.Problem = proto.ScrubProblemE_CORRUPTED
+	case localstorage.ScrubModified:
+		r.Problem = proto.ScrubProblemE_MODIFIED
+	case localstorage.ScrubMissing:
+		r.Problem = proto.ScrubProblemE_MISSING
+	}
+	return r
+}
+
+// Scrub re-hashes files inside path of caller's own home and streams
+// those not matching stored checksums. Corrupted files keep being
+// reported until restored, modified and missing ones are reported once.
+func (s *Server) Scrub(req *proto.ScrubReq, srv proto.Storage_ScrubServer) error {
+	_, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	hfs, ok := home.(localstorage.HashFS)
+	if !ok {
+		return status.Error(codes.Unimplemented, "checksums are not supported")
+	}
+	dir := req.GetPath()
+	if dir == "" {
+		dir = "."
+	}
+	_, err = hfs.Scrub(dir, func(issue localstorage.ScrubIssue) error {
+		return srv.Send(exportScrubIssue(issue))
+	})
+	if errors.Is(err, errors.ErrUnsupported) {
+		return status.Error(codes.Unimplemented, "checksums are not supported")
+	}
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// Users provides home quotas and collects usage reports.
+// Implemented by authentication.Authenticator.
+type Users interface {
+	GetUser(username string) (authentication.User, error)
+	ReportUsage(username string, usedBytes int64, usedFiles int64) error
+}
+
+type home struct {
+	fs       localstorage.WriteFS
+	stored   localstorage.WriteFS // files as they are stored, without layers
+	quota    *localstorage.Quota
+	changes  *localstorage.ChangeFeed
+	hashes   *localstorage.HashStore
+	metadata *localstorage.MetadataStore
+	locks    *localstorage.LockStore
+	keys     *localstorage.KeyRing     // nil if not encrypted
+	search   *localstorage.SearchIndex // nil if search is disabled
+}
+
+// close releases stores of home, files are kept.
+func (o *home) close() {
+	_ = o.changes.Close()
+	_ = o.hashes.Close()
+	_ = o.metadata.Close()
+	_ = o.locks.Close()
+	if o.search != nil {
+		_ = o.search.Close()
+	}
+}
+
+// reconcile recounts usage of home.
+func (o *home) reconcile() error {
+	if root := o.stored.Root(); root != "" {
+		return o.quota.Reconcile(root)
+	}
+	return o.quota.ReconcileFS(o.stored)
+}
+
+// changeLogSize is how many last changes of home are retained
+// for watchers resuming from a sequence number.
+const changeLogSize = 4096
+
+// Homes opens user home directories located under the storage root,
+// each one with its own quota. It is shared by all front ends,
+// so usage is accounted the same way whatever protocol is used.
+type Homes struct {
+	root   fs.FS
+	config *localstorage.Config
+	users  Users
+	grants Grants // nil disables SharedMount
+	groups Groups // nil disables GroupsMount
+
+	mu      sync.Mutex
+	homes   map[string]*home // by username
+	spaces  map[string]*home // by group name
+	retired []*home          // spaces of deleted groups to be closed
+}
+
+// NewHomes opens homes under root, e.g. opened by NewLocalFs or
+// OpenBackend. Homes on backends without local disk need
+// config.StateDir and have no trash, versions, uploads
+// or snapshots.
+func NewHomes(root fs.FS, config *localstorage.Config,
+	users Users, grants Grants, groups Groups) *Homes {
+
+	return &Homes{
+		root:   root,
+		config: config,
+		users:  users,
+		grants: grants,
+		groups: groups,
+		homes:  make(map[string]*home),
+		spaces: make(map[string]*home),
+	}
+}
+
+// open returns home of user, which is checked to be still
+// enabled, since tokens are valid for some time after that.
+func (h *Homes) open(u authentication.User) (*home, error) {
+	var stored authentication.User
+	if h.users != nil {
+		var err error
+		stored, err = h.users.GetUser(u.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !stored.Enabled {
+			return nil, errors.New("user is disabled")
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if opened, ok := h.homes[u.Name]; ok {
+		return opened, nil
+	}
+
+	if u.Home == "" {
+		return nil, errors.New("user has no home")
+	}
+	wrapper, err := h.keyWrapper(u.Name)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := h.newHome(u.Home, h.config.Base, wrapper, stored.Quota.MaxBytes, stored.Quota.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	h.homes[u.Name] = opened
+	return opened, nil
+}
+
+// keyWrapper returns wrapper of data keys of user's home,
+// nil if homes are not encrypted.
+func (h *Homes) keyWrapper(username string) (localstorage.KeyWrapper, error) {
+	if h.config.Encryption == nil || h.config.KeyWrappers == nil {
+		return h.config.Encryption, nil
+	}
+	wrapper, err := h.config.KeyWrappers.KeyWrapper(username)
+	if err != nil || wrapper != nil {
+		return wrapper, err
+	}
+	return h.config.Encryption, nil
+}
+
+// newHome opens dir under the storage root with its own quota.
+// Content is compressed and encrypted with its own data keys,
+// wrapped by wrapper, if it is enabled. Files of base, if not nil, are shown
+// in it until they are changed or removed. Stores of home
+// are kept next to its files on local disk, otherwise in
+// state dir, where they are keyed by dir. Usage of existing
+// files is counted in background.
+func (h *Homes) newHome(dir string, base fs.FS, wrapper localstorage.KeyWrapper,
+	maxBytes int64, maxFiles int64) (*home, error) {
+
+	rootFS, ok := h.root.(localstorage.WriteFS)
+	if !ok {
+		return nil, errors.New("storage root is not writable")
+	}
+	wfs, err := localstorage.Sub(rootFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	local := wfs.Root() != ""
+	stores := wfs.Root()
+	if !local {
+		if h.config.StateDir == "" {
+			return nil, errors.New("state dir is required for homes not on local disk")
+		}
+		if h.config.Encryption != nil || h.config.Compression != nil {
+			return nil, errors.New("encryption and compression need homes on local disk")
+		}
+		stores = filepath.Join(h.config.StateDir, filepath.FromSlash(dir))
+		err = os.MkdirAll(stores, 0750)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	quota := localstorage.NewQuota(maxBytes, maxFiles)
+	hashes, err := localstorage.NewHashStore(stores, h.config.BLAKE3)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := localstorage.NewMetadataStore(stores)
+	if err != nil {
+		_ = hashes.Close()
+		return nil, err
+	}
+	locks, err := localstorage.NewLockStore(stores)
+	if err != nil {
+		_ = hashes.Close()
+		_ = metadata.Close()
+		return nil, err
+	}
+
+	changes := localstorage.NewChangeFeed(stores, changeLogSize)
+	if base != nil {
+		changes.MapWhiteouts()
+	}
+	if local {
+		err = changes.Watch()
+		if err != nil {
+			// e.g. inotify limits are reached, changes made
+			// through storage are still reported
+			log.Printf("cannot watch %s: %v", dir, err)
+		}
+	}
+
+	config := *h.config
+	config.Quota = quota
+	config.Changes = changes
+	config.Hashes = hashes
+	config.Metadata = metadata
+	opened := &home{
+		quota:    quota,
+		changes:  changes,
+		hashes:   hashes,
+		metadata: metadata,
+		locks:    locks,
+	}
+	if local {
+		opened.stored = localstorage.NewLocalFs(stores, &config).(localstorage.WriteFS)
+	} else {
+		opened.stored = localstorage.NewAccountedFS(wfs, stores, &config)
+	}
+	opened.fs = opened.stored
+	if h.config.Encryption != nil {
+		opened.keys, err = localstorage.OpenKeyRing(stores, wrapper)
+		if err != nil {
+			opened.close()
+			return nil, err
+		}
+		opened.fs = localstorage.NewCryptFS(opened.fs, opened.keys)
+	}
+	if h.config.Compression != nil {
+		// content is compressed before it is encrypted
+		opened.fs, err = localstorage.NewCompressFS(opened.fs, h.config.Compression)
+		if err != nil {
+			opened.close()
+			return nil, err
+		}
+	}
+	if base != nil {
+		opened.fs = localstorage.NewOverlayFS(base, opened.fs)
+	}
+	if h.config.Search {
+		// plain text of encrypted homes is not kept in index
+		opened.search, err = localstorage.NewSearchIndex(stores, opened.fs, h.config.Encryption == nil)
+		if err != nil {
+			opened.close()
+			return nil, err
+		}
+		go opened.search.Follow(changes)
+	}
+	// large homes take a while to scan, usage is
+	// counted from changes until it is done
+	go func() {
+		err := opened.reconcile()
+		if err != nil {
+			log.Printf("cannot count usage of %s: %v", dir, err)
+		}
+	}()
+	return opened, nil
+}
+
+// Open returns file system of enabled user's home directory,
+// including SharedMount if grants are enabled
+// and GroupsMount if groups are. Names locked by
+// others could not be modified through it.
+func (h *Homes) Open(u authentication.User) (localstorage.WriteFS, error) {
+	opened, err := h.open(u)
+	if err != nil {
+		return nil, err
+	}
+	return &mountFS{Forward: localstorage.Forward{To: opened.fs}, home: opened.fs, locks: opened.locks, user: u, homes: h}, nil
+}
+
+// mounted reports whether name is inside one of enabled mounts.
+func (h *Homes) mounted(name string) bool {
+	return (h.grants != nil && IsShared(name)) ||
+		(h.groups != nil && IsGroupSpace(name))
+}
+
+// openUser returns own home of enabled user.
+func (h *Homes) openUser(username string) (*home, error) {
+	if h.users == nil {
+		return nil, errors.New("users are not available")
+	}
+	u, err := h.users.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return h.open(u)
+}
+
+// Changes returns change feed of user's own home.
+func (h *Homes) Changes(u authentication.User) (*localstorage.ChangeFeed, error) {
+	opened, err := h.open(u)
+	if err != nil {
+		return nil, err
+	}
+	return opened.changes, nil
+}
+
+// Previews returns preview cache shared by homes, nil if homes are
+// encrypted, since previews are kept there in plain.
+func (h *Homes) Previews() *localstorage.PreviewCache {
+	if h.config.Encryption != nil {
+		return nil
+	}
+	return h.config.Previews
+}
+
+func (h *Homes) Quota(u authentication.User) (*localstorage.Quota, error) {
+	opened, err := h.open(u)
+	if err != nil {
+		return nil, err
+	}
+	return opened.quota, nil
+}
+
+// Housekeeping periodically prunes versions, purges trash, takes snapshots,
+// expires locks, rotates data keys and recounts usage of opened homes and
+// group spaces, refreshes limits and reports usage to Users, prunes unused
+// previews until ctx is done.
+func (h *Homes) Housekeeping(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		h.mu.Lock()
+		homes := make(map[string]*home, len(h.homes))
+		for name, opened := range h.homes {
+			homes[name] = opened
+		}
+		spaces := make(map[string]*home, len(h.spaces))
+		for name, opened := range h.spaces {
+			spaces[name] = opened
+		}
+		// operations started before space was retired
+		// are finished by now
+		retired := h.retired
+		h.retired = nil
+		h.mu.Unlock()
+
+		for _, opened := range retired {
+			opened.close()
+		}
+
+		for name, opened := range homes {
+			h.housekeep(name, opened)
+		}
+		for name, opened := range spaces {
+			h.housekeepGroup(name, opened)
+		}
+		if h.config.Previews != nil && h.config.PreviewMaxAge > 0 {
+			_, err := h.config.Previews.Prune(h.config.PreviewMaxAge)
+			if err != nil {
+				log.Printf("cannot prune previews: %v", err)
+			}
+		}
+	}
+}
+
+func (h *Homes) housekeep(name string, opened *home) {
+	if !h.clean(name, opened) || h.users == nil {
+		return
+	}
+	u, err := h.users.GetUser(name)
+	if err == nil {
+		opened.quota.SetLimits(u.Quota.MaxBytes, u.Quota.MaxFiles)
+	}
+	bytes, files := opened.quota.Usage()
+	err = h.users.ReportUsage(name, bytes, files)
+	if err != nil {
+		log.Printf("cannot report usage of %s: %v", name, err)
+	}
+}
+
+// clean prunes versions, purges trash, expires abandoned uploads,
+// takes scheduled snapshots and prunes old ones, expires locks
+// and recounts usage,
+// reports whether usage is known.
+func (h *Homes) clean(name string, opened *home) bool {
+	if vfs, ok := opened.fs.(localstorage.VersionFS); ok {
+		_, err := vfs.PruneVersions()
+		if err != nil {
+			log.Printf("cannot prune versions of %s: %v", name, err)
+		}
+	}
+	if tfs, ok := opened.fs.(localstorage.TrashFS); ok {
+		_, err := tfs.PurgeTrash()
+		if err != nil {
+			log.Printf("cannot purge trash of %s: %v", name, err)
+		}
+	}
+	if ufs, ok := opened.fs.(localstorage.UploadFS); ok {
+		_, err := ufs.ExpireUploads()
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			log.Printf("cannot expire uploads of %s: %v", name, err)
+		}
+	}
+	if sfs, ok := opened.fs.(localstorage.SnapshotFS); ok {
+		_, err := sfs.ScheduleSnapshot()
+		if err != nil {
+			log.Printf("cannot take snapshot of %s: %v", name, err)
+		}
+		_, err = sfs.PruneSnapshots()
+		if err != nil {
+			log.Printf("cannot prune snapshots of %s: %v", name, err)
+		}
+	}
+	_, err := opened.locks.Expire()
+	if err != nil {
+		log.Printf("cannot expire locks of %s: %v", name, err)
+	}
+	if opened.keys != nil && h.config.KeyMaxAge > 0 {
+		_, err := opened.keys.RotateOlder(h.config.KeyMaxAge)
+		if err != nil {
+			log.Printf("cannot rotate key of %s: %v", name, err)
+		}
+	}
+	err = opened.reconcile()
+	if err != nil {
+		log.Printf("cannot reconcile usage of %s: %v", name, err)
+		return false
+	}
+	return true
+}
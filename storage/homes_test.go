@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+func TestHomesBackend(t *testing.T) {
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true, Quota: authentication.Quota{MaxBytes: 8}},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	root := localstorage.NewMemFS()
+	for _, dir := range []string{"alice", "bob"} {
+		err := root.Mkdir(dir, 0750)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	homes := NewHomes(root, &localstorage.Config{}, users, nil, nil)
+	if _, err := homes.Open(users["alice"]); err == nil {
+		t.Error("home without local disk should need state dir")
+	}
+
+	state := t.TempDir()
+	homes = NewHomes(root, &localstorage.Config{StateDir: state}, users, nil, nil)
+	alice, err := homes.Open(users["alice"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	f, err := alice.Create("notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "draft")
+	err = f.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if content, _ := fs.ReadFile(root, "alice/notes.txt"); string(content) != "draft" {
+		t.Error("file should be stored in home on backend: ", string(content))
+	}
+	f, _ = alice.Create("big.txt")
+	if _, err = io.WriteString(f, "over quota"); !errors.Is(err, localstorage.ErrQuotaExceeded) {
+		t.Error("quota should be applied: ", err)
+	}
+	_ = f.Close()
+
+	// locks and changes are kept in state dir
+	_, err = alice.(LockFS).Lock("notes.txt", localstorage.LockExclusive, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	other, _ := homes.Open(users["alice"])
+	if _, err = other.Create("notes.txt"); !errors.Is(err, localstorage.ErrLocked) {
+		t.Error("lock should be respected: ", err)
+	}
+	feed, err := homes.Changes(users["alice"])
+	if err != nil || feed.Seq() == 0 {
+		t.Error("changes should be reported: ", err)
+	}
+	if _, err = fs.Stat(root, "alice/.cardia"); err == nil {
+		t.Error("stores should not be kept on backend")
+	}
+
+	bob, err := homes.Open(users["bob"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entries, err := fs.ReadDir(bob, ".")
+	if err != nil || len(entries) != 0 {
+		t.Error("homes should be isolated: ", entries, err)
+	}
+}
+
+func TestHomesBlobBackend(t *testing.T) {
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	store := t.TempDir()
+	root, err := localstorage.NewBlobFs(store, &localstorage.Config{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, dir := range []string{"alice", "bob"} {
+		err = root.Mkdir(dir, 0750)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if sub, _ := localstorage.Sub(root, "alice"); sub.Root() != "" {
+		t.Error("sub of blob store should not be local directory: ", sub.Root())
+	}
+
+	state := t.TempDir()
+	homes := NewHomes(root, &localstorage.Config{StateDir: state}, users, nil, nil)
+	alice, err := homes.Open(users["alice"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	f, err := alice.Create("notes.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, _ = io.WriteString(f, "draft")
+	_ = f.Close()
+	if content, _ := fs.ReadFile(root, "alice/notes.txt"); string(content) != "draft" {
+		t.Error("file should be stored in home: ", string(content))
+	}
+	bob, _ := homes.Open(users["bob"])
+	if entries, err := fs.ReadDir(bob, "."); err != nil || len(entries) != 0 {
+		t.Error("homes should be isolated: ", entries, err)
+	}
+	if _, err = os.Stat(filepath.Join(store, ".cardia")); err == nil {
+		t.Error("stores should not be kept in blob store")
+	}
+	if _, err = os.Stat(filepath.Join(state, "alice", ".cardia")); err != nil {
+		t.Error("stores should be kept in state dir: ", err)
+	}
+}
+
+func TestHomesClean(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{CacheSize: 1024 * 1024, UploadTTL: time.Millisecond})
+	opened, err := e.homes.open(e.users["alice"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ufs, ok := opened.fs.(localstorage.UploadFS)
+	if !ok {
+		t.Error("home should support uploads")
+		return
+	}
+	session, err := ufs.CreateUpload(10, strings.Repeat("0", 64))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !e.homes.clean("alice", opened) {
+		t.Error("usage should be recounted")
+	}
+	if _, err = ufs.Upload(session.ID); err == nil {
+		t.Error("abandoned upload should be expired")
+	}
+}
+
+func TestHomesUsage(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{CacheSize: 1024 * 1024})
+	quota, err := e.homes.Quota(e.users["alice"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// existing files are counted in background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		bytes, files := quota.Usage()
+		if bytes == 9 && files == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Error("bytes = ", bytes, "; files = ", files)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// passwordKeys wraps keys of alice with key derived from password.
+type passwordKeys struct{}
+
+func (passwordKeys) KeyWrapper(username string) (localstorage.KeyWrapper, error) {
+	if username != "alice" {
+		return nil, nil
+	}
+	return localstorage.NewPasswordKey("secret", []byte("salt of alice"))
+}
+
+func TestHomesKeyWrappers(t *testing.T) {
+	master, _ := localstorage.NewMasterKey(bytes.Repeat([]byte{1}, 32))
+	e := newTestEnv(t, &localstorage.Config{
+		CacheSize:   1024 * 1024,
+		Encryption:  master,
+		KeyWrappers: passwordKeys{},
+	})
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := e.homes.open(e.users[name]); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	password, _ := passwordKeys{}.KeyWrapper("alice")
+	for _, c := range []struct {
+		dir     string
+		wrapper localstorage.KeyWrapper
+	}{{"alice", password}, {"bob", master}} {
+		if _, err := localstorage.OpenKeyRing(filepath.Join(e.root, c.dir), c.wrapper); err != nil {
+			t.Error("wrong wrapper of ", c.dir, ": ", err)
+		}
+	}
+	if _, err := localstorage.OpenKeyRing(filepath.Join(e.root, "alice"), master); err == nil {
+		t.Error("keys of alice should not be wrapped by server key")
+	}
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LockTokenMetadata is grpc metadata key lock tokens are passed in,
+// writes under lock are accepted only from callers presenting it.
+const LockTokenMetadata = "cardia-lock-token"
+
+// LockFS is home of user holding locks as that user. Locked names
+// could be modified only through sessions of holder presenting lock
+// token, so other users and other clients of the same user are
+// rejected. Front ends without locking, e.g. sftp, present no tokens.
+// Implemented by file systems returned by Homes.Open.
+type LockFS interface {
+	// Lock locks name, write access to it is required.
+	Lock(name string, kind localstorage.LockKind, lease time.Duration) (localstorage.Lock, error)
+	RenewLock(name string, token string, lease time.Duration) (localstorage.Lock, error)
+	Unlock(name string, token string) error
+	// Locks returns active locks of name and directories it is
+	// inside, tokens of locks not held by session are not disclosed.
+	Locks(name string) ([]localstorage.Lock, error)
+	// UseLockTokens presents tokens of locks session holds, locks
+	// taken through it are held by session as well.
+	UseLockTokens(tokens ...string)
+}
+
+func (m *mountFS) UseLockTokens(tokens ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = append(m.tokens, tokens...)
+}
+
+// heldTokens returns tokens presented by session.
+func (m *mountFS) heldTokens() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.tokens)
+}
+
+func (m *mountFS) Lock(name string, kind localstorage.LockKind, lease time.Duration) (localstorage.Lock, error) {
+	t, err := m.resolveWritable("lock", name)
+	if err != nil {
+		return localstorage.Lock{}, err
+	}
+	l, err := t.locks.Acquire(t.name, kind, m.user.Name, lease)
+	if err != nil {
+		return l, &fs.PathError{Op: "lock", Path: name, Err: err}
+	}
+	m.UseLockTokens(l.Token)
+	l.Name = name
+	return l, nil
+}
+
+// lockTarget resolves name of existing lock.
+func (m *mountFS) lockTarget(op string, name string) (target, error) {
+	t, err := m.resolve(op, name)
+	if err != nil {
+		return t, err
+	}
+	if t.isVirtual() || t.locks == nil {
+		return t, &fs.PathError{Op: op, Path: name, Err: localstorage.ErrLockNotHeld}
+	}
+	return t, nil
+}
+
+func (m *mountFS) RenewLock(name string, token string, lease time.Duration) (localstorage.Lock, error) {
+	t, err := m.lockTarget("renewlock", name)
+	if err != nil {
+		return localstorage.Lock{}, err
+	}
+	l, err := t.locks.Renew(t.name, token, m.user.Name, lease)
+	if err != nil {
+		return l, &fs.PathError{Op: "renewlock", Path: name, Err: err}
+	}
+	l.Name = name
+	return l, nil
+}
+
+func (m *mountFS) Unlock(name string, token string) error {
+	t, err := m.lockTarget("unlock", name)
+	if err != nil {
+		return err
+	}
+	err = t.locks.Release(t.name, token, m.user.Name)
+	if err != nil {
+		return &fs.PathError{Op: "unlock", Path: name, Err: err}
+	}
+	return nil
+}
+
+// mountRoot returns granted directory or group space name is inside.
+func mountRoot(name string) string {
+	parts := strings.SplitN(name, "/", 4)
+	if IsGroupSpace(name) {
+		return path.Join(parts[:min(2, len(parts))]...)
+	}
+	return path.Join(parts[:min(3, len(parts))]...)
+}
+
+func (m *mountFS) Locks(name string) ([]localstorage.Lock, error) {
+	t, err := m.resolve("locks", name)
+	if err != nil || t.isVirtual() {
+		return nil, err
+	}
+	locks, err := t.locks.Locks(t.name)
+	if err != nil {
+		return nil, err
+	}
+	held := m.heldTokens()
+	for i, l := range locks {
+		if l.Owner != m.user.Name || !slices.Contains(held, l.Token) {
+			locks[i].Token = ""
+		}
+		// names are mapped back to ones seen by user, locks above
+		// mounted directory are reported as locks of it
+		outer := name
+		if l.Name != t.name {
+			outer = strings.TrimSuffix(name, strings.TrimPrefix(t.name, l.Name))
+		}
+		if m.mounted(name) && len(outer) < len(mountRoot(name)) {
+			outer = mountRoot(name)
+		}
+		locks[i].Name = outer
+	}
+	return locks, nil
+}
+
+func exportLock(l localstorage.Lock) *proto.LockInfo {
+	r := &proto.LockInfo{
+		Path:    l.Name,
+		Owner:   l.Owner,
+		Token:   l.Token,
+		Created: l.Created.Unix(),
+		Expires: l.Expires.Unix(),
+	}
+	if l.Kind == localstorage.LockExclusive {
+		r.Kind = proto.LockKindE_EXCLUSIVE
+	}
+	return r
+}
+
+func lockError(err error) error {
+	switch {
+	case errors.Is(err, localstorage.ErrLocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, localstorage.ErrLockNotHeld):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, fs.ErrPermission):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// lockFS returns caller's home holding locks as caller.
+func (s *Server) lockFS(ctx context.Context) (LockFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lfs, ok := home.(LockFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "locks are not supported")
+	}
+	return lfs, nil
+}
+
+// Lock takes advisory lock of path for lease, 0 for default one.
+// Until it expires, path and names inside it could be modified only
+// by callers passing its token in LockTokenMetadata.
+func (s *Server) Lock(ctx context.Context, req *proto.LockReq) (*proto.LockRes, error) {
+	lfs, err := s.lockFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kind := localstorage.LockShared
+	if req.GetKind() == proto.LockKindE_EXCLUSIVE {
+		kind = localstorage.LockExclusive
+	}
+	l, err := lfs.Lock(req.GetPath(), kind, time.Duration(req.GetLeaseSeconds())*time.Second)
+	if err != nil {
+		return nil, lockError(err)
+	}
+	return &proto.LockRes{Lock: exportLock(l)}, nil
+}
+
+func (s *Server) RenewLock(ctx context.Context, req *proto.RenewLockReq) (*proto.RenewLockRes, error) {
+	lfs, err := s.lockFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l, err := lfs.RenewLock(req.GetPath(), req.GetToken(), time.Duration(req.GetLeaseSeconds())*time.Second)
+	if err != nil {
+		return nil, lockError(err)
+	}
+	return &proto.RenewLockRes{Lock: exportLock(l)}, nil
+}
+
+func (s *Server) Unlock(ctx context.Context, req *proto.UnlockReq) (*proto.UnlockRes, error) {
+	lfs, err := s.lockFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = lfs.Unlock(req.GetPath(), req.GetToken())
+	if err != nil {
+		return nil, lockError(err)
+	}
+	return &proto.UnlockRes{}, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLocks(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{})
+	alice := e.context("alice")
+
+	res, err := e.server.Lock(alice, &proto.LockReq{Path: "docs/report.txt", Kind: proto.LockKindE_EXCLUSIVE})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	token := res.GetLock().GetToken()
+
+	// other client of the same user does not hold lock
+	other, _ := e.homes.Open(e.users["alice"])
+	if _, err = other.Create("docs/report.txt"); !errors.Is(err, localstorage.ErrLocked) {
+		t.Error("write without token should be rejected: ", err)
+	}
+	locks, err := other.(LockFS).Locks("docs/report.txt")
+	if err != nil || len(locks) != 1 || locks[0].Token != "" {
+		t.Error("token should not be disclosed to other session: ", locks, err)
+	}
+	_, err = e.server.Remove(alice, &proto.RemoveReq{Path: "docs/report.txt"})
+	if status.Code(err) == codes.OK {
+		t.Error("rpc without token should be rejected")
+	}
+
+	// shared directory of bob is locked as well
+	bob, _ := e.homes.Open(e.users["bob"])
+	if _, err = bob.(LockFS).Lock(SharedMount+"/alice/docs/report.txt", localstorage.LockShared, 0); err == nil {
+		t.Error("locked file should not be locked by others")
+	}
+
+	// holder passes token
+	other.(LockFS).UseLockTokens(token)
+	f, err := other.Create("docs/report.txt")
+	if err != nil {
+		t.Error("write with token should be accepted: ", err)
+	} else {
+		_ = f.Close()
+	}
+	md, _ := metadata.FromIncomingContext(alice)
+	held := metadata.NewIncomingContext(alice, metadata.Join(md, metadata.Pairs(LockTokenMetadata, token)))
+	_, err = e.server.Remove(held, &proto.RemoveReq{Path: "docs/report.txt"})
+	if err != nil {
+		t.Error("rpc with token should be accepted: ", err)
+	}
+	_, err = e.server.Unlock(alice, &proto.UnlockReq{Path: "docs/report.txt", Token: token})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func metadataError(err error) error {
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		return status.Error(codes.Unimplemented, "metadata is not supported")
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, fs.ErrPermission):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// metadataFS returns caller's home if it keeps metadata.
+func (s *Server) metadataFS(ctx context.Context) (localstorage.MetadataFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mfs, ok := home.(localstorage.MetadataFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "metadata is not supported")
+	}
+	return mfs, nil
+}
+
+// SetMetadata sets user metadata keys of file and returns
+// all of its metadata.
+func (s *Server) SetMetadata(ctx context.Context, req *proto.SetMetadataReq) (*proto.SetMetadataRes, error) {
+	mfs, err := s.metadataFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = mfs.SetMetadata(req.GetPath(), req.GetMetadata())
+	if err != nil {
+		return nil, metadataError(err)
+	}
+	metadata, err := mfs.Metadata(req.GetPath())
+	if err != nil {
+		return nil, metadataError(err)
+	}
+	return &proto.SetMetadataRes{Metadata: metadata}, nil
+}
+
+func (s *Server) GetMetadata(ctx context.Context, req *proto.GetMetadataReq) (*proto.GetMetadataRes, error) {
+	mfs, err := s.metadataFS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := mfs.Metadata(req.GetPath())
+	if err != nil {
+		return nil, metadataError(err)
+	}
+	return &proto.GetMetadataRes{Metadata: metadata}, nil
+}
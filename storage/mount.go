@@ -0,0 +1,544 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+)
+
+// SharedMount is a virtual directory in home root, directories
+// other users granted access to appear as SharedMount/<owner>/<name>.
+const SharedMount = "Shared with me"
+
+// Grants provides directories shared with user.
+// Implemented by authentication.Authenticator.
+type Grants interface {
+	GrantsFor(grantee string) ([]authentication.Grant, error)
+}
+
+// IsShared reports whether name is inside SharedMount.
+func IsShared(name string) bool {
+	first, _, _ := strings.Cut(path.Clean(name), "/")
+	return first == SharedMount
+}
+
+// mountFS is user's home with SharedMount and GroupsMount.
+// Grants are checked on every operation, so revocation takes
+// effect immediately. Group membership is taken from token
+// claims when present, otherwise it is checked the same way.
+// Trash, uploads and snapshots are of own home, mounts are not
+// included.
+type mountFS struct {
+	localstorage.Forward // to home
+
+	home  localstorage.WriteFS
+	locks *localstorage.LockStore // of own home
+	user  authentication.User
+	homes *Homes
+
+	mu     sync.Mutex
+	tokens []string // of locks held by session
+}
+
+// target is a file system and name inside it name is resolved to.
+type target struct {
+	wfs       localstorage.WriteFS
+	locks     *localstorage.LockStore // of home wfs belongs to
+	name      string
+	writable  bool
+	mountRoot bool           // granted directory or group space itself
+	virtual   []fs.DirEntry  // entries of virtual directory
+	info      virtualDirInfo // of virtual directory
+}
+
+func (t target) isVirtual() bool {
+	return t.wfs == nil
+}
+
+type virtualDirInfo struct {
+	name string
+}
+
+func (i virtualDirInfo) Name() string       { return i.name }
+func (i virtualDirInfo) Size() int64        { return 0 }
+func (i virtualDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0550 }
+func (i virtualDirInfo) ModTime() time.Time { return time.Time{} }
+func (i virtualDirInfo) IsDir() bool        { return true }
+func (i virtualDirInfo) Sys() any           { return nil }
+
+// virtualDir is an opened virtual directory.
+type virtualDir struct {
+	info    virtualDirInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *virtualDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *virtualDir) Close() error               { return nil }
+
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *virtualDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		rest = rest[:min(n, len(rest))]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
+
+func virtualEntries(names []string) []fs.DirEntry {
+	sort.Strings(names)
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		entries = append(entries, fs.FileInfoToDirEntry(virtualDirInfo{name: n}))
+	}
+	return entries
+}
+
+// sharedEntries names grants by base name of directory,
+// unique within owner. Grants of whole home are skipped.
+func sharedEntries(grants []authentication.Grant) map[string]map[string]authentication.Grant {
+	r := make(map[string]map[string]authentication.Grant)
+	for _, g := range grants {
+		if path.Clean(g.Path) == "." {
+			continue
+		}
+		byName, ok := r[g.Owner]
+		if !ok {
+			byName = make(map[string]authentication.Grant)
+			r[g.Owner] = byName
+		}
+		base := path.Base(g.Path)
+		name := base
+		for i := 2; ; i++ {
+			if _, taken := byName[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s (%d)", base, i)
+		}
+		byName[name] = g
+	}
+	return r
+}
+
+func (m *mountFS) mounted(name string) bool {
+	return m.homes.mounted(name)
+}
+
+func (m *mountFS) resolve(op string, name string) (target, error) {
+	if !fs.ValidPath(name) {
+		return target{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if !m.mounted(name) {
+		return target{wfs: m.home, locks: m.locks, name: name, writable: true}, nil
+	}
+	if IsGroupSpace(name) {
+		return m.resolveGroup(op, name)
+	}
+	notExist := &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	grants, err := m.homes.grants.GrantsFor(m.user.Name)
+	if err != nil {
+		return target{}, err
+	}
+	entries := sharedEntries(grants)
+
+	_, rest, _ := strings.Cut(name, "/")
+	if rest == "" {
+		owners := make([]string, 0, len(entries))
+		for owner := range entries {
+			owners = append(owners, owner)
+		}
+		return target{
+			virtual: virtualEntries(owners),
+			info:    virtualDirInfo{name: SharedMount},
+		}, nil
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	byName, ok := entries[parts[0]]
+	if !ok {
+		return target{}, notExist
+	}
+	if len(parts) == 1 {
+		names := make([]string, 0, len(byName))
+		for n := range byName {
+			names = append(names, n)
+		}
+		return target{
+			virtual: virtualEntries(names),
+			info:    virtualDirInfo{name: parts[0]},
+		}, nil
+	}
+	g, ok := byName[parts[1]]
+	if !ok {
+		return target{}, notExist
+	}
+	owner, err := m.homes.openUser(g.Owner)
+	if err != nil {
+		return target{}, notExist
+	}
+	t := target{
+		wfs:       owner.fs,
+		locks:     owner.locks,
+		name:      g.Path,
+		writable:  g.Access == authentication.ReadWrite,
+		mountRoot: len(parts) == 2,
+	}
+	if len(parts) == 3 {
+		t.name = path.Join(g.Path, parts[2])
+	}
+	err = confine(t.wfs.Root(), g.Path, t.name)
+	if err != nil {
+		return target{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return t, nil
+}
+
+// confine checks that name inside home on local disk stays inside
+// granted dir once symlinks are resolved, links could point to the
+// rest of home. Other backends have no links.
+func confine(root string, dir string, name string) error {
+	if root == "" {
+		return nil
+	}
+	granted, err := resolveLinks(filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveLinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	if !localstorage.Inside(filepath.ToSlash(resolved), filepath.ToSlash(granted)) {
+		return errors.New("link points outside of granted directory")
+	}
+	return nil
+}
+
+// resolveLinks resolves symlinks of p, names which do not exist yet
+// are appended to their closest existing parent. Dangling links are
+// refused, since file created through them could be anywhere.
+func resolveLinks(p string) (string, error) {
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if _, err = os.Lstat(p); err == nil {
+			return "", fmt.Errorf("%s is a dangling link", p)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// resolveGroup resolves name inside GroupsMount, members
+// have full access to the space except its root.
+func (m *mountFS) resolveGroup(op string, name string) (target, error) {
+	groups, err := m.homes.memberGroups(m.user)
+	if err != nil {
+		return target{}, err
+	}
+	_, rest, _ := strings.Cut(name, "/")
+	if rest == "" {
+		return target{
+			virtual: virtualEntries(slices.Clone(groups)),
+			info:    virtualDirInfo{name: GroupsMount},
+		}, nil
+	}
+	group, inner, _ := strings.Cut(rest, "/")
+	if !slices.Contains(groups, group) {
+		return target{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	space, err := m.homes.openGroup(group)
+	if err != nil {
+		return target{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	t := target{wfs: space.fs, locks: space.locks, name: ".", writable: true, mountRoot: inner == ""}
+	if inner != "" {
+		t.name = inner
+	}
+	return t, nil
+}
+
+// resolveWritable resolves name which is going to be modified,
+// it should not be locked unless session holds the lock.
+func (m *mountFS) resolveWritable(op string, name string) (target, error) {
+	t, err := m.resolve(op, name)
+	if err != nil {
+		return t, err
+	}
+	if t.isVirtual() || !t.writable || t.mountRoot {
+		return t, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	err = t.locks.Check(t.name, m.user.Name, m.heldTokens())
+	if err != nil {
+		return t, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return t, nil
+}
+
+func (m *mountFS) Open(name string) (fs.File, error) {
+	t, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if t.isVirtual() {
+		return &virtualDir{info: t.info, entries: t.virtual}, nil
+	}
+	return t.wfs.Open(t.name)
+}
+
+func (m *mountFS) Stat(name string) (fs.FileInfo, error) {
+	t, err := m.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if t.isVirtual() {
+		return t.info, nil
+	}
+	return fs.Stat(t.wfs, t.name)
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	t, err := m.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if t.isVirtual() {
+		return t.virtual, nil
+	}
+	entries, err := fs.ReadDir(t.wfs, t.name)
+	if err != nil || name != "." {
+		return entries, err
+	}
+
+	// real directory with the same name is hidden by mount
+	r := entries[:0]
+	for _, e := range entries {
+		if !m.mounted(e.Name()) {
+			r = append(r, e)
+		}
+	}
+	if m.homes.grants != nil {
+		grants, err := m.homes.grants.GrantsFor(m.user.Name)
+		if err == nil && len(grants) > 0 {
+			r = append(r, fs.FileInfoToDirEntry(virtualDirInfo{name: SharedMount}))
+		}
+	}
+	if m.homes.groups != nil {
+		groups, err := m.homes.memberGroups(m.user)
+		if err == nil && len(groups) > 0 {
+			r = append(r, fs.FileInfoToDirEntry(virtualDirInfo{name: GroupsMount}))
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Name() < r[j].Name() })
+	return r, nil
+}
+
+func (m *mountFS) Create(name string) (localstorage.FileWriter, error) {
+	t, err := m.resolveWritable("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return t.wfs.Create(t.name)
+}
+
+func (m *mountFS) CreateNew(name string) (localstorage.FileWriter, error) {
+	t, err := m.resolveWritable("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return localstorage.CreateNew(t.wfs, t.name)
+}
+
+func (m *mountFS) Mkdir(name string, perm fs.FileMode) error {
+	t, err := m.resolveWritable("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return t.wfs.Mkdir(t.name, perm)
+}
+
+func (m *mountFS) Remove(name string) error {
+	t, err := m.resolveWritable("remove", name)
+	if err != nil {
+		return err
+	}
+	return t.wfs.Remove(t.name)
+}
+
+func (m *mountFS) Rename(oldname string, newname string) error {
+	from, err := m.resolveWritable("rename", oldname)
+	if err != nil {
+		return err
+	}
+	to, err := m.resolveWritable("rename", newname)
+	if err != nil {
+		return err
+	}
+	if from.wfs != to.wfs {
+		return &fs.PathError{Op: "rename", Path: newname,
+			Err: errors.New("cannot move between homes")}
+	}
+	return from.wfs.Rename(from.name, to.name)
+}
+
+func (m *mountFS) Root() string {
+	return m.home.Root()
+}
+
+// Sub is supported only within own home,
+// since grants could not be checked otherwise.
+func (m *mountFS) Sub(dir string) (fs.FS, error) {
+	if m.mounted(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrPermission}
+	}
+	return fs.Sub(m.home, dir)
+}
+
+func (m *mountFS) Trash(name string) (localstorage.TrashItem, error) {
+	t, err := m.resolveWritable("trash", name)
+	if err != nil {
+		return localstorage.TrashItem{}, err
+	}
+	tfs, ok := t.wfs.(localstorage.TrashFS)
+	if !ok {
+		return localstorage.TrashItem{}, errors.ErrUnsupported
+	}
+	return tfs.Trash(t.name)
+}
+
+func (m *mountFS) versions(op string, name string, write bool) (localstorage.VersionFS, string, error) {
+	var t target
+	var err error
+	if write {
+		t, err = m.resolveWritable(op, name)
+	} else {
+		t, err = m.resolve(op, name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	vfs, ok := t.wfs.(localstorage.VersionFS)
+	if t.isVirtual() || !ok {
+		return nil, "", errors.ErrUnsupported
+	}
+	return vfs, t.name, nil
+}
+
+func (m *mountFS) Versions(name string) ([]localstorage.Version, error) {
+	vfs, n, err := m.versions("versions", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return vfs.Versions(n)
+}
+
+func (m *mountFS) OpenVersion(name string, id string) (fs.File, error) {
+	vfs, n, err := m.versions("open", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return vfs.OpenVersion(n, id)
+}
+
+func (m *mountFS) RestoreVersion(name string, id string) error {
+	vfs, n, err := m.versions("restore", name, true)
+	if err != nil {
+		return err
+	}
+	return vfs.RestoreVersion(n, id)
+}
+
+func (m *mountFS) RestoreSnapshot(id string, name string) (string, error) {
+	if m.mounted(name) {
+		return "", &fs.PathError{Op: "restore", Path: name, Err: fs.ErrPermission}
+	}
+	return m.Forward.RestoreSnapshot(id, name)
+}
+
+func (m *mountFS) CommitUpload(id string, name string) error {
+	if m.mounted(name) {
+		return &fs.PathError{Op: "commit", Path: name,
+			Err: errors.New("uploads cannot be committed into shared directory")}
+	}
+	err := m.locks.Check(name, m.user.Name, m.heldTokens())
+	if err != nil {
+		return &fs.PathError{Op: "commit", Path: name, Err: err}
+	}
+	return m.Forward.CommitUpload(id, name)
+}
+
+func (m *mountFS) Hash(name string) (localstorage.FileHash, error) {
+	t, err := m.resolve("hash", name)
+	if err != nil {
+		return localstorage.FileHash{}, err
+	}
+	hfs, ok := t.wfs.(localstorage.HashFS)
+	if t.isVirtual() || !ok {
+		return localstorage.FileHash{}, errors.ErrUnsupported
+	}
+	return hfs.Hash(t.name)
+}
+
+// Scrub checks own home only, mounted directories are
+// scrubbed by their owners.
+func (m *mountFS) Scrub(dir string, report func(localstorage.ScrubIssue) error) (int, error) {
+	if m.mounted(dir) {
+		return 0, &fs.PathError{Op: "scrub", Path: dir,
+			Err: errors.New("mounted directories cannot be scrubbed")}
+	}
+	return m.Forward.Scrub(dir, report)
+}
+
+func (m *mountFS) Metadata(name string) (map[string]string, error) {
+	t, err := m.resolve("metadata", name)
+	if err != nil {
+		return nil, err
+	}
+	mfs, ok := t.wfs.(localstorage.MetadataFS)
+	if t.isVirtual() || !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return mfs.Metadata(t.name)
+}
+
+// SetMetadata needs write access, metadata of mounted
+// directories themselves is set by their owners.
+func (m *mountFS) SetMetadata(name string, metadata map[string]string) error {
+	t, err := m.resolveWritable("setmetadata", name)
+	if err != nil {
+		return err
+	}
+	mfs, ok := t.wfs.(localstorage.MetadataFS)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return mfs.SetMetadata(t.name, metadata)
+}
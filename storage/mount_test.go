@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSharedMount(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{})
+	bob, err := e.homes.Open(e.users["bob"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	shared := SharedMount + "/alice/docs"
+
+	entries, err := fs.ReadDir(bob, ".")
+	if err != nil || len(entries) != 1 || entries[0].Name() != SharedMount {
+		t.Error("mount should be listed in root: ", entries, err)
+	}
+	entries, err = fs.ReadDir(bob, SharedMount+"/alice")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "docs" {
+		t.Error("granted directories should be listed by owner: ", entries, err)
+	}
+	content, err := fs.ReadFile(bob, shared+"/report.txt")
+	if err != nil || string(content) != "quarterly" {
+		t.Error("granted file should be read: ", string(content), err)
+	}
+
+	// read-only grant rejects every write
+	if err = bob.Mkdir(shared+"/new", 0750); !errors.Is(err, fs.ErrPermission) {
+		t.Error("mkdir should be rejected: ", err)
+	}
+	if err = bob.Rename(shared+"/report.txt", shared+"/renamed.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("rename should be rejected: ", err)
+	}
+	if _, err = bob.(localstorage.TrashFS).Trash(shared + "/report.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("trash should be rejected: ", err)
+	}
+	err = bob.(localstorage.MetadataFS).SetMetadata(shared+"/report.txt", map[string]string{"color": "red"})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Error("metadata should not be set: ", err)
+	}
+	if err = bob.(localstorage.VersionFS).RestoreVersion(shared+"/report.txt", "1"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("version should not be restored: ", err)
+	}
+	f, err := bob.Create("copy.txt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = f.Close()
+	if err = bob.Rename("copy.txt", shared+"/copy.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("move into read-only grant should be rejected: ", err)
+	}
+	if err = bob.Remove(SharedMount + "/alice"); !errors.Is(err, fs.ErrPermission) {
+		t.Error("virtual directory should not be written: ", err)
+	}
+	if _, err = fs.Sub(bob, shared); !errors.Is(err, fs.ErrPermission) {
+		t.Error("mounted directory should not be sub: ", err)
+	}
+	_, err = e.server.Remove(e.context("bob"), &proto.RemoveReq{Path: shared + "/report.txt"})
+	if status.Code(err) == codes.OK {
+		t.Error("rpc should not remove granted file")
+	}
+
+	// names outside granted directory are not reached
+	for _, name := range []string{
+		shared + "/../../alice",
+		SharedMount + "/alice/other",
+		SharedMount + "/carol/docs",
+	} {
+		if _, err = fs.Stat(bob, name); err == nil {
+			t.Error("name should not be resolved: ", name)
+		}
+	}
+	if _, err = fs.Stat(bob, "docs/report.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("own home should not be shared: ", err)
+	}
+
+	// shares of disabled owner are hidden
+	alice := e.users["alice"]
+	alice.Enabled = false
+	e.users["alice"] = alice
+	if _, err = fs.ReadFile(bob, shared+"/report.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("shares of disabled owner should be hidden: ", err)
+	}
+}
+
+func TestSharedMountLinks(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{})
+	err := os.WriteFile(filepath.Join(e.root, "alice/secret.txt"), []byte("secret"), 0640)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for link, to := range map[string]string{
+		"escape.txt": "../secret.txt",
+		"home":       "..",
+		"dangling":   "../missing.txt",
+		"inner.txt":  "report.txt",
+	} {
+		err = os.Symlink(to, filepath.Join(e.root, "alice/docs", link))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	bob, err := e.homes.Open(e.users["bob"])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	shared := SharedMount + "/alice/docs"
+
+	content, err := fs.ReadFile(bob, shared+"/inner.txt")
+	if err != nil || string(content) != "quarterly" {
+		t.Error("link inside granted directory should be followed: ", string(content), err)
+	}
+	for _, name := range []string{"escape.txt", "home/secret.txt", "home", "dangling"} {
+		if _, err = fs.ReadFile(bob, shared+"/"+name); !errors.Is(err, fs.ErrPermission) {
+			t.Error(name, " should not be reached: ", err)
+		}
+	}
+
+	// whole home is never mounted
+	entries := sharedEntries([]authentication.Grant{{Owner: "alice", Path: ".", Grantee: "bob"}})
+	if len(entries) != 0 {
+		t.Error("grant of whole home should be skipped: ", entries)
+	}
+}
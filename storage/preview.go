@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Preview returns thumbnail of image or beginning of text file,
+// generated on first request and cached by content hash
+// unless homes are encrypted.
+func (s *Server) Preview(ctx context.Context, req *proto.PreviewReq) (*proto.PreviewRes, error) {
+	if s.homes.config.Previews == nil {
+		return nil, status.Error(codes.Unimplemented, "previews are disabled")
+	}
+	previews := s.homes.Previews()
+	u, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, sum, err := s.regularFile(u, home, req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	p, err := previews.Preview(home, req.GetPath(), sum, localstorage.PreviewSize(req.GetSize()))
+	if errors.Is(err, localstorage.ErrNoPreview) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.PreviewRes{
+		MimeType: p.MimeType,
+		Data:     p.Data,
+		Width:    int32(p.Width),
+		Height:   int32(p.Height),
+	}, nil
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) GetUsage(ctx context.Context, req *proto.GetUsageReq) (*proto.GetUsageRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	quota, err := s.homes.Quota(u)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	maxBytes, maxFiles := quota.Limits()
+	usedBytes, usedFiles := quota.Usage()
+	return &proto.GetUsageRes{
+		MaxBytes:  maxBytes,
+		MaxFiles:  maxFiles,
+		UsedBytes: usedBytes,
+		UsedFiles: usedFiles,
+	}, nil
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// searchScope is index of home and directory of it user could see,
+// along with the name it appears under in user's home.
+type searchScope struct {
+	index *localstorage.SearchIndex
+	inner string // directory inside indexed home
+	outer string // the same directory in user's home
+}
+
+// prefix maps directory of user's home to directory inside
+// indexed home, reports whether scope is inside it at all.
+func (s searchScope) prefix(dir string) (string, bool) {
+	switch {
+	case dir == "." || localstorage.Inside(s.outer, dir):
+		return s.inner, true
+	case localstorage.Inside(dir, s.outer):
+		return path.Join(s.inner, strings.TrimPrefix(dir, s.outer+"/")), true
+	}
+	return "", false
+}
+
+func (s searchScope) name(inner string) string {
+	rel := inner
+	if s.inner != "." {
+		rel = strings.TrimPrefix(strings.TrimPrefix(inner, s.inner), "/")
+	}
+	return path.Join(s.outer, rel)
+}
+
+// searchScopes returns indexes of everything user could see, own home
+// is the first one. Unavailable group spaces and grants are skipped.
+func (h *Homes) searchScopes(u authentication.User) ([]searchScope, error) {
+	opened, err := h.open(u)
+	if err != nil {
+		return nil, err
+	}
+	if opened.search == nil {
+		return nil, errors.ErrUnsupported
+	}
+	scopes := []searchScope{{index: opened.search, inner: ".", outer: "."}}
+	if h.groups != nil {
+		groups, err := h.memberGroups(u)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			space, err := h.openGroup(g)
+			if err != nil || space.search == nil {
+				continue
+			}
+			scopes = append(scopes, searchScope{
+				index: space.search,
+				inner: ".",
+				outer: path.Join(GroupsMount, g),
+			})
+		}
+	}
+	if h.grants != nil {
+		grants, err := h.grants.GrantsFor(u.Name)
+		if err != nil {
+			return nil, err
+		}
+		for owner, byName := range sharedEntries(grants) {
+			for name, g := range byName {
+				shared, err := h.openUser(g.Owner)
+				if err != nil || shared.search == nil {
+					continue
+				}
+				scopes = append(scopes, searchScope{
+					index: shared.search,
+					inner: g.Path,
+					outer: path.Join(SharedMount, owner, name),
+				})
+			}
+		}
+	}
+	return scopes, nil
+}
+
+// Search finds files user could see: own home, spaces of groups
+// user is member of and directories shared with user, named
+// as they appear in user's home.
+func (h *Homes) Search(u authentication.User, q localstorage.SearchQuery) ([]localstorage.SearchResult, error) {
+	dir := path.Clean(q.Prefix)
+	if q.Prefix == "" {
+		dir = "."
+	}
+	if q.Limit <= 0 {
+		q.Limit = localstorage.DefaultSearchLimit
+	}
+	scopes, err := h.searchScopes(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []localstorage.SearchResult
+	for i, s := range scopes {
+		own := i == 0
+		if own && dir != "." && h.mounted(dir) {
+			continue
+		}
+		scoped := q
+		var ok bool
+		scoped.Prefix, ok = s.prefix(dir)
+		if !ok {
+			continue
+		}
+		found, err := s.index.Search(scoped)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range found {
+			r.Name = s.name(r.Name)
+			if own && h.mounted(r.Name) {
+				// hidden by mount
+				continue
+			}
+			results = append(results, r)
+		}
+	}
+
+	if strings.TrimSpace(q.Text) == "" {
+		sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	} else {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Rank < results[j].Rank })
+	}
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Search finds files by words of their names or text content
+// in everything caller could see.
+func (s *Server) Search(ctx context.Context, req *proto.SearchReq) (*proto.SearchRes, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.homes.Search(u, localstorage.SearchQuery{
+		Text:           req.GetQuery(),
+		Prefix:         req.GetPath(),
+		MimePrefix:     req.GetMimeType(),
+		MinSize:        req.GetMinSize(),
+		MaxSize:        req.GetMaxSize(),
+		ModifiedAfter:  unixTime(req.GetModifiedAfter()),
+		ModifiedBefore: unixTime(req.GetModifiedBefore()),
+		Metadata:       req.GetMetadata(),
+		Limit:          int(req.GetLimit()),
+	})
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, status.Error(codes.Unimplemented, "search is disabled")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	r := &proto.SearchRes{Hits: make([]*proto.SearchHit, 0, len(results))}
+	for _, found := range results {
+		r.Hits = append(r.Hits, &proto.SearchHit{
+			Path:     found.Name,
+			IsDir:    found.IsDir,
+			Size:     found.Size,
+			MimeType: found.MimeType,
+			Snippet:  found.Snippet,
+			Modified: found.Modified.Unix(),
+		})
+	}
+	return r, nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves user homes over grpc.
+type Server struct {
+	homes    *Homes
+	verifier *authentication.Verifier
+	hashes   hashCache
+	proto.UnimplementedStorageServer
+}
+
+func NewServer(homes *Homes, verifier *authentication.Verifier) *Server {
+	return &Server{homes: homes, verifier: verifier}
+}
+
+// caller verifies bearer token passed in "authorization" metadata.
+func (s *Server) caller(ctx context.Context) (authentication.User, error) {
+	u, err := s.verifier.VerifyContext(ctx)
+	if err != nil {
+		return u, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return u, nil
+}
+
+// home returns file system of caller's home directory holding
+// locks caller passed tokens of.
+func (s *Server) home(ctx context.Context) (authentication.User, localstorage.WriteFS, error) {
+	u, err := s.caller(ctx)
+	if err != nil {
+		return u, nil, err
+	}
+	wfs, err := s.homes.Open(u)
+	if err != nil {
+		return u, nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if lfs, ok := wfs.(LockFS); ok {
+			lfs.UseLockTokens(md.Get(LockTokenMetadata)...)
+		}
+	}
+	return u, wfs, nil
+}
+
+// storageError maps storage errors to grpc status.
+func storageError(err error, fallback codes.Code) error {
+	if errors.Is(err, localstorage.ErrQuotaExceeded) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, localstorage.ErrLocked) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(fallback, err.Error())
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"google.golang.org/grpc/metadata"
+)
+
+type testEnv struct {
+	root   string
+	key    *rsa.PrivateKey
+	users  authtest.Users
+	homes  *Homes
+	server *Server
+}
+
+// newTestEnv prepares homes of alice and bob, alice has
+// docs/report.txt shared with bob for reading.
+func newTestEnv(t *testing.T, config *localstorage.Config) *testEnv {
+	root := t.TempDir()
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	for _, dir := range []string{"alice/docs", "bob"} {
+		err := os.MkdirAll(path.Join(root, dir), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(path.Join(root, "alice/docs/report.txt"), []byte("quarterly"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := &authtest.Grants{Grants: []authentication.Grant{{ID: "1", Owner: "alice", Path: "docs", Grantee: "bob", Access: authentication.Read}}}
+	homes := NewHomes(localstorage.NewLocalFs(root, config), config, users, grants, nil)
+	return &testEnv{
+		root:   root,
+		key:    key,
+		users:  users,
+		homes:  homes,
+		server: NewServer(homes, authentication.NewVerifier(&key.PublicKey)),
+	}
+}
+
+// token is identity token signed the way authentication service does.
+func (e *testEnv) token(username string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims{
+		"user": username,
+		"role": "u",
+		"home": e.users[username].Home,
+		"aud":  authentication.IdentityAudience,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	ss, _ := token.SignedString(e.key)
+	return ss
+}
+
+// context carries identity token of user.
+func (e *testEnv) context(username string) context.Context {
+	return metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer "+e.token(username)))
+}
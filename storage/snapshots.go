@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func exportSnapshot(sn localstorage.Snapshot) *proto.SnapshotInfo {
+	return &proto.SnapshotInfo{
+		Id:        sn.ID,
+		Label:     sn.Label,
+		Scheduled: sn.Scheduled,
+		Size:      sn.Size,
+		Files:     sn.Files,
+		Held:      sn.Held,
+		Created:   sn.Created.Unix(),
+	}
+}
+
+func snapshotError(err error) error {
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		return status.Error(codes.Unimplemented, "snapshots are not supported")
+	case errors.Is(err, localstorage.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func (s *Server) snapshots(ctx context.Context) (localstorage.SnapshotFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sfs, ok := home.(localstorage.SnapshotFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "snapshots are not supported")
+	}
+	return sfs, nil
+}
+
+// CreateSnapshot takes snapshot of home on demand, it is kept until deleted.
+func (s *Server) CreateSnapshot(ctx context.Context, req *proto.CreateSnapshotReq) (*proto.CreateSnapshotRes, error) {
+	sfs, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sn, err := sfs.Snapshot(req.GetLabel())
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	return &proto.CreateSnapshotRes{Snapshot: exportSnapshot(sn)}, nil
+}
+
+func (s *Server) ListSnapshots(ctx context.Context, req *proto.ListSnapshotsReq) (*proto.ListSnapshotsRes, error) {
+	sfs, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := sfs.ListSnapshots()
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	res := &proto.ListSnapshotsRes{}
+	for _, sn := range snapshots {
+		res.Snapshots = append(res.Snapshots, exportSnapshot(sn))
+	}
+	return res, nil
+}
+
+func (s *Server) RestoreSnapshot(ctx context.Context, req *proto.RestoreSnapshotReq) (*proto.RestoreSnapshotRes, error) {
+	sfs, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := sfs.RestoreSnapshot(req.GetId(), req.GetPath())
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	return &proto.RestoreSnapshotRes{Path: restored}, nil
+}
+
+func (s *Server) DeleteSnapshot(ctx context.Context, req *proto.DeleteSnapshotReq) (*proto.DeleteSnapshotRes, error) {
+	sfs, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = sfs.DeleteSnapshot(req.GetId())
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	return &proto.DeleteSnapshotRes{}, nil
+}
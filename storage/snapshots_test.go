@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSnapshots(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{})
+	alice := e.context("alice")
+
+	_, err := e.server.CreateSnapshot(context.Background(), &proto.CreateSnapshotReq{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Error("caller should be authenticated: ", err)
+	}
+	created, err := e.server.CreateSnapshot(alice, &proto.CreateSnapshotReq{Label: "before edit"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sn := created.GetSnapshot()
+	if sn.GetLabel() != "before edit" || sn.GetFiles() != 1 || sn.GetSize() != 9 {
+		t.Error("wrong snapshot: ", sn)
+	}
+
+	// removed file is held by snapshot only
+	err = os.Remove(path.Join(e.root, "alice/docs/report.txt"))
+	if err != nil {
+		t.Error(err)
+	}
+	list, err := e.server.ListSnapshots(alice, &proto.ListSnapshotsReq{})
+	if err != nil || len(list.GetSnapshots()) != 1 || list.GetSnapshots()[0].GetHeld() != 9 {
+		t.Error("wrong snapshots: ", list, err)
+	}
+	list, err = e.server.ListSnapshots(e.context("bob"), &proto.ListSnapshotsReq{})
+	if err != nil || len(list.GetSnapshots()) != 0 {
+		t.Error("snapshots of other home should not be listed: ", list, err)
+	}
+
+	restored, err := e.server.RestoreSnapshot(alice, &proto.RestoreSnapshotReq{Id: sn.GetId(), Path: "docs/report.txt"})
+	if err != nil || restored.GetPath() != "docs/report.txt" {
+		t.Error("wrong restored file: ", restored, err)
+	}
+	home, _ := e.homes.Open(e.users["alice"])
+	content, err := fs.ReadFile(home, "docs/report.txt")
+	if err != nil || string(content) != "quarterly" {
+		t.Error("wrong restored content: ", string(content), err)
+	}
+	_, err = e.server.RestoreSnapshot(alice, &proto.RestoreSnapshotReq{Id: sn.GetId(), Path: "missing.txt"})
+	if status.Code(err) != codes.NotFound {
+		t.Error("missing file should not be restored: ", err)
+	}
+
+	_, err = e.server.DeleteSnapshot(e.context("bob"), &proto.DeleteSnapshotReq{Id: sn.GetId()})
+	if status.Code(err) != codes.NotFound {
+		t.Error("snapshot of other home should not be deleted: ", err)
+	}
+	_, err = e.server.DeleteSnapshot(alice, &proto.DeleteSnapshotReq{Id: sn.GetId()})
+	if err != nil {
+		t.Error(err)
+	}
+	list, _ = e.server.ListSnapshots(alice, &proto.ListSnapshotsReq{})
+	if len(list.GetSnapshots()) != 0 {
+		t.Error("snapshot should be deleted: ", list)
+	}
+}
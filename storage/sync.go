@@ -0,0 +1,530 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	signatureBatch = 4096            // blocks per message
+	deltaBatch     = 1024            // ops per message
+	manifestBatch  = 1024            // actions per message
+	stagingChunk   = 4 * 1024 * 1024 // size of writes staging delta result
+	hashCacheSize  = 100000
+)
+
+// dirHash stands for content hash of directories in manifests.
+const dirHash = "/"
+
+type cachedHash struct {
+	size     int64
+	modified time.Time
+	sha256   string
+}
+
+// hashCache keeps content hashes of files by name, they are valid
+// while size and modification time are not changed.
+type hashCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedHash
+}
+
+func (c *hashCache) get(key string, info fs.FileInfo) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.entries[key]
+	if !ok || h.size != info.Size() || !h.modified.Equal(info.ModTime()) {
+		return "", false
+	}
+	return h.sha256, true
+}
+
+func (c *hashCache) put(key string, info fs.FileInfo, sum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= hashCacheSize {
+		c.entries = make(map[string]cachedHash)
+	}
+	c.entries[key] = cachedHash{size: info.Size(), modified: info.ModTime(), sha256: sum}
+}
+
+// fileHash returns sha256 of file content, stored one
+// is preferred if home keeps checksums.
+func (s *Server) fileHash(u authentication.User, home fs.FS, name string, info fs.FileInfo) (string, error) {
+	if hfs, ok := home.(localstorage.HashFS); ok {
+		h, err := hfs.Hash(name)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return h.SHA256, err
+		}
+	}
+	key := u.Name + "\x00" + name
+	if sum, ok := s.hashes.get(key, info); ok {
+		return sum, nil
+	}
+	f, err := home.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	s.hashes.put(key, info, sum)
+	return sum, nil
+}
+
+// regularFile returns info and hash of regular file.
+func (s *Server) regularFile(u authentication.User, home fs.FS, name string) (fs.FileInfo, string, error) {
+	info, err := fs.Stat(home, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, "", fmt.Errorf("%s is not a regular file", name)
+	}
+	sum, err := s.fileHash(u, home, name, info)
+	return info, sum, err
+}
+
+func (s *Server) GetSignature(req *proto.GetSignatureReq, srv proto.Storage_GetSignatureServer) error {
+	u, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	info, sum, err := s.regularFile(u, home, req.GetPath())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	bs := int(req.GetBlockSize())
+	if bs == 0 {
+		bs = localstorage.BlockSize(info.Size())
+	}
+	if bs < localstorage.MinBlockSize || bs > localstorage.MaxBlockSize {
+		return status.Error(codes.InvalidArgument, "block size is out of range")
+	}
+	f, err := home.Open(req.GetPath())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	defer f.Close()
+
+	msg := &proto.FileSignature{
+		Path:      req.GetPath(),
+		Size:      info.Size(),
+		Sha256:    sum,
+		BlockSize: int32(bs),
+		Modified:  info.ModTime().Unix(),
+	}
+	err = localstorage.Sign(f, bs, func(b localstorage.BlockSignature) error {
+		msg.Blocks = append(msg.Blocks, &proto.BlockSignature{
+			Weak:   b.Weak,
+			Strong: b.Strong[:],
+			Size:   int32(b.Size),
+		})
+		if len(msg.Blocks) < signatureBatch {
+			return nil
+		}
+		err := srv.Send(msg)
+		msg = &proto.FileSignature{}
+		return err
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if len(msg.Blocks) > 0 || msg.Path != "" {
+		return srv.Send(msg)
+	}
+	return nil
+}
+
+func importSignature(sig *localstorage.Signature, msg *proto.FileSignature) error {
+	for _, b := range msg.GetBlocks() {
+		imported := localstorage.BlockSignature{Weak: b.GetWeak(), Size: int(b.GetSize())}
+		if copy(imported.Strong[:], b.GetStrong()) != sha256.Size {
+			return errors.New("invalid block signature")
+		}
+		sig.Blocks = append(sig.Blocks, imported)
+	}
+	return nil
+}
+
+// GetDelta receives signature of client's copy of file
+// and sends operations turning it into server's content.
+func (s *Server) GetDelta(srv proto.Storage_GetDeltaServer) error {
+	u, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	first, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+	name := first.GetPath()
+	sig := localstorage.Signature{BlockSize: int(first.GetBlockSize())}
+	for msg := first; ; {
+		err = importSignature(&sig, msg)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		msg, err = srv.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	info, sum, err := s.regularFile(u, home, name)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if sig.BlockSize == 0 && len(sig.Blocks) == 0 {
+		// client has no copy
+		sig.BlockSize = localstorage.BlockSize(info.Size())
+	}
+	if sig.BlockSize < localstorage.MinBlockSize || sig.BlockSize > localstorage.MaxBlockSize {
+		return status.Error(codes.InvalidArgument, "block size is out of range")
+	}
+	f, err := home.Open(name)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	defer f.Close()
+
+	res := &proto.GetDeltaRes{
+		Size:     info.Size(),
+		Sha256:   sum,
+		Modified: info.ModTime().Unix(),
+	}
+	literal := 0
+	err = localstorage.Delta(sig, f, chunkSize, func(op localstorage.DeltaOp) error {
+		res.Ops = append(res.Ops, &proto.DeltaOp{Block: int64(op.Block), Data: op.Data})
+		literal += len(op.Data)
+		if len(res.Ops) < deltaBatch && literal < chunkSize {
+			return nil
+		}
+		err := srv.Send(res)
+		res = &proto.GetDeltaRes{}
+		literal = 0
+		return err
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if len(res.Ops) > 0 || res.Sha256 != "" {
+		return srv.Send(res)
+	}
+	return nil
+}
+
+// checkBase reports conflict if file was changed since client
+// got content with hash base, empty base means it should not exist.
+func (s *Server) checkBase(u authentication.User, home fs.FS, name string, base string) error {
+	_, sum, err := s.regularFile(u, home, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		sum, err = "", nil
+	}
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if sum != base {
+		return status.Errorf(codes.Aborted, "conflict: %s was changed on server", name)
+	}
+	return nil
+}
+
+// stagingWriter writes content into upload session sequentially.
+type stagingWriter struct {
+	ufs    localstorage.UploadFS
+	id     string
+	offset int64
+	buf    []byte
+}
+
+func (w *stagingWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= stagingChunk {
+		return len(p), w.Flush()
+	}
+	return len(p), nil
+}
+
+func (w *stagingWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ufs.WriteUpload(w.id, w.offset, w.buf)
+	w.offset += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return err
+}
+
+// PutDelta receives operations turning server's content of file into
+// client's one. Result is staged as upload, so it replaces file only
+// when complete and verified.
+func (s *Server) PutDelta(srv proto.Storage_PutDeltaServer) error {
+	u, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	ufs, ok := home.(localstorage.UploadFS)
+	if !ok {
+		return status.Error(codes.Unimplemented, "uploads are not supported")
+	}
+	header, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+	name := header.GetPath()
+	if !fs.ValidPath(name) || IsShared(name) || IsGroupSpace(name) {
+		return status.Error(codes.InvalidArgument, "only files of own home could be synced")
+	}
+	err = s.checkBase(u, home, name, header.GetBaseSha256())
+	if err != nil {
+		return err
+	}
+
+	var base io.ReaderAt
+	if header.GetBaseSha256() != "" {
+		f, err := home.Open(name)
+		if err != nil {
+			return status.Error(codes.NotFound, err.Error())
+		}
+		defer f.Close()
+		base, err = localstorage.ReaderAt(f)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	bs := int(header.GetBlockSize())
+	if base != nil && (bs < localstorage.MinBlockSize || bs > localstorage.MaxBlockSize) {
+		return status.Error(codes.InvalidArgument, "block size is out of range")
+	}
+
+	session, err := ufs.CreateUpload(header.GetSize(), header.GetSha256())
+	if err != nil {
+		return storageError(err, codes.InvalidArgument)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = ufs.AbortUpload(session.ID)
+		}
+	}()
+
+	w := &stagingWriter{ufs: ufs, id: session.ID}
+	var literal int64
+	for msg := header; ; {
+		for _, op := range msg.GetOps() {
+			if len(op.GetData()) == 0 && base == nil {
+				return status.Error(codes.InvalidArgument, "new file could not copy blocks")
+			}
+			literal += int64(len(op.GetData()))
+			err = localstorage.ApplyDelta(base, bs,
+				localstorage.DeltaOp{Block: int(op.GetBlock()), Data: op.GetData()}, w)
+			if err != nil {
+				return storageError(err, codes.InvalidArgument)
+			}
+		}
+		msg, err = srv.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return storageError(err, codes.InvalidArgument)
+	}
+
+	// file could be changed while delta was transferred
+	err = s.checkBase(u, home, name, header.GetBaseSha256())
+	if err != nil {
+		return err
+	}
+	err = ufs.CommitUpload(session.ID, name)
+	if err != nil {
+		return storageError(err, codes.FailedPrecondition)
+	}
+	committed = true
+	return srv.SendAndClose(&proto.PutDeltaRes{LiteralBytes: literal})
+}
+
+// syncAction compares client entry with server one, either could be
+// nil if missing. Side changed since last sync wins, if both are
+// changed it is a conflict.
+func syncAction(local *proto.SyncEntry, remote *proto.SyncEntry) (proto.SyncActionE, string, bool) {
+	var localHash, remoteHash, base string
+	if local != nil {
+		base = local.GetBaseSha256()
+		if !local.GetDeleted() {
+			localHash = local.GetSha256()
+			if local.GetIsDir() {
+				localHash = dirHash
+			}
+		}
+	}
+	if remote != nil {
+		remoteHash = remote.GetSha256()
+	}
+	if localHash == remoteHash {
+		return 0, "", false
+	}
+	localChanged := localHash != base
+	remoteChanged := remoteHash != base
+	switch {
+	case localChanged && !remoteChanged && localHash == "":
+		return proto.SyncActionE_DELETE_REMOTE, "", true
+	case localChanged && !remoteChanged:
+		return proto.SyncActionE_UPLOAD, "", true
+	case remoteChanged && !localChanged && remoteHash == "":
+		return proto.SyncActionE_DELETE_LOCAL, "", true
+	case remoteChanged && !localChanged:
+		return proto.SyncActionE_DOWNLOAD, "", true
+	case localHash == "":
+		return proto.SyncActionE_CONFLICT, "deleted locally, changed on server", true
+	case remoteHash == "":
+		return proto.SyncActionE_CONFLICT, "changed locally, deleted on server", true
+	default:
+		return proto.SyncActionE_CONFLICT, "changed on both sides", true
+	}
+}
+
+// manifest lists server entries inside prefix. Mounts are
+// skipped unless prefix is inside of one.
+func (s *Server) manifest(u authentication.User, home fs.FS, prefix string) (map[string]*proto.SyncEntry, error) {
+	entries := make(map[string]*proto.SyncEntry)
+	mounted := IsShared(prefix) || IsGroupSpace(prefix)
+	err := fs.WalkDir(home, prefix, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && name == prefix {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if name == prefix {
+			return nil
+		}
+		if !mounted && (IsShared(name) || IsGroupSpace(name)) {
+			return fs.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		e := &proto.SyncEntry{
+			Path:     name,
+			IsDir:    d.IsDir(),
+			Modified: info.ModTime().Unix(),
+		}
+		switch {
+		case d.IsDir():
+			e.Sha256 = dirHash
+		case info.Mode().IsRegular():
+			e.Size = info.Size()
+			e.Sha256, err = s.fileHash(u, home, name, info)
+			if err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		entries[name] = e
+		return nil
+	})
+	return entries, err
+}
+
+// CompareManifest receives client's manifest and sends actions
+// needed to bring both sides in sync, conflicts are reported
+// for client to resolve.
+func (s *Server) CompareManifest(srv proto.Storage_CompareManifestServer) error {
+	u, home, err := s.home(srv.Context())
+	if err != nil {
+		return err
+	}
+	first, err := srv.Recv()
+	if err != nil {
+		return err
+	}
+	prefix := path.Clean(first.GetPrefix())
+	if !fs.ValidPath(prefix) {
+		return status.Error(codes.InvalidArgument, "invalid prefix")
+	}
+	local := make(map[string]*proto.SyncEntry)
+	for msg := first; ; {
+		for _, e := range msg.GetEntries() {
+			name := path.Clean(e.GetPath())
+			if name != prefix && localstorage.Inside(name, prefix) {
+				local[name] = e
+			}
+		}
+		msg, err = srv.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	remote, err := s.manifest(u, home, prefix)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	res := &proto.CompareManifestRes{}
+	add := func(name string, l *proto.SyncEntry, r *proto.SyncEntry) error {
+		action, reason, ok := syncAction(l, r)
+		if !ok {
+			return nil
+		}
+		res.Actions = append(res.Actions, &proto.SyncAction{
+			Path:   name,
+			Action: action,
+			Remote: r,
+			Reason: reason,
+		})
+		if len(res.Actions) < manifestBatch {
+			return nil
+		}
+		err := srv.Send(res)
+		res = &proto.CompareManifestRes{}
+		return err
+	}
+	for name, l := range local {
+		err = add(name, l, remote[name])
+		if err != nil {
+			return err
+		}
+	}
+	for name, r := range remote {
+		if _, ok := local[name]; ok {
+			continue
+		}
+		err = add(name, nil, r)
+		if err != nil {
+			return err
+		}
+	}
+	return srv.Send(res)
+}
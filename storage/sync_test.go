@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSyncAction(t *testing.T) {
+	entry := func(sum string, base string) *proto.SyncEntry {
+		return &proto.SyncEntry{Sha256: sum, BaseSha256: base}
+	}
+	deleted := func(base string) *proto.SyncEntry {
+		return &proto.SyncEntry{BaseSha256: base, Deleted: true}
+	}
+	for _, c := range []struct {
+		name   string
+		local  *proto.SyncEntry
+		remote *proto.SyncEntry
+		action proto.SyncActionE
+		reason string
+		ok     bool
+	}{
+		{"same content", entry("a", "x"), entry("a", ""), 0, "", false},
+		{"both missing", deleted("a"), nil, 0, "", false},
+		{"same dirs", &proto.SyncEntry{IsDir: true}, entry(dirHash, ""), 0, "", false},
+		{"new local", entry("a", ""), nil, proto.SyncActionE_UPLOAD, "", true},
+		{"changed locally", entry("b", "a"), entry("a", ""), proto.SyncActionE_UPLOAD, "", true},
+		{"deleted locally", deleted("a"), entry("a", ""), proto.SyncActionE_DELETE_REMOTE, "", true},
+		{"new remote", nil, entry("a", ""), proto.SyncActionE_DOWNLOAD, "", true},
+		{"changed on server", entry("a", "a"), entry("b", ""), proto.SyncActionE_DOWNLOAD, "", true},
+		{"deleted on server", entry("a", "a"), nil, proto.SyncActionE_DELETE_LOCAL, "", true},
+		{"changed on both", entry("b", "a"), entry("c", ""), proto.SyncActionE_CONFLICT, "changed on both sides", true},
+		{"created on both", entry("b", ""), entry("c", ""), proto.SyncActionE_CONFLICT, "changed on both sides", true},
+		{"deleted locally, changed on server", deleted("a"), entry("c", ""),
+			proto.SyncActionE_CONFLICT, "deleted locally, changed on server", true},
+		{"changed locally, deleted on server", entry("b", "a"), nil,
+			proto.SyncActionE_CONFLICT, "changed locally, deleted on server", true},
+	} {
+		action, reason, ok := syncAction(c.local, c.remote)
+		if action != c.action || reason != c.reason || ok != c.ok {
+			t.Error(c.name, ": got ", action, " ", reason, " ", ok)
+		}
+	}
+}
+
+// putDeltaStream feeds messages to PutDelta,
+// recv is called before each one is returned.
+type putDeltaStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	msgs []*proto.PutDeltaReq
+	recv func(i int)
+	n    int
+	res  *proto.PutDeltaRes
+}
+
+func (s *putDeltaStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *putDeltaStream) Recv() (*proto.PutDeltaReq, error) {
+	if s.recv != nil {
+		s.recv(s.n)
+	}
+	if s.n >= len(s.msgs) {
+		return nil, io.EOF
+	}
+	s.n++
+	return s.msgs[s.n-1], nil
+}
+
+func (s *putDeltaStream) SendAndClose(res *proto.PutDeltaRes) error {
+	s.res = res
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// deltaMessages returns request turning base into content, ops
+// are split into messages of one op each.
+func deltaMessages(t *testing.T, name string, base []byte, content []byte) []*proto.PutDeltaReq {
+	sig := localstorage.Signature{BlockSize: localstorage.MinBlockSize}
+	err := localstorage.Sign(bytes.NewReader(base), sig.BlockSize, func(b localstorage.BlockSignature) error {
+		sig.Blocks = append(sig.Blocks, b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []*proto.PutDeltaReq{{
+		Path:       name,
+		BaseSha256: sha256Hex(base),
+		Sha256:     sha256Hex(content),
+		Size:       int64(len(content)),
+		BlockSize:  int32(sig.BlockSize),
+	}}
+	err = localstorage.Delta(sig, bytes.NewReader(content), 1024, func(op localstorage.DeltaOp) error {
+		msgs = append(msgs, &proto.PutDeltaReq{Ops: []*proto.DeltaOp{{Block: int64(op.Block), Data: op.Data}}})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func TestPutDelta(t *testing.T) {
+	e := newTestEnv(t, &localstorage.Config{CacheSize: 1024 * 1024})
+	full := filepath.Join(e.root, "alice/docs/data.bin")
+	base := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	err := os.WriteFile(full, base, 0640)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	content := append(bytes.Clone(base[:30000]), []byte("inserted in the middle")...)
+	content = append(content, base[30000:]...)
+
+	stream := &putDeltaStream{ctx: e.context("alice"), msgs: deltaMessages(t, "docs/data.bin", base, content)}
+	err = e.server.PutDelta(stream)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n := stream.res.GetLiteralBytes(); n == 0 || n >= int64(len(content))/2 {
+		t.Error("unexpected literal bytes: ", n)
+	}
+	b, _ := os.ReadFile(full)
+	if !bytes.Equal(b, content) {
+		t.Error("wrong content after delta")
+	}
+
+	// base is not content of file any more
+	stream = &putDeltaStream{ctx: e.context("alice"), msgs: deltaMessages(t, "docs/data.bin", base, content)}
+	err = e.server.PutDelta(stream)
+	if status.Code(err) != codes.Aborted {
+		t.Error("expected conflict with stale base, got ", err)
+	}
+
+	// file is changed while delta is transferred
+	changed := []byte("changed by someone else")
+	stream = &putDeltaStream{ctx: e.context("alice"), msgs: deltaMessages(t, "docs/data.bin", content, base)}
+	stream.recv = func(i int) {
+		if i == 1 {
+			_ = os.WriteFile(full, changed, 0640)
+		}
+	}
+	err = e.server.PutDelta(stream)
+	if status.Code(err) != codes.Aborted {
+		t.Error("expected conflict with file changed during transfer, got ", err)
+	}
+	b, _ = os.ReadFile(full)
+	if !bytes.Equal(b, changed) {
+		t.Error("changed file should be kept")
+	}
+}
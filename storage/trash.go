@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func exportTrashItem(item localstorage.TrashItem) *proto.TrashItem {
+	return &proto.TrashItem{
+		Id:      item.ID,
+		Path:    item.Path,
+		IsDir:   item.IsDir,
+		Size:    item.Size,
+		Files:   item.Files,
+		Deleted: item.Deleted.Unix(),
+	}
+}
+
+func (s *Server) trash(ctx context.Context) (localstorage.TrashFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tfs, ok := home.(localstorage.TrashFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "trash is not supported")
+	}
+	return tfs, nil
+}
+
+// Remove moves file or directory into trash.
+func (s *Server) Remove(ctx context.Context, req *proto.RemoveReq) (*proto.RemoveRes, error) {
+	tfs, err := s.trash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	item, err := tfs.Trash(req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.RemoveRes{Item: exportTrashItem(item)}, nil
+}
+
+func (s *Server) ListTrash(ctx context.Context, req *proto.ListTrashReq) (*proto.ListTrashRes, error) {
+	tfs, err := s.trash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := tfs.ListTrash()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &proto.ListTrashRes{}
+	for _, item := range items {
+		res.Items = append(res.Items, exportTrashItem(item))
+	}
+	return res, nil
+}
+
+func (s *Server) RestoreTrash(ctx context.Context, req *proto.RestoreTrashReq) (*proto.RestoreTrashRes, error) {
+	tfs, err := s.trash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := tfs.RestoreTrash(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.RestoreTrashRes{Path: restored}, nil
+}
+
+func (s *Server) EmptyTrash(ctx context.Context, req *proto.EmptyTrashReq) (*proto.EmptyTrashRes, error) {
+	tfs, err := s.trash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cnt, err := tfs.EmptyTrash()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &proto.EmptyTrashRes{Removed: int64(cnt)}, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func exportSession(s localstorage.UploadSession) *proto.UploadSession {
+	r := &proto.UploadSession{
+		Id:      s.ID,
+		Size:    s.Size,
+		Sha256:  s.Hash,
+		Created: s.Created.Unix(),
+		Expires: s.Expires.Unix(),
+	}
+	for _, b := range s.Received {
+		r.Received = append(r.Received,
+			&proto.ByteRange{Offset: b.Offset, Length: b.Length})
+	}
+	return r
+}
+
+func (s *Server) uploads(ctx context.Context) (localstorage.UploadFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ufs, ok := home.(localstorage.UploadFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "uploads are not supported")
+	}
+	return ufs, nil
+}
+
+func (s *Server) CreateUpload(ctx context.Context, req *proto.CreateUploadReq) (*proto.CreateUploadRes, error) {
+	ufs, err := s.uploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// abandoned sessions are cleaned up lazily
+	_, _ = ufs.ExpireUploads()
+
+	session, err := ufs.CreateUpload(req.GetSize(), req.GetSha256())
+	if err != nil {
+		return nil, storageError(err, codes.InvalidArgument)
+	}
+	return &proto.CreateUploadRes{Session: exportSession(session)}, nil
+}
+
+func (s *Server) PutChunk(ctx context.Context, req *proto.PutChunkReq) (*proto.PutChunkRes, error) {
+	ufs, err := s.uploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := ufs.WriteUpload(req.GetId(), req.GetOffset(), req.GetData())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &proto.PutChunkRes{Session: exportSession(session)}, nil
+}
+
+func (s *Server) GetUpload(ctx context.Context, req *proto.GetUploadReq) (*proto.GetUploadRes, error) {
+	ufs, err := s.uploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	session, err := ufs.Upload(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.GetUploadRes{Session: exportSession(session)}, nil
+}
+
+func (s *Server) CommitUpload(ctx context.Context, req *proto.CommitUploadReq) (*proto.CommitUploadRes, error) {
+	ufs, err := s.uploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = ufs.CommitUpload(req.GetId(), req.GetPath())
+	if err != nil {
+		return nil, storageError(err, codes.FailedPrecondition)
+	}
+	return &proto.CommitUploadRes{}, nil
+}
+
+func (s *Server) AbortUpload(ctx context.Context, req *proto.AbortUploadReq) (*proto.AbortUploadRes, error) {
+	ufs, err := s.uploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = ufs.AbortUpload(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &proto.AbortUploadRes{}, nil
+}
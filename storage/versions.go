@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const chunkSize = 64 * 1024
+
+func (s *Server) versions(ctx context.Context) (localstorage.VersionFS, error) {
+	_, home, err := s.home(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vfs, ok := home.(localstorage.VersionFS)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "versioning is not supported")
+	}
+	return vfs, nil
+}
+
+func (s *Server) ListVersions(ctx context.Context, req *proto.ListVersionsReq) (*proto.ListVersionsRes, error) {
+	vfs, err := s.versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := vfs.Versions(req.GetPath())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res := &proto.ListVersionsRes{}
+	for _, v := range versions {
+		res.Versions = append(res.Versions, &proto.FileVersion{
+			Id:       v.ID,
+			Size:     v.Size,
+			Modified: v.ModTime.Unix(),
+			Replaced: v.Replaced.Unix(),
+		})
+	}
+	return res, nil
+}
+
+// sendFile streams content in chunks.
+func sendFile(r io.Reader, send func(*proto.FileChunk) error) error {
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			err := send(&proto.FileChunk{Offset: offset, Data: buf[:n]})
+			if err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+func (s *Server) ReadVersion(req *proto.ReadVersionReq, srv proto.Storage_ReadVersionServer) error {
+	vfs, err := s.versions(srv.Context())
+	if err != nil {
+		return err
+	}
+	f, err := vfs.OpenVersion(req.GetPath(), req.GetId())
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	defer f.Close()
+	return sendFile(f, srv.Send)
+}
+
+func (s *Server) RestoreVersion(ctx context.Context, req *proto.RestoreVersionReq) (*proto.RestoreVersionRes, error) {
+	vfs, err := s.versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = vfs.RestoreVersion(req.GetPath(), req.GetId())
+	if err != nil {
+		return nil, storageError(err, codes.FailedPrecondition)
+	}
+	return &proto.RestoreVersionRes{}, nil
+}
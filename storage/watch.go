@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func exportChange(c localstorage.Change) *proto.Change {
+	r := &proto.Change{
+		Seq:     c.Seq,
+		Path:    c.Name,
+		OldPath: c.OldName,
+		IsDir:   c.IsDir,
+		Time:    c.Time.Unix(),
+	}
+	switch c.Op {
+	case localstorage.ChangeCreate:
+		r.Op = proto.ChangeOpE_CREATE
+	case localstorage.ChangeModify:
+		r.Op = proto.ChangeOpE_MODIFY
+	case localstorage.ChangeDelete:
+		r.Op = proto.ChangeOpE_DELETE
+	case localstorage.ChangeRename:
+		r.Op = proto.ChangeOpE_RENAME
+	}
+	return r
+}
+
+// Watch streams changes inside prefix of caller's own home until
+// client cancels. Stream ends with Aborted if client does not keep up,
+// it could resume from the last received sequence number and epoch
+// then. Resume after restart begins with root rescan marker.
+func (s *Server) Watch(req *proto.WatchReq, srv proto.Storage_WatchServer) error {
+	u, err := s.caller(srv.Context())
+	if err != nil {
+		return err
+	}
+	prefix := req.GetPrefix()
+	if prefix == "" {
+		prefix = "."
+	}
+	if IsShared(prefix) || IsGroupSpace(prefix) {
+		return status.Error(codes.InvalidArgument, "only own home could be watched")
+	}
+	changes, err := s.homes.Changes(u)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	sub, err := changes.Resume(prefix, req.GetEpoch(), req.GetSince())
+	if errors.Is(err, localstorage.ErrChangesExpired) {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case c, ok := <-sub.C:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return status.Error(codes.Aborted, err.Error())
+				}
+				return status.Error(codes.Unavailable, "storage is closed")
+			}
+			r := exportChange(c)
+			r.Epoch = changes.Epoch()
+			err = srv.Send(r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
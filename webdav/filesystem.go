@@ -0,0 +1,171 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/shabunin/cardia/localstorage"
+	"golang.org/x/net/webdav"
+)
+
+// fileSystem exposes WriteFS as webdav.FileSystem.
+type fileSystem struct {
+	wfs localstorage.WriteFS
+}
+
+// fsName converts slash-prefixed webdav name to fs one.
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// davError unwraps not exist and exist errors since webdav
+// checks them with os.IsNotExist, which sees only one level.
+func davError(name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case errors.Is(err, fs.ErrExist):
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	}
+	return err
+}
+
+func (d *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return davError(name, d.wfs.Mkdir(fsName(name), perm))
+}
+
+func (d *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	n := fsName(name)
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		f, err := d.wfs.Create(n)
+		if err != nil {
+			return nil, davError(name, err)
+		}
+		if ff, ok := f.(fs.File); ok {
+			return &file{File: ff, fsys: d.wfs, name: n}, nil
+		}
+		return &file{File: writeOnly{f}, fsys: d.wfs, name: n}, nil
+	}
+
+	f, err := d.wfs.Open(n)
+	if err != nil {
+		return nil, davError(name, err)
+	}
+	return &file{File: f, fsys: d.wfs, name: n}, nil
+}
+
+// RemoveAll moves entry into trash if supported.
+func (d *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	n := fsName(name)
+	if tfs, ok := d.wfs.(localstorage.TrashFS); ok {
+		_, err := tfs.Trash(n)
+		return davError(name, err)
+	}
+	return davError(name, removeAll(d.wfs, n))
+}
+
+func removeAll(wfs localstorage.WriteFS, name string) error {
+	info, err := fs.Stat(wfs, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(wfs, name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			err = removeAll(wfs, path.Join(name, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return wfs.Remove(name)
+}
+
+func (d *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return davError(oldName, d.wfs.Rename(fsName(oldName), fsName(newName)))
+}
+
+func (d *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := fs.Stat(d.wfs, fsName(name))
+	return info, davError(name, err)
+}
+
+// writeOnly adapts FileWriter of backends
+// which could not read files being written.
+type writeOnly struct {
+	localstorage.FileWriter
+}
+
+func (w writeOnly) Read(p []byte) (int, error) {
+	return 0, errors.New("file is opened write only")
+}
+
+// file adapts fs.File to webdav.File,
+// writes are possible only for files opened by Create.
+type file struct {
+	fs.File
+	fsys    fs.FS
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, errors.New("seek is not supported")
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if w, ok := f.File.(io.Writer); ok {
+		return w.Write(p)
+	}
+	return 0, errors.New("file is opened read only")
+}
+
+func (f *file) Readdir(count int) ([]fs.FileInfo, error) {
+	if f.entries == nil {
+		entries, err := fs.ReadDir(f.fsys, f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+	}
+
+	rest := f.entries[f.offset:]
+	if count > 0 {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		if count < len(rest) {
+			rest = rest[:count]
+		}
+	}
+	f.offset += len(rest)
+
+	infos := make([]fs.FileInfo, 0, len(rest))
+	for _, e := range rest {
+		info, err := e.Info()
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,144 @@
+package webdav
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/storage"
+	"golang.org/x/net/webdav"
+)
+
+// PasswordAuthenticator issues token for valid credentials.
+// Implemented by authentication.Authenticator.
+type PasswordAuthenticator interface {
+	AuthenticateWithPassword(username string, password string) (string, error)
+	PasswordStamp(username string) (string, error)
+}
+
+// credentialsTTL limits how long verified basic credentials are cached.
+// Clients send them with every request and password hashing is slow.
+// Cached ones are keyed by password stamp, so they are not accepted
+// once password is changed.
+const credentialsTTL = 5 * time.Minute
+
+type cachedUser struct {
+	user    authentication.User
+	expires time.Time
+}
+
+// Handler serves user homes over WebDAV.
+// Clients authenticate with Basic auth or bearer token.
+type Handler struct {
+	prefix   string
+	auth     PasswordAuthenticator
+	verifier *authentication.Verifier
+	homes    *storage.Homes
+
+	mu          sync.Mutex
+	credentials map[[sha256.Size]byte]cachedUser
+	locks       map[string]*lockSystem // by username
+}
+
+func NewHandler(prefix string, auth PasswordAuthenticator,
+	verifier *authentication.Verifier, homes *storage.Homes) *Handler {
+
+	return &Handler{
+		prefix:      prefix,
+		auth:        auth,
+		verifier:    verifier,
+		homes:       homes,
+		credentials: make(map[[sha256.Size]byte]cachedUser),
+		locks:       make(map[string]*lockSystem),
+	}
+}
+
+func (h *Handler) basicAuth(username string, password string) (authentication.User, error) {
+	stamp, err := h.auth.PasswordStamp(username)
+	if err != nil {
+		return authentication.User{}, errors.New("wrong credentials")
+	}
+	key := sha256.Sum256([]byte(username + "\x00" + password + "\x00" + stamp))
+	now := time.Now()
+
+	h.mu.Lock()
+	cached, ok := h.credentials[key]
+	h.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.user, nil
+	}
+
+	token, err := h.auth.AuthenticateWithPassword(username, password)
+	if err != nil {
+		return authentication.User{}, err
+	}
+	u, err := h.verifier.VerifyToken(token)
+	if err != nil {
+		return u, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, c := range h.credentials {
+		if now.After(c.expires) {
+			delete(h.credentials, k)
+		}
+	}
+	h.credentials[key] = cachedUser{user: u, expires: now.Add(credentialsTTL)}
+	return u, nil
+}
+
+func (h *Handler) authenticate(r *http.Request) (authentication.User, error) {
+	if username, password, ok := r.BasicAuth(); ok {
+		return h.basicAuth(username, password)
+	}
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return h.verifier.VerifyToken(token)
+	}
+	return authentication.User{}, errors.New("no credentials")
+}
+
+// lockSystem returns locks of user, since names are relative
+// to home they should not be shared between users.
+func (h *Handler) lockSystem(u authentication.User) *lockSystem {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ls, ok := h.locks[u.Name]
+	if !ok {
+		ls = newLockSystem()
+		h.locks[u.Name] = ls
+	}
+	return ls
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := h.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="cardia"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	home, err := h.homes.Open(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	locks := requestLocks{lockSystem: h.lockSystem(u)}
+	if lfs, ok := home.(storage.LockFS); ok {
+		// locks submitted by client are held by this request
+		lfs.UseLockTokens(locks.held(r.Header.Get("If"))...)
+		locks.fsys = lfs
+	}
+	dav := &webdav.Handler{
+		Prefix:     h.prefix,
+		FileSystem: &fileSystem{wfs: home},
+		LockSystem: locks,
+	}
+	dav.ServeHTTP(w, r)
+}
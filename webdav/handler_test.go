@@ -0,0 +1,251 @@
+package webdav
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shabunin/cardia/authentication"
+	"github.com/shabunin/cardia/authentication/authtest"
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+)
+
+// testAuth issues tokens for shared password of enabled users.
+type testAuth struct {
+	key      *rsa.PrivateKey
+	users    authtest.Users
+	password string
+	stamp    int // changed along with password
+	logins   int
+}
+
+func (a *testAuth) token(username string) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims{
+		"user": username,
+		"role": "u",
+		"home": a.users[username].Home,
+		"aud":  authentication.IdentityAudience,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}).SignedString(a.key)
+	return token
+}
+
+func (a *testAuth) AuthenticateWithPassword(username string, password string) (string, error) {
+	a.logins++
+	u, ok := a.users[username]
+	if !ok || !u.Enabled || password != a.password {
+		return "", errors.New("wrong credentials")
+	}
+	return a.token(username), nil
+}
+
+func (a *testAuth) PasswordStamp(username string) (string, error) {
+	if _, ok := a.users[username]; !ok {
+		return "", errors.New("user not found")
+	}
+	return fmt.Sprint(a.stamp), nil
+}
+
+func TestHandler(t *testing.T) {
+	root := t.TempDir()
+	users := authtest.Users{
+		"alice": {Name: "alice", Home: "alice", Enabled: true},
+		"bob":   {Name: "bob", Home: "bob", Enabled: true},
+	}
+	for _, dir := range []string{"alice/docs", "bob"} {
+		err := os.MkdirAll(path.Join(root, dir), 0750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(path.Join(root, "alice/docs/report.txt"), []byte("quarterly"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &testAuth{key: key, users: users, password: "secret"}
+	grants := &authtest.Grants{Grants: []authentication.Grant{{ID: "1", Owner: "alice", Path: "docs", Grantee: "bob", Access: authentication.Read}}}
+	config := &localstorage.Config{}
+	homes := storage.NewHomes(localstorage.NewLocalFs(root, config), config, users, grants, nil)
+	h := NewHandler("/dav", auth, authentication.NewVerifier(&key.PublicKey), homes)
+
+	do := func(method string, name string, body string, header map[string]string) (*http.Response, string) {
+		req := httptest.NewRequest(method, "/dav/"+name, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		res := w.Result()
+		content, _ := io.ReadAll(res.Body)
+		return res, string(content)
+	}
+	basic := func(username string, password string) map[string]string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(username, password)
+		return map[string]string{"Authorization": req.Header.Get("Authorization")}
+	}
+	alice := basic("alice", "secret")
+
+	// authentication
+	res, _ := do(http.MethodGet, "docs/report.txt", "", nil)
+	if res.StatusCode != http.StatusUnauthorized || res.Header.Get("WWW-Authenticate") == "" {
+		t.Error("anonymous request should be challenged: ", res.Status)
+	}
+	res, _ = do(http.MethodGet, "docs/report.txt", "", basic("alice", "wrong"))
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Error("wrong password should be rejected: ", res.Status)
+	}
+	res, body := do(http.MethodGet, "docs/report.txt", "", alice)
+	if res.StatusCode != http.StatusOK || body != "quarterly" {
+		t.Error("basic auth should be accepted: ", res.Status, body)
+	}
+	logins := auth.logins
+	_, _ = do(http.MethodGet, "docs/report.txt", "", alice)
+	if auth.logins != logins {
+		t.Error("verified credentials should be cached")
+	}
+	auth.password = "changed"
+	auth.stamp++
+	res, _ = do(http.MethodGet, "docs/report.txt", "", alice)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Error("cached credentials should be rejected once password is changed: ", res.Status)
+	}
+	auth.password = "secret"
+	auth.stamp++
+	res, body = do(http.MethodGet, "docs/report.txt", "", map[string]string{"Authorization": "Bearer " + auth.token("alice")})
+	if res.StatusCode != http.StatusOK || body != "quarterly" {
+		t.Error("bearer token should be accepted: ", res.Status, body)
+	}
+
+	// collections
+	res, _ = do("MKCOL", "drafts", "", alice)
+	if res.StatusCode != http.StatusCreated {
+		t.Error("collection should be created: ", res.Status)
+	}
+	res, _ = do(http.MethodPut, "drafts/plan.txt", "first", alice)
+	if res.StatusCode != http.StatusCreated {
+		t.Error("file should be put: ", res.Status)
+	}
+	copyTo := map[string]string{"Authorization": alice["Authorization"], "Destination": "/dav/drafts/copy.txt"}
+	res, _ = do("COPY", "drafts/plan.txt", "", copyTo)
+	if res.StatusCode != http.StatusCreated {
+		t.Error("file should be copied: ", res.Status)
+	}
+	moveTo := map[string]string{"Authorization": alice["Authorization"], "Destination": "/dav/docs/plan.txt"}
+	res, _ = do("MOVE", "drafts/plan.txt", "", moveTo)
+	if res.StatusCode != http.StatusCreated {
+		t.Error("file should be moved: ", res.Status)
+	}
+	propfind := map[string]string{"Authorization": alice["Authorization"], "Depth": "1"}
+	res, body = do("PROPFIND", "", "", propfind)
+	if res.StatusCode != http.StatusMultiStatus ||
+		!strings.Contains(body, "/dav/docs/") || !strings.Contains(body, "/dav/drafts/") {
+		t.Error("wrong listing of home: ", res.Status, body)
+	}
+	content, _ := os.ReadFile(path.Join(root, "alice/docs/plan.txt"))
+	if string(content) != "first" {
+		t.Error("moved file should be in home: ", string(content))
+	}
+	res, _ = do(http.MethodGet, "../bob/", "", alice)
+	if res.StatusCode == http.StatusOK {
+		t.Error("request should be confined to home")
+	}
+
+	// grants
+	bob := basic("bob", "secret")
+	shared := strings.ReplaceAll(storage.SharedMount, " ", "%20") + "/alice/docs/"
+	res, body = do(http.MethodGet, shared+"report.txt", "", bob)
+	if res.StatusCode != http.StatusOK || body != "quarterly" {
+		t.Error("granted file should be read: ", res.Status, body)
+	}
+	res, _ = do(http.MethodPut, shared+"report.txt", "changed", bob)
+	if res.StatusCode < 400 {
+		t.Error("read-only grant should reject put: ", res.Status)
+	}
+	res, _ = do(http.MethodDelete, shared+"report.txt", "", bob)
+	if res.StatusCode < 400 {
+		t.Error("read-only grant should reject delete: ", res.Status)
+	}
+	res, _ = do("MKCOL", shared+"new", "", bob)
+	if res.StatusCode < 400 {
+		t.Error("read-only grant should reject mkcol: ", res.Status)
+	}
+	content, _ = os.ReadFile(path.Join(root, "alice/docs/report.txt"))
+	if string(content) != "quarterly" {
+		t.Error("granted file should not change: ", string(content))
+	}
+
+	// locks are held by client presenting token
+	lockBody := `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope>
+<D:locktype><D:write/></D:locktype><D:owner>alice</D:owner></D:lockinfo>`
+	res, _ = do("LOCK", "docs/report.txt", lockBody, map[string]string{
+		"Authorization": alice["Authorization"],
+		"Timeout":       "Second-60",
+	})
+	token := strings.Trim(res.Header.Get("Lock-Token"), "<>")
+	if res.StatusCode != http.StatusOK || token == "" {
+		t.Error("file should be locked: ", res.Status)
+		return
+	}
+	res, _ = do(http.MethodPut, "docs/report.txt", "clobbered", alice)
+	if res.StatusCode != http.StatusLocked {
+		t.Error("put without lock token should be rejected: ", res.Status)
+	}
+	other, _ := homes.Open(users["alice"])
+	if _, err = other.Create("docs/report.txt"); !errors.Is(err, localstorage.ErrLocked) {
+		t.Error("lock should be respected by other front ends: ", err)
+	}
+	res, _ = do(http.MethodPut, "docs/report.txt", "annual", map[string]string{
+		"Authorization": alice["Authorization"],
+		"If":            "(<" + token + ">)",
+	})
+	if res.StatusCode >= 300 {
+		t.Error("put with lock token should be accepted: ", res.Status)
+	}
+	content, _ = os.ReadFile(path.Join(root, "alice/docs/report.txt"))
+	if string(content) != "annual" {
+		t.Error("locked file should be written by holder: ", string(content))
+	}
+	res, _ = do("UNLOCK", "docs/report.txt", "", map[string]string{
+		"Authorization": alice["Authorization"],
+		"Lock-Token":    "<" + token + ">",
+	})
+	if res.StatusCode != http.StatusNoContent {
+		t.Error("file should be unlocked: ", res.Status)
+	}
+	if f, err := other.Create("docs/report.txt"); err != nil {
+		t.Error("unlocked file should be written by others: ", err)
+	} else {
+		_ = f.Close()
+	}
+
+	// disabled user loses access with token still valid
+	token = auth.token("alice")
+	u := users["alice"]
+	u.Enabled = false
+	users["alice"] = u
+	res, _ = do(http.MethodGet, "docs/report.txt", "", map[string]string{"Authorization": "Bearer " + token})
+	if res.StatusCode != http.StatusForbidden {
+		t.Error("disabled user should be rejected: ", res.Status)
+	}
+	res, _ = do(http.MethodGet, "docs/report.txt", "", alice)
+	if res.StatusCode != http.StatusForbidden {
+		t.Error("cached credentials of disabled user should be rejected: ", res.Status)
+	}
+}
@@ -0,0 +1,153 @@
+package webdav
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shabunin/cardia/localstorage"
+	"github.com/shabunin/cardia/storage"
+	"golang.org/x/net/webdav"
+)
+
+// stored is lock of home backing WebDAV one.
+type stored struct {
+	name    string
+	token   string
+	expires time.Time
+}
+
+// lockSystem keeps WebDAV locks of user in memory, since tokens are
+// checked by protocol itself, and backs them with locks of home, so
+// they are respected by other users and front ends. Names user could
+// not write are locked in memory only, writes to them are rejected
+// by file system anyway.
+type lockSystem struct {
+	mem webdav.LockSystem
+
+	mu     sync.Mutex
+	stored map[string]stored // by WebDAV token
+}
+
+func newLockSystem() *lockSystem {
+	return &lockSystem{
+		mem:    webdav.NewMemLS(),
+		stored: make(map[string]stored),
+	}
+}
+
+// held returns tokens of home locks backing WebDAV locks
+// submitted in If header.
+func (ls *lockSystem) held(ifHeader string) []string {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var r []string
+	for token, s := range ls.stored {
+		if strings.Contains(ifHeader, "<"+token+">") {
+			r = append(r, s.token)
+		}
+	}
+	return r
+}
+
+// requestLocks is lock system of user as seen by one request,
+// home locks are taken in file system opened for it, since
+// it is bound to lock tokens submitted with request.
+type requestLocks struct {
+	*lockSystem
+	fsys storage.LockFS // nil if home has no locks
+}
+
+func (rl requestLocks) Confirm(now time.Time, name0, name1 string,
+	conditions ...webdav.Condition) (func(), error) {
+
+	return rl.mem.Confirm(now, name0, name1, conditions...)
+}
+
+func (rl requestLocks) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	ls := rl.lockSystem
+	if details.Duration < 0 || details.Duration > localstorage.MaxLease {
+		details.Duration = localstorage.MaxLease
+	}
+	token, err := ls.mem.Create(now, details)
+	if err != nil {
+		return token, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if rl.fsys == nil {
+		return token, nil
+	}
+	for t, s := range ls.stored {
+		if now.After(s.expires) {
+			delete(ls.stored, t)
+		}
+	}
+	name := fsName(details.Root)
+	l, err := rl.fsys.Lock(name, localstorage.LockExclusive, details.Duration)
+	if errors.Is(err, localstorage.ErrLocked) {
+		_ = ls.mem.Unlock(now, token)
+		return "", webdav.ErrLocked
+	}
+	if err == nil {
+		ls.stored[token] = stored{name: name, token: l.Token, expires: l.Expires}
+	}
+	return token, nil
+}
+
+func (rl requestLocks) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	ls := rl.lockSystem
+	if duration < 0 || duration > localstorage.MaxLease {
+		duration = localstorage.MaxLease
+	}
+	details, err := ls.mem.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	s, ok := ls.stored[token]
+	if !ok || rl.fsys == nil {
+		return details, nil
+	}
+	l, err := rl.fsys.RenewLock(s.name, s.token, duration)
+	if errors.Is(err, localstorage.ErrLockNotHeld) {
+		// expired meanwhile and could be taken by others
+		delete(ls.stored, token)
+		_ = ls.mem.Unlock(now, token)
+		return webdav.LockDetails{}, webdav.ErrNoSuchLock
+	}
+	if err != nil {
+		return details, err
+	}
+	s.expires = l.Expires
+	ls.stored[token] = s
+	return details, nil
+}
+
+func (rl requestLocks) Unlock(now time.Time, token string) error {
+	ls := rl.lockSystem
+	err := ls.mem.Unlock(now, token)
+	if err != nil {
+		return err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	s, ok := ls.stored[token]
+	if !ok {
+		return nil
+	}
+	delete(ls.stored, token)
+	if rl.fsys == nil {
+		return nil
+	}
+	err = rl.fsys.Unlock(s.name, s.token)
+	if errors.Is(err, localstorage.ErrLockNotHeld) {
+		return nil
+	}
+	return err
+}